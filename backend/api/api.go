@@ -23,7 +23,8 @@ type UserInfo struct {
 }
 
 // GetUserInfo extracts the user info from the id token claim of the given request.
-// Any fields that cannot be extracted are left blank.
+// Any fields that cannot be extracted are left blank. If the username is present,
+// it is added to the logs of the current request.
 func GetUserInfo(event Request) *UserInfo {
 	var username string
 	var email string
@@ -37,6 +38,9 @@ func GetUserInfo(event Request) *UserInfo {
 		}
 	}
 
+	if username != "" {
+		log.SetField(log.UsernameField, username)
+	}
 	return &UserInfo{Username: username, Email: email}
 }
 
@@ -82,6 +86,7 @@ func Failure(err error) Response {
 	var message string
 	body, err := json.Marshal(map[string]interface{}{
 		"code":    500,
+		"reason":  errors.ReasonInternal,
 		"message": "Unknown error (unknown type): " + err.Error(),
 	})
 	if err != nil {
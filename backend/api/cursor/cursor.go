@@ -0,0 +1,193 @@
+// Package cursor encodes the pagination cursors returned to clients as startKeys.
+//
+// A cursor is a versioned, base64-encoded payload followed by an HMAC-SHA256 signature
+// of the payload, so a cursor cannot be modified or crafted by the client. Each cursor is
+// bound to a scope (such as the table and index it was read from) and cannot be reused
+// for a different scope. The scope does not include the key condition of a query, so a
+// cursor can be passed to another query on the same table and index. Cursors older than
+// MaxAge are rejected as stale.
+//
+// Composite cursors contain one cursor per source, for operations that merge the
+// results of several queries, such as the newsfeed.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+// version is the current cursor format. Cursors with a different version are rejected.
+const version = 1
+
+// MaxAge is the maximum age of a cursor before it is rejected as stale.
+var MaxAge = 7 * 24 * time.Hour
+
+// secret is the key used to sign cursors. It is read from the cursorSecret environment
+// variable. If that is not set, cursors cannot be encoded or decoded, except in tests
+// (where IS_TEST is true or the stage is not set), which use a fixed key.
+var secret = loadSecret()
+
+// now returns the current time. It is overridden in tests.
+var now = time.Now
+
+func loadSecret() []byte {
+	if s := os.Getenv("cursorSecret"); s != "" {
+		return []byte(s)
+	}
+	if os.Getenv("IS_TEST") != "true" && os.Getenv("stage") != "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte("chess-dojo-cursor-test"))
+	return sum[:]
+}
+
+// payload is the signed content of a cursor.
+type payload struct {
+	// The version of the cursor format.
+	Version int `json:"v"`
+
+	// The scope the cursor was issued for.
+	Scope string `json:"s"`
+
+	// The time the cursor was issued, in Unix seconds.
+	IssuedAt int64 `json:"t"`
+
+	// The DynamoDB exclusive start key, for single-source cursors.
+	Key map[string]*dynamodb.AttributeValue `json:"k,omitempty"`
+
+	// The cursor of each source, for composite cursors.
+	Parts map[string]string `json:"p,omitempty"`
+}
+
+// EncodeKey returns a cursor for the given DynamoDB LastEvaluatedKey. An empty string is
+// returned if the key is empty, indicating there are no more results.
+func EncodeKey(scope string, key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	return encode(payload{Scope: scope, Key: key})
+}
+
+// DecodeKey returns the DynamoDB exclusive start key contained in the given cursor. A
+// nil key is returned if the cursor is empty. A 400 error is returned if the cursor was
+// not issued for the given scope, has been modified or is stale.
+func DecodeKey(scope, cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	p, err := decode(scope, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Key) == 0 {
+		return nil, invalid("cursor has no key")
+	}
+	return p.Key, nil
+}
+
+// EncodeComposite returns a cursor containing the cursors of several sources, keyed by
+// source. Sources with an empty cursor are omitted. An empty string is returned if
+// every source is empty.
+func EncodeComposite(scope string, parts map[string]string) (string, error) {
+	nonEmpty := make(map[string]string, len(parts))
+	for source, cursor := range parts {
+		if cursor != "" {
+			nonEmpty[source] = cursor
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return "", nil
+	}
+	return encode(payload{Scope: scope, Parts: nonEmpty})
+}
+
+// DecodeComposite returns the cursor of each source contained in the given composite
+// cursor. A nil map is returned if the cursor is empty. The cursors of the sources
+// are not verified until they are decoded themselves.
+func DecodeComposite(scope, cursor string) (map[string]string, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	p, err := decode(scope, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Parts) == 0 {
+		return nil, invalid("cursor has no parts")
+	}
+	return p.Parts, nil
+}
+
+func encode(p payload) (string, error) {
+	if len(secret) == 0 {
+		return "", errMissingSecret()
+	}
+	p.Version = version
+	p.IssuedAt = now().Unix()
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", errors.Wrap(500, "Temporary server error", "Failed to marshal cursor", err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(b)
+	return body + "." + sign(body), nil
+}
+
+func decode(scope, cursor string) (*payload, error) {
+	if len(secret) == 0 {
+		return nil, errMissingSecret()
+	}
+	body, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, invalid("cursor has no signature")
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(body))) {
+		return nil, invalid("cursor signature does not match")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, invalid("cursor is not base64: " + err.Error())
+	}
+	var p payload
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, invalid("cursor payload is not JSON: " + err.Error())
+	}
+
+	if p.Version != version {
+		return nil, stale("cursor has unsupported version")
+	}
+	if p.Scope != scope {
+		return nil, invalid("cursor scope " + p.Scope + " does not match " + scope)
+	}
+	if now().Sub(time.Unix(p.IssuedAt, 0)) > MaxAge {
+		return nil, stale("cursor is older than MaxAge")
+	}
+	return &p, nil
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func errMissingSecret() error {
+	return errors.New(500, "Temporary server error", "cursorSecret is not set")
+}
+
+func invalid(privateMsg string) error {
+	return errors.New(400, "Invalid request: startKey is not valid", privateMsg, errors.WithReason(errors.ReasonInvalidCursor))
+}
+
+func stale(privateMsg string) error {
+	return errors.New(400, "Invalid request: startKey has expired, reload to see the latest results", privateMsg, errors.WithReason(errors.ReasonStaleCursor))
+}
@@ -0,0 +1,157 @@
+package cursor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+var testKey = map[string]*dynamodb.AttributeValue{
+	"username": {S: aws.String("test")},
+	"sortKey":  {N: aws.String("12")},
+}
+
+func TestKeyRoundTrip(t *testing.T) {
+	c, err := EncodeKey("users/", testKey)
+	if err != nil {
+		t.Fatalf("EncodeKey got err: %v", err)
+	}
+	if strings.Contains(c, "username") {
+		t.Errorf("EncodeKey got %q; want the key to be encoded", c)
+	}
+
+	got, err := DecodeKey("users/", c)
+	if err != nil {
+		t.Fatalf("DecodeKey got err: %v", err)
+	}
+	if diff := cmp.Diff(testKey, got); diff != "" {
+		t.Errorf("DecodeKey diff (-want +got):\n%s", diff)
+	}
+
+	if c, err := EncodeKey("users/", nil); c != "" || err != nil {
+		t.Errorf("EncodeKey(nil) got (%q, %v); want empty", c, err)
+	}
+	if key, err := DecodeKey("users/", ""); key != nil || err != nil {
+		t.Errorf("DecodeKey(\"\") got (%v, %v); want nil", key, err)
+	}
+}
+
+func TestDecodeRejected(t *testing.T) {
+	valid, err := EncodeKey("users/", testKey)
+	if err != nil {
+		t.Fatalf("EncodeKey got err: %v", err)
+	}
+	body, signature, _ := strings.Cut(valid, ".")
+	forged, _ := EncodeKey("users/", map[string]*dynamodb.AttributeValue{"username": {S: aws.String("other")}})
+	forgedBody, _, _ := strings.Cut(forged, ".")
+
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(-MaxAge - time.Hour) }
+	stale, err := EncodeKey("users/", testKey)
+	if err != nil {
+		t.Fatalf("EncodeKey got err: %v", err)
+	}
+	now = time.Now
+
+	tests := []struct {
+		name       string
+		scope      string
+		cursor     string
+		wantReason errors.Reason
+	}{
+		{name: "RawJSON", scope: "users/", cursor: `{"username":{"S":"test"}}`, wantReason: errors.ReasonInvalidCursor},
+		{name: "NoSignature", scope: "users/", cursor: body, wantReason: errors.ReasonInvalidCursor},
+		{name: "SwappedBody", scope: "users/", cursor: forgedBody + "." + signature, wantReason: errors.ReasonInvalidCursor},
+		{name: "WrongScope", scope: "games/OwnerIdx", cursor: valid, wantReason: errors.ReasonInvalidCursor},
+		{name: "Stale", scope: "users/", cursor: stale, wantReason: errors.ReasonStaleCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeKey(tc.scope, tc.cursor)
+			var e *errors.Error
+			if !errors.As(err, &e) || e.Code != 400 {
+				t.Fatalf("DecodeKey got err %v; want 400", err)
+			}
+			if e.Reason != tc.wantReason {
+				t.Errorf("DecodeKey got reason %s; want %s", e.Reason, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestComposite(t *testing.T) {
+	white, _ := EncodeKey("games/WhiteIndex", testKey)
+
+	c, err := EncodeComposite("ListGamesByPlayer", map[string]string{"white": white, "black": ""})
+	if err != nil {
+		t.Fatalf("EncodeComposite got err: %v", err)
+	}
+
+	parts, err := DecodeComposite("ListGamesByPlayer", c)
+	if err != nil {
+		t.Fatalf("DecodeComposite got err: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{"white": white}, parts); diff != "" {
+		t.Errorf("DecodeComposite diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := DecodeKey("games/WhiteIndex", c); err == nil {
+		t.Errorf("DecodeKey(composite cursor) got nil err; want 400")
+	}
+	if c, err := EncodeComposite("ListGamesByPlayer", map[string]string{"white": ""}); c != "" || err != nil {
+		t.Errorf("EncodeComposite(empty parts) got (%q, %v); want empty", c, err)
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	t.Setenv("cursorSecret", "")
+	t.Setenv("IS_TEST", "")
+	t.Setenv("stage", "")
+	if got := loadSecret(); len(got) == 0 {
+		t.Errorf("loadSecret() without a stage got empty secret; want the test secret")
+	}
+
+	t.Setenv("stage", "dev")
+	t.Setenv("IS_TEST", "true")
+	if got := loadSecret(); len(got) == 0 {
+		t.Errorf("loadSecret() with IS_TEST got empty secret; want the test secret")
+	}
+
+	t.Setenv("stage", "prod")
+	t.Setenv("IS_TEST", "")
+	if got := loadSecret(); got != nil {
+		t.Errorf("loadSecret() in prod without cursorSecret got %q; want nil", got)
+	}
+
+	t.Setenv("cursorSecret", "secret")
+	if got := string(loadSecret()); got != "secret" {
+		t.Errorf("loadSecret() got %q; want secret", got)
+	}
+}
+
+func TestMissingSecret(t *testing.T) {
+	valid, err := EncodeKey("users/", testKey)
+	if err != nil {
+		t.Fatalf("EncodeKey got err: %v", err)
+	}
+
+	defer func(s []byte) { secret = s }(secret)
+	secret = nil
+
+	var e *errors.Error
+	if _, err := EncodeKey("users/", testKey); !errors.As(err, &e) || e.Code != 500 {
+		t.Errorf("EncodeKey without secret got err %v; want 500", err)
+	}
+	if _, err := DecodeKey("users/", valid); !errors.As(err, &e) || e.Code != 500 {
+		t.Errorf("DecodeKey without secret got err %v; want 500", err)
+	}
+	if key, err := DecodeKey("users/", ""); key != nil || err != nil {
+		t.Errorf("DecodeKey(\"\") without secret got (%v, %v); want nil", key, err)
+	}
+}
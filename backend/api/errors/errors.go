@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"encoding/json"
 	stderrors "errors"
 	"fmt"
 	"runtime"
@@ -24,54 +25,80 @@ func Unwrap(err error) error {
 }
 
 type Error struct {
-	Code           int    `json:"code"`
-	PublicMessage  string `json:"message"`
-	PrivateMessage string `json:"-"`
-	Cause          error  `json:"-"`
-	Trace          string `json:"-"`
+	Code           int          `json:"code"`
+	Reason         Reason       `json:"reason,omitempty"`
+	PublicMessage  string       `json:"message"`
+	Details        []FieldError `json:"details,omitempty"`
+	PrivateMessage string       `json:"-"`
+	Cause          error        `json:"-"`
+	Trace          string       `json:"-"`
 }
 
 // New returns an error with the given status code, public message and private message.
-func New(code int, publicMsg, privateMsg string) error {
+// Options can be passed to attach a Reason or field-level details.
+func New(code int, publicMsg, privateMsg string, opts ...Option) error {
 
 	b := make([]byte, 2048)
 	n := runtime.Stack(b, false)
 	trace := string(b[:n])
-	trace = strings.ReplaceAll(trace, "\n", "\r")
 
-	return &Error{
+	e := &Error{
 		Code:           code,
 		PublicMessage:  publicMsg,
 		PrivateMessage: privateMsg,
 		Trace:          trace,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Wrap returns an error with the given status code, public message, private message and cause.
-// If the cause is nil, Wrap returns nil.
-func Wrap(code int, publicMsg, privateMsg string, cause error) error {
+// If the cause is nil, Wrap returns nil. Unless a Reason is passed as an option, the
+// Reason of the cause is kept if the cause has the same status code.
+func Wrap(code int, publicMsg, privateMsg string, cause error, opts ...Option) error {
 	if cause == nil {
 		return nil
 	}
 
 	var trace string
+	var reason Reason
 	var err *Error
 	if As(cause, &err) {
 		trace = err.Trace
+		if err.Code == code {
+			reason = err.Reason
+		}
 	} else {
 		b := make([]byte, 2048)
 		n := runtime.Stack(b, false)
 		trace = string(b[:n])
-		trace = strings.ReplaceAll(trace, "\n", "\r")
 	}
 
-	return &Error{
+	e := &Error{
 		Code:           code,
+		Reason:         reason,
 		PublicMessage:  publicMsg,
 		PrivateMessage: privateMsg,
 		Cause:          cause,
 		Trace:          trace,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// MarshalJSON encodes the error as sent to clients. Errors without a Reason are
+// encoded with the default Reason for their status code.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	type publicError Error
+	pe := publicError(*e)
+	if pe.Reason == "" {
+		pe.Reason = DefaultReason(pe.Code)
+	}
+	return json.Marshal(pe)
 }
 
 // Unwrap returns the cause of the given error.
@@ -82,57 +109,43 @@ func (e *Error) Unwrap() error {
 	return e.Cause
 }
 
-func toString(err error, depth int) string {
-	if err == nil {
-		return "nil"
+// Error returns a description of the error as a single line. The trace is not included;
+// use LogFields to get the error's structured data for logging.
+func (e *Error) Error() string {
+	if e == nil {
+		return ""
 	}
 
-	const tab = "    " // 4 spaces
-	var separator = "\r" + strings.Repeat(tab, depth)
-
 	var b strings.Builder
-	b.WriteString("{")
-	b.WriteString(separator)
-
-	var e *Error
-	if As(err, &e) {
-		b.WriteString(fmt.Sprintf("Code: %d,", e.Code))
-		b.WriteString(separator)
-
-		b.WriteString(fmt.Sprintf("Public Message: %s,", e.PublicMessage))
-		b.WriteString(separator)
-
-		b.WriteString(fmt.Sprintf("Private Message: %s,", e.PrivateMessage))
-		b.WriteString(separator)
-
-		b.WriteString(fmt.Sprintf("Cause: %s,", toString(e.Cause, depth+1)))
-		b.WriteString("\r")
-		b.WriteString(strings.Repeat(tab, depth-1))
-		b.WriteString("}")
-
-		if depth == 1 {
-			b.WriteString("\r\r")
-			b.WriteString(e.Trace)
-			b.WriteString("\n")
-		}
-	} else {
-		b.WriteString(fmt.Sprintf("Public Message: %s", err))
-		b.WriteString(separator)
-
-		b.WriteString(fmt.Sprintf("Cause: %s,", toString(Unwrap(err), depth+1)))
-		b.WriteString("\r")
-		b.WriteString(strings.Repeat(tab, depth-1))
-		b.WriteString("}")
+	b.WriteString(fmt.Sprintf("%d: %s", e.Code, e.PublicMessage))
+	if e.PrivateMessage != "" {
+		b.WriteString("; ")
+		b.WriteString(e.PrivateMessage)
+	}
+	if e.Cause != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Cause.Error())
 	}
-
 	return b.String()
 }
 
-// Error returns a description of the error as a string.
-func (e *Error) Error() string {
+// LogFields returns the fields added to a structured log line when the error is logged.
+func (e *Error) LogFields() map[string]any {
 	if e == nil {
-		return ""
+		return nil
 	}
 
-	return toString(e, 1)
+	fields := map[string]any{
+		"errorCode":     e.Code,
+		"errorReason":   ReasonOf(e),
+		"publicMessage": e.PublicMessage,
+		"trace":         e.Trace,
+	}
+	if e.PrivateMessage != "" {
+		fields["privateMessage"] = e.PrivateMessage
+	}
+	if e.Cause != nil {
+		fields["cause"] = e.Cause.Error()
+	}
+	return fields
 }
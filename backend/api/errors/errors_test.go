@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestErrorJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want map[string]interface{}
+	}{
+		{
+			name: "DefaultReason",
+			err:  New(404, "Invalid request: user not found", "private"),
+			want: map[string]interface{}{
+				"code":    float64(404),
+				"reason":  "NOT_FOUND",
+				"message": "Invalid request: user not found",
+			},
+		},
+		{
+			name: "WithReason",
+			err:  New(400, "Invalid request: event is full", "", WithReason(ReasonEventFull)),
+			want: map[string]interface{}{
+				"code":    float64(400),
+				"reason":  "EVENT_FULL",
+				"message": "Invalid request: event is full",
+			},
+		},
+		{
+			name: "Validation",
+			err: NewValidation(
+				FieldError{Field: "name", Reason: ReasonRequired, Message: "name cannot be empty"},
+				FieldError{Field: "cohorts[0]", Reason: ReasonInvalidType},
+			),
+			want: map[string]interface{}{
+				"code":    float64(400),
+				"reason":  "VALIDATION_FAILED",
+				"message": "Invalid request: name cannot be empty",
+				"details": []interface{}{
+					map[string]interface{}{"field": "name", "reason": "REQUIRED", "message": "name cannot be empty"},
+					map[string]interface{}{"field": "cohorts[0]", "reason": "INVALID_TYPE"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.err)
+			if err != nil {
+				t.Fatalf("Marshal got err: %v", err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("Unmarshal got err: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Marshal(%s) diff (-want +got):\n%s", tc.name, diff)
+			}
+		})
+	}
+}
+
+func TestWrapReason(t *testing.T) {
+	cause := New(400, "Invalid request: event is full", "", WithReason(ReasonEventFull))
+
+	if got := ReasonOf(Wrap(400, "Invalid request: booking failed", "", cause)); got != ReasonEventFull {
+		t.Errorf("Wrap with same code got reason %s; want %s", got, ReasonEventFull)
+	}
+	if got := ReasonOf(Wrap(500, "Temporary server error", "", cause)); got != ReasonInternal {
+		t.Errorf("Wrap with different code got reason %s; want %s", got, ReasonInternal)
+	}
+	if got := ReasonOf(Wrap(400, "Invalid request", "", cause, WithReason(ReasonConflict))); got != ReasonConflict {
+		t.Errorf("Wrap with reason option got reason %s; want %s", got, ReasonConflict)
+	}
+	if got := ReasonOf(stderrors.New("unknown")); got != ReasonInternal {
+		t.Errorf("ReasonOf(unknown error) got %s; want %s", got, ReasonInternal)
+	}
+	if got := ReasonOf(nil); got != "" {
+		t.Errorf("ReasonOf(nil) got %s; want empty", got)
+	}
+}
@@ -0,0 +1,128 @@
+package errors
+
+// Reason is a stable, machine-readable identifier of why a request failed. Clients
+// should match on the Reason rather than the public message, which may change.
+type Reason string
+
+// Generic reasons, used when an error does not have a more specific Reason.
+const (
+	ReasonInvalidRequest        Reason = "INVALID_REQUEST"
+	ReasonValidation            Reason = "VALIDATION_FAILED"
+	ReasonUnauthenticated       Reason = "UNAUTHENTICATED"
+	ReasonForbidden             Reason = "FORBIDDEN"
+	ReasonNotFound              Reason = "NOT_FOUND"
+	ReasonConflict              Reason = "CONFLICT"
+	ReasonIdempotencyKeyReused  Reason = "IDEMPOTENCY_KEY_REUSED"
+	ReasonIdempotencyInProgress Reason = "IDEMPOTENCY_IN_PROGRESS"
+	ReasonRateLimited           Reason = "RATE_LIMITED"
+	ReasonInvalidCursor         Reason = "INVALID_CURSOR"
+	ReasonStaleCursor           Reason = "STALE_CURSOR"
+	ReasonInternal              Reason = "INTERNAL"
+)
+
+// Reasons for field-level validation failures.
+const (
+	ReasonRequired    Reason = "REQUIRED"
+	ReasonInvalidType Reason = "INVALID_TYPE"
+	ReasonOutOfRange  Reason = "OUT_OF_RANGE"
+)
+
+// Reasons specific to a feature of the site.
+const (
+	ReasonPermissionDenied             Reason = "PERMISSION_DENIED"
+	ReasonRoleAlreadyAssigned          Reason = "ROLE_ALREADY_ASSIGNED"
+	ReasonRoleNotAssigned              Reason = "ROLE_NOT_ASSIGNED"
+	ReasonUserNotFound                 Reason = "USER_NOT_FOUND"
+	ReasonUserVersionConflict          Reason = "USER_VERSION_CONFLICT"
+	ReasonGameNotFound                 Reason = "GAME_NOT_FOUND"
+	ReasonGameReviewAlreadyRequested   Reason = "GAME_REVIEW_ALREADY_REQUESTED"
+	ReasonGameAlreadyReviewed          Reason = "GAME_ALREADY_REVIEWED"
+	ReasonEventNotFound                Reason = "EVENT_NOT_FOUND"
+	ReasonEventFull                    Reason = "EVENT_FULL"
+	ReasonEventOwnBooking              Reason = "EVENT_OWN_BOOKING"
+	ReasonClubNotFound                 Reason = "CLUB_NOT_FOUND"
+	ReasonClubFreeTierForbidden        Reason = "CLUB_FREE_TIER_FORBIDDEN"
+	ReasonClubJoinForbidden            Reason = "CLUB_JOIN_FORBIDDEN"
+	ReasonTournamentRegistrationClosed Reason = "TOURNAMENT_REGISTRATION_CLOSED"
+	ReasonExamAlreadyTaken             Reason = "EXAM_ALREADY_TAKEN"
+	ReasonRatingNotFound               Reason = "RATING_NOT_FOUND"
+	ReasonRatingProviderUnavailable    Reason = "RATING_PROVIDER_UNAVAILABLE"
+	ReasonSubscriptionRequired         Reason = "SUBSCRIPTION_REQUIRED"
+	ReasonAccountNotVerified           Reason = "ACCOUNT_NOT_VERIFIED"
+	ReasonAccountVerificationFailed    Reason = "ACCOUNT_VERIFICATION_FAILED"
+)
+
+// DefaultReason returns the generic Reason for the given HTTP status code.
+func DefaultReason(code int) Reason {
+	switch {
+	case code == 401:
+		return ReasonUnauthenticated
+	case code == 403:
+		return ReasonForbidden
+	case code == 404:
+		return ReasonNotFound
+	case code == 409:
+		return ReasonConflict
+	case code == 429:
+		return ReasonRateLimited
+	case code >= 400 && code < 500:
+		return ReasonInvalidRequest
+	}
+	return ReasonInternal
+}
+
+// FieldError describes why a single field of a request is invalid.
+type FieldError struct {
+	// The path of the invalid field in the request, such as `cohorts` or `owner.displayName`.
+	Field string `json:"field"`
+
+	// The reason the field is invalid.
+	Reason Reason `json:"reason"`
+
+	// A human-readable description of the problem.
+	Message string `json:"message,omitempty"`
+}
+
+// Option sets optional data on an Error created by New or Wrap.
+type Option func(*Error)
+
+// WithReason sets the Reason of the error.
+func WithReason(reason Reason) Option {
+	return func(e *Error) {
+		e.Reason = reason
+	}
+}
+
+// WithFieldErrors adds the given field-level details to the error.
+func WithFieldErrors(details ...FieldError) Option {
+	return func(e *Error) {
+		e.Details = append(e.Details, details...)
+	}
+}
+
+// NewValidation returns a 400 error with the ReasonValidation reason and the given
+// field-level details. The public message is taken from the first detail.
+func NewValidation(details ...FieldError) error {
+	message := "Invalid request"
+	if len(details) > 0 && details[0].Message != "" {
+		message = "Invalid request: " + details[0].Message
+	}
+	return New(400, message, "", WithReason(ReasonValidation), WithFieldErrors(details...))
+}
+
+// ReasonOf returns the Reason of the given error. If err is not an Error or does
+// not have a Reason, the default Reason for its status code is returned. An empty
+// Reason is returned if err is nil.
+func ReasonOf(err error) Reason {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if !As(err, &e) {
+		return ReasonInternal
+	}
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return DefaultReason(e.Code)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header containing the client's idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set to true on responses replayed from an earlier request.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key.
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotent returns middleware that makes retries of a request safe. If the request has an
+// Idempotency-Key header, the response is saved in the provided store, and later requests from
+// the same caller with the same key receive the saved response instead of running the handler
+// again. A duplicate request received while the first is still running is rejected with a 409,
+// and reusing a key for a different request is rejected with a 422. Responses the client is
+// expected to retry (409, 429 and 5xx) are not saved, so that the request can be retried with the
+// same key. Requests without the header are not affected.
+func Idempotent(store database.IdempotencyStore) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (Response, error) {
+			key := getHeader(event, IdempotencyKeyHeader)
+			if key == "" {
+				return next(ctx, event)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return Failure(errors.NewValidation(errors.FieldError{
+					Field:   IdempotencyKeyHeader,
+					Reason:  errors.ReasonOutOfRange,
+					Message: fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength),
+				})), nil
+			}
+
+			scope := GetUserInfo(event).Username
+			if scope == "" {
+				scope = "public"
+			}
+			record := &database.IdempotencyRecord{
+				Id:          scope + "#" + key,
+				Fingerprint: fingerprint(event),
+			}
+
+			existing, err := store.StartIdempotentRequest(record)
+			if err != nil {
+				return Failure(err), nil
+			}
+			if existing != nil {
+				return replay(existing, record), nil
+			}
+
+			resp, err := next(ctx, event)
+			if err != nil || isRetryable(resp.StatusCode) {
+				if derr := store.DeleteIdempotentRequest(record.Id); derr != nil {
+					log.Error("Failed to release idempotency key: ", derr)
+				}
+				return resp, err
+			}
+
+			record.StatusCode = resp.StatusCode
+			record.Headers = resp.Headers
+			record.Body = resp.Body
+			record.IsBase64Encoded = resp.IsBase64Encoded
+			if err := store.CompleteIdempotentRequest(record); err != nil {
+				log.Error("Failed to save idempotent response: ", err)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// isRetryable returns true if a response with the given status is transient and the
+// request should run again when retried, such as a version conflict or rate limit.
+func isRetryable(status int) bool {
+	return status == 409 || status == 429 || status >= 500
+}
+
+// replay returns the response for a request whose idempotency key matches the existing record.
+func replay(existing, record *database.IdempotencyRecord) Response {
+	if existing.Fingerprint != record.Fingerprint {
+		return Failure(errors.New(422, "Invalid request: Idempotency-Key was already used for a different request",
+			fmt.Sprintf("Idempotency record %q has fingerprint %s, got %s", record.Id, existing.Fingerprint, record.Fingerprint),
+			errors.WithReason(errors.ReasonIdempotencyKeyReused)))
+	}
+	if existing.Status != database.IdempotencyStatusCompleted {
+		return Failure(errors.New(409, "Invalid request: a request with this Idempotency-Key is already in progress",
+			fmt.Sprintf("Idempotency record %q is %s", record.Id, existing.Status),
+			errors.WithReason(errors.ReasonIdempotencyInProgress)))
+	}
+
+	headers := make(map[string]string, len(existing.Headers)+1)
+	for k, v := range existing.Headers {
+		headers[k] = v
+	}
+	headers[IdempotentReplayedHeader] = "true"
+	log.Infof("Replaying response to idempotency key %q", record.Id)
+	return Response{
+		StatusCode:      existing.StatusCode,
+		Headers:         headers,
+		Body:            existing.Body,
+		IsBase64Encoded: existing.IsBase64Encoded,
+	}
+}
+
+// fingerprint returns a hash of the request's method, path, query string and body.
+func fingerprint(event Request) string {
+	h := sha256.New()
+	for _, s := range []string{event.RequestContext.HTTP.Method, event.RawPath, event.RawQueryString, event.Body} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getHeader returns the value of the header with the provided name, ignoring case.
+func getHeader(event Request, name string) string {
+	if v, ok := event.Headers[name]; ok {
+		return v
+	}
+	for k, v := range event.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
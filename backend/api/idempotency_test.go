@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+func idempotentRequest(key, body string) Request {
+	event := authorizedRequest("player")
+	event.RequestContext.HTTP.Method = "PUT"
+	event.RawPath = "/event/1/book"
+	event.Headers = map[string]string{"idempotency-key": key}
+	event.Body = body
+	return event
+}
+
+func responseReason(t *testing.T, resp Response) errors.Reason {
+	var body struct {
+		Reason errors.Reason `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response body %q: %v", resp.Body, err)
+	}
+	return body.Reason
+}
+
+func TestIdempotent(t *testing.T) {
+	store := database.NewInMemory()
+	calls := 0
+	status := 200
+	handler := Chain(func(ctx context.Context, event Request) (Response, error) {
+		calls++
+		resp := Success(map[string]int{"call": calls})
+		resp.StatusCode = status
+		return resp, nil
+	}, Idempotent(store))
+
+	call := func(event Request) Response {
+		resp, err := handler(context.Background(), event)
+		if err != nil {
+			t.Fatalf("handler got err: %v", err)
+		}
+		return resp
+	}
+
+	first := call(idempotentRequest("key-1", `{"a":1}`))
+	second := call(idempotentRequest("key-1", `{"a":1}`))
+	if calls != 1 {
+		t.Errorf("Retried request ran the handler %d times; want 1", calls)
+	}
+	if second.Body != first.Body || second.StatusCode != first.StatusCode {
+		t.Errorf("Retried request got %d %s; want %d %s", second.StatusCode, second.Body, first.StatusCode, first.Body)
+	}
+	if second.Headers[IdempotentReplayedHeader] != "true" {
+		t.Errorf("Retried request got headers %v; want %s", second.Headers, IdempotentReplayedHeader)
+	}
+
+	resp := call(idempotentRequest("key-1", `{"a":2}`))
+	if resp.StatusCode != 422 || responseReason(t, resp) != errors.ReasonIdempotencyKeyReused {
+		t.Errorf("Reused key got %d %s; want 422 %s", resp.StatusCode, resp.Body, errors.ReasonIdempotencyKeyReused)
+	}
+
+	call(Request{Body: `{"a":1}`})
+	call(Request{Body: `{"a":1}`})
+	if calls != 3 {
+		t.Errorf("Requests without a key ran the handler %d times; want 3", calls)
+	}
+
+	for _, retryable := range []int{409, 429, 500} {
+		key := fmt.Sprintf("retry-%d", retryable)
+		status = retryable
+		call(idempotentRequest(key, `{}`))
+		status = 200
+		before := calls
+		if resp := call(idempotentRequest(key, `{}`)); resp.StatusCode != 200 || calls != before+1 {
+			t.Errorf("Retry after a %d got status %d after %d calls; want 200 after %d calls", retryable, resp.StatusCode, calls, before+1)
+		}
+	}
+
+	status = 400
+	call(idempotentRequest("key-2", `{}`))
+	status = 200
+	before := calls
+	if resp := call(idempotentRequest("key-2", `{}`)); resp.StatusCode != 400 || calls != before {
+		t.Errorf("Retry after a 400 got status %d after %d calls; want the saved 400 after %d calls", resp.StatusCode, calls, before)
+	}
+
+	event := idempotentRequest("key-3", `{}`)
+	if _, err := store.StartIdempotentRequest(&database.IdempotencyRecord{Id: "player#key-3", Fingerprint: fingerprint(event)}); err != nil {
+		t.Fatalf("StartIdempotentRequest got err: %v", err)
+	}
+	resp = call(event)
+	if resp.StatusCode != 409 || responseReason(t, resp) != errors.ReasonIdempotencyInProgress {
+		t.Errorf("Concurrent duplicate got %d %s; want 409 %s", resp.StatusCode, resp.Body, errors.ReasonIdempotencyInProgress)
+	}
+}
@@ -1,10 +1,16 @@
+// Package log writes structured JSON logs. Each log line is a single JSON object
+// containing the level, the message and the fields of the current request (such as
+// the request id, username and handler name), along with any fields added by the caller.
 package log
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	stdlog "log"
+	"io"
 	"os"
-	"strings"
+	"sync"
+	"time"
 )
 
 var isTest = os.Getenv("IS_TEST") == "true"
@@ -30,112 +36,262 @@ const (
 	DebugLevel
 )
 
-type logger struct {
-	level     logLevel
-	requestId string
+// Names of the fields set by this package.
+const (
+	LevelField     = "level"
+	MessageField   = "message"
+	TimestampField = "timestamp"
+	RequestIdField = "requestId"
+	HandlerField   = "handler"
+	UsernameField  = "username"
+	LatencyField   = "latencyMs"
+	ErrorCodeField = "errorCode"
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case ErrorLevel:
+		return "ERROR"
+	case WarnLevel:
+		return "WARN"
+	case InfoLevel:
+		return "INFO"
+	case DebugLevel:
+		return "DEBUG"
+	}
+	return "TEST"
+}
+
+// Fields contains structured data attached to a log line.
+type Fields map[string]any
+
+// Fielder is implemented by values that contribute fields when logged, such as
+// errors.Error. Its fields are added to the log line when it is passed to any of
+// the logging functions.
+type Fielder interface {
+	LogFields() map[string]any
 }
 
-var defaultLogger = logger{
-	level:     DebugLevel,
-	requestId: "",
+// Logger writes log lines with a fixed set of fields, in addition to the fields
+// of the current request.
+type Logger struct {
+	fields Fields
 }
 
+// requestState contains the level and request-scoped fields shared by all loggers.
+type requestState struct {
+	mu     sync.Mutex
+	level  logLevel
+	fields Fields
+	out    io.Writer
+}
+
+var defaultState = requestState{
+	level:  DebugLevel,
+	fields: Fields{},
+	out:    os.Stderr,
+}
+
+// handlerName is the name of the Lambda function writing the logs.
+var handlerName = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+
 func init() {
 	if isTest {
-		defaultLogger.level = TestLevel
+		defaultState.level = TestLevel
+	}
+	if handlerName != "" {
+		defaultState.fields[HandlerField] = handlerName
 	}
 }
 
 // SetLevel sets the current logging level.
 func SetLevel(level logLevel) {
-	defaultLogger.level = level
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	defaultState.level = level
+}
+
+// SetOutput sets the destination of future logs.
+func SetOutput(w io.Writer) {
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	defaultState.out = w
 }
 
-// SetRequestId sets the request id associated with future logs.
+// SetRequestId starts a new request with the given id. Fields set by a previous
+// request are cleared.
 func SetRequestId(requestId string) {
-	defaultLogger.requestId = requestId
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	defaultState.fields = Fields{RequestIdField: requestId}
+	if handlerName != "" {
+		defaultState.fields[HandlerField] = handlerName
+	}
+}
+
+// SetField adds the given field to all future logs of the current request.
+func SetField(key string, value any) {
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+	defaultState.fields[key] = value
 }
 
-// levelPrint prints the provided arguments prefixed by the provided level name
-// and the current request id.
-func levelPrint(level string, v ...any) {
-	result := fmt.Sprint(v...)
-	if !isTest {
-		result = strings.ReplaceAll(result, "\n", "\r")
+// With returns a Logger which adds the given fields to each log line.
+func With(fields Fields) *Logger {
+	return (&Logger{}).With(fields)
+}
+
+// With returns a copy of the Logger which also adds the given fields to each log line.
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
 	}
-	stdlog.Printf("[%s] - [%s]: %s", defaultLogger.requestId, level, result)
+	return &Logger{fields: merged}
 }
 
-// levelPrint prints the provided format string prefixed by the provided level name
-// and the current request id.
-func levelPrintf(level, format string, v ...any) {
-	result := fmt.Sprintf(format, v...)
-	if !isTest {
-		result = strings.ReplaceAll(result, "\n", "\r")
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the given Logger.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the Logger carried by ctx. If ctx has no Logger, a Logger
+// with no additional fields is returned.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok && logger != nil {
+		return logger
 	}
-	stdlog.Printf("[%s] - [%s]: %s", defaultLogger.requestId, level, result)
+	return &Logger{}
 }
 
+// write encodes a log line with the given level and message as JSON.
+func (l *Logger) write(level logLevel, message string, values []any) {
+	defaultState.mu.Lock()
+	defer defaultState.mu.Unlock()
+
+	if defaultState.level < level {
+		return
+	}
+
+	line := make(map[string]any, len(defaultState.fields)+len(l.fields)+3)
+	for k, v := range defaultState.fields {
+		line[k] = v
+	}
+	for k, v := range l.fields {
+		line[k] = v
+	}
+	for _, v := range values {
+		if f, ok := v.(Fielder); ok {
+			for k, fv := range f.LogFields() {
+				line[k] = fv
+			}
+		}
+	}
+	line[TimestampField] = time.Now().UTC().Format(time.RFC3339Nano)
+	line[LevelField] = level.String()
+	line[MessageField] = message
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		// A field could not be encoded, so fall back to the message and a description of the fields.
+		b, _ = json.Marshal(map[string]any{
+			TimestampField: line[TimestampField],
+			LevelField:     line[LevelField],
+			MessageField:   message,
+			"fields":       fmt.Sprintf("%+v", line),
+			"encodeError":  err.Error(),
+		})
+	}
+	defaultState.out.Write(append(b, '\n'))
+}
+
+func (l *Logger) print(level logLevel, v ...any) {
+	l.write(level, fmt.Sprint(v...), v)
+}
+
+func (l *Logger) printf(level logLevel, format string, v ...any) {
+	l.write(level, fmt.Sprintf(format, v...), v)
+}
+
+// Error logs the provided arguments only if the current log level is >= ErrorLevel.
+func (l *Logger) Error(v ...any) { l.print(ErrorLevel, v...) }
+
+// Errorf logs the provided format string and arguments only if the current log
+// level is >= ErrorLevel.
+func (l *Logger) Errorf(format string, v ...any) { l.printf(ErrorLevel, format, v...) }
+
+// Warn logs the provided arguments only if the current log level is >= WarnLevel.
+func (l *Logger) Warn(v ...any) { l.print(WarnLevel, v...) }
+
+// Warnf logs the provided format string and arguments only if the current log
+// level is >= WarnLevel.
+func (l *Logger) Warnf(format string, v ...any) { l.printf(WarnLevel, format, v...) }
+
+// Info logs the provided arguments only if the current log level is >= InfoLevel.
+func (l *Logger) Info(v ...any) { l.print(InfoLevel, v...) }
+
+// Infof logs the provided format string and arguments only if the current log
+// level is >= InfoLevel.
+func (l *Logger) Infof(format string, v ...any) { l.printf(InfoLevel, format, v...) }
+
+// Debug logs the provided arguments only if the current log level is >= DebugLevel.
+func (l *Logger) Debug(v ...any) { l.print(DebugLevel, v...) }
+
+// Debugf logs the provided format string and arguments only if the current log
+// level is >= DebugLevel.
+func (l *Logger) Debugf(format string, v ...any) { l.printf(DebugLevel, format, v...) }
+
+var defaultLogger = &Logger{}
+
 // Error prints the provided arguments only if the current log level is
 // >= ErrorLevel.
 func Error(v ...any) {
-	if defaultLogger.level >= ErrorLevel {
-		levelPrint("ERROR", v...)
-	}
+	defaultLogger.print(ErrorLevel, v...)
 }
 
 // Errorf prints the provided format string and arguments only if the
 // current log level is >= ErrorLevel.
 func Errorf(format string, v ...any) {
-	if defaultLogger.level >= ErrorLevel {
-		levelPrintf("ERROR", format, v...)
-	}
+	defaultLogger.printf(ErrorLevel, format, v...)
 }
 
 // Warn prints the provided arguments only if the current log level is
 // >= WarnLevel.
 func Warn(v ...any) {
-	if defaultLogger.level >= WarnLevel {
-		levelPrint("WARN", v...)
-	}
+	defaultLogger.print(WarnLevel, v...)
 }
 
 // Warnf prints the provided format string and arguments only if the
 // current log level is >= WarnLevel.
 func Warnf(format string, v ...any) {
-	if defaultLogger.level >= WarnLevel {
-		levelPrintf("WARN", format, v...)
-	}
+	defaultLogger.printf(WarnLevel, format, v...)
 }
 
 // Info prints the provided arguments only if the current log level is
 // >= InfoLevel.
 func Info(v ...any) {
-	if defaultLogger.level >= InfoLevel {
-		levelPrint("INFO", v...)
-	}
+	defaultLogger.print(InfoLevel, v...)
 }
 
 // Infof prints the provided format string and arguments only if the
 // current log level is >= InfoLevel.
 func Infof(format string, v ...any) {
-	if defaultLogger.level >= InfoLevel {
-		levelPrintf("INFO", format, v...)
-	}
+	defaultLogger.printf(InfoLevel, format, v...)
 }
 
 // Debug prints the provided arguments only if the current log level is
 // >= DebugLevel.
 func Debug(v ...any) {
-	if defaultLogger.level >= DebugLevel {
-		levelPrint("DEBUG", v...)
-	}
+	defaultLogger.print(DebugLevel, v...)
 }
 
 // Debugf prints the provided format string and arguments only if the
 // current log level is >= DebugLevel.
 func Debugf(format string, v ...any) {
-	if defaultLogger.level >= DebugLevel {
-		levelPrintf("DEBUG", format, v...)
-	}
+	defaultLogger.printf(DebugLevel, format, v...)
 }
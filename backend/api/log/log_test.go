@@ -0,0 +1,114 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testError struct{}
+
+func (testError) Error() string { return "test error" }
+
+func (testError) LogFields() map[string]any {
+	return map[string]any{"errorCode": 404, "privateMessage": "private"}
+}
+
+func captureLogs(t *testing.T, level logLevel) *bytes.Buffer {
+	var buf bytes.Buffer
+	previousLevel := defaultState.level
+	SetOutput(&buf)
+	SetLevel(level)
+	t.Cleanup(func() {
+		SetOutput(os.Stderr)
+		SetLevel(previousLevel)
+		SetRequestId("")
+	})
+	return &buf
+}
+
+func parseLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if l == "" {
+			continue
+		}
+		var line map[string]any
+		if err := json.Unmarshal([]byte(l), &line); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", l, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStructuredFields(t *testing.T) {
+	buf := captureLogs(t, InfoLevel)
+
+	SetRequestId("request-1")
+	SetField(UsernameField, "user-1")
+	logger := With(Fields{"routeKey": "GET /user"})
+	ctx := NewContext(context.Background(), logger)
+
+	FromContext(ctx).With(Fields{LatencyField: 12}).Infof("Request %s", "completed")
+	Errorf("Failed: %v", testError{})
+	Debug("Not logged")
+
+	lines := parseLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines; want 2: %s", len(lines), buf)
+	}
+
+	want := map[string]any{
+		LevelField:     "INFO",
+		MessageField:   "Request completed",
+		RequestIdField: "request-1",
+		UsernameField:  "user-1",
+		"routeKey":     "GET /user",
+		LatencyField:   float64(12),
+	}
+	for k, v := range want {
+		if lines[0][k] != v {
+			t.Errorf("line 0 got %s = %v; want %v", k, lines[0][k], v)
+		}
+	}
+
+	want = map[string]any{
+		LevelField:       "ERROR",
+		MessageField:     "Failed: test error",
+		RequestIdField:   "request-1",
+		ErrorCodeField:   float64(404),
+		"privateMessage": "private",
+	}
+	for k, v := range want {
+		if lines[1][k] != v {
+			t.Errorf("line 1 got %s = %v; want %v", k, lines[1][k], v)
+		}
+	}
+	if _, ok := lines[1]["routeKey"]; ok {
+		t.Errorf("line 1 got routeKey from an unrelated logger")
+	}
+}
+
+func TestSetRequestIdClearsFields(t *testing.T) {
+	buf := captureLogs(t, InfoLevel)
+
+	SetRequestId("request-1")
+	SetField(UsernameField, "user-1")
+	SetRequestId("request-2")
+	Info("Second request")
+
+	lines := parseLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines; want 1", len(lines))
+	}
+	if lines[0][RequestIdField] != "request-2" {
+		t.Errorf("got requestId %v; want request-2", lines[0][RequestIdField])
+	}
+	if _, ok := lines[0][UsernameField]; ok {
+		t.Errorf("got username from previous request")
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// Handler is the signature of a Lambda handler invoked by API Gateway.
+type Handler func(ctx context.Context, event Request) (Response, error)
+
+// Middleware wraps a Handler with additional behavior, such as authentication or logging.
+type Middleware func(next Handler) Handler
+
+// Chain returns the handler wrapped by the provided middleware. The first middleware
+// is the outermost, so it runs first on the request and last on the response.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+type contextKey int
+
+const (
+	userInfoContextKey contextKey = iota
+	userContextKey
+)
+
+// UserInfoFromContext returns the UserInfo stored in the context by Authenticate or
+// RequireUser. Nil is returned if neither middleware ran on the request.
+func UserInfoFromContext(ctx context.Context) *UserInfo {
+	info, _ := ctx.Value(userInfoContextKey).(*UserInfo)
+	return info
+}
+
+// UserFromContext returns the User loaded by RequireUser. Nil is returned if
+// RequireUser did not run on the request.
+func UserFromContext(ctx context.Context) *database.User {
+	user, _ := ctx.Value(userContextKey).(*database.User)
+	return user
+}
+
+// Recover returns middleware that converts a panic in the handler into a 500 response.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (resp Response, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("Handler panicked: %v\n%s", r, debug.Stack())
+					resp = Failure(errors.New(500, "Temporary server error", fmt.Sprintf("panic: %v", r)))
+					err = nil
+				}
+			}()
+			return next(ctx, event)
+		}
+	}
+}
+
+// LogRequest returns middleware that starts a new request on the logger, logs the
+// request with its secrets redacted and logs the response status and latency.
+// A Logger carrying the request's route is stored in the context.
+func LogRequest() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (Response, error) {
+			start := time.Now()
+			log.SetRequestId(event.RequestContext.RequestID)
+			GetUserInfo(event) // Adds the username to the request's log fields
+
+			logger := log.With(log.Fields{"routeKey": event.RouteKey})
+			redacted := RedactRequest(event)
+			logger.With(log.Fields{
+				"pathParameters":        redacted.PathParameters,
+				"queryStringParameters": redacted.QueryStringParameters,
+				"headers":               redacted.Headers,
+				"body":                  redacted.Body,
+			}).Info("Request received")
+
+			resp, err := next(log.NewContext(ctx, logger), event)
+
+			fields := log.Fields{
+				"statusCode":     resp.StatusCode,
+				log.LatencyField: time.Since(start).Milliseconds(),
+			}
+			if resp.StatusCode >= 400 {
+				fields[log.ErrorCodeField] = resp.StatusCode
+			}
+			if err != nil {
+				fields["handlerError"] = err.Error()
+			}
+			logger.With(fields).Info("Request completed")
+			return resp, err
+		}
+	}
+}
+
+// Authenticate returns middleware that requires the request to contain a username
+// in its Cognito claims. The UserInfo is stored in the context.
+func Authenticate() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (Response, error) {
+			ctx, err := authenticate(ctx, event)
+			if err != nil {
+				return Failure(err), nil
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+func authenticate(ctx context.Context, event Request) (context.Context, error) {
+	if info := UserInfoFromContext(ctx); info != nil {
+		return ctx, nil
+	}
+	info := GetUserInfo(event)
+	if info.Username == "" {
+		return ctx, errors.New(400, "Invalid request: username is required", "", errors.WithReason(errors.ReasonUnauthenticated))
+	}
+	return context.WithValue(ctx, userInfoContextKey, info), nil
+}
+
+// RequireUser returns middleware that authenticates the request and loads the caller's
+// User from the provided repository. The User is stored in the context.
+func RequireUser(repository database.UserGetter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (Response, error) {
+			ctx, err := authenticate(ctx, event)
+			if err != nil {
+				return Failure(err), nil
+			}
+			user, err := repository.GetUser(UserInfoFromContext(ctx).Username)
+			if err != nil {
+				return Failure(err), nil
+			}
+			return next(context.WithValue(ctx, userContextKey, user), event)
+		}
+	}
+}
+
+// Authorize returns a 403 error unless the user has the provided permission on the provided
+// resource. Resource should be the scope of the resource being accessed, such as the value of
+// database.ClubScope, or empty if the permission is not tied to a resource.
+func Authorize(user *database.User, permission database.Permission, resource string) error {
+	if user.HasPermission(permission, resource) {
+		return nil
+	}
+
+	username := ""
+	if user != nil {
+		username = user.Username
+	}
+	return errors.New(403, "Invalid request: you do not have permission to perform this action",
+		fmt.Sprintf("User %q lacks permission %q on resource %q", username, permission, resource),
+		errors.WithReason(errors.ReasonPermissionDenied))
+}
+
+// RequirePermission returns middleware that requires the caller to have the provided
+// permission, independent of any resource. It must run after RequireUser.
+func RequirePermission(permission database.Permission) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Request) (Response, error) {
+			user := UserFromContext(ctx)
+			if user == nil {
+				return Failure(errors.New(500, "Temporary server error", "RequirePermission used without RequireUser")), nil
+			}
+			if err := Authorize(user, permission, ""); err != nil {
+				return Failure(err), nil
+			}
+			return next(ctx, event)
+		}
+	}
+}
+
+// redacted replaces secret values in logged requests.
+const redacted = "[REDACTED]"
+
+// secretHeaders contains the lowercase names of headers whose values are never logged.
+var secretHeaders = map[string]bool{
+	"authorization":    true,
+	"cookie":           true,
+	"x-api-key":        true,
+	"stripe-signature": true,
+}
+
+// secretFieldSubstrings contains the lowercase substrings which mark a JSON body field as secret.
+var secretFieldSubstrings = []string{"password", "token", "secret", "apikey", "authorization"}
+
+// RedactRequest returns a copy of the request with its authorization headers, cookies
+// and secret body fields replaced so that it can be logged safely.
+func RedactRequest(event Request) Request {
+	if len(event.Headers) > 0 {
+		headers := make(map[string]string, len(event.Headers))
+		for k, v := range event.Headers {
+			if secretHeaders[strings.ToLower(k)] {
+				v = redacted
+			}
+			headers[k] = v
+		}
+		event.Headers = headers
+	}
+
+	if len(event.Cookies) > 0 {
+		event.Cookies = []string{redacted}
+	}
+
+	if event.Body != "" && !event.IsBase64Encoded {
+		var body interface{}
+		if err := json.Unmarshal([]byte(event.Body), &body); err == nil {
+			if b, err := json.Marshal(redactValue(body)); err == nil {
+				event.Body = string(b)
+			}
+		}
+	}
+	return event
+}
+
+// redactValue replaces the values of secret fields in the provided JSON value.
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if isSecretField(k) {
+				val[k] = redacted
+			} else {
+				val[k] = redactValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = redactValue(child)
+		}
+	}
+	return v
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range secretFieldSubstrings {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+type fakeUserGetter map[string]*database.User
+
+func (f fakeUserGetter) GetUser(username string) (*database.User, error) {
+	if user, ok := f[username]; ok {
+		return user, nil
+	}
+	return nil, errors.New(404, "Invalid request: user not found", "")
+}
+
+func authorizedRequest(username string) Request {
+	return Request{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RequestID: "test",
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+					Claims: map[string]string{"cognito:username": username},
+				},
+			},
+		},
+	}
+}
+
+func TestChainPermissions(t *testing.T) {
+	repository := fakeUserGetter{
+		"admin":      {Username: "admin", IsAdmin: true},
+		"tournament": {Username: "tournament", IsTournamentAdmin: true},
+		"revoked":    {Username: "revoked", IsTournamentAdmin: true, Roles: []database.RoleAssignment{}},
+		"assigned":   {Username: "assigned", Roles: []database.RoleAssignment{{Role: database.RoleTournamentAdmin}}},
+		"coach":      {Username: "coach", Roles: []database.RoleAssignment{{Role: database.RoleCoach}}},
+		"player":     {Username: "player"},
+	}
+
+	var gotUser *database.User
+	handler := Chain(func(ctx context.Context, event Request) (Response, error) {
+		gotUser = UserFromContext(ctx)
+		return Success(nil), nil
+	}, Recover(), LogRequest(), RequireUser(repository), RequirePermission(database.PermissionTournamentPairingsWrite))
+
+	tests := []struct {
+		name     string
+		request  Request
+		wantCode int
+	}{
+		{name: "Unauthenticated", request: Request{}, wantCode: 400},
+		{name: "MissingUser", request: authorizedRequest("missing"), wantCode: 404},
+		{name: "NoRole", request: authorizedRequest("player"), wantCode: 403},
+		{name: "OtherRole", request: authorizedRequest("coach"), wantCode: 403},
+		{name: "LegacyTournamentAdmin", request: authorizedRequest("tournament"), wantCode: 200},
+		{name: "RevokedLegacyRole", request: authorizedRequest("revoked"), wantCode: 403},
+		{name: "AssignedTournamentAdmin", request: authorizedRequest("assigned"), wantCode: 200},
+		{name: "Admin", request: authorizedRequest("admin"), wantCode: 200},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotUser = nil
+			resp, err := handler(context.Background(), tc.request)
+			if err != nil {
+				t.Fatalf("handler got err: %v", err)
+			}
+			if resp.StatusCode != tc.wantCode {
+				t.Fatalf("handler got status %d (%s); want %d", resp.StatusCode, resp.Body, tc.wantCode)
+			}
+			if tc.wantCode == 200 && gotUser == nil {
+				t.Errorf("handler got nil user from context")
+			}
+		})
+	}
+}
+
+func TestAuthorizeScoped(t *testing.T) {
+	user := &database.User{
+		Username: "owner",
+		Roles: []database.RoleAssignment{
+			{Role: database.RoleClubAdmin, Scope: database.ClubScope("club1")},
+			{Role: database.RoleGameReviewer, Scope: database.CohortScope("1500-1600")},
+		},
+	}
+
+	tests := []struct {
+		permission database.Permission
+		resource   string
+		want       bool
+	}{
+		{permission: database.PermissionClubWrite, resource: database.ClubScope("club1"), want: true},
+		{permission: database.PermissionClubJoinRequestsWrite, resource: database.ClubScope("club1"), want: true},
+		{permission: database.PermissionClubWrite, resource: database.ClubScope("club2"), want: false},
+		{permission: database.PermissionClubWrite, resource: "", want: false},
+		{permission: database.PermissionGameReviewComplete, resource: database.CohortScope("1500-1600"), want: true},
+		{permission: database.PermissionGameReviewComplete, resource: database.CohortScope("1600-1700"), want: false},
+		{permission: database.PermissionRolesWrite, resource: database.ClubScope("club1"), want: false},
+	}
+
+	for _, tc := range tests {
+		err := Authorize(user, tc.permission, tc.resource)
+		if got := err == nil; got != tc.want {
+			t.Errorf("Authorize(%s, %q) got %v; want authorized = %v", tc.permission, tc.resource, err, tc.want)
+		}
+		if err != nil && errors.ReasonOf(err) != errors.ReasonPermissionDenied {
+			t.Errorf("Authorize(%s, %q) got reason %s; want %s", tc.permission, tc.resource, errors.ReasonOf(err), errors.ReasonPermissionDenied)
+		}
+	}
+
+	if err := Authorize(nil, database.PermissionBetaAccess, ""); err == nil {
+		t.Errorf("Authorize(nil) got nil error; want 403")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := Chain(func(ctx context.Context, event Request) (Response, error) {
+		panic("test panic")
+	}, Recover())
+
+	resp, err := handler(context.Background(), Request{})
+	if err != nil {
+		t.Fatalf("handler got err: %v", err)
+	}
+	if resp.StatusCode != 500 {
+		t.Errorf("handler got status %d; want 500", resp.StatusCode)
+	}
+}
+
+func TestRedactRequest(t *testing.T) {
+	event := Request{
+		Headers: map[string]string{
+			"Authorization": "Bearer secret-jwt",
+			"content-type":  "application/json",
+		},
+		Cookies: []string{"session=secret-cookie"},
+		Body:    `{"name":"test","password":"secret-password","nested":{"refreshToken":"secret-refresh"}}`,
+	}
+
+	redactedEvent := RedactRequest(event)
+
+	if event.Headers["Authorization"] != "Bearer secret-jwt" {
+		t.Errorf("RedactRequest modified the original headers")
+	}
+	if got := redactedEvent.Headers["content-type"]; got != "application/json" {
+		t.Errorf("RedactRequest got content-type %q; want application/json", got)
+	}
+	for _, s := range []string{"secret-jwt", "secret-cookie", "secret-password", "secret-refresh"} {
+		for _, field := range []string{redactedEvent.Headers["Authorization"], strings.Join(redactedEvent.Cookies, ","), redactedEvent.Body} {
+			if strings.Contains(field, s) {
+				t.Errorf("RedactRequest did not redact %q", s)
+			}
+		}
+	}
+	if !strings.Contains(redactedEvent.Body, `"name":"test"`) {
+		t.Errorf("RedactRequest got body %s; want name to be kept", redactedEvent.Body)
+	}
+}
@@ -0,0 +1,62 @@
+// Implements a Lambda handler which returns a paginated list of audit log entries,
+// newest first. The entries can be filtered by the query parameters actor or target,
+// and by startTime and endTime in RFC3339 format. Pagination is handled by the query
+// parameter startKey.
+//
+// The caller must have the audit.read permission.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type ListAuditEntriesResponse struct {
+	Entries          []database.AuditEntry `json:"entries"`
+	LastEvaluatedKey string                `json:"lastEvaluatedKey"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionAuditRead),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	query := database.AuditQuery{
+		Actor:  event.QueryStringParameters["actor"],
+		Target: event.QueryStringParameters["target"],
+	}
+
+	var err error
+	if query.StartTime, err = parseTime(event.QueryStringParameters["startTime"]); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: startTime must be in RFC3339 format", "", err)), nil
+	}
+	if query.EndTime, err = parseTime(event.QueryStringParameters["endTime"]); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: endTime must be in RFC3339 format", "", err)), nil
+	}
+
+	entries, lastKey, err := repository.ListAuditEntries(query, event.QueryStringParameters["startKey"])
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(ListAuditEntriesResponse{Entries: entries, LastEvaluatedKey: lastKey}), nil
+}
+
+// parseTime returns the time represented by s, or the zero time if s is empty.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -23,13 +23,10 @@ func main() {
 	if stage == "prod" {
 		log.SetLevel(log.InfoLevel)
 	}
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	id := event.PathParameters["id"]
 	if id == "" {
 		return api.Failure(errors.New(400, "Invalid request: id is required", "")), nil
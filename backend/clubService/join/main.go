@@ -15,13 +15,10 @@ import (
 var repository = database.DynamoDB
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	if strings.Contains(event.RawPath, "requests") {
 		return handleJoinRequest(event), nil
 	}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
@@ -17,13 +16,10 @@ type ListClubsResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	startKey := event.QueryStringParameters["startKey"]
 	clubs, lastKey, err := repository.ListClubs(startKey)
 	if err != nil {
@@ -16,6 +16,9 @@ var repository = database.DynamoDB
 
 type ProcessJoinRequest struct {
 	Status database.ClubJoinRequestStatus `json:"status"`
+
+	// The reason for approving or rejecting the request, which is saved in the audit log
+	Reason string `json:"reason"`
 }
 
 type ProcessJoinRequestResponse struct {
@@ -24,13 +27,10 @@ type ProcessJoinRequestResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		return api.Failure(errors.New(400, "Invalid request: caller username is required", "")), nil
@@ -51,14 +51,23 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: unable to unmarshal body", "")), nil
 	}
 
-	var club *database.Club
-	var scoreboard []database.ScoreboardSummary
-	var err error
+	club, err := repository.GetClub(id)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	if err := checkPermission(club, info.Username); err != nil {
+		return api.Failure(err), nil
+	}
 
+	before := joinState(club, username)
+	var scoreboard []database.ScoreboardSummary
+	var action database.AuditAction
 	if request.Status == database.ClubJoinRequestStatus_Approved {
-		club, scoreboard, err = approveJoinRequest(id, username, info.Username)
+		action = database.AuditAction_ClubApproveJoinRequest
+		club, scoreboard, err = approveJoinRequest(id, username, club.Owner)
 	} else if request.Status == database.ClubJoinRequestStatus_Rejected {
-		club, err = repository.RejectClubJoinRequest(id, username, info.Username)
+		action = database.AuditAction_ClubRejectJoinRequest
+		club, err = repository.RejectClubJoinRequest(id, username, club.Owner)
 	} else {
 		err = errors.New(400, fmt.Sprintf("Invalid request: status %q is not supported", request.Status), "")
 	}
@@ -66,11 +75,45 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if err != nil {
 		return api.Failure(err), nil
 	}
+
+	entry := database.NewAuditEntry(info.Username, action, database.ClubAuditTarget(id), request.Reason)
+	if err := repository.CreateAuditEntry(entry, before, joinState(club, username)); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(ProcessJoinRequestResponse{Club: club, Scoreboard: scoreboard}), nil
 }
 
-func approveJoinRequest(id, username, caller string) (*database.Club, []database.ScoreboardSummary, error) {
-	club, err := repository.ApproveClubJoinRequest(id, username, caller)
+// joinState returns the join request and membership of the given username in the club,
+// for saving in the audit log.
+func joinState(club *database.Club, username string) map[string]interface{} {
+	state := map[string]interface{}{
+		"joinRequests." + username: nil,
+		"members." + username:      nil,
+	}
+	if request, ok := club.JoinRequests[username]; ok {
+		state["joinRequests."+username] = request
+	}
+	if member, ok := club.Members[username]; ok {
+		state["members."+username] = member
+	}
+	return state
+}
+
+// checkPermission returns an error if the caller is not the owner of the club and does not
+// have permission to process the club's join requests.
+func checkPermission(club *database.Club, caller string) error {
+	if club.Owner == caller {
+		return nil
+	}
+	user, err := repository.GetUser(caller)
+	if err != nil {
+		return err
+	}
+	return api.Authorize(user, database.PermissionClubJoinRequestsWrite, database.ClubScope(club.Id))
+}
+
+func approveJoinRequest(id, username, owner string) (*database.Club, []database.ScoreboardSummary, error) {
+	club, err := repository.ApproveClubJoinRequest(id, username, owner)
 	if err != nil {
 		return nil, nil, err
 	}
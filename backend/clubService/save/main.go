@@ -11,7 +11,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
@@ -19,13 +18,10 @@ var repository = database.DynamoDB
 var mediaStore = database.S3
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	if id := event.PathParameters["id"]; id == "" {
 		return createClub(event), nil
 	}
@@ -43,7 +39,7 @@ func createClub(event api.Request) api.Response {
 		return api.Failure(err)
 	}
 	if user.SubscriptionStatus != database.SubscriptionStatus_Subscribed {
-		return api.Failure(errors.New(403, "Invalid request: free-tier users cannot create clubs", ""))
+		return api.Failure(errors.New(403, "Invalid request: free-tier users cannot create clubs", "", errors.WithReason(errors.ReasonClubFreeTierForbidden)))
 	}
 
 	club := &database.Club{}
@@ -85,6 +81,20 @@ func saveClub(event api.Request) api.Response {
 		return api.Failure(errors.New(400, "Invalid request: username is required", ""))
 	}
 
+	club, err := repository.GetClub(event.PathParameters["id"])
+	if err != nil {
+		return api.Failure(err)
+	}
+	if club.Owner != info.Username {
+		user, err := repository.GetUser(info.Username)
+		if err != nil {
+			return api.Failure(err)
+		}
+		if err := api.Authorize(user, database.PermissionClubWrite, database.ClubScope(club.Id)); err != nil {
+			return api.Failure(err)
+		}
+	}
+
 	clubUpdate := &database.ClubUpdate{}
 	if err := json.Unmarshal([]byte(event.Body), clubUpdate); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err))
@@ -102,7 +112,7 @@ func saveClub(event api.Request) api.Response {
 		}
 	}
 
-	club, err := repository.UpdateClub(event.PathParameters["id"], info.Username, clubUpdate)
+	club, err = repository.UpdateClub(club.Id, club.Owner, clubUpdate)
 	if err != nil {
 		return api.Failure(err)
 	}
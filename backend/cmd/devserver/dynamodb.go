@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const dynamoTargetPrefix = "DynamoDB_20120810."
+
+// dynamoServer serves the DynamoDB JSON protocol using the given implementation
+// of the DynamoDB API. Functions started by the dev server send their requests here
+// when running with an in-memory database.
+type dynamoServer struct {
+	svc dynamodbiface.DynamoDBAPI
+}
+
+func (s *dynamoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), dynamoTargetPrefix)
+	method := reflect.ValueOf(s.svc).MethodByName(operation)
+	if operation == "" || !method.IsValid() || method.Type().NumIn() != 1 || method.Type().NumOut() != 2 {
+		writeDynamoError(w, awserr.New("UnknownOperationException", fmt.Sprintf("Unknown operation %q", operation), nil))
+		return
+	}
+
+	input := reflect.New(method.Type().In(0).Elem())
+	if err := jsonutil.UnmarshalJSON(input.Interface(), r.Body); err != nil {
+		writeDynamoError(w, awserr.New("SerializationException", err.Error(), nil))
+		return
+	}
+
+	output, err := s.call(method, input)
+	if err != nil {
+		writeDynamoError(w, err)
+		return
+	}
+
+	body, err := jsonutil.BuildJSON(output)
+	if err != nil {
+		writeDynamoError(w, awserr.New("InternalServerError", err.Error(), nil))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Write(body)
+}
+
+// call invokes the given method. Methods not implemented by the in-memory
+// database panic, which is reported as an unknown operation.
+func (s *dynamoServer) call(method, input reflect.Value) (output interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("DynamoDB operation panicked: %v", r)
+			err = awserr.New("UnknownOperationException", "Operation is not supported by the in-memory database", nil)
+		}
+	}()
+
+	results := method.Call([]reflect.Value{input})
+	if e, ok := results[1].Interface().(error); ok && e != nil {
+		return nil, e
+	}
+	return results[0].Interface(), nil
+}
+
+func writeDynamoError(w http.ResponseWriter, err error) {
+	code, message, status := "InternalServerError", err.Error(), http.StatusInternalServerError
+	if aerr, ok := err.(awserr.Error); ok {
+		code, message = aerr.Code(), aerr.Message()
+		if code != "InternalServerError" {
+			status = http.StatusBadRequest
+		}
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + code,
+		"message": message,
+	})
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	w.Write(body)
+}
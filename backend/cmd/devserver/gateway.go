@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// gatewayRoute is a route mounted on the gateway.
+type gatewayRoute struct {
+	route
+	function *function
+	segments []string
+}
+
+// claimsConfig holds the fake Cognito claims injected into authorized requests.
+type claimsConfig struct {
+	username string
+	email    string
+}
+
+// gateway emulates API Gateway's HTTP API, converting net/http requests into
+// APIGatewayV2HTTPRequest events and invoking the matching function.
+type gateway struct {
+	runner *lambdaRunner
+	routes []*gatewayRoute
+	claims claimsConfig
+}
+
+func newGateway(runner *lambdaRunner, functions []*function, claims claimsConfig) *gateway {
+	g := &gateway{runner: runner, claims: claims}
+	for _, f := range functions {
+		for _, r := range f.routes {
+			if r.path == "" || r.method == "" {
+				continue
+			}
+			g.routes = append(g.routes, &gatewayRoute{
+				route:    r,
+				function: f,
+				segments: strings.Split(strings.Trim(r.path, "/"), "/"),
+			})
+		}
+	}
+
+	// API Gateway prefers the most specific route, so routes with more literal
+	// segments are matched first and greedy path variables are matched last.
+	sort.SliceStable(g.routes, func(i, j int) bool {
+		return routeSpecificity(g.routes[i].segments) > routeSpecificity(g.routes[j].segments)
+	})
+	return g
+}
+
+func routeSpecificity(segments []string) int {
+	score := 0
+	for _, s := range segments {
+		switch {
+		case strings.HasSuffix(s, "+}"):
+		case strings.HasPrefix(s, "{"):
+			score += 1
+		default:
+			score += 3
+		}
+		score *= 4
+	}
+	return score + len(segments)
+}
+
+// match returns the route matching the given method and path, along with the
+// path parameters extracted from the path.
+func (g *gateway) match(method, path string) (*gatewayRoute, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, r := range g.routes {
+		if r.method != method && r.method != "ANY" && r.method != "*" {
+			continue
+		}
+		if params, ok := matchSegments(r.segments, segments); ok {
+			return r, params
+		}
+	}
+	return nil, nil
+}
+
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "+}") {
+			if i >= len(segments) {
+				return nil, false
+			}
+			params[strings.TrimSuffix(strings.TrimPrefix(p, "{"), "+}")] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(p, "{}")] = segments[i]
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, len(pattern) == len(segments)
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	route, params := g.match(r.Method, r.URL.Path)
+	if route == nil {
+		writeMessage(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	event, err := g.toEvent(r, route, params)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if route.authorized && event.RequestContext.Authorizer == nil {
+		writeMessage(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		writeMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	start := time.Now()
+	out, err := g.runner.invoke(route.function, payload)
+	if err != nil {
+		fmt.Printf("%s %s -> %s/%s: %v\n", r.Method, r.URL.Path, route.function.service, route.function.name, err)
+		writeMessage(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	var resp events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		writeMessage(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	fmt.Printf("%s %s -> %s/%s: %d (%s)\n", r.Method, r.URL.Path, route.function.service, route.function.name, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, vs := range resp.MultiValueHeaders {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	for _, c := range resp.Cookies {
+		w.Header().Add("Set-Cookie", c)
+	}
+
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(resp.Body); err != nil {
+			writeMessage(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
+	if resp.StatusCode == 0 {
+		resp.StatusCode = http.StatusOK
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
+
+// toEvent converts the given request into the event API Gateway would send to the function.
+func (g *gateway) toEvent(r *http.Request, route *gatewayRoute, params map[string]string) (*events.APIGatewayV2HTTPRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	for k, v := range r.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+
+	query := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		query[k] = strings.Join(v, ",")
+	}
+
+	var cookies []string
+	for _, c := range r.Cookies() {
+		cookies = append(cookies, c.String())
+	}
+
+	sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	now := time.Now()
+	routeKey := route.method + " " + route.path
+
+	event := &events.APIGatewayV2HTTPRequest{
+		Version:               "2.0",
+		RouteKey:              routeKey,
+		RawPath:               r.URL.Path,
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		PathParameters:        params,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:     routeKey,
+			AccountID:    "000000000000",
+			Stage:        "$default",
+			RequestID:    uuid.NewString(),
+			APIID:        "devserver",
+			DomainName:   r.Host,
+			DomainPrefix: "devserver",
+			Time:         now.Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch:    now.UnixMilli(),
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Protocol:  r.Proto,
+				SourceIP:  sourceIP,
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+
+	if utf8.Valid(body) {
+		event.Body = string(body)
+	} else {
+		event.Body = base64.StdEncoding.EncodeToString(body)
+		event.IsBase64Encoded = true
+	}
+
+	if route.authorized {
+		claims, err := g.requestClaims(r)
+		if err != nil {
+			return nil, err
+		}
+		if claims != nil {
+			event.RequestContext.Authorizer = &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{Claims: claims},
+			}
+		}
+	}
+	return event, nil
+}
+
+// requestClaims returns the Cognito claims for the given request. If the request
+// contains a bearer token, its claims are used without verifying the signature.
+// Otherwise, the X-Dev-Username and X-Dev-Email headers or the configured defaults
+// are used. A nil map is returned if the request has no user.
+func (g *gateway) requestClaims(r *http.Request) (map[string]string, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return decodeJWTClaims(token)
+	}
+
+	username := r.Header.Get("X-Dev-Username")
+	if username == "" {
+		username = g.claims.username
+	}
+	if username == "" {
+		return nil, nil
+	}
+
+	email := r.Header.Get("X-Dev-Email")
+	if email == "" {
+		email = g.claims.email
+	}
+	return map[string]string{
+		"cognito:username": username,
+		"email":            email,
+		"token_use":        "id",
+	}, nil
+}
+
+// decodeJWTClaims returns the claims of the given JWT, formatted the way API
+// Gateway passes them to Lambda.
+func decodeJWTClaims(token string) (map[string]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid bearer token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %w", err)
+	}
+
+	claims := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch val := v.(type) {
+		case string:
+			claims[k] = val
+		case float64:
+			claims[k] = fmt.Sprintf("%.0f", val)
+		default:
+			b, _ := json.Marshal(val)
+			claims[k] = string(b)
+		}
+	}
+	return claims, nil
+}
+
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
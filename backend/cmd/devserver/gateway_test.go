@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGatewayMatch(t *testing.T) {
+	functions := []*function{
+		{service: "user", name: "get", routes: []route{{method: "GET", path: "/user"}, {method: "GET", path: "/public/user/{username}"}}},
+		{service: "game", name: "get", routes: []route{{method: "GET", path: "/game/{cohort}/{id+}"}}},
+		{service: "game", name: "featured", routes: []route{{method: "GET", path: "/game/featured"}}},
+		{service: "event", name: "proxy", routes: []route{{method: "GET", path: "/event/{id+}"}}},
+		{service: "event", name: "list", routes: []route{{method: "GET", path: "/event"}}},
+	}
+	g := newGateway(nil, functions, claimsConfig{})
+
+	table := []struct {
+		method     string
+		path       string
+		wantName   string
+		wantParams map[string]string
+	}{
+		{method: "GET", path: "/user", wantName: "user/get", wantParams: map[string]string{}},
+		{method: "GET", path: "/public/user/test", wantName: "user/get", wantParams: map[string]string{"username": "test"}},
+		{method: "GET", path: "/game/featured", wantName: "game/featured", wantParams: map[string]string{}},
+		{method: "GET", path: "/game/1500-1600/2024.01.01_abc", wantName: "game/get", wantParams: map[string]string{"cohort": "1500-1600", "id": "2024.01.01_abc"}},
+		{method: "GET", path: "/event/a/b/c", wantName: "event/proxy", wantParams: map[string]string{"id": "a/b/c"}},
+		{method: "GET", path: "/event", wantName: "event/list", wantParams: map[string]string{}},
+		{method: "POST", path: "/user"},
+		{method: "GET", path: "/public/user"},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.method+tc.path, func(t *testing.T) {
+			r, params := g.match(tc.method, tc.path)
+			if tc.wantName == "" {
+				if r != nil {
+					t.Errorf("match(%s %s) got %s/%s; want nil", tc.method, tc.path, r.function.service, r.function.name)
+				}
+				return
+			}
+			if r == nil {
+				t.Fatalf("match(%s %s) got nil; want %s", tc.method, tc.path, tc.wantName)
+			}
+			if got := r.function.service + "/" + r.function.name; got != tc.wantName {
+				t.Errorf("match(%s %s) got %s; want %s", tc.method, tc.path, got, tc.wantName)
+			}
+			if diff := cmp.Diff(tc.wantParams, params); diff != "" {
+				t.Errorf("match(%s %s) params diff (-want +got):\n%s", tc.method, tc.path, diff)
+			}
+		})
+	}
+}
+
+func TestParseServerless(t *testing.T) {
+	functions, err := parseServerless("../../user/serverless.yml")
+	if err != nil {
+		t.Fatalf("parseServerless got err: %v", err)
+	}
+
+	byName := make(map[string]*function)
+	for _, f := range functions {
+		byName[f.name] = f
+	}
+
+	get := byName["get"]
+	if get == nil || get.dir != "user/get" {
+		t.Fatalf("parseServerless got get function %+v; want dir user/get", get)
+	}
+	wantRoutes := []route{
+		{method: "GET", path: "/user", authorized: true},
+		{method: "GET", path: "/public/user/{username}"},
+	}
+	if diff := cmp.Diff(wantRoutes, get.routes, cmp.AllowUnexported(route{})); diff != "" {
+		t.Errorf("parseServerless get routes diff (-want +got):\n%s", diff)
+	}
+
+	ratings := byName["updateRatings"]
+	if ratings == nil || len(ratings.schedules) == 0 {
+		t.Fatalf("parseServerless got updateRatings %+v; want schedules", ratings)
+	}
+	wantInput := map[string]interface{}{
+		"id":          "StatsUpdate0-300",
+		"detail-type": "Scheduled Event",
+		"source":      "Serverless",
+		"region":      "${aws:region}",
+		"detail": map[string]interface{}{
+			"cohorts": []interface{}{"0-300"},
+		},
+	}
+	if diff := cmp.Diff(wantInput, ratings.schedules[0].input); diff != "" {
+		t.Errorf("parseServerless updateRatings input diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/google/uuid"
+)
+
+// invokeTimeout is the deadline passed to each invocation, matching the
+// default Lambda timeout used by serverless.
+const invokeTimeout = 30 * time.Second
+
+// lambdaRunner builds Lambda functions and runs them as local processes. The
+// processes are started in the RPC mode supported by aws-lambda-go, which is
+// enabled by setting _LAMBDA_SERVER_PORT.
+type lambdaRunner struct {
+	root   string
+	binDir string
+	env    []string
+
+	mu        sync.Mutex
+	processes map[string]*lambdaProcess
+}
+
+// lambdaProcess is a single running Lambda function.
+type lambdaProcess struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	client *rpc.Client
+}
+
+func newLambdaRunner(root, binDir string, env []string) *lambdaRunner {
+	return &lambdaRunner{
+		root:      root,
+		binDir:    binDir,
+		env:       env,
+		processes: make(map[string]*lambdaProcess),
+	}
+}
+
+// invoke runs the given function with the given payload, building and starting
+// the function if necessary. The function's response payload is returned.
+func (r *lambdaRunner) invoke(f *function, payload []byte) ([]byte, error) {
+	p, err := r.process(f)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	deadline := time.Now().Add(invokeTimeout)
+	req := &messages.InvokeRequest{
+		Payload:   payload,
+		RequestId: uuid.NewString(),
+		Deadline: messages.InvokeRequest_Timestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+		InvokedFunctionArn: fmt.Sprintf("arn:aws:lambda:local:000000000000:function:%s-%s", f.service, f.name),
+	}
+
+	var resp messages.InvokeResponse
+	if err := p.client.Call("Function.Invoke", req, &resp); err != nil {
+		r.stop(f)
+		return nil, fmt.Errorf("failed to invoke %s/%s: %w", f.service, f.name, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s/%s returned error: %s: %s", f.service, f.name, resp.Error.Type, resp.Error.Message)
+	}
+	return resp.Payload, nil
+}
+
+// process returns the running process for the given function, starting it if necessary.
+func (r *lambdaRunner) process(f *function) (*lambdaProcess, error) {
+	key := f.service + "/" + f.name
+
+	r.mu.Lock()
+	p, ok := r.processes[key]
+	if !ok {
+		p = &lambdaProcess{}
+		r.processes[key] = p
+	}
+	r.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p, nil
+	}
+
+	bin := filepath.Join(r.binDir, f.service+"-"+f.name)
+	log.Printf("Building %s/%s", f.service, f.name)
+	build := exec.Command("go", "build", "-o", bin, "./"+filepath.ToSlash(f.dir))
+	build.Dir = r.root
+	if out, err := build.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to build %s/%s: %w\n%s", f.service, f.name, err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Dir = filepath.Join(r.root, f.dir)
+	cmd.Env = append(append(os.Environ(), r.env...), "_LAMBDA_SERVER_PORT="+strconv.Itoa(port))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s/%s: %w", f.service, f.name, err)
+	}
+
+	var client *rpc.Client
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(50 * time.Millisecond) {
+		if client, err = rpc.Dial("tcp", fmt.Sprintf("localhost:%d", port)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("failed to connect to %s/%s: %w", f.service, f.name, err)
+	}
+
+	p.cmd = cmd
+	p.client = client
+	return p, nil
+}
+
+// stop kills the process for the given function, if it is running.
+func (r *lambdaRunner) stop(f *function) {
+	r.mu.Lock()
+	p := r.processes[f.service+"/"+f.name]
+	delete(r.processes, f.service+"/"+f.name)
+	r.mu.Unlock()
+
+	if p != nil && p.cmd != nil {
+		p.client.Close()
+		p.cmd.Process.Kill()
+		p.cmd.Wait()
+	}
+}
+
+// stopAll kills all running processes. They are rebuilt on their next invocation.
+func (r *lambdaRunner) stopAll() {
+	r.mu.Lock()
+	processes := r.processes
+	r.processes = make(map[string]*lambdaProcess)
+	r.mu.Unlock()
+
+	for _, p := range processes {
+		p.mu.Lock()
+		if p.cmd != nil {
+			p.client.Close()
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// freePort returns a TCP port that is currently unused.
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,101 @@
+// Command devserver runs the backend API locally. Each Lambda function defined in the
+// serverless.yml files is built and run as a local process, and HTTP requests are
+// routed to it the same way API Gateway does. Functions triggered by DynamoDB streams
+// and CloudWatch schedules can be invoked through the /_dev endpoints.
+//
+// Usage (from the backend directory):
+//
+//	go run ./cmd/devserver -username myCognitoUsername
+//	go run ./cmd/devserver -memory
+//
+// With -memory, all functions share an in-memory database served by the dev server
+// instead of the DynamoDB tables of the configured stage.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to serve the API on")
+	root := flag.String("root", ".", "path to the backend directory")
+	stage := flag.String("stage", "dev", "the stage whose tables and config are used")
+	region := flag.String("region", "us-east-1", "the AWS region passed to the functions")
+	username := flag.String("username", "", "the Cognito username injected into authorized requests without a bearer token")
+	email := flag.String("email", "", "the email injected into authorized requests without a bearer token")
+	memory := flag.Bool("memory", false, "use an in-memory database instead of DynamoDB")
+	dynamoAddr := flag.String("dynamodb-addr", "localhost:8081", "address to serve the in-memory database on when using -memory")
+	flag.Parse()
+
+	backend, err := filepath.Abs(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(backend, "go.mod")); err != nil {
+		log.Fatalf("%s is not the backend directory: %v", backend, err)
+	}
+
+	functions, err := loadFunctions(backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	binDir, err := os.MkdirTemp("", "devserver")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+
+	env := []string{"stage=" + *stage, "AWS_REGION=" + *region}
+	if os.Getenv("cursorSecret") == "" {
+		// Cursors are only signed for the lifetime of the dev server.
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal(err)
+		}
+		env = append(env, "cursorSecret="+hex.EncodeToString(secret))
+	}
+	if *memory {
+		env = append(env,
+			"dynamodbEndpoint=http://"+*dynamoAddr,
+			"AWS_ACCESS_KEY_ID=devserver",
+			"AWS_SECRET_ACCESS_KEY=devserver",
+			"AWS_SESSION_TOKEN=",
+		)
+		go func() {
+			log.Printf("Serving in-memory DynamoDB on %s", *dynamoAddr)
+			log.Fatal(http.ListenAndServe(*dynamoAddr, &dynamoServer{svc: database.NewMemoryDynamoDB(database.WithStage(*stage))}))
+		}()
+	}
+
+	runner := newLambdaRunner(backend, binDir, env)
+	gw := newGateway(runner, functions, claimsConfig{username: *username, email: *email})
+
+	mux := http.NewServeMux()
+	mux.Handle("/_dev/", &replayer{runner: runner, functions: functions, region: *region})
+	mux.Handle("/", gw)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		runner.stopAll()
+		os.RemoveAll(binDir)
+		os.Exit(0)
+	}()
+
+	fmt.Printf("Loaded %d functions with %d routes\n", len(functions), len(gw.routes))
+	log.Printf("Serving API on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// replayer handles the /_dev endpoints, which invoke functions that are not
+// reachable through API Gateway.
+//
+//	GET  /_dev/functions                           lists the known functions and their events
+//	POST /_dev/stream/{service}/{function}         invokes the function with the DynamoDB stream event in the body
+//	POST /_dev/schedule/{service}/{function}[?index=N]
+//	                                               invokes the function with the input of its Nth schedule,
+//	                                               or with the CloudWatch event in the body if one is provided
+//	POST /_dev/invoke/{service}/{function}         invokes the function with the raw event in the body,
+//	                                               such as a Cognito trigger event
+//	POST /_dev/reload                              stops all functions so that they are rebuilt on their next call
+type replayer struct {
+	runner    *lambdaRunner
+	functions []*function
+	region    string
+}
+
+func (rp *replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/_dev"), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && parts[0] == "functions":
+		rp.listFunctions(w)
+
+	case r.Method == http.MethodPost && parts[0] == "reload":
+		rp.runner.stopAll()
+		writeMessage(w, http.StatusOK, "All functions will be rebuilt on their next invocation")
+
+	case r.Method == http.MethodPost && len(parts) == 3 && (parts[0] == "stream" || parts[0] == "schedule" || parts[0] == "invoke"):
+		f := rp.find(parts[1], parts[2])
+		if f == nil {
+			writeMessage(w, http.StatusNotFound, fmt.Sprintf("Function %s/%s not found", parts[1], parts[2]))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		payload := body
+		switch parts[0] {
+		case "stream":
+			payload, err = rp.streamPayload(f, body)
+		case "schedule":
+			payload, err = rp.schedulePayload(f, body, r.URL.Query().Get("index"))
+		case "invoke":
+			if !json.Valid(body) {
+				err = fmt.Errorf("body is not valid JSON")
+			}
+		}
+		if err != nil {
+			writeMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		out, err := rp.runner.invoke(f, payload)
+		if err != nil {
+			writeMessage(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+
+	default:
+		writeMessage(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (rp *replayer) find(service, name string) *function {
+	for _, f := range rp.functions {
+		if f.service == service && f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (rp *replayer) listFunctions(w http.ResponseWriter) {
+	type functionSummary struct {
+		Service   string   `json:"service"`
+		Name      string   `json:"name"`
+		Dir       string   `json:"dir"`
+		Routes    []string `json:"routes,omitempty"`
+		Streams   []string `json:"streams,omitempty"`
+		Schedules []string `json:"schedules,omitempty"`
+	}
+
+	summaries := make([]functionSummary, 0, len(rp.functions))
+	for _, f := range rp.functions {
+		s := functionSummary{Service: f.service, Name: f.name, Dir: f.dir}
+		for _, r := range f.routes {
+			s.Routes = append(s.Routes, r.method+" "+r.path)
+		}
+		for _, st := range f.streams {
+			s.Streams = append(s.Streams, fmt.Sprintf("%s %v", st.arn, st.eventNames))
+		}
+		for _, sc := range f.schedules {
+			s.Schedules = append(s.Schedules, sc.rate)
+		}
+		summaries = append(summaries, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// streamPayload validates that body is a DynamoDB stream event accepted by the
+// function's stream filters.
+func (rp *replayer) streamPayload(f *function, body []byte) ([]byte, error) {
+	if len(f.streams) == 0 {
+		return nil, fmt.Errorf("function %s/%s has no stream events", f.service, f.name)
+	}
+
+	var event events.DynamoDBEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("body is not a DynamoDB stream event: %w", err)
+	}
+
+	// Drop records that would be removed by the function's filter patterns.
+	var allowed []events.DynamoDBEventRecord
+	for _, record := range event.Records {
+		for _, s := range f.streams {
+			if len(s.eventNames) == 0 || contains(s.eventNames, record.EventName) {
+				allowed = append(allowed, record)
+				break
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("no records match the stream filters of %s/%s", f.service, f.name)
+	}
+	event.Records = allowed
+	return json.Marshal(event)
+}
+
+// schedulePayload returns the event sent to the function by the schedule with the
+// given index. If body is not empty, it is used instead.
+func (rp *replayer) schedulePayload(f *function, body []byte, index string) ([]byte, error) {
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("body is not valid JSON")
+		}
+		return body, nil
+	}
+
+	if len(f.schedules) == 0 {
+		return nil, fmt.Errorf("function %s/%s has no schedule events", f.service, f.name)
+	}
+	i := 0
+	if index != "" {
+		var err error
+		if i, err = strconv.Atoi(index); err != nil || i < 0 || i >= len(f.schedules) {
+			return nil, fmt.Errorf("index must be between 0 and %d", len(f.schedules)-1)
+		}
+	}
+
+	if input := f.schedules[i].input; input != nil {
+		b, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.ReplaceAll(string(b), "${aws:region}", rp.region)), nil
+	}
+
+	return json.Marshal(events.CloudWatchEvent{
+		Version:    "0",
+		ID:         uuid.NewString(),
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		AccountID:  "000000000000",
+		Time:       time.Now().UTC(),
+		Region:     rp.region,
+		Resources:  []string{},
+		Detail:     json.RawMessage("{}"),
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
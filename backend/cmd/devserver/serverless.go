@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// route is an httpApi event on a Lambda function.
+type route struct {
+	method string
+	path   string
+
+	// Whether the route uses the Cognito JWT authorizer.
+	authorized bool
+}
+
+// stream is a DynamoDB stream event on a Lambda function.
+type stream struct {
+	// The serverless param naming the stream, such as TimelineTableStreamArn.
+	arn string
+
+	// The eventNames the stream is filtered to, if any.
+	eventNames []string
+}
+
+// schedule is a CloudWatch schedule event on a Lambda function.
+type schedule struct {
+	rate string
+
+	// The constant input passed to the function, if any.
+	input interface{}
+}
+
+// function is a single Lambda function defined in a serverless.yml file.
+type function struct {
+	service   string
+	name      string
+	dir       string
+	routes    []route
+	streams   []stream
+	schedules []schedule
+}
+
+// loadFunctions returns the Go functions defined in every serverless.yml file
+// one level below root.
+func loadFunctions(root string) ([]*function, error) {
+	files, err := filepath.Glob(filepath.Join(root, "*", "serverless.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []*function
+	for _, file := range files {
+		fs, err := parseServerless(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		for _, f := range fs {
+			if _, err := os.Stat(filepath.Join(root, f.dir)); err == nil {
+				functions = append(functions, f)
+			}
+		}
+	}
+	return functions, nil
+}
+
+// yamlLine is a single non-empty, non-comment line of a YAML file.
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+func readYamlLines(file string) ([]yamlLine, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []yamlLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	return lines, scanner.Err()
+}
+
+// parseServerless extracts the functions and their events from a serverless.yml
+// file. Only the small subset of YAML used by this repository's serverless files
+// is understood. Functions not written in Go are skipped.
+func parseServerless(file string) ([]*function, error) {
+	lines, err := readYamlLines(file)
+	if err != nil {
+		return nil, err
+	}
+
+	service := filepath.Base(filepath.Dir(file))
+	var functions []*function
+	var current *function
+	var event string
+	functionIndent := -1
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if line.indent == 0 {
+			functionIndent = -1
+			if line.text == "functions:" {
+				functionIndent = 0
+			}
+			continue
+		}
+		if functionIndent < 0 {
+			continue
+		}
+		if functionIndent == 0 {
+			functionIndent = line.indent
+		}
+
+		key, value := splitYamlKey(line.text)
+		switch {
+		case line.indent == functionIndent:
+			current = &function{service: service, name: key}
+			functions = append(functions, current)
+			event = ""
+
+		case current == nil:
+
+		case key == "handler" && line.indent == functionIndent+2:
+			if strings.HasSuffix(value, ".go") {
+				current.dir = filepath.Join(service, filepath.Dir(value))
+			}
+
+		case strings.HasPrefix(line.text, "- "):
+			event, _ = splitYamlKey(strings.TrimPrefix(line.text, "- "))
+			switch event {
+			case "httpApi":
+				current.routes = append(current.routes, route{})
+			case "stream":
+				current.streams = append(current.streams, stream{})
+			case "schedule":
+				current.schedules = append(current.schedules, schedule{})
+				if _, v := splitYamlKey(strings.TrimPrefix(line.text, "- ")); v != "" {
+					current.schedules[len(current.schedules)-1].rate = v
+				}
+			case "eventName":
+				if n := len(current.streams); n > 0 {
+					_, v := splitYamlKey(strings.TrimPrefix(line.text, "- "))
+					current.streams[n-1].eventNames = parseFlowList(v)
+				}
+			}
+
+		case event == "httpApi":
+			r := &current.routes[len(current.routes)-1]
+			switch key {
+			case "path":
+				r.path = value
+			case "method":
+				r.method = strings.ToUpper(value)
+			case "authorizer":
+				r.authorized = true
+			}
+
+		case event == "stream" && key == "arn":
+			s := &current.streams[len(current.streams)-1]
+			s.arn = strings.TrimSuffix(strings.TrimPrefix(value, "${param:"), "}")
+
+		case event == "schedule":
+			s := &current.schedules[len(current.schedules)-1]
+			switch key {
+			case "rate":
+				s.rate = value
+			case "input":
+				var end int
+				s.input, end = parseYamlBlock(lines, i+1, line.indent)
+				i = end - 1
+			}
+		}
+	}
+
+	var result []*function
+	for _, f := range functions {
+		if f.dir != "" {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// splitYamlKey splits a `key: value` line. The value is unquoted.
+func splitYamlKey(text string) (string, string) {
+	key, value, found := strings.Cut(text, ":")
+	if !found {
+		return "", unquoteYaml(text)
+	}
+	return strings.TrimSpace(key), unquoteYaml(strings.TrimSpace(value))
+}
+
+func unquoteYaml(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseFlowList parses a YAML flow sequence such as [INSERT, MODIFY].
+func parseFlowList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = unquoteYaml(strings.TrimSpace(v)); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// parseYamlBlock parses the block of maps, lists and scalars starting at lines[start]
+// and indented deeper than parentIndent. The parsed value and the index of the first
+// line after the block are returned.
+func parseYamlBlock(lines []yamlLine, start, parentIndent int) (interface{}, int) {
+	if start >= len(lines) || lines[start].indent <= parentIndent {
+		return nil, start
+	}
+	indent := lines[start].indent
+
+	if strings.HasPrefix(lines[start].text, "- ") {
+		var list []interface{}
+		i := start
+		for i < len(lines) && lines[i].indent == indent && strings.HasPrefix(lines[i].text, "- ") {
+			list = append(list, unquoteYaml(strings.TrimPrefix(lines[i].text, "- ")))
+			i++
+		}
+		return list, i
+	}
+
+	m := make(map[string]interface{})
+	i := start
+	for i < len(lines) && lines[i].indent == indent {
+		key, value := splitYamlKey(lines[i].text)
+		i++
+		if value != "" {
+			m[key] = value
+			continue
+		}
+		var nested interface{}
+		nested, i = parseYamlBlock(lines, i, indent)
+		if nested == nil && i < len(lines) && lines[i].indent == indent && strings.HasPrefix(lines[i].text, "- ") {
+			// YAML allows lists to be at the same indentation as their parent key.
+			nested, i = parseYamlBlock(lines, i, indent-1)
+		}
+		m[key] = nested
+	}
+	return m, i
+}
@@ -0,0 +1,39 @@
+// Implements a Lambda handler which returns the cohort boundaries in effect at the
+// date given by the optional query parameter date, in RFC3339 format. The current
+// boundaries are returned if date is not provided.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository database.CohortBoundariesGetter = database.DynamoDB
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	date := time.Now()
+	if d := event.QueryStringParameters["date"]; d != "" {
+		var err error
+		if date, err = time.Parse(time.RFC3339, d); err != nil {
+			return api.Failure(errors.Wrap(400, "Invalid request: date must be in RFC3339 format", "", err)), nil
+		}
+	}
+
+	boundaries, err := repository.GetCohortBoundaries(date)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(boundaries), nil
+}
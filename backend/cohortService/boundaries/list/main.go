@@ -0,0 +1,40 @@
+// Implements a Lambda handler which returns every proposed and published version of
+// the cohort boundaries.
+//
+// The caller must have the cohortBoundaries.write permission.
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type ListCohortBoundariesResponse struct {
+	// The compiled boundaries, which are in effect before the first published version.
+	Default database.CohortBoundaries `json:"default"`
+
+	// The proposed and published versions.
+	Versions []database.CohortBoundaries `json:"versions"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionCohortBoundariesWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	versions, err := repository.ListCohortBoundaries()
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(ListCohortBoundariesResponse{Default: database.DefaultCohortBoundaries, Versions: versions}), nil
+}
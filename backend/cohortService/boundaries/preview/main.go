@@ -0,0 +1,110 @@
+// Implements a Lambda handler which previews the effect of publishing proposed cohort
+// boundaries. Each user with a rating in their preferred rating system is placed using
+// both the boundaries in effect at the proposal's effectiveAt and the proposal, and
+// the users whose cohort would change are counted.
+//
+// The caller must have the cohortBoundaries.write permission.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type PreviewCohortBoundariesResponse struct {
+	// The proposal being previewed.
+	Proposal *database.CohortBoundaries `json:"proposal"`
+
+	// The boundaries the proposal would replace.
+	Current *database.CohortBoundaries `json:"current"`
+
+	// The number of users with a rating in their preferred rating system.
+	Users int `json:"users"`
+
+	// The number of users whose cohort would change.
+	Moved int `json:"moved"`
+
+	// The number of users moving between each pair of cohorts, keyed by the cohort
+	// under the current boundaries and then by the cohort under the proposal.
+	Moves map[database.DojoCohort]map[database.DojoCohort]int `json:"moves"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionCohortBoundariesWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	id := event.PathParameters["id"]
+	if id == "" {
+		return api.Failure(errors.New(400, "Invalid request: id is required", "")), nil
+	}
+
+	proposal, err := repository.GetCohortBoundariesProposal(id)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, proposal.EffectiveAt)
+	if err != nil {
+		return api.Failure(errors.Wrap(500, "Temporary server error", "Invalid proposal effectiveAt", err)), nil
+	}
+	current, err := repository.GetCohortBoundaries(effectiveAt)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	resp := PreviewCohortBoundariesResponse{
+		Proposal: proposal,
+		Current:  current,
+		Moves:    make(map[database.DojoCohort]map[database.DojoCohort]int),
+	}
+	for _, cohort := range database.Cohorts {
+		startKey := ""
+		for ok := true; ok; ok = startKey != "" {
+			var users []*database.User
+			users, startKey, err = repository.ListUserRatings(cohort, startKey)
+			if err != nil {
+				return api.Failure(err), nil
+			}
+			for _, user := range users {
+				preview(&resp, user)
+			}
+		}
+	}
+	return api.Success(resp), nil
+}
+
+// preview adds the given user to the response.
+func preview(resp *PreviewCohortBoundariesResponse, user *database.User) {
+	rating := user.Ratings[user.RatingSystem]
+	if rating == nil || rating.CurrentRating <= 0 {
+		return
+	}
+
+	from := resp.Current.GetCohort(user.RatingSystem, rating.CurrentRating)
+	to := resp.Proposal.GetCohort(user.RatingSystem, rating.CurrentRating)
+	if from == database.NoCohort || to == database.NoCohort {
+		return
+	}
+
+	resp.Users++
+	if from == to {
+		return
+	}
+	resp.Moved++
+	if resp.Moves[from] == nil {
+		resp.Moves[from] = make(map[database.DojoCohort]int)
+	}
+	resp.Moves[from][to]++
+}
@@ -0,0 +1,86 @@
+// Implements a Lambda handler which proposes a new version of the cohort boundaries.
+// Rating systems missing from the proposal keep the boundaries in effect at the
+// proposal's effectiveAt. The proposal has no effect until it is published.
+//
+// The caller must have the cohortBoundaries.write permission.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/google/uuid"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type ProposeCohortBoundariesRequest struct {
+	// The boundaries of each changed rating system.
+	Boundaries map[database.RatingSystem][]int `json:"boundaries"`
+
+	// The time the boundaries take effect once published, in RFC3339 format. Must be in the future.
+	EffectiveAt string `json:"effectiveAt"`
+
+	// A description of the changes.
+	Description string `json:"description"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionCohortBoundariesWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := ProposeCohortBoundariesRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+
+	effectiveAt, err := time.Parse(time.RFC3339, req.EffectiveAt)
+	if err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: effectiveAt must be in RFC3339 format", "", err)), nil
+	}
+	if effectiveAt.Before(time.Now()) {
+		return api.Failure(errors.New(400, "Invalid request: effectiveAt must be in the future", "")), nil
+	}
+
+	current, err := repository.GetCohortBoundaries(effectiveAt)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	proposal := database.CohortBoundaries{
+		Status:      database.CohortBoundariesStatus_Proposed,
+		Id:          uuid.NewString(),
+		Boundaries:  make(map[database.RatingSystem][]int),
+		EffectiveAt: effectiveAt.UTC().Format(time.RFC3339),
+		Description: req.Description,
+		CreatedBy:   caller.Username,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+	for system, boundaries := range current.Boundaries {
+		proposal.Boundaries[system] = boundaries
+	}
+	for system, boundaries := range req.Boundaries {
+		proposal.Boundaries[system] = boundaries
+	}
+	if err := proposal.Validate(); err != nil {
+		return api.Failure(err), nil
+	}
+
+	if err := repository.PutCohortBoundariesProposal(&proposal); err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(proposal), nil
+}
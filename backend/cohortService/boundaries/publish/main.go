@@ -0,0 +1,79 @@
+// Implements a Lambda handler which publishes proposed cohort boundaries. The
+// boundaries are used for all dates after the proposal's effectiveAt, which must
+// still be in the future, so that historical data keeps its original cohorts.
+//
+// The caller must have the cohortBoundaries.write permission.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type PublishCohortBoundariesRequest struct {
+	// The reason for publishing, which is saved in the audit log.
+	Reason string `json:"reason"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionCohortBoundariesWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	id := event.PathParameters["id"]
+	if id == "" {
+		return api.Failure(errors.New(400, "Invalid request: id is required", "")), nil
+	}
+
+	req := PublishCohortBoundariesRequest{}
+	if event.Body != "" {
+		if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+			return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+		}
+	}
+
+	proposal, err := repository.GetCohortBoundariesProposal(id)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, proposal.EffectiveAt)
+	if err != nil {
+		return api.Failure(errors.Wrap(500, "Temporary server error", "Invalid proposal effectiveAt", err)), nil
+	}
+	if effectiveAt.Before(time.Now()) {
+		return api.Failure(errors.New(400, "Invalid request: the proposal's effectiveAt has passed. Propose the boundaries again with a new effectiveAt", "")), nil
+	}
+
+	current, err := repository.GetCohortBoundaries(effectiveAt)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	published, err := repository.PublishCohortBoundaries(proposal, caller.Username)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	entry := database.NewAuditEntry(caller.Username, database.AuditAction_CohortBoundariesPublish,
+		database.CohortBoundariesAuditTarget(published.EffectiveAt), req.Reason)
+	if err := repository.CreateAuditEntry(entry, current.Boundaries, published.Boundaries); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
+	return api.Success(published), nil
+}
@@ -0,0 +1,32 @@
+// Implements a Lambda handler which returns the settings of the nightly graduation
+// eligibility check.
+//
+// The caller must have the graduationSettings.write permission.
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionGraduationSettingsWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	settings, err := repository.GetGraduationSettings()
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(settings), nil
+}
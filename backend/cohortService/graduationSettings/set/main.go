@@ -0,0 +1,103 @@
+// Implements a Lambda handler which updates the settings of the nightly graduation
+// eligibility check: the number of days a user's rating must stay in the next cohort
+// before they are notified, whether they are graduated automatically and how users whose
+// rating dropped below their cohort are handled. Only the fields sent in the request are
+// changed.
+//
+// The caller must have the graduationSettings.write permission.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+type SetGraduationSettingsRequest struct {
+	// The number of days a user's rating must stay in the next cohort before they are
+	// notified that they can graduate.
+	SustainedDays *int `json:"sustainedDays"`
+
+	// Whether eligible users are graduated automatically.
+	AutoGraduate *bool `json:"autoGraduate"`
+
+	// How users whose rating dropped below their cohort are handled.
+	DemotionMode *database.DemotionMode `json:"demotionMode"`
+
+	// How far below the lower end of their cohort a user's normalized rating must drop
+	// before they are suggested or moved to a lower cohort.
+	DemotionMargin *int `json:"demotionMargin"`
+
+	// The number of days a user's rating must stay below the demotion margin before they
+	// are suggested or moved to a lower cohort.
+	DemotionSustainedDays *int `json:"demotionSustainedDays"`
+
+	// The reason for the change, which is saved in the audit log.
+	Reason string `json:"reason"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionGraduationSettingsWrite),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := SetGraduationSettingsRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+
+	current, err := repository.GetGraduationSettings()
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	settings := *current
+	if req.SustainedDays != nil {
+		settings.SustainedDays = *req.SustainedDays
+	}
+	if req.AutoGraduate != nil {
+		settings.AutoGraduate = *req.AutoGraduate
+	}
+	if req.DemotionMode != nil {
+		settings.DemotionMode = *req.DemotionMode
+	}
+	if req.DemotionMargin != nil {
+		settings.DemotionMargin = *req.DemotionMargin
+	}
+	if req.DemotionSustainedDays != nil {
+		settings.DemotionSustainedDays = *req.DemotionSustainedDays
+	}
+	settings.UpdatedBy = caller.Username
+	settings.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := settings.Validate(); err != nil {
+		return api.Failure(err), nil
+	}
+	if err := repository.PutGraduationSettings(&settings); err != nil {
+		return api.Failure(err), nil
+	}
+
+	entry := database.NewAuditEntry(caller.Username, database.AuditAction_GraduationSettingsUpdate,
+		database.GraduationSettingsAuditTarget(), req.Reason)
+	before, after := *current, settings
+	before.UpdatedBy, before.UpdatedAt = "", ""
+	after.UpdatedBy, after.UpdatedAt = "", ""
+	if err := repository.CreateAuditEntry(entry, before, after); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
+	return api.Success(&settings), nil
+}
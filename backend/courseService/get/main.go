@@ -25,13 +25,10 @@ type GetCourseResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	courseType := event.PathParameters["type"]
 	id := event.PathParameters["id"]
 	if courseType == "" || id == "" {
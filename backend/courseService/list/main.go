@@ -5,7 +5,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
@@ -25,13 +24,10 @@ func main() {
 	if stage == "prod" {
 		log.SetLevel(log.InfoLevel)
 	}
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
-func handler(ctx context.Context, event events.APIGatewayV2HTTPRequest) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	var courses []database.Course
 	var lastKey string
 	var err error
@@ -18,13 +18,10 @@ type PurchaseCourseResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest(), api.Idempotent(database.DynamoDB)))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 
 	courseType := event.PathParameters["type"]
@@ -9,20 +9,16 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
 var repository database.CourseSetter = database.DynamoDB
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		err := errors.New(403, "Invalid request: username is required", "")
@@ -33,8 +29,8 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if err != nil {
 		return api.Failure(err), nil
 	}
-	if !user.IsCoach {
-		return api.Failure(errors.New(403, "Invalid request: user is not a coach", "")), nil
+	if err := api.Authorize(user, database.PermissionCoursesWrite, ""); err != nil {
+		return api.Failure(err), nil
 	}
 	if user.CoachInfo == nil || user.CoachInfo.StripeId == "" {
 		return api.Failure(errors.New(400, "Invalid request: user must complete Stripe onboarding first", "")), nil
@@ -63,51 +59,60 @@ func checkCourse(user *database.User, course *database.Course) error {
 		return errors.New(403, fmt.Sprintf("Invalid course type: `%s`", course.Type), "")
 	}
 
+	var details []errors.FieldError
+	invalid := func(field string, reason errors.Reason, message string) {
+		details = append(details, errors.FieldError{Field: field, Reason: reason, Message: message})
+	}
+
 	if course.Name == "" {
-		return errors.New(400, "Invalid request: course name cannot be empty", "")
+		invalid("name", errors.ReasonRequired, "course name cannot be empty")
 	}
 
 	if course.Description == "" {
-		return errors.New(400, "Invalid request: course description cannot be empty", "")
+		invalid("description", errors.ReasonRequired, "course description cannot be empty")
 	}
 
 	if !course.Color.IsValid() {
-		return errors.New(400, fmt.Sprintf("Invalid course color: `%s`", course.Color), "")
+		invalid("color", errors.ReasonInvalidType, fmt.Sprintf("invalid course color: `%s`", course.Color))
 	}
 
 	if len(course.Cohorts) == 0 {
-		return errors.New(400, "Invalid request: cohorts cannot be empty", "")
+		invalid("cohorts", errors.ReasonRequired, "cohorts cannot be empty")
 	}
-	for _, c := range course.Cohorts {
+	for i, c := range course.Cohorts {
 		if !c.IsValid() {
-			return errors.New(400, fmt.Sprintf("Invalid cohort: `%s`", c), "")
+			invalid(fmt.Sprintf("cohorts[%d]", i), errors.ReasonInvalidType, fmt.Sprintf("invalid cohort: `%s`", c))
 		}
 	}
 
 	if course.CohortRange == "" {
-		return errors.New(400, "Invalid request: cohortRange cannot be empty", "")
+		invalid("cohortRange", errors.ReasonRequired, "cohortRange cannot be empty")
 	}
 
 	if (course.AvailableForFreeUsers || !course.IncludedWithSubscription) && len(course.PurchaseOptions) == 0 {
-		return errors.New(400, "Invalid request: purchaseOptions cannot be empty when availableForFreeUsers is true or includedWithSubscription is false", "")
+		invalid("purchaseOptions", errors.ReasonRequired, "purchaseOptions cannot be empty when availableForFreeUsers is true or includedWithSubscription is false")
 	}
-	for _, option := range course.PurchaseOptions {
+	for i, option := range course.PurchaseOptions {
+		field := fmt.Sprintf("purchaseOptions[%d]", i)
 		if option.FullPrice < 100 {
-			return errors.New(400, "Invalid request: fullPrice must be at least $1", "")
+			invalid(field+".fullPrice", errors.ReasonOutOfRange, "fullPrice must be at least $1")
 		}
 		if option.CurrentPrice > 0 && option.CurrentPrice < 100 {
-			return errors.New(400, "Invalid request: currentPrice must be at least $1 if set", "")
+			invalid(field+".currentPrice", errors.ReasonOutOfRange, "currentPrice must be at least $1 if set")
 		}
 		if option.CurrentPrice > option.FullPrice {
-			return errors.New(400, "Invalid request: currentPrice must be less than or equal to fullPrice", "")
+			invalid(field+".currentPrice", errors.ReasonOutOfRange, "currentPrice must be less than or equal to fullPrice")
 		}
-		for _, sp := range option.SellingPoints {
+		for j, sp := range option.SellingPoints {
 			if sp.Description == "" {
-				return errors.New(400, "Invalid request: selling point description cannot be empty", "")
+				invalid(fmt.Sprintf("%s.sellingPoints[%d].description", field, j), errors.ReasonRequired, "selling point description cannot be empty")
 			}
 		}
 	}
 
+	if len(details) > 0 {
+		return errors.NewValidation(details...)
+	}
 	return nil
 }
 
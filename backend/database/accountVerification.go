@@ -0,0 +1,44 @@
+package database
+
+import "time"
+
+// AccountVerificationTTL is how long a user has to complete the verification of an account.
+const AccountVerificationTTL = time.Hour
+
+// AccountVerification is a pending proof that a user owns an online account. Lichess
+// accounts are verified through OAuth with PKCE, and chess.com accounts by adding a
+// one-time token to the location of their public profile.
+type AccountVerification struct {
+	// The username of the account being verified, as linked in the user's ratings.
+	Username string `dynamodbav:"username"`
+
+	// For chess.com, the token the user must add to their profile. For Lichess, the
+	// OAuth state.
+	Token string `dynamodbav:"token"`
+
+	// The PKCE code verifier of the Lichess OAuth flow.
+	CodeVerifier string `dynamodbav:"codeVerifier,omitempty"`
+
+	// The URI Lichess redirects the user to after they authorize the Dojo.
+	RedirectUri string `dynamodbav:"redirectUri,omitempty"`
+
+	// When the verification expires, in time.RFC3339 format.
+	ExpiresAt string `dynamodbav:"expiresAt"`
+}
+
+// IsExpired returns true if the verification can no longer be completed at the given time.
+func (v *AccountVerification) IsExpired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, v.ExpiresAt)
+	return err != nil || !now.Before(expiresAt)
+}
+
+// IsVerifiable returns true if the ownership of accounts in the given rating system can be verified.
+func IsVerifiable(system RatingSystem) bool {
+	return system == Lichess || system == Chesscom
+}
+
+// IsAccountVerified returns true if the user has verified their account in the given rating system.
+func (u *User) IsAccountVerified(system RatingSystem) bool {
+	rating := u.Ratings[system]
+	return rating != nil && rating.Username != "" && rating.Verified
+}
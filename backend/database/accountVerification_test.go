@@ -0,0 +1,61 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountVerificationIsExpired(t *testing.T) {
+	now := time.Date(2024, 10, 3, 12, 0, 0, 0, time.UTC)
+
+	table := []struct {
+		expiresAt string
+		want      bool
+	}{
+		{expiresAt: "2024-10-03T12:30:00Z", want: false},
+		{expiresAt: "2024-10-03T12:00:00Z", want: true},
+		{expiresAt: "2024-10-03T11:00:00Z", want: true},
+		{expiresAt: "", want: true},
+	}
+
+	for _, tc := range table {
+		v := &AccountVerification{ExpiresAt: tc.expiresAt}
+		if got := v.IsExpired(now); got != tc.want {
+			t.Errorf("IsExpired(%q) = %t; want %t", tc.expiresAt, got, tc.want)
+		}
+	}
+}
+
+func TestInMemoryAccountVerifications(t *testing.T) {
+	repo := NewInMemory()
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", SubscriptionStatus_Subscribed); err != nil {
+		t.Fatalf("CreateUser(test) got err: %v", err)
+	}
+
+	verifications := map[RatingSystem]*AccountVerification{
+		Chesscom: {Username: "DojoPlayer", Token: "chessdojo-token", ExpiresAt: "2024-10-03T12:00:00Z"},
+	}
+	user, err := repo.UpdateUser("test", &UserUpdate{
+		Ratings:              &map[RatingSystem]*Rating{Chesscom: {Username: "DojoPlayer"}},
+		AccountVerifications: &verifications,
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser(test) got err: %v", err)
+	}
+	if v := user.AccountVerifications[Chesscom]; v == nil || v.Token != "chessdojo-token" {
+		t.Errorf("UpdateUser(test) got verifications %+v; want the chess.com token", user.AccountVerifications)
+	}
+
+	user, err = repo.ModifyUser("test", func(user *User) (*UserUpdate, error) {
+		ratings := user.Ratings
+		ratings[Chesscom].Verified = true
+		verifications := map[RatingSystem]*AccountVerification{}
+		return &UserUpdate{Ratings: &ratings, AccountVerifications: &verifications}, nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyUser(test) got err: %v", err)
+	}
+	if !user.IsAccountVerified(Chesscom) || user.IsAccountVerified(Lichess) || len(user.AccountVerifications) != 0 {
+		t.Errorf("ModifyUser(test) got ratings %+v and verifications %+v; want a verified chess.com account and no verifications", user.Ratings, user.AccountVerifications)
+	}
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/google/uuid"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+type AuditAction string
+
+const (
+	AuditAction_OpenClassicalCloseRegistrations AuditAction = "OPEN_CLASSICAL_CLOSE_REGISTRATIONS"
+	AuditAction_OpenClassicalSetPairings        AuditAction = "OPEN_CLASSICAL_SET_PAIRINGS"
+	AuditAction_OpenClassicalVerifyResult       AuditAction = "OPEN_CLASSICAL_VERIFY_RESULT"
+	AuditAction_OpenClassicalComplete           AuditAction = "OPEN_CLASSICAL_COMPLETE"
+	AuditAction_OpenClassicalBanPlayer          AuditAction = "OPEN_CLASSICAL_BAN_PLAYER"
+	AuditAction_OpenClassicalUnbanPlayer        AuditAction = "OPEN_CLASSICAL_UNBAN_PLAYER"
+	AuditAction_OpenClassicalWithdrawPlayer     AuditAction = "OPEN_CLASSICAL_WITHDRAW_PLAYER"
+	AuditAction_GameReview                      AuditAction = "GAME_REVIEW"
+	AuditAction_ClubApproveJoinRequest          AuditAction = "CLUB_APPROVE_JOIN_REQUEST"
+	AuditAction_ClubRejectJoinRequest           AuditAction = "CLUB_REJECT_JOIN_REQUEST"
+	AuditAction_RoleAssign                      AuditAction = "ROLE_ASSIGN"
+	AuditAction_RoleRevoke                      AuditAction = "ROLE_REVOKE"
+	AuditAction_CohortBoundariesPublish         AuditAction = "COHORT_BOUNDARIES_PUBLISH"
+	AuditAction_RatingAnomalyDismiss            AuditAction = "RATING_ANOMALY_DISMISS"
+	AuditAction_RatingAnomalyEnforce            AuditAction = "RATING_ANOMALY_ENFORCE"
+	AuditAction_GraduationSettingsUpdate        AuditAction = "GRADUATION_SETTINGS_UPDATE"
+)
+
+// auditEntryType is the value of AuditEntry.Type, used as the hash key of the index
+// for querying entries by time.
+const auditEntryType = "AUDIT"
+
+// auditTimeFormat is the fixed-width format of the timestamp prefix of AuditEntry ids,
+// so that ids sort chronologically.
+const auditTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+const auditTableActorIndex = "ActorIndex"
+const auditTableDateIndex = "DateIndex"
+
+// AuditEntry records a single administrative action. Audit entries are never updated
+// or deleted.
+type AuditEntry struct {
+	// The resource the action was performed on, as returned by one of the *AuditTarget
+	// functions. The hash key of the table.
+	Target string `dynamodbav:"target" json:"target"`
+
+	// The id of the entry, formatted as time_uuid so that entries sort chronologically.
+	// The range key of the table and its indices.
+	Id string `dynamodbav:"id" json:"id"`
+
+	// Set to the hardcoded value `AUDIT`. Used as the hash key of the index for querying by date.
+	Type string `dynamodbav:"type" json:"-"`
+
+	// The username of the user who performed the action.
+	Actor string `dynamodbav:"actor" json:"actor"`
+
+	// The action that was performed.
+	Action AuditAction `dynamodbav:"action" json:"action"`
+
+	// The fields of the target changed by the action, mapped by their dot-separated path.
+	// If the changed value is not an object, its only path is `.`.
+	Changes map[string]AuditChange `dynamodbav:"changes,omitempty" json:"changes,omitempty"`
+
+	// The reason given by the actor for the action, if any.
+	Reason string `dynamodbav:"reason,omitempty" json:"reason,omitempty"`
+
+	// The time the action was performed, in time.RFC3339 format.
+	CreatedAt string `dynamodbav:"createdAt" json:"createdAt"`
+}
+
+// AuditChange contains the value of a single field before and after an action.
+// A nil value means the field did not exist.
+type AuditChange struct {
+	Before interface{} `dynamodbav:"before" json:"before"`
+	After  interface{} `dynamodbav:"after" json:"after"`
+}
+
+// OpenClassicalAuditTarget returns the audit target of the open classical starting at startsAt.
+func OpenClassicalAuditTarget(startsAt string) string {
+	return fmt.Sprintf("openClassical:%s", startsAt)
+}
+
+// OpenClassicalPlayerAuditTarget returns the audit target of the open classical player with
+// the provided Lichess username.
+func OpenClassicalPlayerAuditTarget(lichessUsername string) string {
+	return fmt.Sprintf("openClassicalPlayer:%s", strings.ToLower(lichessUsername))
+}
+
+// GameAuditTarget returns the audit target of the game with the provided cohort and id.
+func GameAuditTarget(cohort, id string) string {
+	return fmt.Sprintf("game:%s/%s", cohort, id)
+}
+
+// ClubAuditTarget returns the audit target of the club with the provided id.
+func ClubAuditTarget(id string) string {
+	return fmt.Sprintf("club:%s", id)
+}
+
+// UserAuditTarget returns the audit target of the user with the provided username.
+func UserAuditTarget(username string) string {
+	return fmt.Sprintf("user:%s", username)
+}
+
+// CohortBoundariesAuditTarget returns the audit target of the cohort boundaries taking
+// effect at effectiveAt.
+func CohortBoundariesAuditTarget(effectiveAt string) string {
+	return fmt.Sprintf("cohortBoundaries:%s", effectiveAt)
+}
+
+// GraduationSettingsAuditTarget returns the audit target of the graduation settings.
+func GraduationSettingsAuditTarget() string {
+	return "graduationSettings"
+}
+
+// NewAuditEntry returns an AuditEntry for the provided action, created at the current time.
+// The entry's changes are set by CreateAuditEntry.
+func NewAuditEntry(actor string, action AuditAction, target, reason string) *AuditEntry {
+	now := time.Now().UTC()
+	return &AuditEntry{
+		Target:    target,
+		Id:        fmt.Sprintf("%s_%s", now.Format(auditTimeFormat), uuid.NewString()),
+		Type:      auditEntryType,
+		Actor:     actor,
+		Action:    action,
+		Reason:    reason,
+		CreatedAt: now.Format(time.RFC3339),
+	}
+}
+
+// auditDiff returns the fields which differ between before and after, mapped by their
+// dot-separated path. Objects are compared field by field; all other values, including
+// arrays, are compared as a whole.
+func auditDiff(before, after interface{}) (map[string]AuditChange, error) {
+	b, err := toJsonValue(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toJsonValue(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]AuditChange)
+	diffJsonValues("", b, a, changes)
+	return changes, nil
+}
+
+// toJsonValue converts v to the generic value produced by encoding/json.
+func toJsonValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+func diffJsonValues(path string, before, after interface{}, changes map[string]AuditChange) {
+	bm, bok := before.(map[string]interface{})
+	am, aok := after.(map[string]interface{})
+	if !bok || !aok {
+		if !reflect.DeepEqual(before, after) {
+			if path == "" {
+				path = "."
+			}
+			changes[path] = AuditChange{Before: before, After: after}
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(bm)+len(am))
+	for k := range bm {
+		keys = append(keys, k)
+	}
+	for k := range am {
+		if _, ok := bm[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		diffJsonValues(p, bm[k], am[k], changes)
+	}
+}
+
+type AuditEntryCreator interface {
+	// CreateAuditEntry saves the provided entry with its changes set to the difference between
+	// before and after, which can be nil if the target did not exist before or after the action.
+	CreateAuditEntry(entry *AuditEntry, before, after interface{}) error
+}
+
+// CreateAuditEntry saves the provided entry with its changes set to the difference between
+// before and after, which can be nil if the target did not exist before or after the action.
+func (repo *dynamoRepository) CreateAuditEntry(entry *AuditEntry, before, after interface{}) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to diff audit entry", err)
+	}
+	entry.Changes = changes
+
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to marshal audit entry", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+		TableName:           aws.String(auditTable),
+	}
+	_, err = repo.svc.PutItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+}
+
+// AuditQuery selects the audit entries returned by ListAuditEntries. At most one of Actor
+// and Target can be set. If neither is set, entries are returned for all actors and targets.
+type AuditQuery struct {
+	// Only return entries performed by this actor.
+	Actor string
+
+	// Only return entries performed on this target.
+	Target string
+
+	// Only return entries created at or after this time.
+	StartTime time.Time
+
+	// Only return entries created before this time. If zero, there is no upper bound.
+	EndTime time.Time
+}
+
+type AuditEntryLister interface {
+	// ListAuditEntries returns the audit entries matching the provided query, newest first, up to
+	// 1MB of data. startKey is an optional parameter that can be used to perform pagination.
+	// The list of entries and the next start key are returned.
+	ListAuditEntries(query AuditQuery, startKey string) ([]AuditEntry, string, error)
+}
+
+// ListAuditEntries returns the audit entries matching the provided query, newest first, up to
+// 1MB of data. startKey is an optional parameter that can be used to perform pagination.
+// The list of entries and the next start key are returned.
+func (repo *dynamoRepository) ListAuditEntries(query AuditQuery, startKey string) ([]AuditEntry, string, error) {
+	if query.Actor != "" && query.Target != "" {
+		return nil, "", errors.New(400, "Invalid request: actor and target cannot both be set", "")
+	}
+	if !query.EndTime.IsZero() && query.EndTime.Before(query.StartTime) {
+		return nil, "", errors.New(400, "Invalid request: endTime must be after startTime", "")
+	}
+
+	input := &dynamodb.QueryInput{
+		ScanIndexForward: aws.Bool(false),
+		TableName:        aws.String(auditTable),
+	}
+
+	var keyCondition expression.KeyConditionBuilder
+	switch {
+	case query.Target != "":
+		keyCondition = expression.Key("target").Equal(expression.Value(query.Target))
+	case query.Actor != "":
+		keyCondition = expression.Key("actor").Equal(expression.Value(query.Actor))
+		input.IndexName = aws.String(auditTableActorIndex)
+	default:
+		keyCondition = expression.Key("type").Equal(expression.Value(auditEntryType))
+		input.IndexName = aws.String(auditTableDateIndex)
+	}
+
+	// Ids start with the time the entry was created, so the time range is a range of ids.
+	// Ids created at exactly EndTime sort after the bare timestamp, so the end is exclusive.
+	start := query.StartTime.UTC().Format(auditTimeFormat)
+	if query.EndTime.IsZero() {
+		keyCondition = keyCondition.And(expression.Key("id").GreaterThanEqual(expression.Value(start)))
+	} else {
+		end := query.EndTime.UTC().Format(auditTimeFormat)
+		keyCondition = keyCondition.And(expression.Key("id").Between(expression.Value(start), expression.Value(end)))
+	}
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, "", errors.Wrap(500, "Temporary server error", "DynamoDB expression building error", err)
+	}
+	input.KeyConditionExpression = expr.KeyCondition()
+	input.ExpressionAttributeNames = expr.Names()
+	input.ExpressionAttributeValues = expr.Values()
+
+	var entries []AuditEntry
+	lastKey, err := repo.query(input, startKey, &entries)
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, lastKey, nil
+}
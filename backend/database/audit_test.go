@@ -0,0 +1,98 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuditDiff(t *testing.T) {
+	table := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]AuditChange
+	}{
+		{
+			name:   "Unchanged",
+			before: GraduationSettings{SustainedDays: 7},
+			after:  GraduationSettings{SustainedDays: 7},
+			want:   map[string]AuditChange{},
+		},
+		{
+			name:   "ChangedFields",
+			before: GraduationSettings{SustainedDays: 7, DemotionMode: DemotionMode_Suggest},
+			after:  GraduationSettings{SustainedDays: 3, DemotionMode: DemotionMode_Suggest, AutoGraduate: true},
+			want: map[string]AuditChange{
+				"sustainedDays": {Before: 7.0, After: 3.0},
+				"autoGraduate":  {Before: false, After: true},
+			},
+		},
+		{
+			name:   "NestedFields",
+			before: map[string]interface{}{"player": map[string]interface{}{"banned": false, "name": "a"}},
+			after:  map[string]interface{}{"player": map[string]interface{}{"banned": true, "name": "a", "reason": "cheating"}},
+			want: map[string]AuditChange{
+				"player.banned": {Before: false, After: true},
+				"player.reason": {Before: nil, After: "cheating"},
+			},
+		},
+		{
+			name:   "Arrays",
+			before: map[string]interface{}{"roles": []string{"coach"}},
+			after:  map[string]interface{}{"roles": []string{"coach", "admin"}},
+			want: map[string]AuditChange{
+				"roles": {Before: []interface{}{"coach"}, After: []interface{}{"coach", "admin"}},
+			},
+		},
+		{
+			name:   "Created",
+			before: nil,
+			after:  map[string]interface{}{"id": "1"},
+			want: map[string]AuditChange{
+				".": {Before: nil, After: map[string]interface{}{"id": "1"}},
+			},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := auditDiff(tc.before, tc.after)
+			if err != nil {
+				t.Fatalf("auditDiff got err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("auditDiff got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCreateAuditEntryAppendOnly(t *testing.T) {
+	repo := NewInMemory()
+	target := GraduationSettingsAuditTarget()
+
+	entry := NewAuditEntry("admin", AuditAction_GraduationSettingsUpdate, target, "faster graduations")
+	if err := repo.CreateAuditEntry(entry, GraduationSettings{SustainedDays: 7}, GraduationSettings{SustainedDays: 3}); err != nil {
+		t.Fatalf("CreateAuditEntry got err: %v", err)
+	}
+
+	overwrite := *entry
+	overwrite.Actor = "attacker"
+	overwrite.Reason = ""
+	if err := repo.CreateAuditEntry(&overwrite, GraduationSettings{SustainedDays: 3}, GraduationSettings{SustainedDays: 90}); err == nil {
+		t.Errorf("CreateAuditEntry with an existing id got nil err; want an error")
+	}
+
+	entries, _, err := repo.ListAuditEntries(AuditQuery{Target: target}, "")
+	if err != nil {
+		t.Fatalf("ListAuditEntries got err: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("ListAuditEntries got %d entries; want 1", len(entries))
+	}
+	got := entries[0]
+	want := AuditChange{Before: 7.0, After: 3.0}
+	if got.Actor != "admin" || got.Reason != "faster graduations" || len(got.Changes) != 1 || !reflect.DeepEqual(got.Changes["sustainedDays"], want) {
+		t.Errorf("ListAuditEntries got %+v; want the original entry changing sustainedDays from 7 to 3", got)
+	}
+}
@@ -0,0 +1,366 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+)
+
+// BatchRetryPolicy controls how batch requests retry items that DynamoDB did not
+// process, which usually happens when the table is being throttled.
+type BatchRetryPolicy struct {
+	// The maximum number of requests sent for a single batch, including the first.
+	MaxAttempts int
+
+	// The delay before the first retry. Each following retry doubles the delay.
+	BaseDelay time.Duration
+
+	// The maximum delay before a single retry.
+	MaxDelay time.Duration
+
+	// The maximum total time spent waiting between retries of a single batch.
+	Budget time.Duration
+}
+
+// DefaultBatchRetryPolicy is the BatchRetryPolicy used unless another one is set
+// with SetBatchRetryPolicy.
+var DefaultBatchRetryPolicy = BatchRetryPolicy{
+	MaxAttempts: 10,
+	BaseDelay:   50 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Budget:      30 * time.Second,
+}
+
+// SetBatchRetryPolicy sets the policy used to retry unprocessed items in batch requests.
+func (repo *dynamoRepository) SetBatchRetryPolicy(policy BatchRetryPolicy) {
+	repo.batchRetry = &policy
+}
+
+func (repo *dynamoRepository) batchRetryPolicy() BatchRetryPolicy {
+	if repo.batchRetry != nil {
+		return *repo.batchRetry
+	}
+	return DefaultBatchRetryPolicy
+}
+
+// batchRetrier tracks the attempts and waiting time of a single batch.
+type batchRetrier struct {
+	policy   BatchRetryPolicy
+	sleep    func(time.Duration)
+	attempts int
+	waited   time.Duration
+}
+
+func (repo *dynamoRepository) newBatchRetrier() *batchRetrier {
+	sleep := repo.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	return &batchRetrier{policy: repo.batchRetryPolicy(), sleep: sleep}
+}
+
+// next records an attempt. If another attempt is allowed, it waits using exponential
+// backoff with full jitter and returns true. Otherwise, it returns false immediately.
+func (r *batchRetrier) next() bool {
+	r.attempts++
+	if r.attempts >= r.policy.MaxAttempts {
+		return false
+	}
+
+	delay := r.policy.BaseDelay << (r.attempts - 1)
+	if delay > r.policy.MaxDelay || delay <= 0 {
+		delay = r.policy.MaxDelay
+	}
+	delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	if r.waited+delay > r.policy.Budget {
+		return false
+	}
+
+	r.waited += delay
+	r.sleep(delay)
+	return true
+}
+
+// BatchItemFailure describes an item in a batch request that was not processed.
+type BatchItemFailure struct {
+	// The table of the item.
+	Table string `json:"table"`
+
+	// The primary key of the item, formatted as name=value pairs.
+	Key string `json:"key"`
+
+	// The DynamoDB error code explaining why the item was not processed.
+	Reason string `json:"reason"`
+}
+
+// BatchError is the cause of the error returned by a batch operation when some of
+// its items could not be processed after retrying.
+type BatchError struct {
+	// The number of items that were processed successfully.
+	Processed int `json:"processed"`
+
+	// The items that were not processed.
+	Failures []BatchItemFailure `json:"failures"`
+}
+
+func (e *BatchError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d of %d items were not processed", len(e.Failures), e.Processed+len(e.Failures)))
+	for i, f := range e.Failures {
+		if i == 0 {
+			sb.WriteString(": ")
+		} else {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(fmt.Sprintf("%s[%s] %s", f.Table, f.Key, f.Reason))
+	}
+	return sb.String()
+}
+
+// BatchFailures returns the items that were not processed if err was returned by a
+// batch operation. Otherwise, nil is returned.
+func BatchFailures(err error) []BatchItemFailure {
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Failures
+	}
+	return nil
+}
+
+// batchFailure returns the error returned by a batch operation that failed to process
+// the given items.
+func batchFailure(operation string, processed int, failures []BatchItemFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+	return errors.Wrap(500, "Temporary server error", fmt.Sprintf("%s left unprocessed items after retrying", operation),
+		&BatchError{Processed: processed, Failures: failures})
+}
+
+// tableKeyAttributes contains the primary key attribute names of each table, keyed by the
+// table name without the stage prefix.
+var tableKeyAttributes = func() map[string][]string {
+	keys := make(map[string][]string)
+	for suffix, schema := range memoryTableSchemas() {
+		keys[suffix] = []string{schema.hashKey}
+		if schema.rangeKey != "" {
+			keys[suffix] = append(keys[suffix], schema.rangeKey)
+		}
+	}
+	return keys
+}()
+
+// formatKey returns the primary key of the given item in the given table, formatted as
+// name=value pairs.
+func formatKey(tableName string, item map[string]*dynamodb.AttributeValue) string {
+	_, suffix, _ := strings.Cut(tableName, "-")
+	names, ok := tableKeyAttributes[suffix]
+	if !ok {
+		for name := range item {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		if v := item[name]; v != nil {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", name, formatScalar(v)))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatScalar(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return *v.S
+	case v.N != nil:
+		return *v.N
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// isRetryableBatchError returns true if the given error returned by a batch request
+// is caused by throttling or a temporary server error.
+func isRetryableBatchError(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return isRetryableCode(aerr.Code())
+	}
+	return false
+}
+
+func isRetryableCode(code string) bool {
+	switch code {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		dynamodb.ErrCodeInternalServerError,
+		dynamodb.ErrCodeTransactionConflictException,
+		"ThrottlingException",
+		// PartiQL statements report errors with these codes.
+		dynamodb.BatchStatementErrorCodeEnumProvisionedThroughputExceeded,
+		dynamodb.BatchStatementErrorCodeEnumThrottlingError,
+		dynamodb.BatchStatementErrorCodeEnumTransactionConflict:
+		return true
+	}
+	return false
+}
+
+// batchWrite handles sending a DynamoDB BatchWriteItem request using the provided slice of WriteRequests.
+// The WriteRequests are mapped to the provided table name. Unprocessed items are retried according to
+// the repository's BatchRetryPolicy. If some items are still unprocessed, the returned error's cause is
+// a *BatchError listing them.
+func (repo *dynamoRepository) batchWrite(reqs []*dynamodb.WriteRequest, tableName string) error {
+	retrier := repo.newBatchRetrier()
+	pending := reqs
+	reason := dynamodb.ErrCodeProvisionedThroughputExceededException
+
+	for len(pending) > 0 {
+		output, err := repo.svc.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems:           map[string][]*dynamodb.WriteRequest{tableName: pending},
+			ReturnConsumedCapacity: aws.String("NONE"),
+		})
+		if err != nil {
+			if !isRetryableBatchError(err) {
+				return errors.Wrap(500, "Temporary server error", "Failed DynamoDB BatchWriteItem", err)
+			}
+			reason = err.(awserr.Error).Code()
+		} else {
+			pending = output.UnprocessedItems[tableName]
+			reason = dynamodb.ErrCodeProvisionedThroughputExceededException
+		}
+
+		if len(pending) == 0 || !retrier.next() {
+			break
+		}
+		log.Debugf("Retrying %d unprocessed items in BatchWriteItem to %s", len(pending), tableName)
+	}
+
+	failures := make([]BatchItemFailure, 0, len(pending))
+	for _, req := range pending {
+		var key map[string]*dynamodb.AttributeValue
+		if req.PutRequest != nil {
+			key = req.PutRequest.Item
+		} else if req.DeleteRequest != nil {
+			key = req.DeleteRequest.Key
+		}
+		failures = append(failures, BatchItemFailure{Table: tableName, Key: formatKey(tableName, key), Reason: reason})
+	}
+	return batchFailure("BatchWriteItem", len(reqs)-len(pending), failures)
+}
+
+// batchGet handles sending a DynamoDB BatchGetItem request for the provided keys in the provided table.
+// Unprocessed keys are retried according to the repository's BatchRetryPolicy. The items that were
+// fetched are returned. If some keys are still unprocessed, they are returned along with an error
+// whose cause is a *BatchError listing them.
+func (repo *dynamoRepository) batchGet(tableName string, keys *dynamodb.KeysAndAttributes) ([]map[string]*dynamodb.AttributeValue, error) {
+	retrier := repo.newBatchRetrier()
+	total := len(keys.Keys)
+	pending := keys
+	reason := dynamodb.ErrCodeProvisionedThroughputExceededException
+	var items []map[string]*dynamodb.AttributeValue
+
+	for pending != nil && len(pending.Keys) > 0 {
+		output, err := repo.svc.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{tableName: pending},
+		})
+		if err != nil {
+			if !isRetryableBatchError(err) {
+				return nil, errors.Wrap(500, "Temporary server error", "Failed call to BatchGetItem", err)
+			}
+			reason = err.(awserr.Error).Code()
+		} else {
+			items = append(items, output.Responses[tableName]...)
+			pending = output.UnprocessedKeys[tableName]
+			reason = dynamodb.ErrCodeProvisionedThroughputExceededException
+		}
+
+		if pending == nil || len(pending.Keys) == 0 || !retrier.next() {
+			break
+		}
+		log.Debugf("Retrying %d unprocessed keys in BatchGetItem from %s", len(pending.Keys), tableName)
+	}
+
+	if pending == nil || len(pending.Keys) == 0 {
+		return items, nil
+	}
+
+	failures := make([]BatchItemFailure, 0, len(pending.Keys))
+	for _, key := range pending.Keys {
+		failures = append(failures, BatchItemFailure{Table: tableName, Key: formatKey(tableName, key), Reason: reason})
+	}
+	return items, batchFailure("BatchGetItem", total-len(failures), failures)
+}
+
+// batchExecute handles sending a DynamoDB BatchExecuteStatement request with the provided
+// PartiQL statements. keys contains a description of the item updated by each statement,
+// which is used in the failure report. Statements which fail due to throttling are retried
+// according to the repository's BatchRetryPolicy. If some statements still fail, the
+// returned error's cause is a *BatchError listing them.
+func (repo *dynamoRepository) batchExecute(tableName string, statements []*dynamodb.BatchStatementRequest, keys []string) error {
+	retrier := repo.newBatchRetrier()
+	pending := make([]int, len(statements))
+	for i := range statements {
+		pending[i] = i
+	}
+	reasons := make(map[int]string)
+	var failures []BatchItemFailure
+
+	for len(pending) > 0 {
+		input := &dynamodb.BatchExecuteStatementInput{Statements: make([]*dynamodb.BatchStatementRequest, 0, len(pending))}
+		for _, i := range pending {
+			input.Statements = append(input.Statements, statements[i])
+		}
+
+		log.Debugf("Batch execute statement input: %v", input)
+		output, err := repo.svc.BatchExecuteStatement(input)
+		log.Debugf("Batch execute statement output: %v", output)
+
+		var retry []int
+		if err != nil {
+			if !isRetryableBatchError(err) {
+				return errors.Wrap(500, "Temporary server error", "Failed BatchExecuteStatement", err)
+			}
+			for _, i := range pending {
+				reasons[i] = err.(awserr.Error).Code()
+			}
+			retry = pending
+		} else {
+			for j, resp := range output.Responses {
+				if j >= len(pending) || resp.Error == nil {
+					continue
+				}
+				i := pending[j]
+				code := aws.StringValue(resp.Error.Code)
+				if isRetryableCode(code) {
+					reasons[i] = code
+					retry = append(retry, i)
+				} else {
+					failures = append(failures, BatchItemFailure{Table: tableName, Key: keys[i], Reason: code})
+				}
+			}
+		}
+
+		pending = retry
+		if len(pending) == 0 || !retrier.next() {
+			break
+		}
+		log.Debugf("Retrying %d throttled statements in BatchExecuteStatement", len(pending))
+	}
+
+	for _, i := range pending {
+		failures = append(failures, BatchItemFailure{Table: tableName, Key: keys[i], Reason: reasons[i]})
+	}
+	return batchFailure("BatchExecuteStatement", len(statements)-len(failures), failures)
+}
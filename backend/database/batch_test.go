@@ -0,0 +1,223 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// throttledDynamo wraps the in-memory DynamoDB and processes at most perCall items of each
+// batch request, returning the rest as unprocessed. Items owned by stuck are never processed.
+type throttledDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	perCall int
+	stuck   string
+	calls   int
+}
+
+func (t *throttledDynamo) isStuck(item map[string]*dynamodb.AttributeValue) bool {
+	for _, name := range []string{"owner", "username"} {
+		if v := item[name]; v != nil && aws.StringValue(v.S) == t.stuck {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *throttledDynamo) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	t.calls++
+	processed := map[string][]*dynamodb.WriteRequest{}
+	unprocessed := map[string][]*dynamodb.WriteRequest{}
+	for table, reqs := range input.RequestItems {
+		for _, req := range reqs {
+			if len(processed[table]) < t.perCall && !t.isStuck(req.PutRequest.Item) {
+				processed[table] = append(processed[table], req)
+			} else {
+				unprocessed[table] = append(unprocessed[table], req)
+			}
+		}
+	}
+	if len(processed) > 0 {
+		if _, err := t.DynamoDBAPI.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: processed}); err != nil {
+			return nil, err
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+func (t *throttledDynamo) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	t.calls++
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]*dynamodb.AttributeValue{},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}
+	for table, keys := range input.RequestItems {
+		processed := &dynamodb.KeysAndAttributes{ProjectionExpression: keys.ProjectionExpression, ExpressionAttributeNames: keys.ExpressionAttributeNames}
+		unprocessed := &dynamodb.KeysAndAttributes{ProjectionExpression: keys.ProjectionExpression, ExpressionAttributeNames: keys.ExpressionAttributeNames}
+		for _, key := range keys.Keys {
+			if len(processed.Keys) < t.perCall && !t.isStuck(key) {
+				processed.Keys = append(processed.Keys, key)
+			} else {
+				unprocessed.Keys = append(unprocessed.Keys, key)
+			}
+		}
+		if len(processed.Keys) > 0 {
+			result, err := t.DynamoDBAPI.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{table: processed}})
+			if err != nil {
+				return nil, err
+			}
+			output.Responses[table] = result.Responses[table]
+		}
+		if len(unprocessed.Keys) > 0 {
+			output.UnprocessedKeys[table] = unprocessed
+		}
+	}
+	return output, nil
+}
+
+func (t *throttledDynamo) BatchExecuteStatement(input *dynamodb.BatchExecuteStatementInput) (*dynamodb.BatchExecuteStatementOutput, error) {
+	t.calls++
+	output := &dynamodb.BatchExecuteStatementOutput{}
+	for i, statement := range input.Statements {
+		stuck := false
+		for _, p := range statement.Parameters {
+			stuck = stuck || aws.StringValue(p.S) == t.stuck
+		}
+		if i >= t.perCall || stuck {
+			output.Responses = append(output.Responses, &dynamodb.BatchStatementResponse{
+				Error: &dynamodb.BatchStatementError{Code: aws.String(dynamodb.BatchStatementErrorCodeEnumThrottlingError)},
+			})
+			continue
+		}
+		result, err := t.DynamoDBAPI.BatchExecuteStatement(&dynamodb.BatchExecuteStatementInput{Statements: []*dynamodb.BatchStatementRequest{statement}})
+		if err != nil {
+			return nil, err
+		}
+		output.Responses = append(output.Responses, result.Responses...)
+	}
+	return output, nil
+}
+
+func newThrottledRepository(perCall int, stuck string) (*dynamoRepository, *throttledDynamo, *[]time.Duration) {
+	svc := &throttledDynamo{DynamoDBAPI: NewMemoryDynamoDB(), perCall: perCall, stuck: stuck}
+	var delays []time.Duration
+	repo := &dynamoRepository{svc: svc, sleep: func(d time.Duration) { delays = append(delays, d) }}
+	repo.SetBatchRetryPolicy(BatchRetryPolicy{MaxAttempts: 20, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Budget: time.Second})
+	return repo, svc, &delays
+}
+
+func timelineEntries(owners ...string) []*TimelineEntry {
+	entries := make([]*TimelineEntry, 0, len(owners))
+	for i, owner := range owners {
+		entries = append(entries, &TimelineEntry{TimelineEntryKey: TimelineEntryKey{Owner: owner, Id: fmt.Sprintf("2024-01-01_%d", i)}})
+	}
+	return entries
+}
+
+func TestBatchWriteRetriesUnprocessedItems(t *testing.T) {
+	repo, svc, delays := newThrottledRepository(4, "")
+
+	owners := make([]string, 30)
+	for i := range owners {
+		owners[i] = fmt.Sprintf("user%d", i)
+	}
+	updated, err := repo.PutTimelineEntries(timelineEntries(owners...))
+	if err != nil {
+		t.Fatalf("PutTimelineEntries got err: %v", err)
+	}
+	if updated != 30 {
+		t.Errorf("PutTimelineEntries got %d updated; want 30", updated)
+	}
+	if svc.calls != 9 {
+		t.Errorf("PutTimelineEntries made %d calls; want 9", svc.calls)
+	}
+	for _, d := range *delays {
+		if d < 0 || d > 10*time.Millisecond {
+			t.Errorf("PutTimelineEntries slept %v; want between 0 and the max delay", d)
+		}
+	}
+
+	keys := make(map[string]TimelineEntryKey)
+	for _, e := range timelineEntries(owners[:10]...) {
+		keys[e.Owner] = e.TimelineEntryKey
+	}
+	entries, err := repo.BatchGetTimelineEntries(keys)
+	if err != nil {
+		t.Fatalf("BatchGetTimelineEntries got err: %v", err)
+	}
+	if len(entries) != 10 {
+		t.Errorf("BatchGetTimelineEntries got %d entries; want 10", len(entries))
+	}
+}
+
+func TestBatchWriteReportsFailures(t *testing.T) {
+	repo, _, _ := newThrottledRepository(25, "stuck")
+
+	updated, err := repo.PutTimelineEntries(timelineEntries("a", "stuck", "b"))
+	if err == nil {
+		t.Fatal("PutTimelineEntries got nil err; want failure report")
+	}
+	if updated != 2 {
+		t.Errorf("PutTimelineEntries got %d updated; want 2", updated)
+	}
+
+	failures := BatchFailures(err)
+	want := BatchItemFailure{Table: timelineTable, Key: "owner=stuck,id=2024-01-01_1", Reason: dynamodb.ErrCodeProvisionedThroughputExceededException}
+	if len(failures) != 1 || failures[0] != want {
+		t.Errorf("BatchFailures got %+v; want [%+v]", failures, want)
+	}
+
+	keys := map[string]TimelineEntryKey{}
+	for _, e := range timelineEntries("a", "stuck") {
+		keys[e.Owner] = e.TimelineEntryKey
+	}
+	if _, err := repo.BatchGetTimelineEntries(keys); len(BatchFailures(err)) != 1 {
+		t.Errorf("BatchGetTimelineEntries got err %v; want 1 failure", err)
+	}
+}
+
+func TestBatchExecuteRetriesThrottledStatements(t *testing.T) {
+	repo, _, _ := newThrottledRepository(2, "stuck")
+
+	for _, username := range []string{"a", "b", "c", "stuck"} {
+		if _, err := repo.CreateUser(username, username+"@example.com", username, SubscriptionStatus_Subscribed); err != nil {
+			t.Fatalf("CreateUser(%s) got err: %v", username, err)
+		}
+	}
+
+	var users []*User
+	for _, username := range []string{"a", "b", "stuck", "c"} {
+		users = append(users, &User{Username: username, Version: 1, Ratings: map[RatingSystem]*Rating{Lichess: {CurrentRating: 1500}}})
+	}
+	err := repo.UpdateUserRatings(users)
+	failures := BatchFailures(err)
+	if len(failures) != 1 || failures[0].Key != "username=stuck" {
+		t.Fatalf("UpdateUserRatings got failures %+v (err %v); want stuck", failures, err)
+	}
+
+	for _, username := range []string{"a", "b", "c"} {
+		user, err := repo.GetUser(username)
+		if err != nil {
+			t.Fatalf("GetUser(%s) got err: %v", username, err)
+		}
+		if user.Ratings[Lichess] == nil || user.Ratings[Lichess].CurrentRating != 1500 {
+			t.Errorf("GetUser(%s) got ratings %+v; want lichess rating 1500", username, user.Ratings)
+		}
+	}
+}
+
+func TestBatchRetryBudget(t *testing.T) {
+	repo, svc, _ := newThrottledRepository(25, "stuck")
+	repo.SetBatchRetryPolicy(BatchRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: time.Second})
+
+	if _, err := repo.PutTimelineEntries(timelineEntries("stuck")); err == nil {
+		t.Fatal("PutTimelineEntries got nil err; want failure report")
+	}
+	if svc.calls != 3 {
+		t.Errorf("PutTimelineEntries made %d calls; want 3", svc.calls)
+	}
+}
@@ -166,8 +166,9 @@ func (repo *dynamoRepository) CreateClub(club *Club) error {
 	return nil
 }
 
-// Applies the given update to the given club. The club after the update is returned.
-func (repo *dynamoRepository) UpdateClub(id string, caller string, update *ClubUpdate) (*Club, error) {
+// Applies the given update to the given club. The update is only applied if the club is still
+// owned by the given owner. The club after the update is returned.
+func (repo *dynamoRepository) UpdateClub(id string, owner string, update *ClubUpdate) (*Club, error) {
 	update.UpdatedAt = aws.String(time.Now().Format(time.RFC3339))
 
 	av, err := dynamodbattribute.Marshal(update)
@@ -189,7 +190,7 @@ func (repo *dynamoRepository) UpdateClub(id string, caller string, update *ClubU
 	exprAttrNames["#owner"] = aws.String("owner")
 
 	exprAttrValues := expr.Values()
-	exprAttrValues[":caller"] = &dynamodb.AttributeValue{S: aws.String(caller)}
+	exprAttrValues[":owner"] = &dynamodb.AttributeValue{S: aws.String(owner)}
 
 	input := &dynamodb.UpdateItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
@@ -198,14 +199,14 @@ func (repo *dynamoRepository) UpdateClub(id string, caller string, update *ClubU
 		ExpressionAttributeNames:  exprAttrNames,
 		ExpressionAttributeValues: exprAttrValues,
 		UpdateExpression:          expr.Update(),
-		ConditionExpression:       aws.String("attribute_exists(id) AND #owner = :caller"),
+		ConditionExpression:       aws.String("attribute_exists(id) AND #owner = :owner"),
 		TableName:                 aws.String(clubTable),
 		ReturnValues:              aws.String("ALL_NEW"),
 	}
 	club := &Club{}
 	if err := repo.updateItem(input, club); err != nil {
 		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: club not found", "DynamoDB conditional check failed", err)
+			return nil, errors.Wrap(404, "Invalid request: club not found", "DynamoDB conditional check failed", err, errors.WithReason(errors.ReasonClubNotFound))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem", err)
 	}
@@ -283,11 +284,10 @@ func (repo *dynamoRepository) BatchGetClubs(ids []string) ([]Club, error) {
 		input.RequestItems[clubTable].Keys = append(input.RequestItems[clubTable].Keys, key)
 	}
 
-	result, err := repo.svc.BatchGetItem(input)
+	list, err := repo.batchGet(clubTable, input.RequestItems[clubTable])
 	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed call to BatchGetItem", err)
+		return nil, err
 	}
-	list := result.Responses[clubTable]
 
 	var clubs []Club
 	if err := dynamodbattribute.UnmarshalListOfMaps(list, &clubs); err != nil {
@@ -334,7 +334,7 @@ func (repo *dynamoRepository) JoinClub(id string, username string, isFreeTier bo
 	club := &Club{}
 	if err := repo.updateItem(input, club); err != nil {
 		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: club not found, you are already a member or you do not have permission to join", "DynamoDB conditional check failed", err)
+			return nil, errors.Wrap(404, "Invalid request: club not found, you are already a member or you do not have permission to join", "DynamoDB conditional check failed", err, errors.WithReason(errors.ReasonClubJoinForbidden))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed to update club", err)
 	}
@@ -385,21 +385,21 @@ func (repo *dynamoRepository) RequestToJoinClub(id string, request *ClubJoinRequ
 	club := &Club{}
 	if err := repo.updateItem(input, club); err != nil {
 		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: club does not exist, you have already requested to join or you do not have permission to request to join", "DynamoDB conditional check failed", err)
+			return nil, errors.Wrap(404, "Invalid request: club does not exist, you have already requested to join or you do not have permission to request to join", "DynamoDB conditional check failed", err, errors.WithReason(errors.ReasonClubJoinForbidden))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed to update club", err)
 	}
 	return club, nil
 }
 
-// Converts a join request with the given username into a member for the given club. The club
-// after updating is returned.
-func (repo *dynamoRepository) ApproveClubJoinRequest(id, username, caller string) (*Club, error) {
+// Converts a join request with the given username into a member for the given club. The request
+// is only approved if the club is still owned by the given owner. The club after updating is returned.
+func (repo *dynamoRepository) ApproveClubJoinRequest(id, username, owner string) (*Club, error) {
 	input := &dynamodb.UpdateItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {S: aws.String(id)},
 		},
-		ConditionExpression: aws.String("attribute_exists(#requests.#username) AND #owner = :caller"),
+		ConditionExpression: aws.String("attribute_exists(#requests.#username) AND #owner = :owner"),
 		UpdateExpression:    aws.String("REMOVE #requests.#username SET #members.#username = :member ADD #memberCount :q"),
 		ExpressionAttributeNames: map[string]*string{
 			"#requests":    aws.String("joinRequests"),
@@ -409,8 +409,8 @@ func (repo *dynamoRepository) ApproveClubJoinRequest(id, username, caller string
 			"#memberCount": aws.String("memberCount"),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":caller": {S: aws.String(caller)},
-			":q":      {N: aws.String("1")},
+			":owner": {S: aws.String(owner)},
+			":q":     {N: aws.String("1")},
 			":member": {M: map[string]*dynamodb.AttributeValue{
 				"username": {S: aws.String(username)},
 				"joinedAt": {S: aws.String(time.Now().Format(time.RFC3339))},
@@ -423,7 +423,7 @@ func (repo *dynamoRepository) ApproveClubJoinRequest(id, username, caller string
 	club := &Club{}
 	if err := repo.updateItem(input, club); err != nil {
 		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: club not found", "DynamoDB conditional check failed", err)
+			return nil, errors.Wrap(404, "Invalid request: club not found", "DynamoDB conditional check failed", err, errors.WithReason(errors.ReasonClubNotFound))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem", err)
 	}
@@ -457,14 +457,14 @@ func (repo *dynamoRepository) DeleteClubJoinRequest(id string, username string)
 	return club, nil
 }
 
-// Marks the join request with the given club id and username as rejected. The club after updating is returned.
-// The caller must be the owner of the club.
-func (repo *dynamoRepository) RejectClubJoinRequest(id, username, caller string) (*Club, error) {
+// Marks the join request with the given club id and username as rejected. The request is only
+// rejected if the club is still owned by the given owner. The club after updating is returned.
+func (repo *dynamoRepository) RejectClubJoinRequest(id, username, owner string) (*Club, error) {
 	input := &dynamodb.UpdateItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {S: aws.String(id)},
 		},
-		ConditionExpression: aws.String("attribute_exists(#requests.#username) AND #owner = :caller"),
+		ConditionExpression: aws.String("attribute_exists(#requests.#username) AND #owner = :owner"),
 		UpdateExpression:    aws.String("SET #requests.#username.#status = :rejected"),
 		ExpressionAttributeNames: map[string]*string{
 			"#requests": aws.String("joinRequests"),
@@ -474,7 +474,7 @@ func (repo *dynamoRepository) RejectClubJoinRequest(id, username, caller string)
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":rejected": {S: aws.String(ClubJoinRequestStatus_Rejected)},
-			":caller":   {S: aws.String(caller)},
+			":owner":    {S: aws.String(owner)},
 		},
 		TableName:    aws.String(clubTable),
 		ReturnValues: aws.String("ALL_NEW"),
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+)
+
+type CohortBoundariesStatus string
+
+const (
+	// The boundaries are a proposed change, which has no effect until it is published.
+	CohortBoundariesStatus_Proposed CohortBoundariesStatus = "PROPOSED"
+
+	// The boundaries are used for dates after their EffectiveAt, until the next
+	// published version takes effect.
+	CohortBoundariesStatus_Published CohortBoundariesStatus = "PUBLISHED"
+)
+
+// CohortBoundaries is a version of the rating boundaries of each cohort. The boundaries
+// of each rating system contain the rating at the upper end of each cohort in Cohorts,
+// except the last. Published versions are never modified, so that historical data is
+// always interpreted using the boundaries in effect at the time.
+type CohortBoundaries struct {
+	// The status of the version. The hash key of the table.
+	Status CohortBoundariesStatus `dynamodbav:"status" json:"status"`
+
+	// The id of the version. For published versions, this is the EffectiveAt date. For
+	// proposed versions, this is a v4 UUID. The range key of the table.
+	Id string `dynamodbav:"id" json:"id"`
+
+	// The boundaries of each rating system.
+	Boundaries map[RatingSystem][]int `dynamodbav:"boundaries" json:"boundaries"`
+
+	// The time the version takes effect, in time.RFC3339 format in UTC.
+	EffectiveAt string `dynamodbav:"effectiveAt" json:"effectiveAt"`
+
+	// A description of the changes in the version.
+	Description string `dynamodbav:"description" json:"description"`
+
+	// The username of the user that proposed the version.
+	CreatedBy string `dynamodbav:"createdBy" json:"createdBy"`
+
+	// The time the version was proposed, in time.RFC3339 format.
+	CreatedAt string `dynamodbav:"createdAt" json:"createdAt"`
+
+	// The id of the proposal that the version was published from.
+	ProposalId string `dynamodbav:"proposalId,omitempty" json:"proposalId,omitempty"`
+
+	// The username of the user that published the version.
+	PublishedBy string `dynamodbav:"publishedBy,omitempty" json:"publishedBy,omitempty"`
+
+	// The time the version was published, in time.RFC3339 format.
+	PublishedAt string `dynamodbav:"publishedAt,omitempty" json:"publishedAt,omitempty"`
+}
+
+// DefaultCohortBoundaries are the compiled boundaries, which are in effect before the
+// first version published in the database.
+var DefaultCohortBoundaries = CohortBoundaries{
+	Status:      CohortBoundariesStatus_Published,
+	Boundaries:  ratingBoundaries,
+	Description: "Default boundaries",
+}
+
+// Validate returns an error if the boundaries of a rating system do not contain a
+// non-decreasing, non-negative boundary for each cohort but the last.
+func (b *CohortBoundaries) Validate() error {
+	if len(b.Boundaries) == 0 {
+		return errors.New(400, "Invalid request: boundaries are required", "")
+	}
+	for system, boundaries := range b.Boundaries {
+		if system == Custom || system == Custom2 || system == Custom3 || !slices.Contains(ratingSystems, system) {
+			return errors.New(400, fmt.Sprintf("Invalid request: rating system `%s` cannot have boundaries", system), "")
+		}
+		if len(boundaries) != len(Cohorts)-1 {
+			return errors.New(400, fmt.Sprintf("Invalid request: %s must have %d boundaries", system, len(Cohorts)-1), "")
+		}
+		for i, boundary := range boundaries {
+			if boundary < 0 || (i > 0 && boundary < boundaries[i-1]) {
+				return errors.New(400, fmt.Sprintf("Invalid request: %s boundaries must be non-negative and non-decreasing", system), "")
+			}
+		}
+	}
+	return nil
+}
+
+// GetCohort returns the cohort of the given rating in the given rating system, or
+// NoCohort if the rating system has no boundaries.
+func (b *CohortBoundaries) GetCohort(system RatingSystem, rating int) DojoCohort {
+	boundaries := b.Boundaries[system]
+	if boundaries == nil {
+		return NoCohort
+	}
+
+	for i := 0; i < len(boundaries); i++ {
+		if rating < boundaries[i] {
+			return Cohorts[i]
+		}
+	}
+	return Cohorts[len(Cohorts)-1]
+}
+
+// PlaceCohort returns the cohort in which to place a user with the given rating, and
+// whether the rating is provisional. Provisional ratings are placed using their
+// ConservativeRating, so that users with only a few games are not placed in a cohort far
+// above their strength. NoCohort is returned if the rating is nil or the rating system
+// has no boundaries.
+func (b *CohortBoundaries) PlaceCohort(system RatingSystem, rating *Rating) (DojoCohort, bool) {
+	if rating == nil {
+		return NoCohort, false
+	}
+	if rating.IsProvisional(system) {
+		return b.GetCohort(system, rating.ConservativeRating()), true
+	}
+	return b.GetCohort(system, rating.CurrentRating), false
+}
+
+// normalizedBoundaries returns the normalized rating at each boundary, which is the
+// upper end of the corresponding cohort.
+func normalizedBoundaries() []float64 {
+	result := make([]float64, 0, len(Cohorts)-1)
+	for _, cohort := range Cohorts[:len(Cohorts)-1] {
+		_, upper, _ := strings.Cut(string(cohort), "-")
+		n, _ := strconv.Atoi(upper)
+		result = append(result, float64(n))
+	}
+	return result
+}
+
+// interpolate returns the y value at x on the piecewise linear function through
+// (0, 0) and the given points. xs must be non-decreasing. If several points share
+// the x value, the last of them is used. Values above the last point are extrapolated
+// from the last segment.
+func interpolate(xs, ys []float64, x float64) float64 {
+	x1, y1 := 0.0, 0.0
+	for i := range xs {
+		if x < xs[i] {
+			return y1 + (ys[i]-y1)*(x-x1)/(xs[i]-x1)
+		}
+		x1, y1 = xs[i], ys[i]
+	}
+
+	n := len(xs)
+	x0, y0 := xs[n-2], ys[n-2]
+	return y1 + (y1-y0)*(x-x1)/(x1-x0)
+}
+
+// floatBoundaries returns the boundaries of the given rating system as floats, or an
+// error if the rating system has no boundaries.
+func (b *CohortBoundaries) floatBoundaries(system RatingSystem) ([]float64, error) {
+	boundaries := b.Boundaries[system]
+	if boundaries == nil {
+		return nil, errors.New(400, fmt.Sprintf("Invalid request: rating system `%s` cannot be converted", system), "")
+	}
+
+	result := make([]float64, len(boundaries))
+	for i, boundary := range boundaries {
+		result[i] = float64(boundary)
+	}
+	return result, nil
+}
+
+// NormalizedRating returns the Dojo's normalized rating equivalent to the given rating in
+// the given rating system. The normalized rating is found by linear interpolation between
+// the rating system's cohort boundaries, so that, for example, a rating at the boundary
+// between the 1500-1600 and 1600-1700 cohorts has a normalized rating of 1600.
+func (b *CohortBoundaries) NormalizedRating(system RatingSystem, rating float64) (float64, error) {
+	xs, err := b.floatBoundaries(system)
+	if err != nil {
+		return 0, err
+	}
+	return interpolate(xs, normalizedBoundaries(), rating), nil
+}
+
+// RatingFromNormalized returns the rating in the given rating system equivalent to the
+// given normalized rating. It is the inverse of NormalizedRating, except that normalized
+// ratings which share a boundary in the rating system (such as the FIDE rating floor)
+// return that boundary.
+func (b *CohortBoundaries) RatingFromNormalized(system RatingSystem, normalized float64) (float64, error) {
+	ys, err := b.floatBoundaries(system)
+	if err != nil {
+		return 0, err
+	}
+	return interpolate(normalizedBoundaries(), ys, normalized), nil
+}
+
+// ConvertRating returns the rating in the rating system to that is equivalent to the
+// given rating in the rating system from.
+func (b *CohortBoundaries) ConvertRating(from, to RatingSystem, rating float64) (float64, error) {
+	normalized, err := b.NormalizedRating(from, rating)
+	if err != nil {
+		return 0, err
+	}
+	return b.RatingFromNormalized(to, normalized)
+}
+
+type CohortBoundariesGetter interface {
+	// GetCohortBoundaries returns the cohort boundaries in effect at the given date.
+	GetCohortBoundaries(date time.Time) (*CohortBoundaries, error)
+}
+
+// GetCohortBoundaries returns the cohort boundaries in effect at the given date. If no
+// version was published before the date, DefaultCohortBoundaries is returned.
+func (repo *dynamoRepository) GetCohortBoundaries(date time.Time) (*CohortBoundaries, error) {
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("#status = :published AND #id <= :date"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+			"#id":     aws.String("id"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":published": {S: aws.String(string(CohortBoundariesStatus_Published))},
+			":date":      {S: aws.String(date.UTC().Format(time.RFC3339))},
+		},
+		Limit:            aws.Int64(1),
+		ScanIndexForward: aws.Bool(false),
+		TableName:        aws.String(cohortBoundariesTable),
+	}
+
+	var versions []CohortBoundaries
+	if _, err := repo.query(input, "", &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		boundaries := DefaultCohortBoundaries
+		return &boundaries, nil
+	}
+
+	// Rating systems added after the version was published use the compiled boundaries.
+	version := &versions[0]
+	for system, boundaries := range ratingBoundaries {
+		if _, ok := version.Boundaries[system]; !ok {
+			if version.Boundaries == nil {
+				version.Boundaries = make(map[RatingSystem][]int)
+			}
+			version.Boundaries[system] = boundaries
+		}
+	}
+	return version, nil
+}
+
+type CohortBoundariesEditor interface {
+	CohortBoundariesGetter
+
+	// ListCohortBoundaries returns all proposed and published versions of the cohort
+	// boundaries. Published versions are sorted by EffectiveAt.
+	ListCohortBoundaries() ([]CohortBoundaries, error)
+
+	// GetCohortBoundariesProposal returns the proposed cohort boundaries with the given id.
+	GetCohortBoundariesProposal(id string) (*CohortBoundaries, error)
+
+	// PutCohortBoundariesProposal saves the given proposed cohort boundaries.
+	PutCohortBoundariesProposal(proposal *CohortBoundaries) error
+
+	// PublishCohortBoundaries publishes the given proposal and returns the published version.
+	PublishCohortBoundaries(proposal *CohortBoundaries, publishedBy string) (*CohortBoundaries, error)
+}
+
+// ListCohortBoundaries returns all proposed and published versions of the cohort
+// boundaries. Published versions are sorted by EffectiveAt.
+func (repo *dynamoRepository) ListCohortBoundaries() ([]CohortBoundaries, error) {
+	var result []CohortBoundaries
+	for _, status := range []CohortBoundariesStatus{CohortBoundariesStatus_Published, CohortBoundariesStatus_Proposed} {
+		input := &dynamodb.QueryInput{
+			KeyConditionExpression: aws.String("#status = :status"),
+			ExpressionAttributeNames: map[string]*string{
+				"#status": aws.String("status"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":status": {S: aws.String(string(status))},
+			},
+			TableName: aws.String(cohortBoundariesTable),
+		}
+
+		startKey := ""
+		for ok := true; ok; ok = startKey != "" {
+			var versions []CohortBoundaries
+			var err error
+			startKey, err = repo.query(input, startKey, &versions)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, versions...)
+		}
+	}
+	return result, nil
+}
+
+// GetCohortBoundariesProposal returns the proposed cohort boundaries with the given id.
+func (repo *dynamoRepository) GetCohortBoundariesProposal(id string) (*CohortBoundaries, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"status": {S: aws.String(string(CohortBoundariesStatus_Proposed))},
+			"id":     {S: aws.String(id)},
+		},
+		TableName: aws.String(cohortBoundariesTable),
+	}
+
+	proposal := CohortBoundaries{}
+	err := repo.getItem(input, &proposal)
+	return &proposal, err
+}
+
+// PutCohortBoundariesProposal saves the given proposed cohort boundaries.
+func (repo *dynamoRepository) PutCohortBoundariesProposal(proposal *CohortBoundaries) error {
+	if proposal.Status != CohortBoundariesStatus_Proposed {
+		return errors.New(500, "Temporary server error", "PutCohortBoundariesProposal called with a published version")
+	}
+
+	item, err := dynamodbattribute.MarshalMap(proposal)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to marshal cohort boundaries", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(cohortBoundariesTable),
+	}
+	_, err = repo.svc.PutItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+}
+
+// PublishCohortBoundaries publishes the given proposal and returns the published version.
+// The published version's id is the proposal's EffectiveAt, which must not match the
+// EffectiveAt of another published version. The proposal is deleted once it is published.
+func (repo *dynamoRepository) PublishCohortBoundaries(proposal *CohortBoundaries, publishedBy string) (*CohortBoundaries, error) {
+	published := *proposal
+	published.Status = CohortBoundariesStatus_Published
+	published.Id = proposal.EffectiveAt
+	published.ProposalId = proposal.Id
+	published.PublishedBy = publishedBy
+	published.PublishedAt = time.Now().Format(time.RFC3339)
+
+	item, err := dynamodbattribute.MarshalMap(published)
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", "Unable to marshal cohort boundaries", err)
+	}
+
+	_, err = repo.svc.PutItem(&dynamodb.PutItemInput{
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+		TableName:           aws.String(cohortBoundariesTable),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, errors.New(400, fmt.Sprintf("Invalid request: a version is already published at %s", published.Id), "")
+		}
+		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+	}
+
+	_, err = repo.svc.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"status": {S: aws.String(string(CohortBoundariesStatus_Proposed))},
+			"id":     {S: aws.String(proposal.Id)},
+		},
+		TableName: aws.String(cohortBoundariesTable),
+	})
+	if err != nil {
+		log.Errorf("Published cohort boundaries but failed to delete proposal %s: %v", proposal.Id, err)
+	}
+	return &published, nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConvertRating(t *testing.T) {
+	table := []struct {
+		name   string
+		from   RatingSystem
+		to     RatingSystem
+		rating float64
+		want   float64
+	}{
+		{name: "Boundary", from: Uscf, to: Fide, rating: 1600, want: 1700},
+		{name: "OnlineSystems", from: Lichess, to: Chesscom, rating: 1500, want: 950},
+		{name: "Interpolated", from: Chesscom, to: Lichess, rating: 1000, want: 1525},
+		{name: "BelowFirstBoundary", from: Chesscom, to: Uscf, rating: 275, want: 175},
+		{name: "RatingFloor", from: Fide, to: Chesscom, rating: 1400, want: 1150},
+		{name: "Extrapolated", from: Chesscom, to: Uscf, rating: 2615, want: 2605},
+		{name: "SameSystem", from: Dwz, to: Dwz, rating: 1234, want: 1234},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DefaultCohortBoundaries.ConvertRating(tc.from, tc.to, tc.rating)
+			if err != nil {
+				t.Fatalf("ConvertRating got err: %v", err)
+			}
+			if math.Abs(got-tc.want) > 0.001 {
+				t.Errorf("ConvertRating(%s, %s, %v) = %v; want %v", tc.from, tc.to, tc.rating, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := DefaultCohortBoundaries.ConvertRating(Custom, Fide, 1500); err == nil {
+		t.Errorf("ConvertRating(Custom) got nil err; want 400")
+	}
+	if got, _ := DefaultCohortBoundaries.NormalizedRating(Lichess, 1850); got != 1300 {
+		t.Errorf("NormalizedRating(Lichess, 1850) = %v; want 1300", got)
+	}
+}
+
+func TestPlaceCohort(t *testing.T) {
+	table := []struct {
+		name            string
+		system          RatingSystem
+		rating          *Rating
+		wantRating      int
+		wantProvisional bool
+	}{
+		{name: "Established", system: Lichess, rating: &Rating{CurrentRating: 1850, Deviation: 60, NumGames: 500}, wantRating: 1850},
+		{name: "UnknownStats", system: Fide, rating: &Rating{CurrentRating: 1800}, wantRating: 1800},
+		{name: "HighDeviation", system: Lichess, rating: &Rating{CurrentRating: 2200, Deviation: 250, NumGames: 5}, wantRating: 1950, wantProvisional: true},
+		{name: "FewGames", system: Chesscom, rating: &Rating{CurrentRating: 1500, Deviation: 80, NumGames: 10}, wantRating: 1390, wantProvisional: true},
+		{name: "UscfProvisional", system: Uscf, rating: &Rating{CurrentRating: 1600, NumGames: 25}, wantRating: 1490, wantProvisional: true},
+		{name: "UscfEstablished", system: Uscf, rating: &Rating{CurrentRating: 1600, NumGames: 26}, wantRating: 1600},
+		{name: "RatingFloor", system: Chesscom, rating: &Rating{CurrentRating: 100, Deviation: 300}, wantRating: 0, wantProvisional: true},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			cohort, provisional := DefaultCohortBoundaries.PlaceCohort(tc.system, tc.rating)
+			if want := DefaultCohortBoundaries.GetCohort(tc.system, tc.wantRating); cohort != want || provisional != tc.wantProvisional {
+				t.Errorf("PlaceCohort(%s, %+v) = %s, %v; want %s, %v", tc.system, tc.rating, cohort, provisional, want, tc.wantProvisional)
+			}
+		})
+	}
+
+	if cohort, _ := DefaultCohortBoundaries.PlaceCohort(Lichess, nil); cohort != NoCohort {
+		t.Errorf("PlaceCohort(nil) = %s; want NoCohort", cohort)
+	}
+
+	system := Lichess
+	update := &UserUpdate{RatingSystem: &system, Ratings: &map[RatingSystem]*Rating{Lichess: {CurrentRating: 2200, Deviation: 250}}}
+	if cohort := update.AutopickCohort(&DefaultCohortBoundaries); update.ProvisionalCohort == nil || !*update.ProvisionalCohort || cohort != DefaultCohortBoundaries.GetCohort(Lichess, 1950) {
+		t.Errorf("AutopickCohort got cohort %s, update %+v; want provisional cohort", cohort, update)
+	}
+}
+
+func TestCohortBoundariesValidate(t *testing.T) {
+	valid := make([]int, len(Cohorts)-1)
+	for i := range valid {
+		valid[i] = 100 * i
+	}
+	decreasing := append([]int{}, valid...)
+	decreasing[3] = 0
+
+	table := []struct {
+		name       string
+		boundaries map[RatingSystem][]int
+		wantErr    bool
+	}{
+		{name: "Valid", boundaries: map[RatingSystem][]int{Uscf: valid}},
+		{name: "Empty", boundaries: nil, wantErr: true},
+		{name: "Custom", boundaries: map[RatingSystem][]int{Custom: valid}, wantErr: true},
+		{name: "TooShort", boundaries: map[RatingSystem][]int{Uscf: valid[1:]}, wantErr: true},
+		{name: "Decreasing", boundaries: map[RatingSystem][]int{Uscf: decreasing}, wantErr: true},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			b := CohortBoundaries{Boundaries: tc.boundaries}
+			if err := b.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate got err %v; want err: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemoryCohortBoundaries(t *testing.T) {
+	repo := NewInMemory()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	got, err := repo.GetCohortBoundaries(now)
+	if err != nil {
+		t.Fatalf("GetCohortBoundaries got err: %v", err)
+	}
+	if got.GetCohort(Chesscom, 1000) != "700-800" {
+		t.Errorf("GetCohortBoundaries got %+v; want the default boundaries", got)
+	}
+
+	boundaries := make([]int, len(Cohorts)-1)
+	for i := range boundaries {
+		boundaries[i] = 1000 + 10*i
+	}
+	proposal := &CohortBoundaries{
+		Status:      CohortBoundariesStatus_Proposed,
+		Id:          "proposal",
+		Boundaries:  map[RatingSystem][]int{Chesscom: boundaries},
+		EffectiveAt: now.Add(time.Hour).Format(time.RFC3339),
+	}
+	if err := repo.PutCohortBoundariesProposal(proposal); err != nil {
+		t.Fatalf("PutCohortBoundariesProposal got err: %v", err)
+	}
+	if _, err := repo.PublishCohortBoundaries(proposal, "admin"); err != nil {
+		t.Fatalf("PublishCohortBoundaries got err: %v", err)
+	}
+	if _, err := repo.PublishCohortBoundaries(proposal, "admin"); err == nil {
+		t.Errorf("PublishCohortBoundaries at the same EffectiveAt got nil err; want 400")
+	}
+
+	before, err := repo.GetCohortBoundaries(now)
+	if err != nil {
+		t.Fatalf("GetCohortBoundaries(now) got err: %v", err)
+	}
+	after, err := repo.GetCohortBoundaries(now.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetCohortBoundaries(later) got err: %v", err)
+	}
+	if before.GetCohort(Chesscom, 1000) != "700-800" || after.GetCohort(Chesscom, 1000) != "300-400" {
+		t.Errorf("GetCohortBoundaries got cohorts %s before and %s after publishing; want 700-800 and 300-400",
+			before.GetCohort(Chesscom, 1000), after.GetCohort(Chesscom, 1000))
+	}
+
+	versions, err := repo.ListCohortBoundaries()
+	if err != nil {
+		t.Fatalf("ListCohortBoundaries got err: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Status != CohortBoundariesStatus_Published || versions[0].ProposalId != "proposal" {
+		t.Errorf("ListCohortBoundaries got %+v; want only the published version", versions)
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+type CohortChangeType string
+
+const (
+	// The user moved to a lower cohort after a sustained rating drop.
+	CohortChangeType_Demotion CohortChangeType = "DEMOTION"
+)
+
+// CohortChange records a move of a user between cohorts other than a graduation.
+type CohortChange struct {
+	// The Cognito username of the user.
+	// The hash key of the table.
+	Username string `dynamodbav:"username" json:"username"`
+
+	// The display name of the user.
+	DisplayName string `dynamodbav:"displayName" json:"displayName"`
+
+	// The type of the cohort change.
+	Type CohortChangeType `dynamodbav:"type" json:"type"`
+
+	// The cohort the user moved from.
+	PreviousCohort DojoCohort `dynamodbav:"previousCohort" json:"previousCohort"`
+
+	// The cohort the user moved to.
+	NewCohort DojoCohort `dynamodbav:"newCohort" json:"newCohort"`
+
+	// A human-readable explanation of the change.
+	Reason string `dynamodbav:"reason" json:"reason"`
+
+	// Whether the user was moved automatically by the nightly graduation eligibility check,
+	// rather than accepting a suggestion.
+	Automatic bool `dynamodbav:"automatic,omitempty" json:"automatic,omitempty"`
+
+	// The user's preferred rating system at the time of the change.
+	RatingSystem RatingSystem `dynamodbav:"ratingSystem" json:"ratingSystem"`
+
+	// The user's rating at the time of the change.
+	CurrentRating int `dynamodbav:"currentRating" json:"currentRating"`
+
+	// The user's score in PreviousCohort at the time of the change.
+	Score float32 `dynamodbav:"score" json:"score"`
+
+	// The user's score in NewCohort, including the progress carried from PreviousCohort.
+	NewScore float32 `dynamodbav:"newScore" json:"newScore"`
+
+	// The time the user started PreviousCohort.
+	StartedAt string `dynamodbav:"startedAt" json:"startedAt"`
+
+	// The time of the change, in time.RFC3339 format.
+	// The range key of the table.
+	CreatedAt string `dynamodbav:"createdAt" json:"createdAt"`
+}
+
+type CohortChangeCreator interface {
+	UserGetter
+	UserUpdater
+	RequirementLister
+
+	// PutCohortChange saves the provided CohortChange in the database.
+	PutCohortChange(change *CohortChange) error
+}
+
+type CohortChangeLister interface {
+	// ListCohortChangesByOwner returns a list of cohort changes matching the provided username.
+	ListCohortChangesByOwner(username, startKey string) ([]CohortChange, string, error)
+}
+
+// PutCohortChange saves the provided CohortChange in the database.
+func (repo *dynamoRepository) PutCohortChange(change *CohortChange) error {
+	item, err := dynamodbattribute.MarshalMap(change)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to marshal cohort change", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(cohortChangeTable),
+	}
+	_, err = repo.svc.PutItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+}
+
+// ListCohortChangesByOwner returns a list of cohort changes matching the provided username.
+func (repo *dynamoRepository) ListCohortChangesByOwner(username, startKey string) ([]CohortChange, string, error) {
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("#username = :username"),
+		ExpressionAttributeNames: map[string]*string{
+			"#username": aws.String("username"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":username": {S: aws.String(username)},
+		},
+		TableName: aws.String(cohortChangeTable),
+	}
+
+	var changes []CohortChange
+	lastKey, err := repo.query(input, startKey, &changes)
+	if err != nil {
+		return nil, "", err
+	}
+	return changes, lastKey, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// customRatingSystems are the rating systems whose ratings are entered by the user
+// instead of fetched.
+var customRatingSystems = []RatingSystem{Custom, Custom2, Custom3}
+
+// federationAliases maps the normalized names users give their custom ratings to the
+// supported rating system they refer to.
+var federationAliases = map[string]RatingSystem{
+	"ffe":        Ffe,
+	"france":     Ffe,
+	"french":     Ffe,
+	"feda":       Feda,
+	"spain":      Feda,
+	"spanish":    Feda,
+	"españa":     Feda,
+	"espana":     Feda,
+	"fsi":        Fsi,
+	"italy":      Fsi,
+	"italian":    Fsi,
+	"italia":     Fsi,
+	"aicf":       Aicf,
+	"india":      Aicf,
+	"indian":     Aicf,
+	"cbx":        Cbx,
+	"brazil":     Cbx,
+	"brazilian":  Cbx,
+	"brasil":     Cbx,
+	"nzcf":       Nzcf,
+	"nz":         Nzcf,
+	"newzealand": Nzcf,
+}
+
+// ignoredNameWords are the words removed from the name of a custom rating before it
+// is looked up in federationAliases, so that eg. "FFE Elo" and "French Chess Federation"
+// both match.
+var ignoredNameWords = map[string]bool{
+	"elo":        true,
+	"rating":     true,
+	"ratings":    true,
+	"chess":      true,
+	"federation": true,
+	"national":   true,
+	"the":        true,
+	"of":         true,
+	"de":         true,
+}
+
+// FederationByName returns the supported national federation rating system matching the
+// given name of a custom rating, or an empty string if there is none.
+func FederationByName(name string) RatingSystem {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var sb strings.Builder
+	for _, word := range words {
+		if !ignoredNameWords[word] {
+			sb.WriteString(word)
+		}
+	}
+
+	return federationAliases[sb.String()]
+}
+
+// MigrateCustomRatings moves the user's custom ratings whose name matches a supported
+// national federation into that rating system, so that they are updated nightly and
+// placed using its cohort boundaries. A custom rating is not moved if the user already
+// has a rating in the matching rating system. If the user's preferred rating system is
+// moved, the preferred rating system is changed as well. Returns nil if no custom rating
+// was moved.
+func MigrateCustomRatings(user *User) *UserUpdate {
+	var ratings map[RatingSystem]*Rating
+	var preferred *RatingSystem
+
+	for _, custom := range customRatingSystems {
+		rating := user.Ratings[custom]
+		if rating == nil {
+			continue
+		}
+		system := FederationByName(rating.Name)
+		if system == "" {
+			continue
+		}
+		if existing := user.Ratings[system]; existing != nil && (existing.Username != "" || existing.CurrentRating > 0) {
+			continue
+		}
+		if ratings != nil && ratings[system] != nil {
+			continue
+		}
+
+		if ratings == nil {
+			ratings = make(map[RatingSystem]*Rating, len(user.Ratings))
+			for s, r := range user.Ratings {
+				ratings[s] = r
+			}
+		}
+		ratings[system] = &Rating{
+			Username:      strings.TrimSpace(rating.Username),
+			HideUsername:  rating.HideUsername,
+			StartRating:   rating.StartRating,
+			CurrentRating: rating.CurrentRating,
+		}
+		delete(ratings, custom)
+
+		if user.RatingSystem == custom {
+			preferred = &system
+		}
+	}
+
+	if ratings == nil {
+		return nil
+	}
+
+	migrated := *user
+	migrated.Ratings = ratings
+	searchKey := GetSearchKey(&migrated, nil)
+	return &UserUpdate{Ratings: &ratings, RatingSystem: preferred, SearchKey: &searchKey}
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFederationByName(t *testing.T) {
+	table := []struct {
+		name string
+		want RatingSystem
+	}{
+		{name: "FFE", want: Ffe},
+		{name: "French Chess Federation", want: Ffe},
+		{name: "Elo FEDA", want: Feda},
+		{name: "España", want: Feda},
+		{name: "Elo Italia", want: Fsi},
+		{name: "AICF rating", want: Aicf},
+		{name: "CBX", want: Cbx},
+		{name: "New Zealand", want: Nzcf},
+		{name: "NZCF Ratings", want: Nzcf},
+		{name: "Chess Federation", want: ""},
+		{name: "Iceland", want: ""},
+		{name: "", want: ""},
+	}
+
+	for _, tc := range table {
+		if got := FederationByName(tc.name); got != tc.want {
+			t.Errorf("FederationByName(%q) = %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMigrateCustomRatings(t *testing.T) {
+	user := &User{
+		Username:     "test",
+		DisplayName:  "Test",
+		RatingSystem: Custom,
+		Ratings: map[RatingSystem]*Rating{
+			Custom:   {Name: "FFE Elo", Username: " F12345 ", StartRating: 1500, CurrentRating: 1600},
+			Custom2:  {Name: "Iceland", StartRating: 1700, CurrentRating: 1700},
+			Custom3:  {Name: "Brazil", CurrentRating: 1800},
+			Cbx:      {Username: "54321", CurrentRating: 1850},
+			Chesscom: {Username: "dojoplayer", CurrentRating: 1400},
+		},
+	}
+
+	update := MigrateCustomRatings(user)
+	if update == nil {
+		t.Fatal("MigrateCustomRatings got nil; want the FFE rating migrated")
+	}
+
+	want := map[RatingSystem]*Rating{
+		Ffe:      {Username: "F12345", StartRating: 1500, CurrentRating: 1600},
+		Custom2:  {Name: "Iceland", StartRating: 1700, CurrentRating: 1700},
+		Custom3:  {Name: "Brazil", CurrentRating: 1800},
+		Cbx:      {Username: "54321", CurrentRating: 1850},
+		Chesscom: {Username: "dojoplayer", CurrentRating: 1400},
+	}
+	if diff := cmp.Diff(want, *update.Ratings); diff != "" {
+		t.Errorf("MigrateCustomRatings ratings mismatch (-want +got):\n%s", diff)
+	}
+	if update.RatingSystem == nil || *update.RatingSystem != Ffe {
+		t.Errorf("MigrateCustomRatings got rating system %v; want FFE", update.RatingSystem)
+	}
+	if wantKey := "display:test_discord:_chesscom:dojoplayer_ffe:f12345_cbx:54321"; update.SearchKey == nil || *update.SearchKey != wantKey {
+		t.Errorf("MigrateCustomRatings got search key %s; want %s", aws.StringValue(update.SearchKey), wantKey)
+	}
+	if _, ok := user.Ratings[Custom]; !ok {
+		t.Error("MigrateCustomRatings modified the user's ratings")
+	}
+
+	if update := MigrateCustomRatings(&User{Ratings: map[RatingSystem]*Rating{Custom: {Name: "Club"}}}); update != nil {
+		t.Errorf("MigrateCustomRatings got %+v; want nil when no custom rating matches", update)
+	}
+}
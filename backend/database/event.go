@@ -406,7 +406,7 @@ func (repo *dynamoRepository) DeleteEvent(id string) (*Event, error) {
 	result, err := repo.svc.DeleteItem(input)
 	if err != nil {
 		if aerr, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: event does not exist", "DynamoDB conditional check failed", aerr)
+			return nil, errors.Wrap(404, "Invalid request: event does not exist", "DynamoDB conditional check failed", aerr, errors.WithReason(errors.ReasonEventNotFound))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed Dynamo DeleteItem call", err)
 	}
@@ -494,7 +494,7 @@ func (repo *dynamoRepository) BookEvent(event *Event, user *User, startTime stri
 	result, err := repo.svc.UpdateItem(input)
 	if err != nil {
 		if aerr, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(400, "Invalid request: event no longer exists or is already fully booked", "DynamoDB conditional check failed", aerr)
+			return nil, errors.Wrap(400, "Invalid request: event no longer exists or is already fully booked", "DynamoDB conditional check failed", aerr, errors.WithReason(errors.ReasonEventFull))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem call", err)
 	}
@@ -7,7 +7,6 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 )
 
 // The key of an item in the exams table.
@@ -197,7 +196,7 @@ func (repo *dynamoRepository) PutExamAnswerSummary(answer *ExamAnswer, score int
 	exam := &Exam{}
 	if err := repo.updateItem(input, exam); err != nil {
 		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(400, "Invalid request: exam not found or you have already taken it", "DynamoDB conditional check failed", err)
+			return nil, errors.Wrap(400, "Invalid request: exam not found or you have already taken it", "DynamoDB conditional check failed", err, errors.WithReason(errors.ReasonExamAlreadyTaken))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem", err)
 	}
@@ -279,8 +278,12 @@ type UserExamSummaryUpdate struct {
 
 // UpdateUserExamRatings uses DynamoDB PartiQL to batch update the given users' exam summaries for the given exam.
 func (repo *dynamoRepository) UpdateUserExamRatings(examId string, updates []UserExamSummaryUpdate) error {
+	var failures []BatchItemFailure
+	processed := 0
+
 	for i := 0; i < len(updates); i += 25 {
 		statements := make([]*dynamodb.BatchStatementRequest, 0, 25)
+		keys := make([]string, 0, 25)
 
 		for j := i; j < len(updates) && j < i+25; j++ {
 			update := updates[j]
@@ -293,15 +296,21 @@ func (repo *dynamoRepository) UpdateUserExamRatings(examId string, updates []Use
 				Statement:  aws.String(fmt.Sprintf("UPDATE \"%s\" SET exams.\"%s\"=? WHERE username=?", userTable, examId)),
 				Parameters: params,
 			})
+			keys = append(keys, "username="+update.Username)
+		}
+
+		err := repo.batchExecute(userTable, statements, keys)
+		if err == nil {
+			processed += len(statements)
+			continue
 		}
 
-		input := &dynamodb.BatchExecuteStatementInput{Statements: statements}
-		log.Debugf("Batch execute statement input: %v", input)
-		output, err := repo.svc.BatchExecuteStatement(input)
-		log.Debugf("Batch execute statement output: %v", output)
-		if err != nil {
-			return errors.Wrap(500, "Temporary server error", "Failed BatchExecuteStatement", err)
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) {
+			return err
 		}
+		processed += batchErr.Processed
+		failures = append(failures, batchErr.Failures...)
 	}
-	return nil
+	return batchFailure("BatchExecuteStatement", processed, failures)
 }
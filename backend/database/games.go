@@ -1,7 +1,6 @@
 package database
 
 import (
-	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -11,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/cursor"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
 )
 
@@ -500,51 +500,43 @@ func (repo *dynamoRepository) ListGamesByOwner(isOwner bool, owner, startDate, e
 	return games, lastKey, nil
 }
 
-type listGamesByPlayerStartKey struct {
-	WhiteKey string `json:"whiteKey"`
-	BlackKey string `json:"blackKey"`
-}
+// listGamesByPlayerCursorScope is the scope of the composite cursors returned by ListGamesByPlayer.
+// The cursor contains one part for each color.
+const listGamesByPlayerCursorScope = "ListGamesByPlayer"
 
 // ListGamesByPlayer returns a list of Games matching the provided player. The PGN text is excluded and must
 // be fetched separately with a call to GetGame.
 func (repo *dynamoRepository) ListGamesByPlayer(player string, color PlayerColor, startDate, endDate, startKey string) ([]*Game, string, error) {
 	player = strings.ToLower(strings.TrimSpace(player))
 
-	startKeys := listGamesByPlayerStartKey{}
-	if startKey != "" {
-		if err := json.Unmarshal([]byte(startKey), &startKeys); err != nil {
-			return nil, "", errors.Wrap(400, "Invalid request: startKey is not valid", "startKey could not be unmarshaled", err)
-		}
+	startKeys, err := cursor.DecodeComposite(listGamesByPlayerCursorScope, startKey)
+	if err != nil {
+		return nil, "", err
 	}
 
-	lastKeys := listGamesByPlayerStartKey{}
+	lastKeys := make(map[string]string)
 	games := make([]*Game, 0)
 
-	if (color == White || color == Either) && (startKey == "" || startKeys.WhiteKey != "") {
-		whiteGames, whiteKey, err := repo.listColorGames(player, White, startDate, endDate, startKeys.WhiteKey)
-		if err != nil {
-			return nil, "", err
+	for _, c := range []PlayerColor{White, Black} {
+		if color != c && color != Either {
+			continue
+		}
+		if startKey != "" && startKeys[string(c)] == "" {
+			// This color has already been fully listed
+			continue
 		}
-		games = append(games, whiteGames...)
-		lastKeys.WhiteKey = whiteKey
-	}
 
-	if (color == Black || color == Either) && (startKey == "" || startKeys.BlackKey != "") {
-		blackGames, blackKey, err := repo.listColorGames(player, Black, startDate, endDate, startKeys.BlackKey)
+		colorGames, colorKey, err := repo.listColorGames(player, c, startDate, endDate, startKeys[string(c)])
 		if err != nil {
 			return nil, "", err
 		}
-		games = append(games, blackGames...)
-		lastKeys.BlackKey = blackKey
+		games = append(games, colorGames...)
+		lastKeys[string(c)] = colorKey
 	}
 
-	var lastKey string
-	if lastKeys.WhiteKey != "" || lastKeys.BlackKey != "" {
-		b, err := json.Marshal(&lastKeys)
-		if err != nil {
-			return nil, "", errors.Wrap(500, "Temporary server error", "Failed to marshal listGamesByPlayerStartKey", err)
-		}
-		lastKey = string(b)
+	lastKey, err := cursor.EncodeComposite(listGamesByPlayerCursorScope, lastKeys)
+	if err != nil {
+		return nil, "", err
 	}
 
 	sort.Sort(byDate(games))
@@ -791,7 +783,7 @@ func (repo *dynamoRepository) PutComment(cohort, id string, comment *PositionCom
 	err = repo.updateItem(input, &game)
 	if err != nil {
 		if aerr, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(400, "Invalid request: game does not exist", "DynamoDB UpdateItem failure", aerr)
+			return nil, errors.Wrap(400, "Invalid request: game does not exist", "DynamoDB UpdateItem failure", aerr, errors.WithReason(errors.ReasonGameNotFound))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB UpdateItem failure", err)
 	}
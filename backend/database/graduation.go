@@ -41,6 +41,9 @@ type Graduation struct {
 	// The user's comments on graduating.
 	Comments string `dynamodbav:"comments" json:"comments"`
 
+	// Whether the user was graduated automatically by the nightly graduation eligibility check.
+	Automatic bool `dynamodbav:"automatic,omitempty" json:"automatic,omitempty"`
+
 	// The user's progress at the time of graduation.
 	Progress map[string]*RequirementProgress `dynamodbav:"progress" json:"progress"`
 
@@ -71,6 +74,7 @@ type GraduationCreator interface {
 	UserUpdater
 	RequirementLister
 	TimelineEditor
+	RatingHistoryLister
 
 	// PutGraduation saves the provided Graduation in the database.
 	PutGraduation(graduation *Graduation) error
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+// The status and id of the graduation settings, which are saved in the cohort boundaries
+// table alongside the versions of the boundaries they are evaluated against.
+const graduationSettingsStatus = "SETTINGS"
+const graduationSettingsId = "GRADUATION"
+
+// The maximum value of GraduationSettings.SustainedDays and DemotionSustainedDays.
+const maxSustainedDays = 90
+
+// The maximum value of GraduationSettings.DemotionMargin, in normalized rating points.
+const maxDemotionMargin = 500
+
+type DemotionMode string
+
+const (
+	// Users are not moved to a lower cohort after a rating drop.
+	DemotionMode_Off DemotionMode = "OFF"
+
+	// Users are notified that they can move to a lower cohort after a sustained rating drop.
+	DemotionMode_Suggest DemotionMode = "SUGGEST"
+
+	// Users are moved to a lower cohort automatically after a sustained rating drop.
+	DemotionMode_Automatic DemotionMode = "AUTOMATIC"
+)
+
+// GraduationSettings configures the nightly graduation eligibility check, including how
+// it handles users whose rating dropped below their cohort.
+type GraduationSettings struct {
+	// Set to the hardcoded value `SETTINGS`. The hash key of the table.
+	Status string `dynamodbav:"status" json:"-"`
+
+	// Set to the hardcoded value `GRADUATION`. The range key of the table.
+	Id string `dynamodbav:"id" json:"-"`
+
+	// The number of days a user's rating must stay in the next cohort before they are
+	// notified that they can graduate. If 0, users are notified the first night their
+	// rating reaches the next cohort.
+	SustainedDays int `dynamodbav:"sustainedDays" json:"sustainedDays"`
+
+	// Whether eligible users are graduated automatically, instead of only being notified.
+	AutoGraduate bool `dynamodbav:"autoGraduate" json:"autoGraduate"`
+
+	// How users whose rating dropped below their cohort are handled. Empty is the same
+	// as DemotionMode_Off.
+	DemotionMode DemotionMode `dynamodbav:"demotionMode,omitempty" json:"demotionMode"`
+
+	// How far below the lower end of their cohort a user's normalized rating must drop
+	// before they are suggested or moved to a lower cohort. Each cohort spans 100
+	// normalized rating points.
+	DemotionMargin int `dynamodbav:"demotionMargin" json:"demotionMargin"`
+
+	// The number of days a user's rating must stay below the demotion margin before they
+	// are suggested or moved to a lower cohort.
+	DemotionSustainedDays int `dynamodbav:"demotionSustainedDays" json:"demotionSustainedDays"`
+
+	// The username of the user who last updated the settings.
+	UpdatedBy string `dynamodbav:"updatedBy,omitempty" json:"updatedBy,omitempty"`
+
+	// The time the settings were last updated, in time.RFC3339 format.
+	UpdatedAt string `dynamodbav:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// DefaultGraduationSettings are used until the settings are saved in the database.
+var DefaultGraduationSettings = GraduationSettings{
+	Status:        graduationSettingsStatus,
+	Id:            graduationSettingsId,
+	SustainedDays: 7,
+
+	DemotionMode:          DemotionMode_Suggest,
+	DemotionMargin:        100,
+	DemotionSustainedDays: 30,
+}
+
+// Validate returns an error if the settings' DemotionMode is unknown or any of their
+// other fields is out of range.
+func (s *GraduationSettings) Validate() error {
+	if s.SustainedDays < 0 || s.SustainedDays > maxSustainedDays {
+		return errors.New(400, fmt.Sprintf("Invalid request: sustainedDays must be between 0 and %d", maxSustainedDays), "")
+	}
+	if s.AutoGraduate && s.SustainedDays < 1 {
+		return errors.New(400, "Invalid request: sustainedDays must be at least 1 when autoGraduate is true", "")
+	}
+	switch s.DemotionMode {
+	case "", DemotionMode_Off, DemotionMode_Suggest, DemotionMode_Automatic:
+	default:
+		return errors.New(400, fmt.Sprintf("Invalid request: demotionMode `%s` is not supported", s.DemotionMode), "")
+	}
+	if s.DemotionMargin < 0 || s.DemotionMargin > maxDemotionMargin {
+		return errors.New(400, fmt.Sprintf("Invalid request: demotionMargin must be between 0 and %d", maxDemotionMargin), "")
+	}
+	if s.DemotionSustainedDays < 0 || s.DemotionSustainedDays > maxSustainedDays {
+		return errors.New(400, fmt.Sprintf("Invalid request: demotionSustainedDays must be between 0 and %d", maxSustainedDays), "")
+	}
+	return nil
+}
+
+// DemotionEnabled returns true if users are suggested or moved to a lower cohort after
+// a sustained rating drop.
+func (s *GraduationSettings) DemotionEnabled() bool {
+	return s.DemotionMode == DemotionMode_Suggest || s.DemotionMode == DemotionMode_Automatic
+}
+
+// EvaluateGraduationEligibility returns the user's GraduationEligibleSince at now, and
+// whether the user has been eligible to graduate for at least sustainedDays. A user is
+// eligible while their current rating in their preferred rating system is in the next
+// cohort or above, using the given boundaries. Users in a provisional cohort, with a
+// provisional rating or without a next cohort are not eligible.
+func EvaluateGraduationEligibility(user *User, boundaries *CohortBoundaries, sustainedDays int, now time.Time) (string, bool) {
+	next := user.DojoCohort.GetNextCohort()
+	if next == NoCohort || user.ProvisionalCohort {
+		return "", false
+	}
+
+	rating := user.Ratings[user.RatingSystem]
+	if rating == nil || rating.CurrentRating <= 0 || rating.IsProvisional(user.RatingSystem) {
+		return "", false
+	}
+
+	cohort := boundaries.GetCohort(user.RatingSystem, rating.CurrentRating)
+	if cohort == NoCohort || slices.Index(Cohorts, cohort) < slices.Index(Cohorts, next) {
+		return "", false
+	}
+
+	since := user.GraduationEligibleSince
+	start, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		since = now.UTC().Format(time.RFC3339)
+		start = now
+	}
+	return since, !now.Before(start.AddDate(0, 0, sustainedDays))
+}
+
+// EvaluateCohortDrop returns the user's CohortDropSince at now, the cohort of the user's
+// current rating and whether the rating has stayed below the drop threshold for at least
+// sustainedDays. The threshold is margin normalized rating points below the lower end of
+// the user's cohort, using the given boundaries. Users in the first cohort or a
+// provisional cohort, with a provisional rating or with a rating system which has no
+// boundaries never drop.
+func EvaluateCohortDrop(user *User, boundaries *CohortBoundaries, margin, sustainedDays int, now time.Time) (string, DojoCohort, bool) {
+	index := slices.Index(Cohorts, user.DojoCohort)
+	if index <= 0 || user.ProvisionalCohort {
+		return "", NoCohort, false
+	}
+
+	rating := user.Ratings[user.RatingSystem]
+	if rating == nil || rating.CurrentRating <= 0 || rating.IsProvisional(user.RatingSystem) {
+		return "", NoCohort, false
+	}
+
+	normalized, err := boundaries.NormalizedRating(user.RatingSystem, float64(rating.CurrentRating))
+	if err != nil {
+		return "", NoCohort, false
+	}
+	floor := normalizedBoundaries()[index-1]
+	if normalized >= floor-float64(margin) {
+		return "", NoCohort, false
+	}
+
+	cohort := boundaries.GetCohort(user.RatingSystem, rating.CurrentRating)
+	if cohort == NoCohort || slices.Index(Cohorts, cohort) >= index {
+		return "", NoCohort, false
+	}
+
+	since := user.CohortDropSince
+	start, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		since = now.UTC().Format(time.RFC3339)
+		start = now
+	}
+	return since, cohort, !now.Before(start.AddDate(0, 0, sustainedDays))
+}
+
+type GraduationSettingsEditor interface {
+	// GetGraduationSettings returns the graduation settings, or DefaultGraduationSettings
+	// if they have not been saved.
+	GetGraduationSettings() (*GraduationSettings, error)
+
+	// PutGraduationSettings saves the given graduation settings.
+	PutGraduationSettings(settings *GraduationSettings) error
+}
+
+// GetGraduationSettings returns the graduation settings, or DefaultGraduationSettings
+// if they have not been saved.
+func (repo *dynamoRepository) GetGraduationSettings() (*GraduationSettings, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"status": {S: aws.String(graduationSettingsStatus)},
+			"id":     {S: aws.String(graduationSettingsId)},
+		},
+		TableName: aws.String(cohortBoundariesTable),
+	}
+
+	settings := GraduationSettings{}
+	if err := repo.getItem(input, &settings); err != nil {
+		if aerr, ok := err.(*errors.Error); ok && aerr.Code == 404 {
+			defaults := DefaultGraduationSettings
+			return &defaults, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// PutGraduationSettings saves the given graduation settings.
+func (repo *dynamoRepository) PutGraduationSettings(settings *GraduationSettings) error {
+	settings.Status = graduationSettingsStatus
+	settings.Id = graduationSettingsId
+
+	item, err := dynamodbattribute.MarshalMap(settings)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to marshal graduation settings", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(cohortBoundariesTable),
+	}
+	_, err = repo.svc.PutItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+}
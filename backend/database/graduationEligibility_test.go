@@ -0,0 +1,156 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateGraduationEligibility(t *testing.T) {
+	now := time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC)
+	boundaries := &DefaultCohortBoundaries
+
+	table := []struct {
+		name          string
+		user          *User
+		wantSince     string
+		wantSustained bool
+	}{
+		{
+			name:      "NewlyEligible",
+			user:      &User{DojoCohort: "1400-1500", RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1650}}},
+			wantSince: "2024-10-10T06:00:00Z",
+		},
+		{
+			name:          "Sustained",
+			user:          &User{DojoCohort: "1400-1500", RatingSystem: Uscf, GraduationEligibleSince: "2024-10-03T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1650}}},
+			wantSince:     "2024-10-03T06:00:00Z",
+			wantSustained: true,
+		},
+		{
+			name:      "NotYetSustained",
+			user:      &User{DojoCohort: "1400-1500", RatingSystem: Uscf, GraduationEligibleSince: "2024-10-04T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1800}}},
+			wantSince: "2024-10-04T06:00:00Z",
+		},
+		{
+			name: "DroppedBelow",
+			user: &User{DojoCohort: "1400-1500", RatingSystem: Uscf, GraduationEligibleSince: "2024-10-03T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1599}}},
+		},
+		{
+			name: "ProvisionalRating",
+			user: &User{DojoCohort: "1400-1500", RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1650, NumGames: 5}}},
+		},
+		{
+			name: "ProvisionalCohort",
+			user: &User{DojoCohort: "1400-1500", ProvisionalCohort: true, RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1650}}},
+		},
+		{
+			name: "CustomRating",
+			user: &User{DojoCohort: "1400-1500", RatingSystem: Custom, Ratings: map[RatingSystem]*Rating{Custom: {CurrentRating: 2000}}},
+		},
+		{
+			name: "LastCohort",
+			user: &User{DojoCohort: "2400+", RatingSystem: Fide, Ratings: map[RatingSystem]*Rating{Fide: {CurrentRating: 2600}}},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			since, sustained := EvaluateGraduationEligibility(tc.user, boundaries, 7, now)
+			if since != tc.wantSince || sustained != tc.wantSustained {
+				t.Errorf("EvaluateGraduationEligibility got %q, %v; want %q, %v", since, sustained, tc.wantSince, tc.wantSustained)
+			}
+		})
+	}
+}
+
+func TestEvaluateCohortDrop(t *testing.T) {
+	now := time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC)
+	boundaries := &DefaultCohortBoundaries
+
+	table := []struct {
+		name          string
+		user          *User
+		wantSince     string
+		wantCohort    DojoCohort
+		wantSustained bool
+	}{
+		{
+			name:       "NewlyDropped",
+			user:       &User{DojoCohort: "1400-1500", RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1400}}},
+			wantSince:  "2024-10-10T06:00:00Z",
+			wantCohort: "1200-1300",
+		},
+		{
+			name:          "Sustained",
+			user:          &User{DojoCohort: "1400-1500", RatingSystem: Uscf, CohortDropSince: "2024-09-10T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1300}}},
+			wantSince:     "2024-09-10T06:00:00Z",
+			wantCohort:    "1100-1200",
+			wantSustained: true,
+		},
+		{
+			name:       "NotYetSustained",
+			user:       &User{DojoCohort: "1400-1500", RatingSystem: Uscf, CohortDropSince: "2024-09-11T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1400}}},
+			wantSince:  "2024-09-11T06:00:00Z",
+			wantCohort: "1200-1300",
+		},
+		{
+			name:       "WithinMargin",
+			user:       &User{DojoCohort: "1400-1500", RatingSystem: Uscf, CohortDropSince: "2024-09-10T06:00:00Z", Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1450}}},
+			wantCohort: NoCohort,
+		},
+		{
+			name:       "FirstCohort",
+			user:       &User{DojoCohort: "0-300", RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 100}}},
+			wantCohort: NoCohort,
+		},
+		{
+			name:       "ProvisionalRating",
+			user:       &User{DojoCohort: "1400-1500", RatingSystem: Uscf, Ratings: map[RatingSystem]*Rating{Uscf: {CurrentRating: 1300, NumGames: 5}}},
+			wantCohort: NoCohort,
+		},
+		{
+			name:       "CustomRating",
+			user:       &User{DojoCohort: "1400-1500", RatingSystem: Custom, Ratings: map[RatingSystem]*Rating{Custom: {CurrentRating: 100}}},
+			wantCohort: NoCohort,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			since, cohort, sustained := EvaluateCohortDrop(tc.user, boundaries, 100, 30, now)
+			if since != tc.wantSince || cohort != tc.wantCohort || sustained != tc.wantSustained {
+				t.Errorf("EvaluateCohortDrop got %q, %q, %v; want %q, %q, %v", since, cohort, sustained, tc.wantSince, tc.wantCohort, tc.wantSustained)
+			}
+		})
+	}
+}
+
+func TestInMemoryGraduationSettings(t *testing.T) {
+	repo := NewInMemory()
+
+	settings, err := repo.GetGraduationSettings()
+	if err != nil || *settings != DefaultGraduationSettings {
+		t.Errorf("GetGraduationSettings before saving got %+v, %v; want the defaults", settings, err)
+	}
+
+	if err := repo.PutGraduationSettings(&GraduationSettings{SustainedDays: 3, AutoGraduate: true}); err != nil {
+		t.Fatalf("PutGraduationSettings got err: %v", err)
+	}
+	settings, err = repo.GetGraduationSettings()
+	if err != nil || settings.SustainedDays != 3 || !settings.AutoGraduate {
+		t.Errorf("GetGraduationSettings got %+v, %v; want 3 sustained days with auto-graduation", settings, err)
+	}
+
+	if err := (&GraduationSettings{SustainedDays: maxSustainedDays + 1}).Validate(); err == nil {
+		t.Errorf("Validate with %d sustained days got nil err; want an error", maxSustainedDays+1)
+	}
+	if err := (&GraduationSettings{AutoGraduate: true}).Validate(); err == nil {
+		t.Errorf("Validate with auto-graduation and 0 sustained days got nil err; want an error")
+	}
+	if err := (&GraduationSettings{DemotionMode: "SOMETIMES"}).Validate(); err == nil {
+		t.Errorf("Validate with an unknown demotion mode got nil err; want an error")
+	}
+	if err := (&GraduationSettings{DemotionMode: DemotionMode_Automatic, DemotionMargin: maxDemotionMargin + 1}).Validate(); err == nil {
+		t.Errorf("Validate with a demotion margin of %d got nil err; want an error", maxDemotionMargin+1)
+	}
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+type IdempotencyStatus string
+
+const (
+	// The request is being handled. Duplicate requests are rejected until it completes
+	// or its lock expires.
+	IdempotencyStatusInProgress IdempotencyStatus = "IN_PROGRESS"
+
+	// The request has completed. Duplicate requests receive the saved response.
+	IdempotencyStatusCompleted IdempotencyStatus = "COMPLETED"
+)
+
+const (
+	// How long a completed request's response is saved.
+	IdempotencyRecordTTL = 24 * time.Hour
+
+	// How long an in-progress request blocks duplicates. If the handler crashes, a
+	// retry is allowed after this long.
+	IdempotencyLockTTL = 30 * time.Second
+)
+
+// IdempotencyRecord saves the response of a request made with an Idempotency-Key header,
+// so that retries of the request receive the same response.
+type IdempotencyRecord struct {
+	// The id of the record, formatted as scope#idempotencyKey, where the scope is usually
+	// the username of the caller. Hash key of the idempotency table.
+	Id string `dynamodbav:"id" json:"id"`
+
+	// A hash of the request's method, path, query string and body. A request that reuses
+	// an Idempotency-Key with a different fingerprint is rejected.
+	Fingerprint string `dynamodbav:"fingerprint" json:"fingerprint"`
+
+	// The status of the request.
+	Status IdempotencyStatus `dynamodbav:"status" json:"status"`
+
+	// The status code of the saved response. Only set if the request has completed.
+	StatusCode int `dynamodbav:"statusCode,omitempty" json:"statusCode,omitempty"`
+
+	// The headers of the saved response. Only set if the request has completed.
+	Headers map[string]string `dynamodbav:"headers,omitempty" json:"headers,omitempty"`
+
+	// The body of the saved response. Only set if the request has completed.
+	Body string `dynamodbav:"body,omitempty" json:"body,omitempty"`
+
+	// Whether the body of the saved response is base64 encoded.
+	IsBase64Encoded bool `dynamodbav:"isBase64Encoded,omitempty" json:"isBase64Encoded,omitempty"`
+
+	// The time the request was first received, in time.RFC3339 format.
+	CreatedAt string `dynamodbav:"createdAt" json:"createdAt"`
+
+	// The time, in epoch seconds, after which an in-progress request no longer blocks duplicates.
+	LockExpirationTime int64 `dynamodbav:"lockExpirationTime,omitempty" json:"lockExpirationTime,omitempty"`
+
+	// The time, in epoch seconds, after which the record is ignored. DynamoDB deletes the record
+	// some time after this.
+	ExpirationTime int64 `dynamodbav:"expirationTime" json:"expirationTime"`
+}
+
+type IdempotencyStore interface {
+	// StartIdempotentRequest saves the provided record as in progress, unless an unexpired record
+	// with the same id already exists. In that case, the existing record is returned and the
+	// provided record is not saved.
+	StartIdempotentRequest(record *IdempotencyRecord) (*IdempotencyRecord, error)
+
+	// CompleteIdempotentRequest saves the provided record, which should contain the response
+	// of the request, as completed.
+	CompleteIdempotentRequest(record *IdempotencyRecord) error
+
+	// DeleteIdempotentRequest deletes the record with the provided id, so that the request
+	// can be retried.
+	DeleteIdempotentRequest(id string) error
+}
+
+// StartIdempotentRequest saves the provided record as in progress, unless an unexpired record
+// with the same id already exists. In that case, the existing record is returned and the
+// provided record is not saved.
+func (repo *dynamoRepository) StartIdempotentRequest(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	now := time.Now()
+	record.Status = IdempotencyStatusInProgress
+	record.CreatedAt = now.Format(time.RFC3339)
+	record.LockExpirationTime = now.Add(IdempotencyLockTTL).Unix()
+	record.ExpirationTime = now.Add(IdempotencyRecordTTL).Unix()
+
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", "Unable to marshal idempotency record", err)
+	}
+
+	// The existing record may be deleted between the failed put and the get, in which case
+	// the put is tried again.
+	for attempt := 0; attempt < 2; attempt++ {
+		input := &dynamodb.PutItemInput{
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(id) OR #exp < :now OR (#status = :inProgress AND #lock < :now)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#exp":    aws.String("expirationTime"),
+				"#status": aws.String("status"),
+				"#lock":   aws.String("lockExpirationTime"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now":        {N: aws.String(fmt.Sprint(now.Unix()))},
+				":inProgress": {S: aws.String(string(IdempotencyStatusInProgress))},
+			},
+			TableName: aws.String(idempotencyTable),
+		}
+
+		_, err = repo.svc.PutItem(input)
+		if err == nil {
+			return nil, nil
+		}
+		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); !ok {
+			return nil, errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+		}
+
+		existing := IdempotencyRecord{}
+		err = repo.getItem(&dynamodb.GetItemInput{
+			Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(record.Id)}},
+			ConsistentRead: aws.Bool(true),
+			TableName:      aws.String(idempotencyTable),
+		}, &existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if aerr, ok := err.(*errors.Error); !ok || aerr.Code != 404 {
+			return nil, err
+		}
+	}
+	return nil, errors.New(500, "Temporary server error", fmt.Sprintf("Idempotency record %q changed during StartIdempotentRequest", record.Id))
+}
+
+// CompleteIdempotentRequest saves the provided record, which should contain the response
+// of the request, as completed.
+func (repo *dynamoRepository) CompleteIdempotentRequest(record *IdempotencyRecord) error {
+	record.Status = IdempotencyStatusCompleted
+	record.LockExpirationTime = 0
+	if record.ExpirationTime == 0 {
+		record.ExpirationTime = time.Now().Add(IdempotencyRecordTTL).Unix()
+	}
+
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Unable to marshal idempotency record", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(idempotencyTable),
+	}
+	_, err = repo.svc.PutItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+}
+
+// DeleteIdempotentRequest deletes the record with the provided id, so that the request
+// can be retried.
+func (repo *dynamoRepository) DeleteIdempotentRequest(id string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		TableName: aws.String(idempotencyTable),
+	}
+	_, err := repo.svc.DeleteItem(input)
+	return errors.Wrap(500, "Temporary server error", "DynamoDB DeleteItem failure", err)
+}
@@ -19,6 +19,10 @@ type MediaStore interface {
 	// The image is saved in the default bucket for pictures.
 	UploadImage(key, imageData string) error
 
+	// UploadFile saves the provided data with the provided content type at the
+	// provided key. The file is saved in the default bucket for pictures.
+	UploadFile(key, contentType string, data []byte) error
+
 	// CopyImageFromURL copies the image from the provided url to the media
 	// store at the provided key. The image is saved in the default bucket for
 	// pictures.
@@ -63,6 +67,18 @@ func (ms *s3MediaStore) UploadImage(key, imageData string) error {
 	return errors.Wrap(500, "Temporary server error", "Failed to upload image", err)
 }
 
+// UploadFile saves the provided data with the provided content type at the
+// provided key. The file is saved in the default bucket for pictures.
+func (ms *s3MediaStore) UploadFile(key, contentType string, data []byte) error {
+	_, err := ms.uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(picturesBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	return errors.Wrap(500, "Temporary server error", "Failed to upload file", err)
+}
+
 // CopyImageFromURL copies the image from the provided url to the media
 // store at the provided key. The image is saved in the default bucket for
 // pictures.
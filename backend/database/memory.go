@@ -0,0 +1,1029 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// maxPageBytes is the maximum amount of data DynamoDB evaluates in a single
+// Query or Scan request.
+const maxPageBytes = 1024 * 1024
+
+// memoryIndex describes the key schema and projection of a global secondary index.
+type memoryIndex struct {
+	hashKey  string
+	rangeKey string
+
+	// One of ALL, KEYS_ONLY or INCLUDE.
+	projection string
+
+	// The non-key attributes projected into an INCLUDE index.
+	nonKeyAttributes []string
+}
+
+// memoryTable is a single DynamoDB table stored in memory.
+type memoryTable struct {
+	hashKey  string
+	rangeKey string
+	indices  map[string]memoryIndex
+
+	// The items in the table, keyed by the string form of their primary key.
+	items map[string]attributeMap
+}
+
+// memoryTableSchemas returns the key schemas of all tables used by this package, keyed
+// by the table name without the stage prefix. These must be kept in sync with the table
+// definitions in serverless.yml.
+func memoryTableSchemas() map[string]*memoryTable {
+	gameIndexAttributes := []string{
+		"white", "black", "date", "createdAt", "updatedAt", "publishedAt", "owner",
+		"ownerDisplayName", "ownerPreviousCohort", "headers", "unlisted",
+	}
+
+	return map[string]*memoryTable{
+		"users": {
+			hashKey: "username",
+			indices: map[string]memoryIndex{
+				"CohortIdx": {hashKey: "dojoCohort", rangeKey: "username", projection: "ALL"},
+				"SearchIdx": {
+					hashKey: "dojoCohort", rangeKey: "username", projection: "INCLUDE",
+					nonKeyAttributes: []string{"displayName", "discordUsername", "searchKey", "ratings"},
+				},
+				"ScoreboardSummaryIdx": {
+					hashKey: "subscriptionStatus", rangeKey: "username", projection: "INCLUDE",
+					nonKeyAttributes: []string{
+						"displayName", "graduationCohorts", "previousCohort", "ratings", "ratingSystem",
+						"totalDojoScore", "dojoCohort", "provisionalCohort", "updatedAt", "minutesSpent",
+					},
+				},
+			},
+		},
+		"timeline":     {hashKey: "owner", rangeKey: "id"},
+		"requirements": {hashKey: "status", rangeKey: "id"},
+		"graduations": {
+			hashKey:  "username",
+			rangeKey: "previousCohort",
+			indices: map[string]memoryIndex{
+				graduationTableCohortIndex: {hashKey: "previousCohort", rangeKey: "createdAt", projection: "ALL"},
+				"DateIndex":                {hashKey: "type", rangeKey: "createdAt", projection: "ALL"},
+			},
+		},
+		"events": {hashKey: "id"},
+		"games": {
+			hashKey:  "cohort",
+			rangeKey: "id",
+			indices: map[string]memoryIndex{
+				gameTableOwnerIndex:    {hashKey: "owner", rangeKey: "id", projection: "INCLUDE", nonKeyAttributes: gameIndexAttributes},
+				gameTableWhiteIndex:    {hashKey: "white", rangeKey: "id", projection: "INCLUDE", nonKeyAttributes: gameIndexAttributes},
+				gameTableBlackIndex:    {hashKey: "black", rangeKey: "id", projection: "INCLUDE", nonKeyAttributes: gameIndexAttributes},
+				gameTableFeaturedIndex: {hashKey: "isFeatured", rangeKey: "featuredAt", projection: "INCLUDE", nonKeyAttributes: gameIndexAttributes},
+				gameTableReviewIndex: {
+					hashKey: "reviewStatus", rangeKey: "reviewRequestedAt", projection: "INCLUDE",
+					nonKeyAttributes: append([]string{"review"}, gameIndexAttributes...),
+				},
+			},
+		},
+		"courses": {
+			hashKey:  "type",
+			rangeKey: "id",
+			indices: map[string]memoryIndex{
+				"SummaryIndex": {
+					hashKey: "type", rangeKey: "id", projection: "INCLUDE",
+					nonKeyAttributes: []string{
+						"name", "description", "whatsIncluded", "color", "cohorts", "cohortRange",
+						"includedWithSubscription", "availableForFreeUsers", "purchaseOptions", "owner", "ownerDisplayName",
+					},
+				},
+			},
+		},
+		"tournaments": {
+			hashKey:  "type",
+			rangeKey: "startsAt",
+			indices: map[string]memoryIndex{
+				tournamentTableOpenClassicalIndex: {hashKey: "type", rangeKey: "name", projection: "KEYS_ONLY"},
+			},
+		},
+		"notifications": {hashKey: "username", rangeKey: "id"},
+		"followers": {
+			hashKey:  "poster",
+			rangeKey: "follower",
+			indices: map[string]memoryIndex{
+				"FollowingIndex": {hashKey: "follower", rangeKey: "poster", projection: "ALL"},
+			},
+		},
+		"newsfeed": {
+			hashKey:  "newsfeedId",
+			rangeKey: "sortKey",
+			indices: map[string]memoryIndex{
+				"PosterIndex": {hashKey: "poster", rangeKey: "timelineId", projection: "KEYS_ONLY"},
+			},
+		},
+		"yearReviews": {hashKey: "username", rangeKey: "period"},
+		"clubs":       {hashKey: "id"},
+		"exams":       {hashKey: "type", rangeKey: "id"},
+		"idempotency": {hashKey: "id"},
+		"audit": {
+			hashKey:  "target",
+			rangeKey: "id",
+			indices: map[string]memoryIndex{
+				auditTableActorIndex: {hashKey: "actor", rangeKey: "id", projection: "ALL"},
+				auditTableDateIndex:  {hashKey: "type", rangeKey: "id", projection: "ALL"},
+			},
+		},
+		"rating-history":    {hashKey: "username", rangeKey: "id"},
+		"cohort-boundaries": {hashKey: "status", rangeKey: "id"},
+		"rating-anomalies": {
+			hashKey:  "username",
+			rangeKey: "id",
+			indices: map[string]memoryIndex{
+				ratingAnomalyTableStatusIndex: {hashKey: "status", rangeKey: "createdAt", projection: "ALL"},
+			},
+		},
+		"cohort-changes": {hashKey: "username", rangeKey: "createdAt"},
+	}
+}
+
+// memoryDynamo implements the subset of the DynamoDB API used by dynamoRepository
+// using tables stored in memory. Key schemas, secondary indices, conditional writes,
+// expressions and pagination behave like DynamoDB, so that code written against
+// dynamoRepository can be tested without access to AWS.
+type memoryDynamo struct {
+	// Embedded so that memoryDynamo satisfies DynamoDBAPI. Calling a method
+	// not implemented below panics.
+	dynamodbiface.DynamoDBAPI
+
+	mu     sync.Mutex
+	tables map[string]*memoryTable
+
+	// If positive, the maximum number of items evaluated by a single Query or
+	// Scan request, in addition to DynamoDB's 1MB limit. Useful for testing pagination.
+	pageSize int
+
+	// The stage prefixed to the table names.
+	stage string
+}
+
+// MemoryOption customizes the behavior of an in-memory repository.
+type MemoryOption func(*memoryDynamo)
+
+// WithPageSize limits the number of items evaluated by each query or scan
+// to the given size, so that pagination can be tested with small data sets.
+func WithPageSize(size int) MemoryOption {
+	return func(m *memoryDynamo) {
+		m.pageSize = size
+	}
+}
+
+// WithStage sets the stage prefixed to the table names. By default, the stage
+// environment variable is used.
+func WithStage(stage string) MemoryOption {
+	return func(m *memoryDynamo) {
+		m.stage = stage
+	}
+}
+
+// NewMemoryDynamoDB returns an implementation of the DynamoDB API backed by
+// tables stored in memory. It is intended for tests and local development.
+func NewMemoryDynamoDB(opts ...MemoryOption) dynamodbiface.DynamoDBAPI {
+	m := &memoryDynamo{stage: stage, tables: make(map[string]*memoryTable)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for name, t := range memoryTableSchemas() {
+		t.items = make(map[string]attributeMap)
+		m.tables[m.stage+"-"+name] = t
+	}
+	return m
+}
+
+// NewInMemory returns a repository implementing all database interfaces using
+// tables stored in memory. It is intended for tests and local development.
+func NewInMemory(opts ...MemoryOption) *dynamoRepository {
+	return &dynamoRepository{svc: NewMemoryDynamoDB(opts...)}
+}
+
+func (m *memoryDynamo) table(name *string) (*memoryTable, error) {
+	t, ok := m.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, &dynamodb.ResourceNotFoundException{
+			Message_: aws.String(fmt.Sprintf("Requested resource not found: Table: %s not found", aws.StringValue(name))),
+		}
+	}
+	return t, nil
+}
+
+func conditionalCheckFailed() error {
+	return &dynamodb.ConditionalCheckFailedException{Message_: aws.String("The conditional request failed")}
+}
+
+// keyAttributes returns the names of the table's key attributes.
+func (t *memoryTable) keyAttributes() []string {
+	if t.rangeKey == "" {
+		return []string{t.hashKey}
+	}
+	return []string{t.hashKey, t.rangeKey}
+}
+
+// primaryKey validates the given key and returns its string form.
+func (t *memoryTable) primaryKey(key attributeMap) (string, error) {
+	attrs := t.keyAttributes()
+	if len(key) != len(attrs) {
+		return "", validationError("The provided key element does not match the schema")
+	}
+
+	parts := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		v, ok := key[a]
+		if !ok || v == nil || (v.S == nil && v.N == nil && v.B == nil) {
+			return "", validationError("The provided key element does not match the schema")
+		}
+		if v.S != nil && *v.S == "" {
+			return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", a)
+		}
+		parts = append(parts, attributeValueType(v)+":"+keyString(v))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+func keyString(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return *v.S
+	case v.N != nil:
+		return *v.N
+	}
+	return string(v.B)
+}
+
+// itemKey returns the primary key attributes of the given item.
+func (t *memoryTable) itemKey(item attributeMap) attributeMap {
+	key := make(attributeMap)
+	for _, a := range t.keyAttributes() {
+		key[a] = copyAttributeValue(item[a])
+	}
+	return key
+}
+
+// GetItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := t.primaryKey(input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, nil)
+	projection, err := parseProjectionExpression(input.ProjectionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.GetItemOutput{}
+	if item, ok := t.items[pk]; ok {
+		output.Item = projectItem(item, projection)
+	}
+	return output, nil
+}
+
+// PutItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := t.primaryKey(t.itemKey(input.Item))
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	cond, err := parseConditionExpression(input.ConditionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	existing := t.items[pk]
+	if ok, err := matchesCondition(cond, existing); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed()
+	}
+
+	t.items[pk] = copyAttributeMap(input.Item)
+
+	output := &dynamodb.PutItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld && existing != nil {
+		output.Attributes = copyAttributeMap(existing)
+	}
+	return output, nil
+}
+
+// UpdateItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := t.primaryKey(input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	cond, err := parseConditionExpression(input.ConditionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := parseUpdateExpression(aws.StringValue(input.UpdateExpression), attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+	for _, a := range actions {
+		for _, k := range t.keyAttributes() {
+			if len(a.path) == 1 && a.path[0].name == k {
+				return nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", k)
+			}
+		}
+	}
+
+	existing, exists := t.items[pk]
+	if ok, err := matchesCondition(cond, existing); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed()
+	}
+
+	base := existing
+	if !exists {
+		base = copyAttributeMap(input.Key)
+	}
+	updated, err := applyUpdate(base, actions)
+	if err != nil {
+		return nil, err
+	}
+	t.items[pk] = updated
+
+	output := &dynamodb.UpdateItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case dynamodb.ReturnValueAllNew:
+		output.Attributes = copyAttributeMap(updated)
+	case dynamodb.ReturnValueAllOld:
+		if exists {
+			output.Attributes = copyAttributeMap(existing)
+		}
+	case dynamodb.ReturnValueUpdatedNew:
+		output.Attributes = updatedAttributes(updated, actions)
+	case dynamodb.ReturnValueUpdatedOld:
+		if exists {
+			output.Attributes = updatedAttributes(existing, actions)
+		}
+	}
+	return output, nil
+}
+
+// updatedAttributes returns the top-level attributes of item modified by the given actions.
+func updatedAttributes(item attributeMap, actions []updateAction) attributeMap {
+	result := make(attributeMap)
+	for _, a := range actions {
+		if v, ok := item[a.path[0].name]; ok {
+			result[a.path[0].name] = copyAttributeValue(v)
+		}
+	}
+	return result
+}
+
+// DeleteItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := t.primaryKey(input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	cond, err := parseConditionExpression(input.ConditionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	existing, exists := t.items[pk]
+	if ok, err := matchesCondition(cond, existing); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed()
+	}
+	delete(t.items, pk)
+
+	output := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld && exists {
+		output.Attributes = copyAttributeMap(existing)
+	}
+	return output, nil
+}
+
+// indexView is the list of items visible through either the base table or one
+// of its indices, along with the ordering of those items.
+type indexView struct {
+	table    *memoryTable
+	hashKey  string
+	rangeKey string
+	index    *memoryIndex
+}
+
+func (m *memoryDynamo) view(t *memoryTable, indexName *string) (*indexView, error) {
+	if indexName == nil {
+		return &indexView{table: t, hashKey: t.hashKey, rangeKey: t.rangeKey}, nil
+	}
+	idx, ok := t.indices[*indexName]
+	if !ok {
+		return nil, validationError("The table does not have the specified index: %s", *indexName)
+	}
+	return &indexView{table: t, hashKey: idx.hashKey, rangeKey: idx.rangeKey, index: &idx}, nil
+}
+
+// keyNames returns the attributes that make up the ordering of the view. For
+// an index, the table's keys are used as tie breakers.
+func (v *indexView) keyNames() []string {
+	names := []string{v.hashKey}
+	if v.rangeKey != "" {
+		names = append(names, v.rangeKey)
+	}
+	if v.index != nil {
+		for _, k := range v.table.keyAttributes() {
+			if k != v.hashKey && k != v.rangeKey {
+				names = append(names, k)
+			}
+		}
+	}
+	return names
+}
+
+// compareKeys orders two items (or an item and an ExclusiveStartKey) by the view's key names.
+func (v *indexView) compareKeys(a, b attributeMap) int {
+	for _, k := range v.keyNames() {
+		av, bv := a[k], b[k]
+		if av == nil || bv == nil {
+			continue
+		}
+		if c, ok := compareAttributeValues(av, bv); ok && c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// items returns the projected items visible through the view, sorted ascending.
+func (v *indexView) items() []attributeMap {
+	var result []attributeMap
+	for _, item := range v.table.items {
+		if v.index == nil {
+			result = append(result, item)
+			continue
+		}
+		if item[v.hashKey] == nil || (v.rangeKey != "" && item[v.rangeKey] == nil) {
+			continue
+		}
+		result = append(result, v.project(item))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return v.compareKeys(result[i], result[j]) < 0
+	})
+	return result
+}
+
+// project applies the index projection to the given item.
+func (v *indexView) project(item attributeMap) attributeMap {
+	if v.index.projection == "ALL" {
+		return item
+	}
+
+	result := make(attributeMap)
+	for _, k := range v.keyNames() {
+		if val, ok := item[k]; ok {
+			result[k] = val
+		}
+	}
+	if v.index.projection == "INCLUDE" {
+		for _, k := range v.index.nonKeyAttributes {
+			if val, ok := item[k]; ok {
+				result[k] = val
+			}
+		}
+	}
+	return result
+}
+
+// lastEvaluatedKey returns the key of the item as returned in LastEvaluatedKey.
+func (v *indexView) lastEvaluatedKey(item attributeMap) attributeMap {
+	key := make(attributeMap)
+	for _, k := range v.keyNames() {
+		key[k] = copyAttributeValue(item[k])
+	}
+	return key
+}
+
+// pageResult is the result of reading a single page from an indexView.
+type pageResult struct {
+	items            []attributeMap
+	count            int
+	scannedCount     int
+	lastEvaluatedKey attributeMap
+}
+
+// readPage reads a single page of items, following the DynamoDB rules for
+// Limit, ExclusiveStartKey and the 1MB page size.
+func (m *memoryDynamo) readPage(v *indexView, candidates []attributeMap, startKey attributeMap, forward bool, limit *int64, filter condition, projection []documentPath) (*pageResult, error) {
+	if !forward {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+
+	start := 0
+	if len(startKey) > 0 {
+		for start < len(candidates) {
+			c := v.compareKeys(candidates[start], startKey)
+			if (forward && c > 0) || (!forward && c < 0) {
+				break
+			}
+			start++
+		}
+	}
+
+	result := &pageResult{}
+	size := 0
+	for i := start; i < len(candidates); i++ {
+		item := candidates[i]
+		result.scannedCount++
+		size += itemSize(item)
+
+		ok, err := matchesCondition(filter, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.items = append(result.items, projectItem(item, projection))
+		}
+
+		full := (limit != nil && int64(result.scannedCount) >= *limit) ||
+			(m.pageSize > 0 && result.scannedCount >= m.pageSize) ||
+			size >= maxPageBytes
+		if full && i < len(candidates)-1 {
+			result.lastEvaluatedKey = v.lastEvaluatedKey(item)
+			break
+		}
+	}
+	result.count = len(result.items)
+	return result, nil
+}
+
+// Query implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := m.view(t, input.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if input.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+	keyCondition, err := parseConditionExpression(input.KeyConditionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := parseConditionExpression(input.FilterExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseProjectionExpression(input.ProjectionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+	if err := v.validateKeyCondition(keyCondition); err != nil {
+		return nil, err
+	}
+
+	var candidates []attributeMap
+	for _, item := range v.items() {
+		ok, err := keyCondition.matches(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, item)
+		}
+	}
+
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	page, err := m.readPage(v, candidates, input.ExclusiveStartKey, forward, input.Limit, filter, projection)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.QueryOutput{
+		Count:            aws.Int64(int64(page.count)),
+		ScannedCount:     aws.Int64(int64(page.scannedCount)),
+		LastEvaluatedKey: page.lastEvaluatedKey,
+	}
+	if aws.StringValue(input.Select) != dynamodb.SelectCount {
+		output.Items = page.items
+		if output.Items == nil {
+			output.Items = []map[string]*dynamodb.AttributeValue{}
+		}
+	}
+	return output, nil
+}
+
+// validateKeyCondition ensures the key condition only references the view's
+// key attributes and contains an equality condition on the hash key.
+func (v *indexView) validateKeyCondition(c condition) error {
+	hasHash := false
+	var visit func(c condition) error
+	visit = func(c condition) error {
+		switch cond := c.(type) {
+		case andCondition:
+			if err := visit(cond.left); err != nil {
+				return err
+			}
+			return visit(cond.right)
+		case comparisonCondition:
+			path, ok := cond.left.(pathOperand)
+			if !ok || len(path.path) != 1 {
+				return validationError("Invalid KeyConditionExpression: key attributes must be on the left side of the comparison")
+			}
+			name := path.path[0].name
+			if name == v.hashKey && cond.comparator == "=" {
+				hasHash = true
+				return nil
+			}
+			if name == v.rangeKey && cond.comparator != "<>" {
+				return nil
+			}
+			return validationError("Query condition missed key schema element or used an unsupported operator on %s", name)
+		case betweenCondition:
+			path, ok := cond.value.(pathOperand)
+			if ok && len(path.path) == 1 && path.path[0].name == v.rangeKey {
+				return nil
+			}
+		case functionCondition:
+			if cond.function == "begins_with" && len(cond.path) == 1 && cond.path[0].name == v.rangeKey {
+				return nil
+			}
+		}
+		return validationError("Invalid operator used in KeyConditionExpression")
+	}
+
+	if err := visit(c); err != nil {
+		return err
+	}
+	if !hasHash {
+		return validationError("Query condition missed key schema element: %s", v.hashKey)
+	}
+	return nil
+}
+
+// Scan implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := m.view(t, input.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := newExpressionAttributes(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	filter, err := parseConditionExpression(input.FilterExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseProjectionExpression(input.ProjectionExpression, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := attrs.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	page, err := m.readPage(v, v.items(), input.ExclusiveStartKey, true, input.Limit, filter, projection)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.ScanOutput{
+		Count:            aws.Int64(int64(page.count)),
+		ScannedCount:     aws.Int64(int64(page.scannedCount)),
+		LastEvaluatedKey: page.lastEvaluatedKey,
+	}
+	if aws.StringValue(input.Select) != dynamodb.SelectCount {
+		output.Items = page.items
+		if output.Items == nil {
+			output.Items = []map[string]*dynamodb.AttributeValue{}
+		}
+	}
+	return output, nil
+}
+
+// BatchGetItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := 0
+	for _, ka := range input.RequestItems {
+		total += len(ka.Keys)
+	}
+	if total > 100 {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]*dynamodb.AttributeValue),
+		UnprocessedKeys: make(map[string]*dynamodb.KeysAndAttributes),
+	}
+	for tableName, ka := range input.RequestItems {
+		t, err := m.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+
+		attrs := newExpressionAttributes(ka.ExpressionAttributeNames, nil)
+		projection, err := parseProjectionExpression(ka.ProjectionExpression, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if err := attrs.checkUnused(); err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		items := []map[string]*dynamodb.AttributeValue{}
+		for _, key := range ka.Keys {
+			pk, err := t.primaryKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if seen[pk] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[pk] = true
+			if item, ok := t.items[pk]; ok {
+				items = append(items, projectItem(item, projection))
+			}
+		}
+		output.Responses[tableName] = items
+	}
+	return output, nil
+}
+
+// BatchWriteItem implements dynamodbiface.DynamoDBAPI.
+func (m *memoryDynamo) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := 0
+	for _, reqs := range input.RequestItems {
+		total += len(reqs)
+	}
+	if total > 25 {
+		return nil, validationError("Too many items requested for the BatchWriteItem call")
+	}
+
+	// Validate the entire batch before writing anything, as DynamoDB rejects
+	// invalid batches as a whole.
+	type write struct {
+		table *memoryTable
+		pk    string
+		item  attributeMap
+	}
+	var writes []write
+	seen := make(map[string]bool)
+	for tableName, reqs := range input.RequestItems {
+		t, err := m.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		for _, req := range reqs {
+			var w write
+			var err error
+			switch {
+			case req.PutRequest != nil:
+				w = write{table: t, item: req.PutRequest.Item}
+				w.pk, err = t.primaryKey(t.itemKey(req.PutRequest.Item))
+			case req.DeleteRequest != nil:
+				w = write{table: t}
+				w.pk, err = t.primaryKey(req.DeleteRequest.Key)
+			default:
+				err = validationError("Supplied WriteRequest has neither PutRequest nor DeleteRequest")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if seen[tableName+"\x01"+w.pk] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[tableName+"\x01"+w.pk] = true
+			writes = append(writes, w)
+		}
+	}
+
+	for _, w := range writes {
+		if w.item != nil {
+			w.table.items[w.pk] = copyAttributeMap(w.item)
+		} else {
+			delete(w.table.items, w.pk)
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]*dynamodb.WriteRequest{},
+	}, nil
+}
+
+var partiqlUpdateRegex = regexp.MustCompile(`(?is)^\s*UPDATE\s+"([^"]+)"\s+(SET\s+.+?)\s+WHERE\s+(.+?)\s*$`)
+var partiqlSetRegex = regexp.MustCompile(`(?is)^SET\s+(.+?)\s*=\s*(\?|'[^']*')\s*`)
+var partiqlAndRegex = regexp.MustCompile(`(?i)\s+AND\s+`)
+var partiqlWhereRegex = regexp.MustCompile(`(?is)^\s*(\w+)\s*=\s*(\?|'[^']*')\s*$`)
+var partiqlMissingRegex = regexp.MustCompile(`(?is)^\s*(\w+)\s+IS\s+MISSING\s*$`)
+
+// BatchExecuteStatement implements dynamodbiface.DynamoDBAPI. Only PartiQL UPDATE
+// statements of the form UPDATE "table" SET a=? [SET b.c=?...] WHERE key=? [AND d=?] [AND e IS MISSING]
+// are supported. The first WHERE clause must select the item's key.
+func (m *memoryDynamo) BatchExecuteStatement(input *dynamodb.BatchExecuteStatementInput) (*dynamodb.BatchExecuteStatementOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(input.Statements) > 25 {
+		return nil, validationError("Too many statements in the BatchExecuteStatement call")
+	}
+
+	output := &dynamodb.BatchExecuteStatementOutput{}
+	for _, stmt := range input.Statements {
+		resp := &dynamodb.BatchStatementResponse{}
+		tableName, err := m.executeUpdateStatement(aws.StringValue(stmt.Statement), stmt.Parameters)
+		if err != nil {
+			if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
+				resp.Error = &dynamodb.BatchStatementError{
+					Code:    aws.String(dynamodb.BatchStatementErrorCodeEnumConditionalCheckFailed),
+					Message: aws.String(err.Error()),
+				}
+			} else {
+				return nil, err
+			}
+		}
+		resp.TableName = aws.String(tableName)
+		output.Responses = append(output.Responses, resp)
+	}
+	return output, nil
+}
+
+// executeUpdateStatement executes a single PartiQL UPDATE statement. As in DynamoDB,
+// updating an item that does not exist fails with a conditional check failure.
+func (m *memoryDynamo) executeUpdateStatement(statement string, params []*dynamodb.AttributeValue) (string, error) {
+	match := partiqlUpdateRegex.FindStringSubmatch(statement)
+	if match == nil {
+		return "", validationError("Unsupported PartiQL statement: %s", statement)
+	}
+	tableName := match[1]
+	t, err := m.table(aws.String(tableName))
+	if err != nil {
+		return tableName, err
+	}
+
+	nextParam := func(token string) (*dynamodb.AttributeValue, error) {
+		if token != "?" {
+			return &dynamodb.AttributeValue{S: aws.String(strings.Trim(token, "'"))}, nil
+		}
+		if len(params) == 0 {
+			return nil, validationError("Number of parameters in request and statement don't match.")
+		}
+		p := params[0]
+		params = params[1:]
+		return p, nil
+	}
+
+	type assignment struct {
+		path  documentPath
+		value *dynamodb.AttributeValue
+	}
+	var assignments []assignment
+	sets := match[2]
+	for strings.TrimSpace(sets) != "" {
+		setMatch := partiqlSetRegex.FindStringSubmatch(sets)
+		if setMatch == nil {
+			return tableName, validationError("Unsupported PartiQL SET clause: %s", sets)
+		}
+		sets = sets[len(setMatch[0]):]
+
+		var path documentPath
+		for _, part := range strings.Split(setMatch[1], ".") {
+			path = append(path, pathElement{name: strings.Trim(strings.TrimSpace(part), `"`)})
+		}
+		value, err := nextParam(setMatch[2])
+		if err != nil {
+			return tableName, err
+		}
+		assignments = append(assignments, assignment{path: path, value: value})
+	}
+
+	type whereCondition struct {
+		name    string
+		value   *dynamodb.AttributeValue
+		missing bool
+	}
+	var conditions []whereCondition
+	for _, clause := range partiqlAndRegex.Split(match[3], -1) {
+		if whereMatch := partiqlWhereRegex.FindStringSubmatch(clause); whereMatch != nil {
+			value, err := nextParam(whereMatch[2])
+			if err != nil {
+				return tableName, err
+			}
+			conditions = append(conditions, whereCondition{name: whereMatch[1], value: value})
+		} else if missingMatch := partiqlMissingRegex.FindStringSubmatch(clause); missingMatch != nil && len(conditions) > 0 {
+			conditions = append(conditions, whereCondition{name: missingMatch[1], missing: true})
+		} else {
+			return tableName, validationError("Unsupported PartiQL WHERE clause: %s", match[3])
+		}
+	}
+	if len(params) > 0 {
+		return tableName, validationError("Number of parameters in request and statement don't match.")
+	}
+
+	pk, err := t.primaryKey(attributeMap{conditions[0].name: conditions[0].value})
+	if err != nil {
+		return tableName, err
+	}
+	existing, ok := t.items[pk]
+	if !ok {
+		return tableName, conditionalCheckFailed()
+	}
+	for _, c := range conditions[1:] {
+		v, exists := existing[c.name]
+		if c.missing && exists {
+			return tableName, conditionalCheckFailed()
+		}
+		if !c.missing && (!exists || !attributeValuesEqual(v, c.value)) {
+			return tableName, conditionalCheckFailed()
+		}
+	}
+
+	updated := copyAttributeMap(existing)
+	for _, a := range assignments {
+		if err := a.path.set(updated, copyAttributeValue(a.value)); err != nil {
+			return tableName, err
+		}
+	}
+	t.items[pk] = updated
+	return tableName, nil
+}
@@ -0,0 +1,1432 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// This file contains the parser and evaluator for the DynamoDB expression
+// language used by memoryDynamo. It supports the subset of condition, key
+// condition, filter, update and projection expressions used by this repository.
+
+type attributeMap = map[string]*dynamodb.AttributeValue
+
+// validationError returns an error matching the one returned by DynamoDB
+// for malformed requests.
+func validationError(format string, args ...interface{}) error {
+	return awserr.New("ValidationException", fmt.Sprintf(format, args...), nil)
+}
+
+type exprTokenKind int
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenName
+	tokenValue
+	tokenNumber
+	tokenPunct
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+}
+
+// tokenizeExpression splits the given expression into tokens.
+func tokenizeExpression(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(expr)
+
+	isWordRune := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, validationError("Invalid expression: unexpected %q in %q", r, expr)
+			}
+			kind := tokenName
+			if r == ':' {
+				kind = tokenValue
+			}
+			tokens = append(tokens, exprToken{kind: kind, text: string(runes[i:j])})
+			i = j
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<>" || two == "<=" || two == ">=" {
+					tokens = append(tokens, exprToken{kind: tokenPunct, text: two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("()[],.=<>+-", r) {
+				tokens = append(tokens, exprToken{kind: tokenPunct, text: string(r)})
+				i++
+				continue
+			}
+			return nil, validationError("Invalid expression: unexpected %q in %q", r, expr)
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokenEOF}), nil
+}
+
+// expressionAttributes holds the ExpressionAttributeNames and ExpressionAttributeValues
+// of a single request. It records which placeholders were used so that unused
+// placeholders can be rejected the same way DynamoDB does.
+type expressionAttributes struct {
+	names      map[string]*string
+	values     attributeMap
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExpressionAttributes(names map[string]*string, values attributeMap) *expressionAttributes {
+	return &expressionAttributes{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+func (ea *expressionAttributes) name(placeholder string) (string, error) {
+	n, ok := ea.names[placeholder]
+	if !ok || n == nil {
+		return "", validationError("An expression attribute name used in the document path is not defined; attribute name: %s", placeholder)
+	}
+	ea.usedNames[placeholder] = true
+	return *n, nil
+}
+
+func (ea *expressionAttributes) value(placeholder string) (*dynamodb.AttributeValue, error) {
+	v, ok := ea.values[placeholder]
+	if !ok || v == nil {
+		return nil, validationError("An expression attribute value used in expression is not defined; attribute value: %s", placeholder)
+	}
+	ea.usedValues[placeholder] = true
+	return v, nil
+}
+
+// checkUnused returns an error if any of the provided names or values were
+// not referenced by a parsed expression.
+func (ea *expressionAttributes) checkUnused() error {
+	for n := range ea.names {
+		if !ea.usedNames[n] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", n)
+		}
+	}
+	for v := range ea.values {
+		if !ea.usedValues[v] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", v)
+		}
+	}
+	return nil
+}
+
+// pathElement is a single element of a document path. It is either
+// a map key or a list index.
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// documentPath is a path to an attribute within an item, such as a.b[2].c.
+type documentPath []pathElement
+
+func (p documentPath) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			sb.WriteString(fmt.Sprintf("[%d]", e.index))
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+// get returns the value at the path within the given item.
+func (p documentPath) get(item attributeMap) (*dynamodb.AttributeValue, bool) {
+	current := &dynamodb.AttributeValue{M: item}
+	for _, e := range p {
+		if e.isIndex {
+			if current.L == nil || e.index >= len(current.L) {
+				return nil, false
+			}
+			current = current.L[e.index]
+		} else {
+			if current.M == nil {
+				return nil, false
+			}
+			v, ok := current.M[e.name]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		}
+		if current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// parent returns the container holding the last element of the path.
+func (p documentPath) parent(item attributeMap) (*dynamodb.AttributeValue, bool) {
+	if len(p) == 1 {
+		return &dynamodb.AttributeValue{M: item}, true
+	}
+	return p[:len(p)-1].get(item)
+}
+
+// set stores the value at the path within the given item. All but the last
+// element of the path must already exist.
+func (p documentPath) set(item attributeMap, value *dynamodb.AttributeValue) error {
+	parent, ok := p.parent(item)
+	last := p[len(p)-1]
+	if !ok {
+		return validationError("The document path provided in the update expression is invalid for update: %s", p)
+	}
+
+	if last.isIndex {
+		if parent.L == nil {
+			return validationError("The document path provided in the update expression is invalid for update: %s", p)
+		}
+		if last.index >= len(parent.L) {
+			parent.L = append(parent.L, value)
+		} else {
+			parent.L[last.index] = value
+		}
+		return nil
+	}
+
+	if parent.M == nil {
+		return validationError("The document path provided in the update expression is invalid for update: %s", p)
+	}
+	parent.M[last.name] = value
+	return nil
+}
+
+// remove deletes the value at the path within the given item. Removing a path
+// that does not exist is a no-op.
+func (p documentPath) remove(item attributeMap) {
+	parent, ok := p.parent(item)
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	if last.isIndex {
+		if parent.L != nil && last.index < len(parent.L) {
+			parent.L = append(parent.L[:last.index], parent.L[last.index+1:]...)
+		}
+		return
+	}
+	if parent.M != nil {
+		delete(parent.M, last.name)
+	}
+}
+
+// operand is a value used in a condition or update expression.
+type operand interface {
+	evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error)
+}
+
+type pathOperand struct{ path documentPath }
+
+func (o pathOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	v, ok := o.path.get(item)
+	return v, ok, nil
+}
+
+type valueOperand struct{ value *dynamodb.AttributeValue }
+
+func (o valueOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	return o.value, true, nil
+}
+
+type sizeOperand struct{ path documentPath }
+
+func (o sizeOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	v, ok := o.path.get(item)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var size int
+	switch {
+	case v.S != nil:
+		size = len(*v.S)
+	case v.B != nil:
+		size = len(v.B)
+	case v.L != nil:
+		size = len(v.L)
+	case v.M != nil:
+		size = len(v.M)
+	case v.SS != nil:
+		size = len(v.SS)
+	case v.NS != nil:
+		size = len(v.NS)
+	case v.BS != nil:
+		size = len(v.BS)
+	default:
+		return nil, false, nil
+	}
+	return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(size))}, true, nil
+}
+
+type arithmeticOperand struct {
+	left, right operand
+	subtract    bool
+}
+
+func (o arithmeticOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	l, lok, err := o.left.evaluate(item)
+	if err != nil {
+		return nil, false, err
+	}
+	r, rok, err := o.right.evaluate(item)
+	if err != nil {
+		return nil, false, err
+	}
+	if !lok || !rok {
+		return nil, false, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	if l.N == nil || r.N == nil {
+		return nil, false, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	lf, _, err := big.ParseFloat(*l.N, 10, 128, big.ToNearestEven)
+	if err != nil {
+		return nil, false, validationError("Invalid number %q", *l.N)
+	}
+	rf, _, err := big.ParseFloat(*r.N, 10, 128, big.ToNearestEven)
+	if err != nil {
+		return nil, false, validationError("Invalid number %q", *r.N)
+	}
+	if o.subtract {
+		lf.Sub(lf, rf)
+	} else {
+		lf.Add(lf, rf)
+	}
+	return &dynamodb.AttributeValue{N: aws.String(formatNumber(lf))}, true, nil
+}
+
+type listAppendOperand struct{ first, second operand }
+
+func (o listAppendOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	f, fok, err := o.first.evaluate(item)
+	if err != nil {
+		return nil, false, err
+	}
+	s, sok, err := o.second.evaluate(item)
+	if err != nil {
+		return nil, false, err
+	}
+	if !fok || !sok {
+		return nil, false, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	if f.L == nil || s.L == nil {
+		return nil, false, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	result := make([]*dynamodb.AttributeValue, 0, len(f.L)+len(s.L))
+	result = append(result, f.L...)
+	result = append(result, s.L...)
+	return &dynamodb.AttributeValue{L: result}, true, nil
+}
+
+type ifNotExistsOperand struct {
+	path     documentPath
+	fallback operand
+}
+
+func (o ifNotExistsOperand) evaluate(item attributeMap) (*dynamodb.AttributeValue, bool, error) {
+	if v, ok := o.path.get(item); ok {
+		return v, true, nil
+	}
+	return o.fallback.evaluate(item)
+}
+
+// condition is a boolean expression evaluated against an item.
+type condition interface {
+	matches(item attributeMap) (bool, error)
+}
+
+type andCondition struct{ left, right condition }
+
+func (c andCondition) matches(item attributeMap) (bool, error) {
+	l, err := c.left.matches(item)
+	if err != nil || !l {
+		return false, err
+	}
+	return c.right.matches(item)
+}
+
+type orCondition struct{ left, right condition }
+
+func (c orCondition) matches(item attributeMap) (bool, error) {
+	l, err := c.left.matches(item)
+	if err != nil {
+		return false, err
+	}
+	if l {
+		return true, nil
+	}
+	return c.right.matches(item)
+}
+
+type notCondition struct{ inner condition }
+
+func (c notCondition) matches(item attributeMap) (bool, error) {
+	m, err := c.inner.matches(item)
+	return !m, err
+}
+
+type comparisonCondition struct {
+	comparator  string
+	left, right operand
+}
+
+func (c comparisonCondition) matches(item attributeMap) (bool, error) {
+	l, lok, err := c.left.evaluate(item)
+	if err != nil {
+		return false, err
+	}
+	r, rok, err := c.right.evaluate(item)
+	if err != nil {
+		return false, err
+	}
+
+	if c.comparator == "=" {
+		return lok && rok && attributeValuesEqual(l, r), nil
+	}
+	if c.comparator == "<>" {
+		return !lok || !rok || !attributeValuesEqual(l, r), nil
+	}
+	if !lok || !rok {
+		return false, nil
+	}
+
+	cmp, ok := compareAttributeValues(l, r)
+	if !ok {
+		return false, nil
+	}
+	switch c.comparator {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return false, validationError("Invalid comparator %q", c.comparator)
+}
+
+type betweenCondition struct{ value, low, high operand }
+
+func (c betweenCondition) matches(item attributeMap) (bool, error) {
+	low, err := comparisonCondition{comparator: ">=", left: c.value, right: c.low}.matches(item)
+	if err != nil || !low {
+		return false, err
+	}
+	return comparisonCondition{comparator: "<=", left: c.value, right: c.high}.matches(item)
+}
+
+type inCondition struct {
+	value   operand
+	options []operand
+}
+
+func (c inCondition) matches(item attributeMap) (bool, error) {
+	for _, o := range c.options {
+		m, err := comparisonCondition{comparator: "=", left: c.value, right: o}.matches(item)
+		if err != nil || m {
+			return m, err
+		}
+	}
+	return false, nil
+}
+
+type functionCondition struct {
+	function string
+	path     documentPath
+	argument operand
+}
+
+func (c functionCondition) matches(item attributeMap) (bool, error) {
+	v, exists := c.path.get(item)
+	switch c.function {
+	case "attribute_exists":
+		return exists, nil
+	case "attribute_not_exists":
+		return !exists, nil
+	}
+
+	if !exists {
+		return false, nil
+	}
+	arg, ok, err := c.argument.evaluate(item)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	switch c.function {
+	case "begins_with":
+		if v.S != nil && arg.S != nil {
+			return strings.HasPrefix(*v.S, *arg.S), nil
+		}
+		if v.B != nil && arg.B != nil {
+			return bytes.HasPrefix(v.B, arg.B), nil
+		}
+		return false, nil
+
+	case "contains":
+		switch {
+		case v.S != nil && arg.S != nil:
+			return strings.Contains(*v.S, *arg.S), nil
+		case v.SS != nil && arg.S != nil:
+			for _, s := range v.SS {
+				if *s == *arg.S {
+					return true, nil
+				}
+			}
+		case v.NS != nil && arg.N != nil:
+			for _, n := range v.NS {
+				if attributeValuesEqual(&dynamodb.AttributeValue{N: n}, arg) {
+					return true, nil
+				}
+			}
+		case v.L != nil:
+			for _, e := range v.L {
+				if attributeValuesEqual(e, arg) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	case "attribute_type":
+		if arg.S == nil {
+			return false, nil
+		}
+		return attributeValueType(v) == *arg.S, nil
+	}
+
+	return false, validationError("Invalid function name; function: %s", c.function)
+}
+
+// updateAction is a single action within an update expression.
+type updateAction struct {
+	clause string
+	path   documentPath
+	value  operand
+}
+
+// expressionParser is a recursive descent parser for DynamoDB expressions.
+type expressionParser struct {
+	tokens []exprToken
+	pos    int
+	attrs  *expressionAttributes
+}
+
+func newExpressionParser(expr string, attrs *expressionAttributes) (*expressionParser, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &expressionParser{tokens: tokens, attrs: attrs}, nil
+}
+
+func (p *expressionParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *expressionParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *expressionParser) isPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == punct
+}
+
+func (p *expressionParser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		return validationError("Invalid expression: expected %q but found %q", punct, p.peek().text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *expressionParser) expectEOF() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return validationError("Invalid expression: unexpected token %q", t.text)
+	}
+	return nil
+}
+
+// parsePath parses a document path such as #a.b[0].#c.
+func (p *expressionParser) parsePath() (documentPath, error) {
+	var path documentPath
+
+	readName := func() error {
+		t := p.next()
+		switch t.kind {
+		case tokenIdent:
+			path = append(path, pathElement{name: t.text})
+		case tokenName:
+			n, err := p.attrs.name(t.text)
+			if err != nil {
+				return err
+			}
+			path = append(path, pathElement{name: n})
+		default:
+			return validationError("Invalid expression: expected attribute name but found %q", t.text)
+		}
+		return nil
+	}
+
+	if err := readName(); err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			if err := readName(); err != nil {
+				return nil, err
+			}
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokenNumber {
+				return nil, validationError("Invalid expression: expected list index but found %q", t.text)
+			}
+			idx, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, validationError("Invalid list index %q", t.text)
+			}
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathElement{index: idx, isIndex: true})
+		default:
+			return path, nil
+		}
+	}
+}
+
+// parseOperand parses a path, value placeholder or size() function.
+func (p *expressionParser) parseOperand() (operand, error) {
+	t := p.peek()
+	if t.kind == tokenValue {
+		p.next()
+		v, err := p.attrs.value(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return valueOperand{value: v}, nil
+	}
+
+	if t.kind == tokenIdent && strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].text == "(" {
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return sizeOperand{path: path}, nil
+	}
+
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return pathOperand{path: path}, nil
+}
+
+// parseCondition parses a full condition expression.
+func (p *expressionParser) parseCondition() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{inner: inner}, nil
+	}
+	return p.parsePrimaryCondition()
+}
+
+var conditionFunctions = map[string]bool{
+	"attribute_exists":     true,
+	"attribute_not_exists": true,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+}
+
+func (p *expressionParser) parsePrimaryCondition() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		c, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	t := p.peek()
+	if t.kind == tokenIdent && conditionFunctions[strings.ToLower(t.text)] && p.tokens[p.pos+1].text == "(" {
+		p.next()
+		p.next()
+		fn := functionCondition{function: strings.ToLower(t.text)}
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		fn.path = path
+		if fn.function != "attribute_exists" && fn.function != "attribute_not_exists" {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			if fn.argument, err = p.parseOperand(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return fn, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("BETWEEN") {
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, validationError("Invalid expression: expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCondition{value: left, low: low, high: high}, nil
+	}
+
+	if p.isKeyword("IN") {
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		in := inCondition{value: left}
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			in.options = append(in.options, o)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+
+	comparator := p.next()
+	switch comparator.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return nil, validationError("Invalid expression: expected comparator but found %q", comparator.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparisonCondition{comparator: comparator.text, left: left, right: right}, nil
+}
+
+// parseUpdate parses a full update expression.
+func (p *expressionParser) parseUpdate() ([]updateAction, error) {
+	var actions []updateAction
+	seen := make(map[string]bool)
+
+	for p.peek().kind != tokenEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokenIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			return nil, validationError("Invalid UpdateExpression: unexpected token %q", t.text)
+		}
+		if seen[clause] {
+			return nil, validationError("Invalid UpdateExpression: The \"%s\" section can only be used once in an update expression", clause)
+		}
+		seen[clause] = true
+
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			action := updateAction{clause: clause, path: path}
+
+			switch clause {
+			case "SET":
+				if err := p.expectPunct("="); err != nil {
+					return nil, err
+				}
+				if action.value, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				if action.value, err = p.parseOperand(); err != nil {
+					return nil, err
+				}
+			}
+			actions = append(actions, action)
+
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if len(actions) == 0 {
+		return nil, validationError("Invalid UpdateExpression: The expression can not be empty")
+	}
+	return actions, nil
+}
+
+func (p *expressionParser) parseSetValue() (operand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("+") || p.isPunct("-") {
+		subtract := p.next().text == "-"
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticOperand{left: left, right: right, subtract: subtract}, nil
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseSetOperand() (operand, error) {
+	t := p.peek()
+	if t.kind == tokenIdent && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "list_append":
+			p.next()
+			p.next()
+			first, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return listAppendOperand{first: first, second: second}, nil
+
+		case "if_not_exists":
+			p.next()
+			p.next()
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return ifNotExistsOperand{path: path, fallback: fallback}, nil
+		}
+	}
+	return p.parseOperand()
+}
+
+// parseProjection parses a comma separated list of document paths.
+func (p *expressionParser) parseProjection() ([]documentPath, error) {
+	var paths []documentPath
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return paths, p.expectEOF()
+}
+
+// parseConditionExpression parses the given condition expression. A nil
+// condition is returned if expr is nil or empty.
+func parseConditionExpression(expr *string, attrs *expressionAttributes) (condition, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return nil, nil
+	}
+	p, err := newExpressionParser(*expr, attrs)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	return c, p.expectEOF()
+}
+
+// parseProjectionExpression parses the given projection expression. A nil
+// slice is returned if expr is nil or empty.
+func parseProjectionExpression(expr *string, attrs *expressionAttributes) ([]documentPath, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return nil, nil
+	}
+	p, err := newExpressionParser(*expr, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseProjection()
+}
+
+// parseUpdateExpression parses the given update expression.
+func parseUpdateExpression(expr string, attrs *expressionAttributes) ([]updateAction, error) {
+	p, err := newExpressionParser(expr, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseUpdate()
+}
+
+// matchesCondition returns true if c is nil or matches the given item.
+func matchesCondition(c condition, item attributeMap) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	return c.matches(item)
+}
+
+// applyUpdate applies the given update actions to a copy of item and returns
+// the copy. All operands are evaluated against the original item, matching
+// DynamoDB semantics.
+func applyUpdate(item attributeMap, actions []updateAction) (attributeMap, error) {
+	values := make([]*dynamodb.AttributeValue, len(actions))
+	for i, a := range actions {
+		if a.value == nil {
+			continue
+		}
+		v, ok, err := a.value.evaluate(item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+		}
+		values[i] = v
+	}
+
+	updated := copyAttributeMap(item)
+	for i, a := range actions {
+		var err error
+		switch a.clause {
+		case "SET":
+			err = a.path.set(updated, copyAttributeValue(values[i]))
+		case "REMOVE":
+			a.path.remove(updated)
+		case "ADD":
+			err = applyAdd(updated, a.path, values[i])
+		case "DELETE":
+			err = applyDelete(updated, a.path, values[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+func applyAdd(item attributeMap, path documentPath, value *dynamodb.AttributeValue) error {
+	existing, ok := path.get(item)
+	if !ok {
+		return path.set(item, copyAttributeValue(value))
+	}
+
+	switch {
+	case existing.N != nil && value.N != nil:
+		sum, _, err := arithmeticOperand{left: valueOperand{existing}, right: valueOperand{value}}.evaluate(item)
+		if err != nil {
+			return err
+		}
+		return path.set(item, sum)
+	case existing.SS != nil && value.SS != nil:
+		existing.SS = unionStrings(existing.SS, value.SS)
+	case existing.NS != nil && value.NS != nil:
+		existing.NS = unionStrings(existing.NS, value.NS)
+	default:
+		return validationError("An operand in the update expression has an incorrect data type")
+	}
+	return nil
+}
+
+func applyDelete(item attributeMap, path documentPath, value *dynamodb.AttributeValue) error {
+	existing, ok := path.get(item)
+	if !ok {
+		return nil
+	}
+
+	var remaining []*string
+	switch {
+	case existing.SS != nil && value.SS != nil:
+		remaining = subtractStrings(existing.SS, value.SS)
+		existing.SS = remaining
+	case existing.NS != nil && value.NS != nil:
+		remaining = subtractStrings(existing.NS, value.NS)
+		existing.NS = remaining
+	default:
+		return validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	if len(remaining) == 0 {
+		path.remove(item)
+	}
+	return nil
+}
+
+func unionStrings(a, b []*string) []*string {
+	seen := make(map[string]bool, len(a))
+	result := make([]*string, 0, len(a)+len(b))
+	for _, s := range append(append([]*string{}, a...), b...) {
+		if !seen[*s] {
+			seen[*s] = true
+			result = append(result, aws.String(*s))
+		}
+	}
+	return result
+}
+
+func subtractStrings(a, b []*string) []*string {
+	remove := make(map[string]bool, len(b))
+	for _, s := range b {
+		remove[*s] = true
+	}
+	var result []*string
+	for _, s := range a {
+		if !remove[*s] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// projectItem returns a copy of item containing only the given paths.
+func projectItem(item attributeMap, paths []documentPath) attributeMap {
+	if paths == nil {
+		return copyAttributeMap(item)
+	}
+
+	result := make(attributeMap)
+	for _, path := range paths {
+		v, ok := path.get(item)
+		if !ok {
+			continue
+		}
+
+		// Rebuild the containers along the path. List indices are collapsed, matching
+		// DynamoDB's behavior of returning the projected elements in order.
+		current := result
+		for i, e := range path {
+			last := i == len(path)-1
+			if e.isIndex {
+				break
+			}
+			if last {
+				current[e.name] = copyAttributeValue(v)
+				break
+			}
+			if path[i+1].isIndex {
+				current[e.name] = copyAttributeValue(documentPath(path[:i+1]).mustGet(item))
+				break
+			}
+			next, ok := current[e.name]
+			if !ok || next.M == nil {
+				next = &dynamodb.AttributeValue{M: make(attributeMap)}
+				current[e.name] = next
+			}
+			current = next.M
+		}
+	}
+	return result
+}
+
+func (p documentPath) mustGet(item attributeMap) *dynamodb.AttributeValue {
+	v, _ := p.get(item)
+	return v
+}
+
+// attributeValueType returns the DynamoDB type descriptor of the given value.
+func attributeValueType(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	case v.L != nil:
+		return "L"
+	case v.M != nil:
+		return "M"
+	}
+	return ""
+}
+
+// compareAttributeValues compares two scalar values of the same type. The bool
+// is false if the values cannot be ordered.
+func compareAttributeValues(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		af, _, err := big.ParseFloat(*a.N, 10, 128, big.ToNearestEven)
+		if err != nil {
+			return 0, false
+		}
+		bf, _, err := big.ParseFloat(*b.N, 10, 128, big.ToNearestEven)
+		if err != nil {
+			return 0, false
+		}
+		return af.Cmp(bf), true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	}
+	return 0, false
+}
+
+// attributeValuesEqual returns true if the two values are deeply equal.
+func attributeValuesEqual(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if attributeValueType(a) != attributeValueType(b) {
+		return false
+	}
+
+	switch {
+	case a.S != nil, a.N != nil, a.B != nil:
+		cmp, ok := compareAttributeValues(a, b)
+		return ok && cmp == 0
+	case a.BOOL != nil:
+		return *a.BOOL == *b.BOOL
+	case a.NULL != nil:
+		return true
+	case a.SS != nil:
+		return stringSetsEqual(a.SS, b.SS)
+	case a.NS != nil:
+		return stringSetsEqual(a.NS, b.NS)
+	case a.BS != nil:
+		if len(a.BS) != len(b.BS) {
+			return false
+		}
+		for _, x := range a.BS {
+			found := false
+			for _, y := range b.BS {
+				if bytes.Equal(x, y) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case a.L != nil:
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !attributeValuesEqual(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case a.M != nil:
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for k, v := range a.M {
+			if !attributeValuesEqual(v, b.M[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func stringSetsEqual(a, b []*string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, s := range a {
+		set[*s] = true
+	}
+	for _, s := range b {
+		if !set[*s] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatNumber formats the given number using the shortest representation.
+func formatNumber(f *big.Float) string {
+	if f.IsInt() {
+		i, _ := f.Int(nil)
+		return i.String()
+	}
+	return f.Text('g', -1)
+}
+
+// copyAttributeValue returns a deep copy of the given value.
+func copyAttributeValue(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+
+	c := &dynamodb.AttributeValue{}
+	if v.S != nil {
+		c.S = aws.String(*v.S)
+	}
+	if v.N != nil {
+		c.N = aws.String(*v.N)
+	}
+	if v.B != nil {
+		c.B = append([]byte{}, v.B...)
+	}
+	if v.BOOL != nil {
+		c.BOOL = aws.Bool(*v.BOOL)
+	}
+	if v.NULL != nil {
+		c.NULL = aws.Bool(*v.NULL)
+	}
+	if v.SS != nil {
+		c.SS = unionStrings(v.SS, nil)
+	}
+	if v.NS != nil {
+		c.NS = unionStrings(v.NS, nil)
+	}
+	if v.BS != nil {
+		c.BS = make([][]byte, len(v.BS))
+		for i, b := range v.BS {
+			c.BS[i] = append([]byte{}, b...)
+		}
+	}
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, e := range v.L {
+			c.L[i] = copyAttributeValue(e)
+		}
+	}
+	if v.M != nil {
+		c.M = copyAttributeMap(v.M)
+	}
+	return c
+}
+
+// copyAttributeMap returns a deep copy of the given item.
+func copyAttributeMap(item attributeMap) attributeMap {
+	if item == nil {
+		return nil
+	}
+	c := make(attributeMap, len(item))
+	for k, v := range item {
+		c[k] = copyAttributeValue(v)
+	}
+	return c
+}
+
+// attributeValueSize returns the approximate size in bytes of the given value,
+// following the rules DynamoDB uses for calculating item size.
+func attributeValueSize(v *dynamodb.AttributeValue) int {
+	switch {
+	case v == nil:
+		return 0
+	case v.S != nil:
+		return len(*v.S)
+	case v.N != nil:
+		return len(*v.N)/2 + 1
+	case v.B != nil:
+		return len(v.B)
+	case v.BOOL != nil, v.NULL != nil:
+		return 1
+	case v.SS != nil:
+		size := 0
+		for _, s := range v.SS {
+			size += len(*s)
+		}
+		return size
+	case v.NS != nil:
+		size := 0
+		for _, n := range v.NS {
+			size += len(*n)/2 + 1
+		}
+		return size
+	case v.BS != nil:
+		size := 0
+		for _, b := range v.BS {
+			size += len(b)
+		}
+		return size
+	case v.L != nil:
+		size := 3
+		for _, e := range v.L {
+			size += attributeValueSize(e) + 1
+		}
+		return size
+	case v.M != nil:
+		return 3 + itemSize(v.M)
+	}
+	return 0
+}
+
+// itemSize returns the approximate size in bytes of the given item.
+func itemSize(item attributeMap) int {
+	size := 0
+	for k, v := range item {
+		size += len(k) + attributeValueSize(v)
+	}
+	return size
+}
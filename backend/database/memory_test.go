@@ -0,0 +1,406 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+func TestInMemoryUser(t *testing.T) {
+	repo := NewInMemory()
+
+	if _, err := repo.GetUser("test"); err == nil {
+		t.Fatal("GetUser(test) got nil err; want 404")
+	} else if aerr, ok := err.(*errors.Error); !ok || aerr.Code != 404 {
+		t.Fatalf("GetUser(test) got err %v; want 404", err)
+	}
+
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", SubscriptionStatus_Subscribed); err != nil {
+		t.Fatalf("CreateUser(test) got err: %v", err)
+	}
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", SubscriptionStatus_Subscribed); err == nil {
+		t.Fatal("CreateUser(test) twice got nil err; want conditional check failure")
+	}
+
+	cohort := DojoCohort("1500-1600")
+	user, err := repo.UpdateUser("test", &UserUpdate{DojoCohort: &cohort, DisplayName: aws.String("Tester")})
+	if err != nil {
+		t.Fatalf("UpdateUser(test) got err: %v", err)
+	}
+	if user.DojoCohort != cohort || user.DisplayName != "Tester" || user.Email != "test@example.com" {
+		t.Errorf("UpdateUser(test) got %+v; want cohort %s, display name Tester and unchanged email", user, cohort)
+	}
+
+	if _, err := repo.UpdateUser("missing", &UserUpdate{DisplayName: aws.String("Missing")}); err == nil {
+		t.Error("UpdateUser(missing) got nil err; want conditional check failure")
+	}
+
+	user, err = repo.UpdateUserProgress("test", &RequirementProgress{RequirementId: "req", MinutesSpent: map[DojoCohort]int{cohort: 10}})
+	if err != nil {
+		t.Fatalf("UpdateUserProgress(test) got err: %v", err)
+	}
+	if user.Progress["req"] == nil || user.Progress["req"].MinutesSpent[cohort] != 10 {
+		t.Errorf("UpdateUserProgress(test) got progress %+v; want 10 minutes", user.Progress)
+	}
+
+	if err := repo.UpdateUserRatings([]*User{{Username: "test", Version: user.Version, Ratings: map[RatingSystem]*Rating{Lichess: {CurrentRating: 1800}}, ProvisionalCohort: true, RatingsUpdatedAt: "2024-10-03T00:00:00Z"}}); err != nil {
+		t.Fatalf("UpdateUserRatings got err: %v", err)
+	}
+	user, err = repo.GetUser("test")
+	if err != nil {
+		t.Fatalf("GetUser(test) got err: %v", err)
+	}
+	if user.Ratings[Lichess] == nil || user.Ratings[Lichess].CurrentRating != 1800 {
+		t.Errorf("GetUser(test) got ratings %+v; want lichess rating 1800", user.Ratings)
+	}
+	if user.DojoCohort != cohort || !user.ProvisionalCohort || user.RatingsUpdatedAt != "2024-10-03T00:00:00Z" {
+		t.Errorf("GetUser(test) got cohort %q, provisional %v, ratingsUpdatedAt %q; want %q, true, 2024-10-03T00:00:00Z", user.DojoCohort, user.ProvisionalCohort, user.RatingsUpdatedAt, cohort)
+	}
+}
+
+func TestInMemoryModifyUser(t *testing.T) {
+	repo := NewInMemory()
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", SubscriptionStatus_Subscribed); err != nil {
+		t.Fatalf("CreateUser(test) got err: %v", err)
+	}
+
+	calls := 0
+	user, err := repo.ModifyUser("test", func(user *User) (*UserUpdate, error) {
+		calls++
+		if calls == 1 {
+			// Simulate a concurrent request writing the user after it was read.
+			if _, err := repo.UpdateUserProgress("test", &RequirementProgress{RequirementId: "concurrent"}); err != nil {
+				t.Fatalf("UpdateUserProgress(test) got err: %v", err)
+			}
+		}
+		courses := map[string]bool{"course": true}
+		for id := range user.PurchasedCourses {
+			courses[id] = true
+		}
+		return &UserUpdate{PurchasedCourses: &courses}, nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyUser(test) got err: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("ModifyUser(test) called modify %d times; want 2", calls)
+	}
+	if user.Progress["concurrent"] == nil || !user.PurchasedCourses["course"] {
+		t.Errorf("ModifyUser(test) got %+v; want both the concurrent progress and the purchased course", user)
+	}
+	if user.Version != 3 {
+		t.Errorf("ModifyUser(test) got version %d; want 3", user.Version)
+	}
+
+	_, err = repo.ModifyUserProgress("test", func(user *User) (*RequirementProgress, error) {
+		_, err := repo.UpdateUser("test", &UserUpdate{DisplayName: aws.String("Conflict")})
+		return &RequirementProgress{RequirementId: "req"}, err
+	})
+	if reason := errors.ReasonOf(err); reason != errors.ReasonUserVersionConflict {
+		t.Errorf("ModifyUserProgress(test) with a write on every attempt got reason %s; want %s", reason, errors.ReasonUserVersionConflict)
+	}
+
+	stale := &User{Username: "test", Version: 1, Ratings: map[RatingSystem]*Rating{Lichess: {CurrentRating: 1500}}}
+	if failures := BatchFailures(repo.UpdateUserRatings([]*User{stale})); len(failures) != 1 {
+		t.Errorf("UpdateUserRatings with a stale user got failures %+v; want 1", failures)
+	}
+}
+
+func TestInMemoryUserRoles(t *testing.T) {
+	repo := NewInMemory()
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", SubscriptionStatus_Subscribed); err != nil {
+		t.Fatalf("CreateUser(test) got err: %v", err)
+	}
+	if _, err := repo.UpdateUser("test", &UserUpdate{IsTournamentAdmin: aws.Bool(true)}); err != nil {
+		t.Fatalf("UpdateUser(test) got err: %v", err)
+	}
+
+	club := RoleAssignment{Role: RoleClubAdmin, Scope: ClubScope("club1"), AssignedBy: "admin"}
+	user, err := repo.AssignUserRole("test", club)
+	if err != nil {
+		t.Fatalf("AssignUserRole(clubAdmin) got err: %v", err)
+	}
+	if len(user.Roles) != 2 || !user.HasPermission(PermissionTournamentPairingsWrite, "") || !user.HasPermission(PermissionClubWrite, ClubScope("club1")) {
+		t.Errorf("AssignUserRole(clubAdmin) got roles %+v; want the legacy tournament admin role and the club admin role", user.Roles)
+	}
+	if _, err := repo.AssignUserRole("test", club); errors.ReasonOf(err) != errors.ReasonRoleAlreadyAssigned {
+		t.Errorf("AssignUserRole(clubAdmin) twice got err %v; want reason %s", err, errors.ReasonRoleAlreadyAssigned)
+	}
+	if _, err := repo.AssignUserRole("test", RoleAssignment{Role: RoleClubAdmin}); err == nil {
+		t.Errorf("AssignUserRole(clubAdmin) without a scope got nil err; want 400")
+	}
+	if _, err := repo.AssignUserRole("test", RoleAssignment{Role: RoleAdmin, Scope: ClubScope("club1")}); err == nil {
+		t.Errorf("AssignUserRole(admin) with a club scope got nil err; want 400")
+	}
+
+	user, err = repo.RevokeUserRole("test", RoleTournamentAdmin, "", "admin")
+	if err != nil {
+		t.Fatalf("RevokeUserRole(tournamentAdmin) got err: %v", err)
+	}
+	if user.IsTournamentAdmin || user.HasPermission(PermissionTournamentPairingsWrite, "") {
+		t.Errorf("RevokeUserRole(tournamentAdmin) got %+v; want the tournament admin role removed", user.Roles)
+	}
+	if _, err := repo.RevokeUserRole("test", RoleTournamentAdmin, "", "admin"); errors.ReasonOf(err) != errors.ReasonRoleNotAssigned {
+		t.Errorf("RevokeUserRole(tournamentAdmin) twice got err %v; want reason %s", err, errors.ReasonRoleNotAssigned)
+	}
+
+	if len(user.RoleHistory) != 2 || user.RoleHistory[0].Action != RoleChangeAction_Assign || user.RoleHistory[1].Action != RoleChangeAction_Revoke {
+		t.Errorf("User got role history %+v; want an assignment and a revocation", user.RoleHistory)
+	}
+}
+
+func TestInMemoryAuditEntries(t *testing.T) {
+	repo := NewInMemory()
+
+	ban := NewAuditEntry("admin", AuditAction_OpenClassicalBanPlayer, OpenClassicalPlayerAuditTarget("Player"), "cheating")
+	before := map[string]interface{}{"bannedPlayers": map[string]interface{}{}}
+	after := map[string]interface{}{"bannedPlayers": map[string]interface{}{"player": map[string]string{"lichessUsername": "Player"}}}
+	if err := repo.CreateAuditEntry(ban, before, after); err != nil {
+		t.Fatalf("CreateAuditEntry(ban) got err: %v", err)
+	}
+	if change, ok := ban.Changes["bannedPlayers.player"]; !ok || change.Before != nil || change.After == nil || len(ban.Changes) != 1 {
+		t.Errorf("CreateAuditEntry(ban) got changes %+v; want only bannedPlayers.player added", ban.Changes)
+	}
+
+	role := NewAuditEntry("owner", AuditAction_RoleAssign, UserAuditTarget("test"), "")
+	if err := repo.CreateAuditEntry(role, []RoleAssignment{}, []RoleAssignment{{Role: RoleCoach}}); err != nil {
+		t.Fatalf("CreateAuditEntry(role) got err: %v", err)
+	}
+	if _, ok := role.Changes["."]; !ok || len(role.Changes) != 1 {
+		t.Errorf("CreateAuditEntry(role) got changes %+v; want only the root path changed", role.Changes)
+	}
+
+	tests := []struct {
+		name  string
+		query AuditQuery
+		want  []string
+	}{
+		{name: "All", query: AuditQuery{}, want: []string{role.Id, ban.Id}},
+		{name: "Actor", query: AuditQuery{Actor: "admin"}, want: []string{ban.Id}},
+		{name: "Target", query: AuditQuery{Target: UserAuditTarget("test")}, want: []string{role.Id}},
+		{name: "Future", query: AuditQuery{StartTime: time.Now().Add(time.Hour)}, want: nil},
+		{name: "Past", query: AuditQuery{EndTime: time.Now().Add(-time.Hour)}, want: nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, _, err := repo.ListAuditEntries(tc.query, "")
+			if err != nil {
+				t.Fatalf("ListAuditEntries got err: %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Id)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ListAuditEntries got ids %v; want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, _, err := repo.ListAuditEntries(AuditQuery{Actor: "admin", Target: "club:1"}, ""); err == nil {
+		t.Errorf("ListAuditEntries(actor, target) got nil err; want 400")
+	}
+}
+
+func TestInMemoryRatingHistory(t *testing.T) {
+	repo := NewInMemory()
+	now := time.Now()
+
+	var updates []RatingHistoryUpdate
+	for i := 0; i < 500; i++ {
+		date := now.Add(-time.Duration(i)*24*time.Hour - time.Hour).Format(time.RFC3339)
+		updates = append(updates, RatingHistoryUpdate{Username: "test", RatingSystem: Lichess, History: RatingHistory{Date: date, Rating: 1000 + i}})
+	}
+	updates = append(updates, RatingHistoryUpdate{Username: "test", RatingSystem: Chesscom, History: RatingHistory{Date: now.Format(time.RFC3339), Rating: 9999}})
+	if err := repo.PutRatingHistories(updates); err != nil {
+		t.Fatalf("PutRatingHistories got err: %v", err)
+	}
+
+	history, err := repo.ListRatingHistory("test", Lichess, time.Time{}, now)
+	if err != nil {
+		t.Fatalf("ListRatingHistory got err: %v", err)
+	}
+
+	daily := 0
+	cutoff := now.Add(-dailyRatingHistoryRetention).Format(time.RFC3339)
+	for i, h := range history {
+		if i > 0 && h.Date <= history[i-1].Date {
+			t.Errorf("ListRatingHistory got %s after %s; want oldest first", h.Date, history[i-1].Date)
+		}
+		if h.Rating == 9999 {
+			t.Errorf("ListRatingHistory got Chesscom point %+v", h)
+		}
+		if h.Date >= cutoff {
+			daily++
+		}
+	}
+	if daily != 31 {
+		t.Errorf("ListRatingHistory got %d points in the last 31 days; want 31", daily)
+	}
+	if len(history) > 31+53+13 {
+		t.Errorf("ListRatingHistory got %d points; want older points compacted", len(history))
+	}
+	if oldest := history[0]; oldest.Rating > 1499 || oldest.Rating < 1470 {
+		t.Errorf("ListRatingHistory got oldest point %+v; want the last point of the oldest month", oldest)
+	}
+
+	legacy := []RatingHistory{{Date: "2020-01-06T00:00:00Z", Rating: 800}, {Date: now.Format(time.RFC3339), Rating: 1}}
+	merged := MergeLegacyRatingHistory(legacy, history, time.Time{}, now)
+	if len(merged) != len(history)+1 || merged[0].Rating != 800 {
+		t.Errorf("MergeLegacyRatingHistory got %d points starting with %+v; want only the older legacy point added", len(merged), merged[0])
+	}
+}
+
+func TestInMemoryPagination(t *testing.T) {
+	repo := NewInMemory(WithPageSize(2))
+
+	for i := 0; i < 5; i++ {
+		if _, err := repo.CreateUser(fmt.Sprintf("user%d", i), "test@example.com", "Test", SubscriptionStatus_Subscribed); err != nil {
+			t.Fatalf("CreateUser got err: %v", err)
+		}
+	}
+
+	var usernames []string
+	startKey := ""
+	pages := 0
+	for {
+		users, lastKey, err := repo.ListUsersByCohort(NoCohort, startKey)
+		if err != nil {
+			t.Fatalf("ListUsersByCohort got err: %v", err)
+		}
+		for _, u := range users {
+			usernames = append(usernames, u.Username)
+		}
+		pages++
+		if lastKey == "" {
+			break
+		}
+		startKey = lastKey
+	}
+
+	if pages != 3 {
+		t.Errorf("ListUsersByCohort got %d pages; want 3", pages)
+	}
+	want := []string{"user0", "user1", "user2", "user3", "user4"}
+	if fmt.Sprint(usernames) != fmt.Sprint(want) {
+		t.Errorf("ListUsersByCohort got %v; want %v", usernames, want)
+	}
+
+	if _, _, err := repo.ListUsersByCohort(NoCohort, "not-json"); err == nil {
+		t.Error("ListUsersByCohort(invalid startKey) got nil err; want 400")
+	} else if aerr, ok := err.(*errors.Error); !ok || aerr.Code != 400 {
+		t.Errorf("ListUsersByCohort(invalid startKey) got err %v; want 400", err)
+	}
+}
+
+func TestInMemoryBookEvent(t *testing.T) {
+	repo := NewInMemory()
+
+	event := &Event{
+		Id:              "event",
+		Type:            EventType_Dojo,
+		Status:          SchedulingStatus_Scheduled,
+		MaxParticipants: 1,
+		Participants:    map[string]*Participant{},
+	}
+	if err := repo.SetEvent(event); err != nil {
+		t.Fatalf("SetEvent got err: %v", err)
+	}
+
+	booked, err := repo.BookEvent(event, &User{Username: "first"}, "", "", nil)
+	if err != nil {
+		t.Fatalf("BookEvent(first) got err: %v", err)
+	}
+	if booked.Status != SchedulingStatus_Booked || booked.Participants["first"] == nil {
+		t.Errorf("BookEvent(first) got %+v; want booked event with participant first", booked)
+	}
+
+	_, err = repo.BookEvent(booked, &User{Username: "second"}, "", "", nil)
+	if aerr, ok := err.(*errors.Error); !ok || aerr.Code != 400 {
+		t.Errorf("BookEvent(second) got err %v; want 400", err)
+	}
+}
+
+func TestInMemoryExpressions(t *testing.T) {
+	item := attributeMap{
+		"name":  {S: aws.String("hello world")},
+		"count": {N: aws.String("3")},
+		"tags":  {SS: []*string{aws.String("a"), aws.String("b")}},
+		"list":  {L: []*dynamodb.AttributeValue{{N: aws.String("1")}, {N: aws.String("2")}}},
+		"nested": {M: attributeMap{
+			"inner": {S: aws.String("value")},
+		}},
+	}
+	values := attributeMap{
+		":hello": {S: aws.String("hello")},
+		":two":   {N: aws.String("2")},
+		":ten":   {N: aws.String("10")},
+		":a":     {S: aws.String("a")},
+		":value": {S: aws.String("value")},
+	}
+
+	table := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "begins_with(#n, :hello)", want: true},
+		{expr: "contains(#n, :hello) AND #c > :two", want: true},
+		{expr: "#c BETWEEN :two AND :ten", want: true},
+		{expr: "NOT (#c < :two OR contains(tags, :a))", want: false},
+		{expr: "size(#l) = :two AND nested.inner = :value", want: true},
+		{expr: "attribute_not_exists(missing) AND attribute_exists(list[1])", want: true},
+		{expr: "missing <> :a", want: true},
+		{expr: "missing = :a", want: false},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.expr, func(t *testing.T) {
+			names := map[string]*string{"#n": aws.String("name"), "#c": aws.String("count"), "#l": aws.String("list")}
+			attrs := newExpressionAttributes(names, values)
+			c, err := parseConditionExpression(aws.String(tc.expr), attrs)
+			if err != nil {
+				t.Fatalf("parseConditionExpression(%s) got err: %v", tc.expr, err)
+			}
+			got, err := c.matches(item)
+			if err != nil {
+				t.Fatalf("matches(%s) got err: %v", tc.expr, err)
+			}
+			if got != tc.want {
+				t.Errorf("matches(%s) got %v; want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+
+	attrs := newExpressionAttributes(nil, values)
+	actions, err := parseUpdateExpression("SET #c = #c + :two, list = list_append(list, :l) REMOVE nested ADD tags :s DELETE tags :d", newExpressionAttributes(
+		map[string]*string{"#c": aws.String("count")},
+		attributeMap{
+			":two": {N: aws.String("2")},
+			":l":   {L: []*dynamodb.AttributeValue{{N: aws.String("3")}}},
+			":s":   {SS: []*string{aws.String("c")}},
+			":d":   {SS: []*string{aws.String("a")}},
+		},
+	))
+	if err != nil {
+		t.Fatalf("parseUpdateExpression got err: %v", err)
+	}
+	updated, err := applyUpdate(item, actions)
+	if err != nil {
+		t.Fatalf("applyUpdate got err: %v", err)
+	}
+	if *updated["count"].N != "5" || len(updated["list"].L) != 3 || updated["nested"] != nil || len(updated["tags"].SS) != 2 {
+		t.Errorf("applyUpdate got %v", updated)
+	}
+	if *item["count"].N != "3" {
+		t.Errorf("applyUpdate modified the original item: %v", item)
+	}
+
+	if err := attrs.checkUnused(); err == nil {
+		t.Error("checkUnused got nil err; want unused values error")
+	}
+}
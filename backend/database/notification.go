@@ -37,6 +37,12 @@ const (
 
 	// Notifications generated by a sensei game review
 	NotificationType_GameReviewComplete NotificationType = "GAME_REVIEW_COMPLETE"
+
+	// Notifications generated by a user's rating reaching the next cohort
+	NotificationType_GraduationEligible NotificationType = "GRADUATION_ELIGIBLE"
+
+	// Notifications generated by a sustained drop of a user's rating below their cohort
+	NotificationType_CohortChange NotificationType = "COHORT_CHANGE"
 )
 
 // Data for a notification
@@ -74,6 +80,12 @@ type Notification struct {
 
 	// Metadata for club-related notifications
 	ClubMetadata *ClubMetadata `dynamodbav:"clubMetadata,omitempty" json:"clubMetadata,omitempty"`
+
+	// Metadata for a graduation eligible notification
+	GraduationMetadata *GraduationMetadata `dynamodbav:"graduationMetadata,omitempty" json:"graduationMetadata,omitempty"`
+
+	// Metadata for a cohort change notification
+	CohortChangeMetadata *CohortChangeMetadata `dynamodbav:"cohortChangeMetadata,omitempty" json:"cohortChangeMetadata,omitempty"`
 }
 
 // Metadata for a game comment notification.
@@ -154,6 +166,42 @@ type ClubMetadata struct {
 	Name string `dynamodbav:"name" json:"name"`
 }
 
+// Metadata for a graduation eligible notification
+type GraduationMetadata struct {
+	// The cohort the user is in, or graduated from if Graduated is true
+	PreviousCohort DojoCohort `dynamodbav:"previousCohort" json:"previousCohort"`
+
+	// The cohort the user can graduate to, or graduated to if Graduated is true
+	NewCohort DojoCohort `dynamodbav:"newCohort" json:"newCohort"`
+
+	// The user's preferred rating system
+	RatingSystem RatingSystem `dynamodbav:"ratingSystem" json:"ratingSystem"`
+
+	// The user's current rating in their preferred rating system
+	CurrentRating int `dynamodbav:"currentRating" json:"currentRating"`
+
+	// Whether the user was graduated automatically
+	Graduated bool `dynamodbav:"graduated" json:"graduated"`
+}
+
+// Metadata for a cohort change notification
+type CohortChangeMetadata struct {
+	// The cohort the user is in, or was moved from if Changed is true
+	PreviousCohort DojoCohort `dynamodbav:"previousCohort" json:"previousCohort"`
+
+	// The cohort suggested to the user, or the user was moved to if Changed is true
+	NewCohort DojoCohort `dynamodbav:"newCohort" json:"newCohort"`
+
+	// The user's preferred rating system
+	RatingSystem RatingSystem `dynamodbav:"ratingSystem" json:"ratingSystem"`
+
+	// The user's current rating in their preferred rating system
+	CurrentRating int `dynamodbav:"currentRating" json:"currentRating"`
+
+	// Whether the user was moved automatically
+	Changed bool `dynamodbav:"changed" json:"changed"`
+}
+
 type NotificationPutter interface {
 	// PutNotification inserts the provided notification into the database.
 	PutNotification(n *Notification) error
@@ -328,6 +376,54 @@ func ClubJoinRequestApprovedNotification(username string, club *Club) *Notificat
 	}
 }
 
+// GraduationEligibleNotification returns a Notification object telling the user that
+// they can graduate to nextCohort, or that they were graduated to it if graduated is
+// true. If the user has graduation notifications turned off, nil is returned.
+func GraduationEligibleNotification(user *User, previousCohort, nextCohort DojoCohort, graduated bool) *Notification {
+	if user.NotificationSettings.SiteNotificationSettings.GetDisableGraduationEligible() {
+		return nil
+	}
+
+	_, currentRating := user.GetRatings()
+	return &Notification{
+		Username:  user.Username,
+		Id:        fmt.Sprintf("%s|%s", NotificationType_GraduationEligible, nextCohort),
+		Type:      NotificationType_GraduationEligible,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+		GraduationMetadata: &GraduationMetadata{
+			PreviousCohort: previousCohort,
+			NewCohort:      nextCohort,
+			RatingSystem:   user.RatingSystem,
+			CurrentRating:  currentRating,
+			Graduated:      graduated,
+		},
+	}
+}
+
+// CohortChangeNotification returns a Notification object suggesting that the user move
+// to newCohort, or telling them that they were moved to it if changed is true. If the
+// user has cohort change notifications turned off, nil is returned.
+func CohortChangeNotification(user *User, previousCohort, newCohort DojoCohort, changed bool) *Notification {
+	if user.NotificationSettings.SiteNotificationSettings.GetDisableCohortChange() {
+		return nil
+	}
+
+	_, currentRating := user.GetRatings()
+	return &Notification{
+		Username:  user.Username,
+		Id:        fmt.Sprintf("%s|%s", NotificationType_CohortChange, newCohort),
+		Type:      NotificationType_CohortChange,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+		CohortChangeMetadata: &CohortChangeMetadata{
+			PreviousCohort: previousCohort,
+			NewCohort:      newCohort,
+			RatingSystem:   user.RatingSystem,
+			CurrentRating:  currentRating,
+			Changed:        changed,
+		},
+	}
+}
+
 // PutNotification inserts the provided notification into the database.
 func (repo *dynamoRepository) PutNotification(n *Notification) error {
 	if n == nil {
@@ -354,6 +450,12 @@ func (repo *dynamoRepository) PutNotification(n *Notification) error {
 	if n.ClubMetadata != nil {
 		update.Set(expression.Name("clubMetadata"), expression.Value(n.ClubMetadata))
 	}
+	if n.GraduationMetadata != nil {
+		update.Set(expression.Name("graduationMetadata"), expression.Value(n.GraduationMetadata))
+	}
+	if n.CohortChangeMetadata != nil {
+		update.Set(expression.Name("cohortChangeMetadata"), expression.Value(n.CohortChangeMetadata))
+	}
 
 	expr, err := expression.NewBuilder().WithUpdate(update).Build()
 	if err != nil {
@@ -0,0 +1,327 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+type RatingAnomalyType string
+
+const (
+	// The user's rating changed by an implausible amount between two rating updates.
+	RatingAnomalyType_RatingJump RatingAnomalyType = "RATING_JUMP"
+
+	// The user's chess.com account was closed for fair play violations.
+	RatingAnomalyType_FairPlayClosure RatingAnomalyType = "FAIR_PLAY_CLOSURE"
+
+	// The user's Lichess account was marked for a terms of service violation.
+	RatingAnomalyType_TosViolation RatingAnomalyType = "TOS_VIOLATION"
+
+	// The user's rating in their preferred rating system differs by an implausible
+	// amount from their rating in another linked rating system.
+	RatingAnomalyType_SystemMismatch RatingAnomalyType = "SYSTEM_MISMATCH"
+)
+
+type RatingAnomalyStatus string
+
+const (
+	// The anomaly is waiting for review by an admin.
+	RatingAnomalyStatus_Open RatingAnomalyStatus = "OPEN"
+
+	// The anomaly was reviewed and no action was taken.
+	RatingAnomalyStatus_Dismissed RatingAnomalyStatus = "DISMISSED"
+
+	// The anomaly was reviewed and the user's subscription was terminated.
+	RatingAnomalyStatus_Enforced RatingAnomalyStatus = "ENFORCED"
+)
+
+// The thresholds above which anomalies are flagged, in normalized rating points. Each
+// cohort spans 100 normalized rating points.
+const (
+	ratingJumpThreshold     = 300
+	systemMismatchThreshold = 500
+)
+
+const ratingAnomalyTableStatusIndex = "StatusIdx"
+
+// RatingAnomalyEvidence contains the data which caused a RatingAnomaly to be flagged.
+// Only the fields relevant to the anomaly's type are set.
+type RatingAnomalyEvidence struct {
+	// The username of the account in the anomaly's rating system.
+	AccountUsername string `dynamodbav:"accountUsername,omitempty" json:"accountUsername,omitempty"`
+
+	// The status of the account reported by the rating system.
+	AccountStatus string `dynamodbav:"accountStatus,omitempty" json:"accountStatus,omitempty"`
+
+	// The rating before the anomaly, for rating jumps.
+	PreviousRating int `dynamodbav:"previousRating,omitempty" json:"previousRating,omitempty"`
+
+	// The rating when the anomaly was flagged.
+	Rating int `dynamodbav:"rating,omitempty" json:"rating,omitempty"`
+
+	// The linked rating system compared against the anomaly's rating system, for
+	// system mismatches.
+	ComparedSystem RatingSystem `dynamodbav:"comparedSystem,omitempty" json:"comparedSystem,omitempty"`
+
+	// The rating in ComparedSystem.
+	ComparedRating int `dynamodbav:"comparedRating,omitempty" json:"comparedRating,omitempty"`
+
+	// The difference between the normalized ratings, for rating jumps and system mismatches.
+	NormalizedDifference int `dynamodbav:"normalizedDifference,omitempty" json:"normalizedDifference,omitempty"`
+}
+
+// RatingAnomaly is a suspicious rating of a user, which is queued for review by an admin.
+type RatingAnomaly struct {
+	// The username of the user. The hash key of the table.
+	Username string `dynamodbav:"username" json:"username"`
+
+	// The id of the anomaly, in the form TYPE#SYSTEM#DETAIL. An anomaly with the same id
+	// is only flagged once. The range key of the table.
+	Id string `dynamodbav:"id" json:"id"`
+
+	// The type of the anomaly.
+	Type RatingAnomalyType `dynamodbav:"type" json:"type"`
+
+	// The rating system of the anomaly.
+	RatingSystem RatingSystem `dynamodbav:"ratingSystem" json:"ratingSystem"`
+
+	// The data which caused the anomaly to be flagged.
+	Evidence RatingAnomalyEvidence `dynamodbav:"evidence" json:"evidence"`
+
+	// The review status of the anomaly. The hash key of the status index.
+	Status RatingAnomalyStatus `dynamodbav:"status" json:"status"`
+
+	// The time the anomaly was flagged, in time.RFC3339 format. The range key of the status index.
+	CreatedAt string `dynamodbav:"createdAt" json:"createdAt"`
+
+	// The username of the admin that resolved the anomaly.
+	ResolvedBy string `dynamodbav:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+
+	// The time the anomaly was resolved, in time.RFC3339 format.
+	ResolvedAt string `dynamodbav:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+
+	// The reason given by the admin for the resolution.
+	Reason string `dynamodbav:"reason,omitempty" json:"reason,omitempty"`
+}
+
+func newRatingAnomaly(username string, anomalyType RatingAnomalyType, system RatingSystem, detail string, evidence RatingAnomalyEvidence, date time.Time) *RatingAnomaly {
+	return &RatingAnomaly{
+		Username:     username,
+		Id:           fmt.Sprintf("%s#%s#%s", anomalyType, system, detail),
+		Type:         anomalyType,
+		RatingSystem: system,
+		Evidence:     evidence,
+		Status:       RatingAnomalyStatus_Open,
+		CreatedAt:    date.Format(time.RFC3339),
+	}
+}
+
+// NewAccountClosureAnomaly returns an anomaly for an account in the given rating system
+// which was closed for fair play or terms of service violations.
+func NewAccountClosureAnomaly(username string, system RatingSystem, accountUsername, accountStatus string, date time.Time) *RatingAnomaly {
+	anomalyType := RatingAnomalyType_FairPlayClosure
+	if system == Lichess {
+		anomalyType = RatingAnomalyType_TosViolation
+	}
+	evidence := RatingAnomalyEvidence{AccountUsername: accountUsername, AccountStatus: accountStatus}
+	return newRatingAnomaly(username, anomalyType, system, strings.ToLower(accountUsername), evidence, date)
+}
+
+// DetectRatingJump returns an anomaly if the user's rating in the given system changed
+// from previous to current by more than ratingJumpThreshold normalized rating points.
+// Nil is returned if the change is plausible or cannot be normalized.
+func DetectRatingJump(username string, system RatingSystem, previous, current int, boundaries *CohortBoundaries, date time.Time) *RatingAnomaly {
+	if previous <= 0 || current <= 0 {
+		return nil
+	}
+
+	difference, err := normalizedDifference(boundaries, system, previous, system, current)
+	if err != nil || difference <= ratingJumpThreshold {
+		return nil
+	}
+
+	evidence := RatingAnomalyEvidence{PreviousRating: previous, Rating: current, NormalizedDifference: difference}
+	return newRatingAnomaly(username, RatingAnomalyType_RatingJump, system, date.UTC().Format(time.DateOnly), evidence, date)
+}
+
+// DetectSystemMismatches returns an anomaly for each linked rating of the user whose
+// normalized rating differs from the normalized rating of the user's preferred rating
+// system by more than systemMismatchThreshold. Provisional ratings are ignored.
+func DetectSystemMismatches(user *User, boundaries *CohortBoundaries, date time.Time) []*RatingAnomaly {
+	preferred := user.Ratings[user.RatingSystem]
+	if preferred == nil || preferred.CurrentRating <= 0 || preferred.IsProvisional(user.RatingSystem) {
+		return nil
+	}
+
+	var anomalies []*RatingAnomaly
+	for system, rating := range user.Ratings {
+		if system == user.RatingSystem || rating == nil || rating.CurrentRating <= 0 || rating.IsProvisional(system) {
+			continue
+		}
+
+		difference, err := normalizedDifference(boundaries, user.RatingSystem, preferred.CurrentRating, system, rating.CurrentRating)
+		if err != nil || difference <= systemMismatchThreshold {
+			continue
+		}
+
+		evidence := RatingAnomalyEvidence{
+			Rating:               preferred.CurrentRating,
+			ComparedSystem:       system,
+			ComparedRating:       rating.CurrentRating,
+			NormalizedDifference: difference,
+		}
+		anomalies = append(anomalies, newRatingAnomaly(user.Username, RatingAnomalyType_SystemMismatch, user.RatingSystem, string(system), evidence, date))
+	}
+	return anomalies
+}
+
+// normalizedDifference returns the absolute difference between the normalized ratings
+// of rating1 in system1 and rating2 in system2.
+func normalizedDifference(boundaries *CohortBoundaries, system1 RatingSystem, rating1 int, system2 RatingSystem, rating2 int) (int, error) {
+	normalized1, err := boundaries.NormalizedRating(system1, float64(rating1))
+	if err != nil {
+		return 0, err
+	}
+	normalized2, err := boundaries.NormalizedRating(system2, float64(rating2))
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(math.Abs(normalized1 - normalized2))), nil
+}
+
+type RatingAnomalyCreator interface {
+	// CreateRatingAnomalies adds the given anomalies to the review queue. Anomalies with
+	// the same username and id as an existing anomaly, including a resolved one, are
+	// skipped. The number of added anomalies is returned.
+	CreateRatingAnomalies(anomalies []*RatingAnomaly) (int, error)
+}
+
+// CreateRatingAnomalies adds the given anomalies to the review queue. Anomalies with
+// the same username and id as an existing anomaly, including a resolved one, are
+// skipped. The number of added anomalies is returned.
+func (repo *dynamoRepository) CreateRatingAnomalies(anomalies []*RatingAnomaly) (int, error) {
+	created := 0
+	for _, anomaly := range anomalies {
+		item, err := dynamodbattribute.MarshalMap(anomaly)
+		if err != nil {
+			return created, errors.Wrap(500, "Temporary server error", "Unable to marshal rating anomaly", err)
+		}
+
+		_, err = repo.svc.PutItem(&dynamodb.PutItemInput{
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+			TableName:           aws.String(ratingAnomalyTable),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue
+		}
+		if err != nil {
+			return created, errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+type RatingAnomalyReviewer interface {
+	UserUpdater
+	AuditEntryCreator
+
+	// ListRatingAnomalies returns the anomalies with the given status, oldest first, up to
+	// 1MB of data. startKey is an optional parameter used to perform pagination.
+	ListRatingAnomalies(status RatingAnomalyStatus, startKey string) ([]RatingAnomaly, string, error)
+
+	// GetRatingAnomaly returns the anomaly with the given username and id.
+	GetRatingAnomaly(username, id string) (*RatingAnomaly, error)
+
+	// ResolveRatingAnomaly sets the status of the given open anomaly and returns the
+	// anomaly after the update.
+	ResolveRatingAnomaly(username, id string, status RatingAnomalyStatus, resolvedBy, reason string) (*RatingAnomaly, error)
+}
+
+// ListRatingAnomalies returns the anomalies with the given status, oldest first, up to
+// 1MB of data. startKey is an optional parameter used to perform pagination.
+func (repo *dynamoRepository) ListRatingAnomalies(status RatingAnomalyStatus, startKey string) ([]RatingAnomaly, string, error) {
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(string(status))},
+		},
+		IndexName: aws.String(ratingAnomalyTableStatusIndex),
+		TableName: aws.String(ratingAnomalyTable),
+	}
+
+	var anomalies []RatingAnomaly
+	lastKey, err := repo.query(input, startKey, &anomalies)
+	if err != nil {
+		return nil, "", err
+	}
+	return anomalies, lastKey, nil
+}
+
+// GetRatingAnomaly returns the anomaly with the given username and id.
+func (repo *dynamoRepository) GetRatingAnomaly(username, id string) (*RatingAnomaly, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"username": {S: aws.String(username)},
+			"id":       {S: aws.String(id)},
+		},
+		TableName: aws.String(ratingAnomalyTable),
+	}
+
+	anomaly := RatingAnomaly{}
+	err := repo.getItem(input, &anomaly)
+	return &anomaly, err
+}
+
+// ResolveRatingAnomaly sets the status of the given open anomaly and returns the
+// anomaly after the update. A 400 error is returned if the anomaly is already resolved.
+func (repo *dynamoRepository) ResolveRatingAnomaly(username, id string, status RatingAnomalyStatus, resolvedBy, reason string) (*RatingAnomaly, error) {
+	if status == RatingAnomalyStatus_Open {
+		return nil, errors.New(500, "Temporary server error", "ResolveRatingAnomaly called with status OPEN")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"username": {S: aws.String(username)},
+			"id":       {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("#status = :open"),
+		UpdateExpression:    aws.String("SET #status = :status, #resolvedBy = :resolvedBy, #resolvedAt = :resolvedAt, #reason = :reason"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status":     aws.String("status"),
+			"#resolvedBy": aws.String("resolvedBy"),
+			"#resolvedAt": aws.String("resolvedAt"),
+			"#reason":     aws.String("reason"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":open":       {S: aws.String(string(RatingAnomalyStatus_Open))},
+			":status":     {S: aws.String(string(status))},
+			":resolvedBy": {S: aws.String(resolvedBy)},
+			":resolvedAt": {S: aws.String(time.Now().Format(time.RFC3339))},
+			":reason":     {S: aws.String(reason)},
+		},
+		TableName:    aws.String(ratingAnomalyTable),
+		ReturnValues: aws.String("ALL_NEW"),
+	}
+
+	anomaly := &RatingAnomaly{}
+	if err := repo.updateItem(input, anomaly); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, errors.New(400, "Invalid request: anomaly not found or already resolved", "")
+		}
+		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB UpdateItem failure", err)
+	}
+	return anomaly, nil
+}
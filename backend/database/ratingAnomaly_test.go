@@ -0,0 +1,105 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectRatingJump(t *testing.T) {
+	now := time.Date(2024, 10, 3, 0, 0, 0, 0, time.UTC)
+
+	table := []struct {
+		name     string
+		previous int
+		current  int
+		want     bool
+	}{
+		{name: "Plausible", previous: 1500, current: 1550},
+		{name: "Jump", previous: 1500, current: 2090, want: true},
+		{name: "Drop", previous: 2090, current: 1500, want: true},
+		{name: "FirstRating", previous: 0, current: 2100},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DetectRatingJump("test", Lichess, tc.previous, tc.current, &DefaultCohortBoundaries, now)
+			if (got != nil) != tc.want {
+				t.Fatalf("DetectRatingJump(%d, %d) = %+v; want anomaly: %t", tc.previous, tc.current, got, tc.want)
+			}
+			if got != nil && (got.Id != "RATING_JUMP#LICHESS#2024-10-03" || got.Evidence.NormalizedDifference != 1000) {
+				t.Errorf("DetectRatingJump(%d, %d) = %+v; want id RATING_JUMP#LICHESS#2024-10-03 and difference 1000", tc.previous, tc.current, got)
+			}
+		})
+	}
+}
+
+func TestDetectSystemMismatches(t *testing.T) {
+	user := &User{
+		Username:     "test",
+		RatingSystem: Chesscom,
+		Ratings: map[RatingSystem]*Rating{
+			Chesscom: {CurrentRating: 1500},
+			Lichess:  {CurrentRating: 1435},
+			Uscf:     {CurrentRating: 1420},
+			Fide:     {CurrentRating: 2400, NumGames: 5},
+			Custom:   {CurrentRating: 100},
+		},
+	}
+
+	got := DetectSystemMismatches(user, &DefaultCohortBoundaries, time.Now())
+	if len(got) != 1 {
+		t.Fatalf("DetectSystemMismatches got %d anomalies; want 1", len(got))
+	}
+	if got[0].Id != "SYSTEM_MISMATCH#CHESSCOM#LICHESS" || got[0].Evidence.ComparedRating != 1435 || got[0].Evidence.NormalizedDifference != 650 {
+		t.Errorf("DetectSystemMismatches got %+v; want a 650 point mismatch with Lichess", got[0])
+	}
+
+	user.Ratings[Chesscom].NumGames = 3
+	if got := DetectSystemMismatches(user, &DefaultCohortBoundaries, time.Now()); len(got) != 0 {
+		t.Errorf("DetectSystemMismatches with a provisional preferred rating got %+v; want none", got)
+	}
+}
+
+func TestInMemoryRatingAnomalies(t *testing.T) {
+	repo := NewInMemory()
+	now := time.Now()
+
+	anomaly := NewAccountClosureAnomaly("test", Chesscom, "ClosedPlayer", "closed:fair_play_violations", now)
+	later := NewAccountClosureAnomaly("test2", Lichess, "Banned", "tosViolation", now.Add(time.Second))
+	created, err := repo.CreateRatingAnomalies([]*RatingAnomaly{anomaly, later})
+	if err != nil || created != 2 {
+		t.Fatalf("CreateRatingAnomalies got %d, %v; want 2, nil", created, err)
+	}
+	if anomaly.Id != "FAIR_PLAY_CLOSURE#CHESSCOM#closedplayer" || later.Type != RatingAnomalyType_TosViolation {
+		t.Errorf("NewAccountClosureAnomaly got ids %q and %q", anomaly.Id, later.Id)
+	}
+
+	created, err = repo.CreateRatingAnomalies([]*RatingAnomaly{anomaly})
+	if err != nil || created != 0 {
+		t.Errorf("CreateRatingAnomalies with an existing anomaly got %d, %v; want 0, nil", created, err)
+	}
+
+	open, _, err := repo.ListRatingAnomalies(RatingAnomalyStatus_Open, "")
+	if err != nil {
+		t.Fatalf("ListRatingAnomalies got err: %v", err)
+	}
+	if len(open) != 2 || open[0].Username != "test" || open[1].Username != "test2" {
+		t.Errorf("ListRatingAnomalies got %+v; want both anomalies, oldest first", open)
+	}
+
+	resolved, err := repo.ResolveRatingAnomaly("test", anomaly.Id, RatingAnomalyStatus_Enforced, "admin", "Closed account")
+	if err != nil {
+		t.Fatalf("ResolveRatingAnomaly got err: %v", err)
+	}
+	if resolved.Status != RatingAnomalyStatus_Enforced || resolved.ResolvedBy != "admin" || resolved.Evidence.AccountStatus != "closed:fair_play_violations" {
+		t.Errorf("ResolveRatingAnomaly got %+v; want enforced by admin with evidence", resolved)
+	}
+	if _, err := repo.ResolveRatingAnomaly("test", anomaly.Id, RatingAnomalyStatus_Dismissed, "admin", ""); err == nil {
+		t.Error("ResolveRatingAnomaly on a resolved anomaly got nil err; want 400")
+	}
+
+	open, _, err = repo.ListRatingAnomalies(RatingAnomalyStatus_Open, "")
+	if err != nil || len(open) != 1 || open[0].Username != "test2" {
+		t.Errorf("ListRatingAnomalies after resolving got %+v, %v; want only test2", open, err)
+	}
+}
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+type RatingHistoryGranularity string
+
+const (
+	RatingHistoryGranularity_Daily   RatingHistoryGranularity = "DAILY"
+	RatingHistoryGranularity_Weekly  RatingHistoryGranularity = "WEEKLY"
+	RatingHistoryGranularity_Monthly RatingHistoryGranularity = "MONTHLY"
+)
+
+// Every rating history update is saved as a daily, weekly and monthly point. A weekly or
+// monthly point is overwritten by each update in its week or month, so it contains the
+// last rating of the period. Daily and weekly points expire after their retention, which
+// compacts older history into weekly and then monthly points. Monthly points never expire.
+const (
+	dailyRatingHistoryRetention  = 31 * 24 * time.Hour
+	weeklyRatingHistoryRetention = 366 * 24 * time.Hour
+)
+
+// ratingHistoryPoint is a single item in the rating history table.
+type ratingHistoryPoint struct {
+	// The username of the user. The hash key of the table.
+	Username string `dynamodbav:"username"`
+
+	// The id of the point, in the form SYSTEM#GRANULARITY#PERIOD, where PERIOD is the date of
+	// the first day of the point's day, week or month. The range key of the table.
+	Id string `dynamodbav:"id"`
+
+	// The rating system of the point.
+	RatingSystem RatingSystem `dynamodbav:"ratingSystem"`
+
+	// The period covered by the point.
+	Granularity RatingHistoryGranularity `dynamodbav:"granularity"`
+
+	// The date the rating was fetched, in time.RFC3339 format.
+	Date string `dynamodbav:"date"`
+
+	// The rating at the given date.
+	Rating int `dynamodbav:"rating"`
+
+	// The ratings at the given date in each time control of the rating system.
+	TimeControls map[TimeControl]int `dynamodbav:"timeControls,omitempty"`
+
+	// The time the point is deleted, in Unix seconds. Not set for monthly points.
+	ExpirationTime int64 `dynamodbav:"expirationTime,omitempty"`
+}
+
+// ratingHistoryPeriod returns the date of the first day of the period with the given
+// granularity that contains t.
+func ratingHistoryPeriod(granularity RatingHistoryGranularity, t time.Time) string {
+	t = t.UTC()
+	switch granularity {
+	case RatingHistoryGranularity_Weekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		t = t.AddDate(0, 0, -offset)
+	case RatingHistoryGranularity_Monthly:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Format(time.DateOnly)
+}
+
+func ratingHistoryId(system RatingSystem, granularity RatingHistoryGranularity, period string) string {
+	return fmt.Sprintf("%s#%s#%s", system, granularity, period)
+}
+
+// RatingHistoryUpdate is a rating fetched for a single user and rating system.
+type RatingHistoryUpdate struct {
+	Username     string
+	RatingSystem RatingSystem
+	History      RatingHistory
+}
+
+// points returns the daily, weekly and monthly points saved for the update.
+func (u RatingHistoryUpdate) points() ([]ratingHistoryPoint, error) {
+	date, err := time.Parse(time.RFC3339, u.History.Date)
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", fmt.Sprintf("Invalid rating history date %q", u.History.Date), err)
+	}
+
+	retentions := []struct {
+		granularity RatingHistoryGranularity
+		retention   time.Duration
+	}{
+		{granularity: RatingHistoryGranularity_Daily, retention: dailyRatingHistoryRetention},
+		{granularity: RatingHistoryGranularity_Weekly, retention: weeklyRatingHistoryRetention},
+		{granularity: RatingHistoryGranularity_Monthly},
+	}
+
+	points := make([]ratingHistoryPoint, 0, len(retentions))
+	for _, r := range retentions {
+		point := ratingHistoryPoint{
+			Username:     u.Username,
+			Id:           ratingHistoryId(u.RatingSystem, r.granularity, ratingHistoryPeriod(r.granularity, date)),
+			RatingSystem: u.RatingSystem,
+			Granularity:  r.granularity,
+			Date:         u.History.Date,
+			Rating:       u.History.Rating,
+			TimeControls: u.History.TimeControls,
+		}
+		if r.retention > 0 {
+			point.ExpirationTime = date.Add(r.retention).Unix()
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+type RatingHistoryPutter interface {
+	// PutRatingHistories saves the provided rating history updates. If some updates could
+	// not be saved, the returned error's cause is a *BatchError listing them.
+	PutRatingHistories(updates []RatingHistoryUpdate) error
+}
+
+// PutRatingHistories saves the provided rating history updates. If some updates could
+// not be saved, the returned error's cause is a *BatchError listing them.
+func (repo *dynamoRepository) PutRatingHistories(updates []RatingHistoryUpdate) error {
+	// A batch cannot contain the same key twice, so only the latest point of each period is kept.
+	var keys []string
+	latest := make(map[string]ratingHistoryPoint)
+	for _, u := range updates {
+		points, err := u.points()
+		if err != nil {
+			return err
+		}
+		for _, p := range points {
+			key := p.Username + "/" + p.Id
+			prev, ok := latest[key]
+			if !ok {
+				keys = append(keys, key)
+			}
+			if !ok || p.Date > prev.Date {
+				latest[key] = p
+			}
+		}
+	}
+
+	reqs := make([]*dynamodb.WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		item, err := dynamodbattribute.MarshalMap(latest[key])
+		if err != nil {
+			return errors.Wrap(500, "Temporary server error", "Unable to marshal rating history point", err)
+		}
+		reqs = append(reqs, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+
+	var failures []BatchItemFailure
+	processed := 0
+	for start := 0; start < len(reqs); start += 25 {
+		end := start + 25
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+
+		err := repo.batchWrite(reqs[start:end], ratingHistoryTable)
+		if f := BatchFailures(err); f != nil {
+			failures = append(failures, f...)
+			processed += end - start - len(f)
+		} else if err != nil {
+			return err
+		} else {
+			processed += end - start
+		}
+	}
+	return batchFailure("BatchWriteItem", processed, failures)
+}
+
+type RatingHistoryLister interface {
+	// ListRatingHistory returns the user's rating history in the given rating system between
+	// start and end, inclusive, oldest first. Recent history contains daily points, while
+	// older history is compacted into weekly and monthly points.
+	ListRatingHistory(username string, system RatingSystem, start, end time.Time) ([]RatingHistory, error)
+}
+
+// ListRatingHistory returns the user's rating history in the given rating system between
+// start and end, inclusive, oldest first. Recent history contains daily points, while
+// older history is compacted into weekly and monthly points.
+func (repo *dynamoRepository) ListRatingHistory(username string, system RatingSystem, start, end time.Time) ([]RatingHistory, error) {
+	if end.Before(start) {
+		return nil, errors.New(400, "Invalid request: endDate must be after startDate", "")
+	}
+
+	now := time.Now()
+	dailyCutoff := now.Add(-dailyRatingHistoryRetention)
+	weeklyCutoff := now.Add(-weeklyRatingHistoryRetention)
+
+	// Each granularity is read only for the time range in which it has not expired.
+	segments := []struct {
+		granularity RatingHistoryGranularity
+		start, end  time.Time
+	}{
+		{granularity: RatingHistoryGranularity_Monthly, start: start, end: minTime(end, weeklyCutoff)},
+		{granularity: RatingHistoryGranularity_Weekly, start: maxTime(start, weeklyCutoff), end: minTime(end, dailyCutoff)},
+		{granularity: RatingHistoryGranularity_Daily, start: maxTime(start, dailyCutoff), end: end},
+	}
+
+	var result []RatingHistory
+	for _, s := range segments {
+		if s.end.Before(s.start) {
+			continue
+		}
+
+		points, err := repo.listRatingHistoryPoints(username, system, s.granularity, s.start, s.end)
+		if err != nil {
+			return nil, err
+		}
+
+		startDate, endDate := s.start.UTC().Format(time.RFC3339), s.end.UTC().Format(time.RFC3339)
+		for _, p := range points {
+			if p.Date >= startDate && p.Date <= endDate {
+				result = append(result, RatingHistory{Date: p.Date, Rating: p.Rating, TimeControls: p.TimeControls})
+			}
+		}
+	}
+	return result, nil
+}
+
+// listRatingHistoryPoints returns all points with the given granularity whose period
+// overlaps start and end.
+func (repo *dynamoRepository) listRatingHistoryPoints(username string, system RatingSystem, granularity RatingHistoryGranularity, start, end time.Time) ([]ratingHistoryPoint, error) {
+	keyCondition := expression.Key("username").Equal(expression.Value(username)).And(
+		expression.Key("id").Between(
+			expression.Value(ratingHistoryId(system, granularity, ratingHistoryPeriod(granularity, start))),
+			expression.Value(ratingHistoryId(system, granularity, end.UTC().Format(time.DateOnly))),
+		),
+	)
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB expression building error", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		TableName:                 aws.String(ratingHistoryTable),
+	}
+
+	var points []ratingHistoryPoint
+	startKey := ""
+	for ok := true; ok; ok = startKey != "" {
+		var page []ratingHistoryPoint
+		startKey, err = repo.query(input, startKey, &page)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, page...)
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
+
+// MergeLegacyRatingHistory returns history preceded by the entries of legacy, the weekly
+// history saved on the user before the rating history table existed, which are between
+// start and end and older than the first entry of history.
+func MergeLegacyRatingHistory(legacy, history []RatingHistory, start, end time.Time) []RatingHistory {
+	startDate, endDate := start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339)
+
+	var result []RatingHistory
+	for _, h := range legacy {
+		if h.Date < startDate || h.Date > endDate || (len(history) > 0 && h.Date >= history[0].Date) {
+			continue
+		}
+		result = append(result, h)
+	}
+	return append(result, history...)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
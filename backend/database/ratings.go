@@ -1,5 +1,9 @@
 package database
 
+// ratingBoundaries contains the compiled cohort boundaries of each rating system. They
+// are used until a version of the boundaries is published in the database. The FFE,
+// FEDA, FSI, AICF, CBX and NZCF boundaries have not yet been checked against a
+// published source.
 var ratingBoundaries = map[RatingSystem][]int{
 	Chesscom: {550, 650, 750, 850, 950, 1050, 1150, 1250, 1350, 1450, 1550, 1650, 1750, 1850, 1950, 2050, 2165, 2275, 2360, 2425, 2485, 2550},
 	Lichess:  {1250, 1310, 1370, 1435, 1500, 1550, 1600, 1665, 1730, 1795, 1850, 1910, 1970, 2030, 2090, 2150, 2225, 2310, 2370, 2410, 2440, 2470},
@@ -10,19 +14,205 @@ var ratingBoundaries = map[RatingSystem][]int{
 	Dwz:      {450, 540, 630, 725, 815, 920, 1025, 1110, 1185, 1260, 1335, 1410, 1480, 1560, 1640, 1720, 1815, 1940, 2070, 2185, 2285, 2385},
 	Knsb:     {400, 600, 800, 1000, 1140, 1280, 1400, 1450, 1500, 1550, 1600, 1650, 1700, 1750, 1800, 1850, 1910, 2000, 2100, 2200, 2300, 2400},
 	Acf:      {300, 395, 490, 585, 680, 775, 870, 990, 1100, 1210, 1320, 1415, 1510, 1605, 1700, 1790, 1900, 2000, 2105, 2215, 2330, 2450},
+	Ffe:      {800, 900, 1000, 1100, 1200, 1300, 1400, 1475, 1550, 1610, 1670, 1730, 1790, 1850, 1900, 1950, 2010, 2090, 2180, 2270, 2350, 2430},
+	Feda:     {1000, 1100, 1200, 1300, 1400, 1450, 1500, 1550, 1600, 1650, 1700, 1750, 1800, 1850, 1900, 1950, 2010, 2090, 2180, 2260, 2340, 2420},
+	Fsi:      {1000, 1100, 1200, 1300, 1400, 1440, 1480, 1520, 1560, 1610, 1660, 1710, 1760, 1810, 1860, 1910, 1970, 2060, 2150, 2240, 2330, 2420},
+	Aicf:     {1000, 1100, 1200, 1300, 1400, 1450, 1500, 1550, 1600, 1650, 1700, 1750, 1800, 1850, 1900, 1950, 2010, 2100, 2190, 2280, 2370, 2460},
+	Cbx:      {1000, 1100, 1200, 1300, 1400, 1475, 1550, 1610, 1670, 1720, 1770, 1820, 1870, 1920, 1970, 2020, 2080, 2150, 2230, 2300, 2380, 2460},
+	Nzcf:     {300, 450, 600, 750, 900, 1000, 1100, 1200, 1300, 1400, 1480, 1560, 1640, 1720, 1800, 1880, 1960, 2060, 2160, 2260, 2360, 2460},
 }
 
-func getCohort(ratingSystem RatingSystem, currentRating int) DojoCohort {
-	boundaries := ratingBoundaries[ratingSystem]
-	if boundaries == nil {
-		return NoCohort
+// provisionalDeviation is the rating deviation above which a rating is provisional, in
+// the rating systems that report a deviation. Lichess uses the same threshold.
+const provisionalDeviation = 110
+
+// provisionalGames maps a rating system to the number of games below which its ratings
+// are provisional. Rating systems not in this map use defaultProvisionalGames.
+var provisionalGames = map[RatingSystem]int{
+	Uscf: 26,
+}
+
+const defaultProvisionalGames = 20
+
+// IsProvisional returns true if the rating is not yet reliable enough to place the user
+// in a cohort, because its deviation is too high or too few games have been played. The
+// deviation and number of games are only checked if they are known.
+func (r *Rating) IsProvisional(system RatingSystem) bool {
+	if r == nil {
+		return false
+	}
+	if r.Deviation > provisionalDeviation {
+		return true
+	}
+
+	minGames, ok := provisionalGames[system]
+	if !ok {
+		minGames = defaultProvisionalGames
+	}
+	return r.NumGames > 0 && r.NumGames < minGames
+}
+
+// ConservativeRating returns the lower end of the rating's expected range, which is used
+// to place users with a provisional rating. If the deviation is unknown or lower than
+// provisionalDeviation, provisionalDeviation is used instead.
+func (r *Rating) ConservativeRating() int {
+	deviation := max(r.Deviation, provisionalDeviation)
+	return max(r.CurrentRating-deviation, 0)
+}
+
+type TimeControl string
+
+const (
+	TimeControl_Bullet         TimeControl = "BULLET"
+	TimeControl_Blitz          TimeControl = "BLITZ"
+	TimeControl_Rapid          TimeControl = "RAPID"
+	TimeControl_Classical      TimeControl = "CLASSICAL"
+	TimeControl_Daily          TimeControl = "DAILY"
+	TimeControl_Correspondence TimeControl = "CORRESPONDENCE"
+)
+
+// TimeControlRating is a user's rating in a single time control of a rating system.
+type TimeControlRating struct {
+	// The user's rating in this time control at the time they joined the Dojo, or at
+	// the time the time control was first fetched.
+	StartRating int `dynamodbav:"startRating" json:"startRating"`
+
+	// The user's current rating in this time control.
+	CurrentRating int `dynamodbav:"currentRating" json:"currentRating"`
+
+	// The user's current rating deviation in this time control, if known.
+	Deviation int `dynamodbav:"deviation,omitempty" json:"-"`
+
+	// The number of games played in this time control, if known.
+	NumGames int `dynamodbav:"numGames,omitempty" json:"-"`
+}
+
+// timeControls maps the rating systems that support more than one time control to
+// their supported time controls. The first time control of each rating system is its
+// default. Rating systems not in this map only have a single rating.
+var timeControls = map[RatingSystem][]TimeControl{
+	Chesscom: {TimeControl_Rapid, TimeControl_Bullet, TimeControl_Blitz, TimeControl_Daily},
+	Lichess:  {TimeControl_Classical, TimeControl_Bullet, TimeControl_Blitz, TimeControl_Rapid, TimeControl_Correspondence},
+	Fide:     {TimeControl_Classical, TimeControl_Rapid, TimeControl_Blitz},
+}
+
+// DefaultTimeControl returns the time control used for the given rating system when
+// the user has not chosen one. An empty string is returned if the rating system does
+// not support multiple time controls.
+func DefaultTimeControl(system RatingSystem) TimeControl {
+	if tcs := timeControls[system]; len(tcs) > 0 {
+		return tcs[0]
+	}
+	return ""
+}
+
+// SupportsTimeControl returns true if the given time control is supported by the
+// rating system. The empty time control is supported by all rating systems.
+func SupportsTimeControl(system RatingSystem, tc TimeControl) bool {
+	if tc == "" {
+		return true
+	}
+	for _, t := range timeControls[system] {
+		if t == tc {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTimeControl returns the time control which drives the rating, or an empty string
+// if the rating system does not support multiple time controls.
+func (r *Rating) GetTimeControl(system RatingSystem) TimeControl {
+	if r.TimeControl != "" {
+		return r.TimeControl
+	}
+	return DefaultTimeControl(system)
+}
+
+// Update sets the rating's time controls and current values from the provided
+// fetched rating, whose StartRatings are ignored. If the rating system supports multiple
+// time controls, the current values are taken from the rating's chosen time control.
+// The StartRating of each time control is set the first time it is fetched. Returns
+// true if any value of the rating changed.
+func (r *Rating) Update(system RatingSystem, fetched *Rating) bool {
+	// Ratings saved before time controls were supported only have the StartRating
+	// of the time control that drove them.
+	legacy := len(r.TimeControls) == 0
+
+	changed := false
+	for tc, f := range fetched.TimeControls {
+		existing := r.TimeControls[tc]
+		updated := TimeControlRating{
+			StartRating:   f.CurrentRating,
+			CurrentRating: f.CurrentRating,
+			Deviation:     f.Deviation,
+			NumGames:      f.NumGames,
+		}
+		if existing != nil && existing.StartRating != 0 {
+			updated.StartRating = existing.StartRating
+		} else if legacy && r.StartRating != 0 && tc == r.GetTimeControl(system) {
+			updated.StartRating = r.StartRating
+		}
+		if existing == nil || *existing != updated {
+			if r.TimeControls == nil {
+				r.TimeControls = make(map[TimeControl]*TimeControlRating)
+			}
+			r.TimeControls[tc] = &updated
+			changed = true
+		}
 	}
 
-	for i := 0; i < len(boundaries); i++ {
-		if currentRating < boundaries[i] {
-			return Cohorts[i]
+	current := TimeControlRating{
+		StartRating:   r.StartRating,
+		CurrentRating: fetched.CurrentRating,
+		Deviation:     fetched.Deviation,
+		NumGames:      fetched.NumGames,
+	}
+	if tc := r.GetTimeControl(system); tc != "" && len(r.TimeControls) > 0 {
+		current = TimeControlRating{}
+		if selected := r.TimeControls[tc]; selected != nil {
+			current = *selected
+		}
+	}
+	if current.StartRating == 0 {
+		current.StartRating = current.CurrentRating
+	}
+
+	if r.StartRating != current.StartRating || r.CurrentRating != current.CurrentRating ||
+		r.Deviation != current.Deviation || r.NumGames != current.NumGames {
+		r.StartRating = current.StartRating
+		r.CurrentRating = current.CurrentRating
+		r.Deviation = current.Deviation
+		r.NumGames = current.NumGames
+		changed = true
+	}
+	return changed
+}
+
+// History returns the RatingHistory of the rating at the given date.
+func (r *Rating) History(date string) RatingHistory {
+	history := RatingHistory{Date: date, Rating: r.CurrentRating}
+	for tc, rating := range r.TimeControls {
+		if rating.CurrentRating > 0 {
+			if history.TimeControls == nil {
+				history.TimeControls = make(map[TimeControl]int, len(r.TimeControls))
+			}
+			history.TimeControls[tc] = rating.CurrentRating
 		}
 	}
+	return history
+}
 
-	return "2400+"
+// SameRatings returns true if h and other have the same overall and time control ratings,
+// regardless of their dates.
+func (h RatingHistory) SameRatings(other RatingHistory) bool {
+	if h.Rating != other.Rating || len(h.TimeControls) != len(other.TimeControls) {
+		return false
+	}
+	for tc, rating := range h.TimeControls {
+		if other.TimeControls[tc] != rating {
+			return false
+		}
+	}
+	return true
 }
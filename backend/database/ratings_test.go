@@ -0,0 +1,139 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRatingUpdate(t *testing.T) {
+	fetched := &Rating{
+		CurrentRating: 1600,
+		TimeControls: map[TimeControl]*TimeControlRating{
+			TimeControl_Blitz: {CurrentRating: 1400, NumGames: 200},
+			TimeControl_Rapid: {CurrentRating: 1600, NumGames: 50},
+		},
+	}
+
+	table := []struct {
+		name        string
+		system      RatingSystem
+		rating      *Rating
+		fetched     *Rating
+		want        *Rating
+		wantChanged bool
+	}{
+		{
+			name:        "SingleTimeControl",
+			system:      Uscf,
+			rating:      &Rating{Username: "1", CurrentRating: 1000},
+			fetched:     &Rating{CurrentRating: 1100, NumGames: 10},
+			want:        &Rating{Username: "1", StartRating: 1100, CurrentRating: 1100, NumGames: 10},
+			wantChanged: true,
+		},
+		{
+			name:    "DefaultTimeControl",
+			system:  Chesscom,
+			rating:  &Rating{Username: "a"},
+			fetched: fetched,
+			want: &Rating{
+				Username:      "a",
+				StartRating:   1600,
+				CurrentRating: 1600,
+				NumGames:      50,
+				TimeControls: map[TimeControl]*TimeControlRating{
+					TimeControl_Blitz: {StartRating: 1400, CurrentRating: 1400, NumGames: 200},
+					TimeControl_Rapid: {StartRating: 1600, CurrentRating: 1600, NumGames: 50},
+				},
+			},
+			wantChanged: true,
+		},
+		{
+			name:    "ChosenTimeControl",
+			system:  Chesscom,
+			rating:  &Rating{Username: "a", TimeControl: TimeControl_Blitz},
+			fetched: fetched,
+			want: &Rating{
+				Username:      "a",
+				TimeControl:   TimeControl_Blitz,
+				StartRating:   1400,
+				CurrentRating: 1400,
+				NumGames:      200,
+				TimeControls: map[TimeControl]*TimeControlRating{
+					TimeControl_Blitz: {StartRating: 1400, CurrentRating: 1400, NumGames: 200},
+					TimeControl_Rapid: {StartRating: 1600, CurrentRating: 1600, NumGames: 50},
+				},
+			},
+			wantChanged: true,
+		},
+		{
+			name:    "LegacyStartRating",
+			system:  Chesscom,
+			rating:  &Rating{Username: "a", StartRating: 1200, CurrentRating: 1500},
+			fetched: fetched,
+			want: &Rating{
+				Username:      "a",
+				StartRating:   1200,
+				CurrentRating: 1600,
+				NumGames:      50,
+				TimeControls: map[TimeControl]*TimeControlRating{
+					TimeControl_Blitz: {StartRating: 1400, CurrentRating: 1400, NumGames: 200},
+					TimeControl_Rapid: {StartRating: 1200, CurrentRating: 1600, NumGames: 50},
+				},
+			},
+			wantChanged: true,
+		},
+		{
+			name:    "MissingTimeControl",
+			system:  Chesscom,
+			rating:  &Rating{Username: "a", TimeControl: TimeControl_Daily},
+			fetched: fetched,
+			want: &Rating{
+				Username:    "a",
+				TimeControl: TimeControl_Daily,
+				TimeControls: map[TimeControl]*TimeControlRating{
+					TimeControl_Blitz: {StartRating: 1400, CurrentRating: 1400, NumGames: 200},
+					TimeControl_Rapid: {StartRating: 1600, CurrentRating: 1600, NumGames: 50},
+				},
+			},
+			wantChanged: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := tc.rating.Update(tc.system, tc.fetched)
+			if changed != tc.wantChanged {
+				t.Errorf("Update got changed %v; want %v", changed, tc.wantChanged)
+			}
+			if diff := cmp.Diff(tc.want, tc.rating); diff != "" {
+				t.Errorf("Update mismatch (-want +got):\n%s", diff)
+			}
+			if tc.rating.Update(tc.system, tc.fetched) {
+				t.Errorf("Update with the same rating got changed true; want false")
+			}
+		})
+	}
+}
+
+func TestRatingHistorySameRatings(t *testing.T) {
+	rating := &Rating{
+		CurrentRating: 1600,
+		TimeControls: map[TimeControl]*TimeControlRating{
+			TimeControl_Blitz: {CurrentRating: 1400},
+			TimeControl_Daily: {CurrentRating: 0},
+		},
+	}
+	history := rating.History("2024-01-01")
+	if len(history.TimeControls) != 1 || history.TimeControls[TimeControl_Blitz] != 1400 {
+		t.Errorf("History got time controls %v; want only blitz", history.TimeControls)
+	}
+	if !history.SameRatings(rating.History("2024-01-08")) {
+		t.Errorf("SameRatings with only a new date got false; want true")
+	}
+
+	rating.TimeControls[TimeControl_Blitz].CurrentRating = 1410
+	if history.SameRatings(rating.History("2024-01-08")) {
+		t.Errorf("SameRatings with a new blitz rating got true; want false")
+	}
+}
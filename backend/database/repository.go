@@ -1,28 +1,47 @@
 package database
 
 import (
-	"encoding/json"
-	"fmt"
 	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/cursor"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
 )
 
 // dynamoRepository implements a database using AWS DynamoDB.
 type dynamoRepository struct {
-	svc *dynamodb.DynamoDB
+	svc dynamodbiface.DynamoDBAPI
+
+	// The policy used to retry unprocessed items in batch requests. If nil,
+	// DefaultBatchRetryPolicy is used.
+	batchRetry *BatchRetryPolicy
+
+	// Waits between retries of batch requests. If nil, time.Sleep is used.
+	sleep func(time.Duration)
 }
 
 var sess = session.Must(session.NewSession())
 
 // DynamoDB implements a database using AWS DynamoDB.
 var DynamoDB = &dynamoRepository{
-	svc: dynamodb.New(sess),
+	svc: dynamodb.New(sess, dynamoConfig()),
+}
+
+// dynamoConfig returns the DynamoDB client config. If the dynamodbEndpoint
+// environment variable is set, requests are sent to that endpoint instead of AWS.
+// This is used by the local dev server.
+func dynamoConfig() *aws.Config {
+	config := aws.NewConfig()
+	if endpoint := os.Getenv("dynamodbEndpoint"); endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+	return config
 }
 
 var stage = os.Getenv("stage")
@@ -40,6 +59,12 @@ var newsfeedTable = stage + "-newsfeed"
 var yearReviewTable = stage + "-yearReviews"
 var clubTable = stage + "-clubs"
 var examsTable = stage + "-exams"
+var idempotencyTable = stage + "-idempotency"
+var auditTable = stage + "-audit"
+var ratingHistoryTable = stage + "-rating-history"
+var cohortBoundariesTable = stage + "-cohort-boundaries"
+var ratingAnomalyTable = stage + "-rating-anomalies"
+var cohortChangeTable = stage + "-cohort-changes"
 
 const gameTableOwnerIndex = "OwnerIdx"
 const gameTableWhiteIndex = "WhiteIndex"
@@ -68,19 +93,22 @@ func (repo *dynamoRepository) getItem(input *dynamodb.GetItemInput, out interfac
 	return errors.Wrap(500, "Temporary server error", "Failed to unmarshal DynamoDB GetItem result", err)
 }
 
+// cursorScope returns the scope of the pagination cursors for the given table and index.
+func cursorScope(table, index *string) string {
+	return aws.StringValue(table) + "/" + aws.StringValue(index)
+}
+
 // query handles sending a DynamoDB Query request and unmarshals the result into the provided output value,
-// which must be a non-nil pointer to a slice. startKey is an optional parameter that can be used to perform
-// pagination. The next startKey is returned. If startKey cannot be unmarshalled, a 400 error is returned.
-// All other errors result in a 500.
+// which must be a non-nil pointer to a slice. startKey is an optional cursor that can be used to perform
+// pagination. The next cursor is returned. If startKey is not a valid cursor for the table and index
+// of the query, a 400 error is returned. All other errors result in a 500.
 func (repo *dynamoRepository) query(input *dynamodb.QueryInput, startKey string, out interface{}) (string, error) {
-	if startKey != "" {
-		var exclusiveStartKey map[string]*dynamodb.AttributeValue
-		err := json.Unmarshal([]byte(startKey), &exclusiveStartKey)
-		if err != nil {
-			return "", errors.Wrap(400, "Invalid request: startKey is not valid", "startKey could not be unmarshaled from json", err)
-		}
-		input.SetExclusiveStartKey(exclusiveStartKey)
+	scope := cursorScope(input.TableName, input.IndexName)
+	exclusiveStartKey, err := cursor.DecodeKey(scope, startKey)
+	if err != nil {
+		return "", err
 	}
+	input.ExclusiveStartKey = exclusiveStartKey
 
 	result, err := repo.svc.Query(input)
 	if err != nil {
@@ -91,30 +119,20 @@ func (repo *dynamoRepository) query(input *dynamodb.QueryInput, startKey string,
 		return "", errors.Wrap(500, "Temporary server error", "Failed to unmarshal Query result", err)
 	}
 
-	var lastKey string
-	if len(result.LastEvaluatedKey) > 0 {
-		b, err := json.Marshal(result.LastEvaluatedKey)
-		if err != nil {
-			return "", errors.Wrap(500, "Temporary server error", "Failed to marshal Query LastEvaluatedKey", err)
-		}
-		lastKey = string(b)
-	}
-	return lastKey, nil
+	return cursor.EncodeKey(scope, result.LastEvaluatedKey)
 }
 
 // scan handles sending a DynamoDB Scan request and unmarshals the result into the provided output value, which
-// must be a non-nil pointer to a slice. startKey is an optional parameter that can be used to perform pagination.
-// The next startKey is returned. If startKey cannot be unmarshalled, a 400 error is returned. All other errors
-// result in a 500.
+// must be a non-nil pointer to a slice. startKey is an optional cursor that can be used to perform pagination.
+// The next cursor is returned. If startKey is not a valid cursor for the table and index of the scan, a 400
+// error is returned. All other errors result in a 500.
 func (repo *dynamoRepository) scan(input *dynamodb.ScanInput, startKey string, out interface{}) (string, error) {
-	if startKey != "" {
-		var exclusiveStartKey map[string]*dynamodb.AttributeValue
-		err := json.Unmarshal([]byte(startKey), &exclusiveStartKey)
-		if err != nil {
-			return "", errors.Wrap(400, "Invalid request: startKey is not valid", "startKey could not be unmarshaled from json", err)
-		}
-		input.SetExclusiveStartKey(exclusiveStartKey)
+	scope := cursorScope(input.TableName, input.IndexName)
+	exclusiveStartKey, err := cursor.DecodeKey(scope, startKey)
+	if err != nil {
+		return "", err
 	}
+	input.ExclusiveStartKey = exclusiveStartKey
 
 	result, err := repo.svc.Scan(input)
 	if err != nil {
@@ -125,23 +143,33 @@ func (repo *dynamoRepository) scan(input *dynamodb.ScanInput, startKey string, o
 		return "", errors.Wrap(500, "Temporary server error", "Failed to unmarshal Scan result", err)
 	}
 
-	var lastKey string
-	if len(result.LastEvaluatedKey) > 0 {
-		b, err := json.Marshal(result.LastEvaluatedKey)
-		if err != nil {
-			return "", errors.Wrap(500, "Temporary server error", "Failed to marshal Scan LastEvaluatedKey", err)
-		}
-		lastKey = string(b)
-	}
-	return lastKey, nil
+	return cursor.EncodeKey(scope, result.LastEvaluatedKey)
 }
 
 // batchWriteObjects inserts the provided objects into the provided table. The number of successfully inserted
-// objects is returned.
+// objects is returned. All batches are attempted even if some items in an earlier batch are not processed. In that
+// case, the returned error's cause is a *BatchError listing every item that was not inserted.
 func batchWriteObjects[T any](repo *dynamoRepository, objects []T, tableName string, opts ...func(object T, item map[string]*dynamodb.AttributeValue)) (int, error) {
 	var putRequests []*dynamodb.WriteRequest
+	var failures []BatchItemFailure
 	updated := 0
 
+	write := func() error {
+		err := repo.batchWrite(putRequests, tableName)
+		if err == nil {
+			updated += len(putRequests)
+			return nil
+		}
+
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) {
+			return err
+		}
+		updated += batchErr.Processed
+		failures = append(failures, batchErr.Failures...)
+		return nil
+	}
+
 	for _, e := range objects {
 		item, err := dynamodbattribute.MarshalMap(e)
 		if err != nil {
@@ -160,42 +188,20 @@ func batchWriteObjects[T any](repo *dynamoRepository, objects []T, tableName str
 		putRequests = append(putRequests, req)
 
 		if len(putRequests) == 25 {
-			if err := repo.batchWrite(putRequests, tableName); err != nil {
+			if err := write(); err != nil {
 				return updated, err
 			}
-			updated += 25
 			putRequests = nil
 		}
 	}
 
 	if len(putRequests) > 0 {
-		if err := repo.batchWrite(putRequests, tableName); err != nil {
+		if err := write(); err != nil {
 			return updated, err
 		}
-		updated += len(putRequests)
 	}
 
-	return updated, nil
-}
-
-// batchWrite handles sending a DynamoDB BatchWriteItem request using the provided slice of WriteRequests.
-// The WriteRequests are mapped to the provided table name.
-func (repo *dynamoRepository) batchWrite(reqs []*dynamodb.WriteRequest, tableName string) error {
-	input := &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]*dynamodb.WriteRequest{
-			tableName: reqs,
-		},
-		ReturnConsumedCapacity: aws.String("NONE"),
-	}
-
-	output, err := repo.svc.BatchWriteItem(input)
-	if err != nil {
-		return errors.Wrap(500, "Temporary server error", "Failed DynamoDB BatchWriteItem", err)
-	}
-	if len(output.UnprocessedItems) > 0 {
-		return errors.New(500, "Temporary server error", fmt.Sprintf("DynamoDB BatchWriteItem failed to process (%+v): %+v", reqs, output))
-	}
-	return nil
+	return updated, batchFailure("BatchWriteItem", updated, failures)
 }
 
 // handles sending a DynamoDB UpdateItem request using the provided input. The result is unmarshaled
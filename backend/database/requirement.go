@@ -69,6 +69,11 @@ type CustomTask struct {
 	// ALL_COHORTS is *not* a valid value.
 	Counts map[DojoCohort]int `dynamodbav:"counts" json:"counts"`
 
+	// The counts carried to a lower cohort when the user was demoted, by cohort. They
+	// are kept apart from Counts, so that they are not mistaken for progress in several
+	// cohorts, and count towards the score as if the user had reached them in the cohort.
+	CarriedCounts map[DojoCohort]int `dynamodbav:"carriedCounts,omitempty" json:"carriedCounts,omitempty"`
+
 	// Must be NonDojo
 	ScoreboardDisplay ScoreboardDisplay `dynamodbav:"scoreboardDisplay" json:"scoreboardDisplay"`
 
@@ -167,6 +172,11 @@ type Requirement struct {
 	// ALL_COHORTS is *not* a valid value.
 	Counts map[DojoCohort]int `dynamodbav:"counts" json:"counts"`
 
+	// The counts carried to a lower cohort when the user was demoted, by cohort. They
+	// are kept apart from Counts, so that they are not mistaken for progress in several
+	// cohorts, and count towards the score as if the user had reached them in the cohort.
+	CarriedCounts map[DojoCohort]int `dynamodbav:"carriedCounts,omitempty" json:"carriedCounts,omitempty"`
+
 	// The minimum starting value, applied to all cohorts. For example, the M2s start at 307
 	StartCount int `dynamodbav:"startCount" json:"startCount"`
 
@@ -250,6 +260,9 @@ func (r *Requirement) CalculateScore(cohort DojoCohort, progress *RequirementPro
 	} else {
 		count = progress.Counts[cohort]
 	}
+	if r.NumberOfCohorts > 1 {
+		count = max(count, progress.CarriedCounts[cohort])
+	}
 
 	if r.TotalScore > 0 {
 		if count >= r.Counts[cohort] {
@@ -321,6 +334,11 @@ type RequirementProgress struct {
 	// ALL_COHORTS *is* a valid value.
 	Counts map[DojoCohort]int `dynamodbav:"counts" json:"counts"`
 
+	// The counts carried to a lower cohort when the user was demoted, by cohort. They
+	// are kept apart from Counts, so that they are not mistaken for progress in several
+	// cohorts, and count towards the score as if the user had reached them in the cohort.
+	CarriedCounts map[DojoCohort]int `dynamodbav:"carriedCounts,omitempty" json:"carriedCounts,omitempty"`
+
 	// The number of minutes spent working on the requirement, by cohort.
 	// ALL_COHORTS is *not* a valid value.
 	MinutesSpent map[DojoCohort]int `dynamodbav:"minutesSpent" json:"minutesSpent"`
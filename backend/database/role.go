@@ -0,0 +1,389 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+// Permission is a named action that a user can be authorized to perform, such as
+// writing the pairings of a tournament.
+type Permission string
+
+const (
+	// Grants every permission. Only the admin role has it.
+	PermissionAll Permission = "*"
+
+	// Allows assigning and revoking roles. Scoped assignments of the permission only
+	// allow assigning and revoking roles with the same scope.
+	PermissionRolesWrite Permission = "roles.write"
+
+	// Allows creating, updating and deleting Dojo and Liga events on the calendar.
+	PermissionCalendarEventsWrite Permission = "calendar.events.write"
+
+	// Allows creating coaching events on the calendar.
+	PermissionCalendarCoachingWrite Permission = "calendar.coaching.write"
+
+	// Allows creating and updating courses.
+	PermissionCoursesWrite Permission = "courses.write"
+
+	// Allows creating a Stripe account to receive payments as a coach.
+	PermissionPaymentAccountWrite Permission = "payment.account.write"
+
+	// Allows marking games as reviewed.
+	PermissionGameReviewComplete Permission = "game.review.complete"
+
+	// Allows reading the registrations for a tournament.
+	PermissionTournamentRegistrationsRead Permission = "tournament.registrations.read"
+
+	// Allows setting the pairings and closing registrations for a tournament.
+	PermissionTournamentPairingsWrite Permission = "tournament.pairings.write"
+
+	// Allows emailing the pairings of a tournament to the players.
+	PermissionTournamentPairingsEmail Permission = "tournament.pairings.email"
+
+	// Allows verifying the results of tournament games.
+	PermissionTournamentResultsWrite Permission = "tournament.results.write"
+
+	// Allows banning, unbanning and withdrawing tournament players.
+	PermissionTournamentPlayersWrite Permission = "tournament.players.write"
+
+	// Allows completing a tournament.
+	PermissionTournamentComplete Permission = "tournament.complete"
+
+	// Allows updating a club's details.
+	PermissionClubWrite Permission = "club.write"
+
+	// Allows approving and rejecting requests to join a club.
+	PermissionClubJoinRequestsWrite Permission = "club.joinRequests.write"
+
+	// Allows reading the audit log of administrative actions.
+	PermissionAuditRead Permission = "audit.read"
+
+	// Allows proposing, previewing and publishing versions of the cohort boundaries.
+	PermissionCohortBoundariesWrite Permission = "cohortBoundaries.write"
+
+	// Allows reviewing flagged rating anomalies and terminating the subscriptions of
+	// the flagged users.
+	PermissionRatingAnomaliesReview Permission = "ratingAnomalies.review"
+
+	// Allows configuring the nightly graduation eligibility check, including whether
+	// eligible users are graduated automatically.
+	PermissionGraduationSettingsWrite Permission = "graduationSettings.write"
+
+	// Allows access to beta features.
+	PermissionBetaAccess Permission = "beta.access"
+)
+
+// Role is a named set of permissions which can be assigned to a user.
+type Role string
+
+const (
+	RoleAdmin           Role = "admin"
+	RoleCalendarAdmin   Role = "calendarAdmin"
+	RoleTournamentAdmin Role = "tournamentAdmin"
+	RoleCoach           Role = "coach"
+	RoleBetaTester      Role = "betaTester"
+	RoleGameReviewer    Role = "gameReviewer"
+	RoleClubAdmin       Role = "clubAdmin"
+)
+
+// ScopeType is the type of resource that a role assignment can be limited to.
+type ScopeType string
+
+const (
+	// The role applies to every resource.
+	ScopeGlobal ScopeType = ""
+
+	// The role applies only to a single club. Scopes are formatted as club:clubId.
+	ScopeClub ScopeType = "club"
+
+	// The role applies only to a single cohort. Scopes are formatted as cohort:cohort.
+	ScopeCohort ScopeType = "cohort"
+)
+
+// roleDefinition describes the permissions granted by a role and the scopes it can be
+// assigned with.
+type roleDefinition struct {
+	permissions []Permission
+	scopeTypes  []ScopeType
+}
+
+var roleDefinitions = map[Role]roleDefinition{
+	RoleAdmin: {
+		permissions: []Permission{PermissionAll},
+		scopeTypes:  []ScopeType{ScopeGlobal},
+	},
+	RoleCalendarAdmin: {
+		permissions: []Permission{PermissionCalendarEventsWrite},
+		scopeTypes:  []ScopeType{ScopeGlobal},
+	},
+	RoleTournamentAdmin: {
+		permissions: []Permission{
+			PermissionTournamentRegistrationsRead,
+			PermissionTournamentPairingsWrite,
+			PermissionTournamentPairingsEmail,
+			PermissionTournamentResultsWrite,
+			PermissionTournamentPlayersWrite,
+			PermissionTournamentComplete,
+		},
+		scopeTypes: []ScopeType{ScopeGlobal},
+	},
+	RoleCoach: {
+		permissions: []Permission{
+			PermissionCalendarCoachingWrite,
+			PermissionCoursesWrite,
+			PermissionPaymentAccountWrite,
+		},
+		scopeTypes: []ScopeType{ScopeGlobal},
+	},
+	RoleBetaTester: {
+		permissions: []Permission{PermissionBetaAccess},
+		scopeTypes:  []ScopeType{ScopeGlobal},
+	},
+	RoleGameReviewer: {
+		permissions: []Permission{PermissionGameReviewComplete},
+		scopeTypes:  []ScopeType{ScopeGlobal, ScopeCohort},
+	},
+	RoleClubAdmin: {
+		permissions: []Permission{PermissionClubWrite, PermissionClubJoinRequestsWrite},
+		scopeTypes:  []ScopeType{ScopeClub},
+	},
+}
+
+// ClubScope returns the scope of the club with the provided id.
+func ClubScope(id string) string {
+	return fmt.Sprintf("%s:%s", ScopeClub, id)
+}
+
+// CohortScope returns the scope of the provided cohort.
+func CohortScope(cohort DojoCohort) string {
+	return fmt.Sprintf("%s:%s", ScopeCohort, cohort)
+}
+
+// scopeType returns the type of the provided scope.
+func scopeType(scope string) ScopeType {
+	if scope == "" {
+		return ScopeGlobal
+	}
+	t, _, _ := strings.Cut(scope, ":")
+	return ScopeType(t)
+}
+
+// RoleAssignment grants a role to a user.
+type RoleAssignment struct {
+	// The assigned role.
+	Role Role `dynamodbav:"role" json:"role"`
+
+	// The resource the role is limited to, such as club:clubId. If empty, the role
+	// applies to every resource.
+	Scope string `dynamodbav:"scope,omitempty" json:"scope,omitempty"`
+
+	// The username of the user who assigned the role. Empty for roles converted from
+	// the legacy admin booleans.
+	AssignedBy string `dynamodbav:"assignedBy,omitempty" json:"assignedBy,omitempty"`
+
+	// The time the role was assigned, in time.RFC3339 format.
+	AssignedAt string `dynamodbav:"assignedAt,omitempty" json:"assignedAt,omitempty"`
+}
+
+// Validate returns an error if the assignment's role does not exist or cannot be
+// assigned with the assignment's scope.
+func (ra RoleAssignment) Validate() error {
+	def, ok := roleDefinitions[ra.Role]
+	if !ok {
+		return errors.New(400, fmt.Sprintf("Invalid request: role `%s` does not exist", ra.Role), "")
+	}
+
+	t := scopeType(ra.Scope)
+	for _, st := range def.scopeTypes {
+		if st == t {
+			if t != ScopeGlobal && strings.TrimPrefix(ra.Scope, string(t)+":") == "" {
+				return errors.New(400, fmt.Sprintf("Invalid request: scope `%s` is missing an id", ra.Scope), "")
+			}
+			return nil
+		}
+	}
+	return errors.New(400, fmt.Sprintf("Invalid request: role `%s` cannot be assigned with scope `%s`", ra.Role, ra.Scope), "")
+}
+
+// grants returns true if the assignment grants the provided permission on the provided resource.
+func (ra RoleAssignment) grants(permission Permission, resource string) bool {
+	if ra.Scope != "" && ra.Scope != resource {
+		return false
+	}
+	for _, p := range roleDefinitions[ra.Role].permissions {
+		if p == PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type RoleChangeAction string
+
+const (
+	RoleChangeAction_Assign RoleChangeAction = "ASSIGN"
+	RoleChangeAction_Revoke RoleChangeAction = "REVOKE"
+)
+
+// RoleChange records a single assignment or revocation of a user's role.
+type RoleChange struct {
+	// Whether the role was assigned or revoked.
+	Action RoleChangeAction `dynamodbav:"action" json:"action"`
+
+	// The role that was assigned or revoked.
+	Role Role `dynamodbav:"role" json:"role"`
+
+	// The scope of the role that was assigned or revoked.
+	Scope string `dynamodbav:"scope,omitempty" json:"scope,omitempty"`
+
+	// The username of the user who made the change.
+	ChangedBy string `dynamodbav:"changedBy" json:"changedBy"`
+
+	// The time the change was made, in time.RFC3339 format.
+	ChangedAt string `dynamodbav:"changedAt" json:"changedAt"`
+}
+
+// GetRoles returns the user's role assignments. Users whose roles have never been edited
+// have their roles derived from the legacy admin booleans.
+func (u *User) GetRoles() []RoleAssignment {
+	if u == nil {
+		return nil
+	}
+	if u.Roles != nil {
+		return u.Roles
+	}
+
+	var roles []RoleAssignment
+	legacy := []struct {
+		value bool
+		role  Role
+	}{
+		{u.IsAdmin, RoleAdmin},
+		{u.IsCalendarAdmin, RoleCalendarAdmin},
+		{u.IsTournamentAdmin, RoleTournamentAdmin},
+		{u.IsCoach, RoleCoach},
+		{u.IsBetaTester, RoleBetaTester},
+	}
+	for _, l := range legacy {
+		if l.value {
+			roles = append(roles, RoleAssignment{Role: l.role})
+		}
+	}
+	return roles
+}
+
+// HasPermission returns true if one of the user's roles grants the provided permission on the
+// provided resource. Resource should be the scope of the resource being accessed, such as the
+// value of ClubScope, or empty if the permission is not tied to a resource.
+func (u *User) HasPermission(permission Permission, resource string) bool {
+	for _, ra := range u.GetRoles() {
+		if ra.grants(permission, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobalRole returns true if the provided roles contain an unscoped assignment of role.
+func hasGlobalRole(roles []RoleAssignment, role Role) bool {
+	for _, ra := range roles {
+		if ra.Role == role && ra.Scope == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// newRoleUpdate returns a UserUpdate which saves the provided roles and role history. The legacy
+// admin booleans are set to match the roles, so that clients which read them are unaffected.
+func newRoleUpdate(roles []RoleAssignment, history []RoleChange) *UserUpdate {
+	if roles == nil {
+		roles = []RoleAssignment{}
+	}
+	return &UserUpdate{
+		Roles:             &roles,
+		RoleHistory:       &history,
+		IsAdmin:           aws.Bool(hasGlobalRole(roles, RoleAdmin)),
+		IsCalendarAdmin:   aws.Bool(hasGlobalRole(roles, RoleCalendarAdmin)),
+		IsTournamentAdmin: aws.Bool(hasGlobalRole(roles, RoleTournamentAdmin)),
+		IsCoach:           aws.Bool(hasGlobalRole(roles, RoleCoach)),
+		IsBetaTester:      aws.Bool(hasGlobalRole(roles, RoleBetaTester)),
+	}
+}
+
+type UserRoleEditor interface {
+	UserGetter
+
+	// AssignUserRole assigns the provided role to the user with the provided username. The
+	// change is recorded in the user's role history.
+	AssignUserRole(username string, assignment RoleAssignment) (*User, error)
+
+	// RevokeUserRole revokes the provided role and scope from the user with the provided
+	// username. The change is recorded in the user's role history.
+	RevokeUserRole(username string, role Role, scope, caller string) (*User, error)
+}
+
+// AssignUserRole assigns the provided role to the user with the provided username. The
+// change is recorded in the user's role history. A 409 error is returned if the user
+// already has the role with the same scope.
+func (repo *dynamoRepository) AssignUserRole(username string, assignment RoleAssignment) (*User, error) {
+	if err := assignment.Validate(); err != nil {
+		return nil, err
+	}
+	if assignment.AssignedAt == "" {
+		assignment.AssignedAt = time.Now().Format(time.RFC3339)
+	}
+
+	return repo.ModifyUser(username, func(user *User) (*UserUpdate, error) {
+		roles := user.GetRoles()
+		for _, ra := range roles {
+			if ra.Role == assignment.Role && ra.Scope == assignment.Scope {
+				return nil, errors.New(409, "Invalid request: user already has this role", "", errors.WithReason(errors.ReasonRoleAlreadyAssigned))
+			}
+		}
+
+		roles = append(append([]RoleAssignment{}, roles...), assignment)
+		history := append(user.RoleHistory, RoleChange{
+			Action:    RoleChangeAction_Assign,
+			Role:      assignment.Role,
+			Scope:     assignment.Scope,
+			ChangedBy: assignment.AssignedBy,
+			ChangedAt: assignment.AssignedAt,
+		})
+		return newRoleUpdate(roles, history), nil
+	})
+}
+
+// RevokeUserRole revokes the provided role and scope from the user with the provided
+// username. The change is recorded in the user's role history. A 404 error is returned
+// if the user does not have the role with the provided scope.
+func (repo *dynamoRepository) RevokeUserRole(username string, role Role, scope, caller string) (*User, error) {
+	return repo.ModifyUser(username, func(user *User) (*UserUpdate, error) {
+		var roles []RoleAssignment
+		found := false
+		for _, ra := range user.GetRoles() {
+			if ra.Role == role && ra.Scope == scope {
+				found = true
+			} else {
+				roles = append(roles, ra)
+			}
+		}
+		if !found {
+			return nil, errors.New(404, "Invalid request: user does not have this role", "", errors.WithReason(errors.ReasonRoleNotAssigned))
+		}
+
+		history := append(user.RoleHistory, RoleChange{
+			Action:    RoleChangeAction_Revoke,
+			Role:      role,
+			Scope:     scope,
+			ChangedBy: caller,
+			ChangedAt: time.Now().Format(time.RFC3339),
+		})
+		return newRoleUpdate(roles, history), nil
+	})
+}
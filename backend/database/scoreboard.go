@@ -30,6 +30,9 @@ type ScoreboardSummary struct {
 	// The user's Dojo cohort.
 	DojoCohort DojoCohort `dynamodbav:"dojoCohort" json:"dojoCohort"`
 
+	// Whether the user's cohort was picked from a provisional rating.
+	ProvisionalCohort bool `dynamodbav:"provisionalCohort,omitempty" json:"provisionalCohort"`
+
 	// The user's total dojo score, across all cohorts.
 	TotalDojoScore float32 `dynamodbav:"totalDojoScore" json:"totalDojoScore"`
 
@@ -86,7 +89,7 @@ func (repo *dynamoRepository) ListScoreboardSummaries(startKey string) ([]Scoreb
 	return summaries, lastKey, nil
 }
 
-const scoreboardSummaryProjection = "username, displayName, graduationCohorts, ratingSystem, ratings, dojoCohort, totalDojoScore, minutesSpent"
+const scoreboardSummaryProjection = "username, displayName, graduationCohorts, ratingSystem, ratings, dojoCohort, provisionalCohort, totalDojoScore, minutesSpent"
 
 // GetScoreboardSummaries returns a list of ScoreboardSummaries matching the provided usernames.
 // Up to 100 usernames can be specified at a time.
@@ -114,11 +117,10 @@ func (repo *dynamoRepository) GetScoreboardSummaries(usernames []string) ([]Scor
 		input.RequestItems[userTable].Keys = append(input.RequestItems[userTable].Keys, key)
 	}
 
-	result, err := repo.svc.BatchGetItem(input)
+	list, err := repo.batchGet(userTable, input.RequestItems[userTable])
 	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed call to BatchGetItem", err)
+		return nil, err
 	}
-	list := result.Responses[userTable]
 
 	var summaries []ScoreboardSummary
 	if err := dynamodbattribute.UnmarshalListOfMaps(list, &summaries); err != nil {
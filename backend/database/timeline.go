@@ -106,6 +106,12 @@ type TimelineGraduationInfo struct {
 
 	// The amount of time spent in minutes on non-dojo tasks in the cohort
 	NonDojoMinutes int `dynamodbav:"nonDojoMinutes" json:"nonDojoMinutes"`
+
+	// The key of the graduation's PNG certificate in the pictures bucket
+	CertificatePngKey string `dynamodbav:"certificatePngKey,omitempty" json:"certificatePngKey,omitempty"`
+
+	// The key of the graduation's PDF certificate in the pictures bucket
+	CertificatePdfKey string `dynamodbav:"certificatePdfKey,omitempty" json:"certificatePdfKey,omitempty"`
 }
 
 // The info on game submission that is copied into the timeline entry
@@ -319,11 +325,10 @@ func (repo *dynamoRepository) BatchGetTimelineEntries(entries map[string]Timelin
 		input.RequestItems[timelineTable].Keys = append(input.RequestItems[timelineTable].Keys, key)
 	}
 
-	result, err := repo.svc.BatchGetItem(input)
+	list, err := repo.batchGet(timelineTable, input.RequestItems[timelineTable])
 	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed call to BatchGetItem", err)
+		return nil, err
 	}
-	list := result.Responses[timelineTable]
 
 	var resultEntries []TimelineEntry
 	if err := dynamodbattribute.UnmarshalListOfMaps(list, &resultEntries); err != nil {
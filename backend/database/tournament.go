@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -171,6 +172,28 @@ type OpenClassical struct {
 	RegistrationClose string `dynamodbav:"registrationClose,omitempty" json:"registrationClose"`
 }
 
+// AuditSummary returns the top-level fields of the open classical, excluding its players
+// and rounds, for saving in the audit log.
+func (oc *OpenClassical) AuditSummary() map[string]interface{} {
+	if oc == nil {
+		return nil
+	}
+	sections := make([]string, 0, len(oc.Sections))
+	for name := range oc.Sections {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+
+	return map[string]interface{}{
+		"startsAt":               oc.StartsAt,
+		"name":                   oc.Name,
+		"acceptingRegistrations": oc.AcceptingRegistrations,
+		"startMonth":             oc.StartMonth,
+		"registrationClose":      oc.RegistrationClose,
+		"sections":               sections,
+	}
+}
+
 // A section in the Open Classical tournament. Generally consists of both a region and a rating range.
 type OpenClassicalSection struct {
 	// The name of the section.
@@ -373,7 +396,7 @@ func (repo *dynamoRepository) UpdateOpenClassicalRegistration(openClassical *Ope
 	result, err := repo.svc.UpdateItem(input)
 	if err != nil {
 		if aerr, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(400, "Registration for this tournament has already closed", "DynamoDB conditional check failed", aerr)
+			return nil, errors.Wrap(400, "Registration for this tournament has already closed", "DynamoDB conditional check failed", aerr, errors.WithReason(errors.ReasonTournamentRegistrationClosed))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem", err)
 	}
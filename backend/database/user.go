@@ -100,6 +100,12 @@ const (
 	Dwz      RatingSystem = "DWZ"
 	Acf      RatingSystem = "ACF"
 	Knsb     RatingSystem = "KNSB"
+	Ffe      RatingSystem = "FFE"
+	Feda     RatingSystem = "FEDA"
+	Fsi      RatingSystem = "FSI"
+	Aicf     RatingSystem = "AICF"
+	Cbx      RatingSystem = "CBX"
+	Nzcf     RatingSystem = "NZCF"
 	Custom   RatingSystem = "CUSTOM"
 	Custom2  RatingSystem = "CUSTOM_2"
 	Custom3  RatingSystem = "CUSTOM_3"
@@ -115,6 +121,12 @@ var ratingSystems = []RatingSystem{
 	Dwz,
 	Acf,
 	Knsb,
+	Ffe,
+	Feda,
+	Fsi,
+	Aicf,
+	Cbx,
+	Nzcf,
 	Custom,
 	Custom2,
 	Custom3,
@@ -127,6 +139,10 @@ type Rating struct {
 	// Whether to hide the username/id from other users
 	HideUsername bool `dynamodbav:"hideUsername" json:"hideUsername"`
 
+	// Whether the user proved that they own the account with this username. Only set
+	// through account verification, and cleared when the username changes.
+	Verified bool `dynamodbav:"verified,omitempty" json:"verified,omitempty"`
+
 	// The user's rating at the time they joined the Dojo
 	StartRating int `dynamodbav:"startRating" json:"startRating"`
 
@@ -141,6 +157,15 @@ type Rating struct {
 
 	// The name of the rating system. Only present if this is a custom rating.
 	Name string `dynamodbav:"name,omitempty" json:"name,omitempty"`
+
+	// The time control whose rating is used as the StartRating, CurrentRating, Deviation
+	// and NumGames of this rating, and therefore for cohort placement. If empty, the rating
+	// system's default time control is used.
+	TimeControl TimeControl `dynamodbav:"timeControl,omitempty" json:"timeControl,omitempty"`
+
+	// The user's ratings in each time control of the rating system, if the rating system
+	// supports more than one time control.
+	TimeControls map[TimeControl]*TimeControlRating `dynamodbav:"timeControls,omitempty" json:"timeControls,omitempty"`
 }
 
 type RatingHistory struct {
@@ -149,6 +174,9 @@ type RatingHistory struct {
 
 	// The rating the user had at the given date.
 	Rating int `dynamodbav:"rating" json:"rating"`
+
+	// The ratings the user had at the given date in each time control of the rating system.
+	TimeControls map[TimeControl]int `dynamodbav:"timeControls,omitempty" json:"timeControls,omitempty"`
 }
 
 type User struct {
@@ -193,31 +221,47 @@ type User struct {
 	// The user's ratings in each rating system
 	Ratings map[RatingSystem]*Rating `dynamodbav:"ratings" json:"ratings"`
 
+	// The time the user's ratings were last refreshed by the nightly rating update,
+	// in time.RFC3339 format. Users with the oldest ratings are refreshed first.
+	RatingsUpdatedAt string `dynamodbav:"ratingsUpdatedAt,omitempty" json:"-"`
+
 	// A map from a rating system to a slice of RatingHistory objects for that rating system.
 	RatingHistories map[RatingSystem][]RatingHistory `dynamodbav:"ratingHistories" json:"ratingHistories"`
 
 	// The user's Dojo cohort
 	DojoCohort DojoCohort `dynamodbav:"dojoCohort" json:"dojoCohort"`
 
+	// Whether the user's cohort was picked from a provisional rating. Provisional cohorts
+	// are re-evaluated by the nightly rating update once the rating is no longer provisional.
+	ProvisionalCohort bool `dynamodbav:"provisionalCohort,omitempty" json:"provisionalCohort"`
+
 	// Maps requirement ids to RequirementProgress objects
 	Progress map[string]*RequirementProgress `dynamodbav:"progress" json:"progress"`
 
 	// The number of games the user has created
 	GamesCreated map[DojoCohort]int `dynamodbav:"gamesCreated" json:"gamesCreated"`
 
-	// Whether the user is an admin or not
+	// The roles assigned to the user. If nil, the user's roles are derived from the legacy
+	// admin booleans below. Use GetRoles and HasPermission instead of reading this directly.
+	Roles []RoleAssignment `dynamodbav:"roles,omitempty" json:"roles,omitempty"`
+
+	// The history of assignments and revocations of the user's roles
+	RoleHistory []RoleChange `dynamodbav:"roleHistory,omitempty" json:"-"`
+
+	// Whether the user is an admin or not. Deprecated: kept in sync with Roles for
+	// clients that have not migrated. Use HasPermission instead.
 	IsAdmin bool `dynamodbav:"isAdmin" json:"isAdmin"`
 
-	// Whether the user has admin privileges for the calendar
+	// Whether the user has admin privileges for the calendar. Deprecated: use HasPermission.
 	IsCalendarAdmin bool `dynamodbav:"isCalendarAdmin" json:"isCalendarAdmin"`
 
-	// Whether the user has admin privileges for tournaments
+	// Whether the user has admin privileges for tournaments. Deprecated: use HasPermission.
 	IsTournamentAdmin bool `dynamodbav:"isTournamentAdmin" json:"isTournamentAdmin"`
 
-	// Whether the user is a beta tester or not
+	// Whether the user is a beta tester or not. Deprecated: use HasPermission.
 	IsBetaTester bool `dynamodbav:"isBetaTester" json:"isBetaTester"`
 
-	// Whether the user is a coach or not
+	// Whether the user is a coach or not. Deprecated: use HasPermission.
 	IsCoach bool `dynamodbav:"isCoach" json:"isCoach"`
 
 	// When the user first created their account
@@ -235,6 +279,24 @@ type User struct {
 	// When the user most recently graduated
 	LastGraduatedAt string `dynamodbav:"lastGraduatedAt" json:"lastGraduatedAt"`
 
+	// When the user's rating in their preferred rating system first reached the next
+	// cohort, in time.RFC3339 format. Cleared when the rating drops below the next cohort
+	// or the user graduates.
+	GraduationEligibleSince string `dynamodbav:"graduationEligibleSince,omitempty" json:"graduationEligibleSince,omitempty"`
+
+	// The cohort the user was most recently notified they can graduate to, so that they
+	// are notified only once per cohort.
+	GraduationNotifiedCohort DojoCohort `dynamodbav:"graduationNotifiedCohort,omitempty" json:"-"`
+
+	// When the user's rating in their preferred rating system first dropped further below
+	// their cohort than the demotion margin, in time.RFC3339 format. Cleared when the rating
+	// recovers or the user changes cohorts.
+	CohortDropSince string `dynamodbav:"cohortDropSince,omitempty" json:"cohortDropSince,omitempty"`
+
+	// The lower cohort suggested to the user after a sustained rating drop. The user can
+	// accept the suggestion through the user/demote function.
+	SuggestedCohort DojoCohort `dynamodbav:"suggestedCohort,omitempty" json:"suggestedCohort,omitempty"`
+
 	// When the user was most recently updated (not including nightly rating updates)
 	UpdatedAt string `dynamodbav:"updatedAt" json:"updatedAt"`
 
@@ -300,11 +362,19 @@ type User struct {
 	// if they have been banned on Lichess.
 	LichessBan string `dynamodbav:"lichessBan,omitempty" json:"-"`
 
+	// The user's pending verifications of their online accounts, mapped by rating system.
+	AccountVerifications map[RatingSystem]*AccountVerification `dynamodbav:"accountVerifications,omitempty" json:"-"`
+
 	// A map from exam id to the user's summary for that exam
 	Exams map[string]UserExamSummary `dynamodbav:"exams" json:"exams"`
 
 	// The IDs of the user's pinned tasks.
 	PinnedTasks []string `dynamodbav:"pinnedTasks,omitempty" json:"pinnedTasks"`
+
+	// The number of times the user has been written. Incremented by every update, and used
+	// to detect whether the user changed between reading and writing it. Users that have not
+	// been written since versioning was added have no version, which is represented as 0.
+	Version int `dynamodbav:"version,omitempty" json:"-"`
 }
 
 // A summary of a user's performance on a single exam.
@@ -374,6 +444,9 @@ type DiscordNotificationSettings struct {
 
 	// Whether to disable notifications when a user's meeting is cancelled
 	DisableMeetingCancellation bool `dynamodbav:"disableMeetingCancellation" json:"disableMeetingCancellation"`
+
+	// Whether to disable notifications when a user can graduate
+	DisableGraduationEligible bool `dynamodbav:"disableGraduationEligible" json:"disableGraduationEligible"`
 }
 
 func (dns *DiscordNotificationSettings) GetDisableMeetingBooking() bool {
@@ -390,6 +463,13 @@ func (dns *DiscordNotificationSettings) GetDisableMeetingCancellation() bool {
 	return dns.DisableMeetingCancellation
 }
 
+func (dns *DiscordNotificationSettings) GetDisableGraduationEligible() bool {
+	if dns == nil {
+		return false
+	}
+	return dns.DisableGraduationEligible
+}
+
 // The user's settings for email notifications.
 type EmailNotificationSettings struct {
 	// Whether to disable the Dojo Digest newsletter
@@ -415,6 +495,12 @@ type SiteNotificationSettings struct {
 
 	// Whether to disable notifications on newsfeed reactions
 	DisableNewsfeedReaction bool `dynamodbav:"disableNewsfeedReaction" json:"disableNewsfeedReaction"`
+
+	// Whether to disable notifications when the user can graduate
+	DisableGraduationEligible bool `dynamodbav:"disableGraduationEligible" json:"disableGraduationEligible"`
+
+	// Whether to disable notifications when the user is suggested or moved to a lower cohort
+	DisableCohortChange bool `dynamodbav:"disableCohortChange" json:"disableCohortChange"`
 }
 
 func (sns *SiteNotificationSettings) GetDisableGameComment() bool {
@@ -452,6 +538,20 @@ func (sns *SiteNotificationSettings) GetDisableNewsfeedReaction() bool {
 	return sns.DisableNewsfeedReaction
 }
 
+func (sns *SiteNotificationSettings) GetDisableGraduationEligible() bool {
+	if sns == nil {
+		return false
+	}
+	return sns.DisableGraduationEligible
+}
+
+func (sns *SiteNotificationSettings) GetDisableCohortChange() bool {
+	if sns == nil {
+		return false
+	}
+	return sns.DisableCohortChange
+}
+
 // UserOpeningModule represents a user's progress on a specific opening module
 type UserOpeningModule struct {
 	// A list of booleans indicating whether the current exercise is complete
@@ -482,6 +582,50 @@ func (u *User) CalculateScore(requirements []*Requirement) float32 {
 	return score
 }
 
+// CarryProgress returns a copy of the user's progress in which the counts of the
+// requirements restricted to several cohorts are carried from the cohort from to the
+// CarriedCounts of the cohort to, up to the requirement's count in to. Counts already
+// higher in to are kept. Requirements shared by all cohorts need no carrying, and
+// requirements which restart in every cohort are not carried.
+func (u *User) CarryProgress(requirements []*Requirement, from, to DojoCohort) map[string]*RequirementProgress {
+	if u == nil {
+		return nil
+	}
+
+	result := make(map[string]*RequirementProgress, len(u.Progress))
+	for id, p := range u.Progress {
+		result[id] = p
+	}
+
+	for _, r := range requirements {
+		p := u.Progress[r.Id]
+		if p == nil || r.NumberOfCohorts <= 1 {
+			continue
+		}
+		goal, ok := r.Counts[to]
+		if !ok {
+			continue
+		}
+		count, ok := p.Counts[from]
+		if !ok {
+			continue
+		}
+		count = min(count, goal)
+		if count <= max(p.Counts[to], p.CarriedCounts[to]) {
+			continue
+		}
+
+		carried := *p
+		carried.CarriedCounts = make(map[DojoCohort]int, len(p.CarriedCounts)+1)
+		for c, n := range p.CarriedCounts {
+			carried.CarriedCounts[c] = n
+		}
+		carried.CarriedCounts[to] = count
+		result[r.Id] = &carried
+	}
+	return result
+}
+
 func (u *User) TimeSpentOnReqs(requirements []*Requirement) int {
 	if u == nil {
 		return 0
@@ -573,6 +717,14 @@ type UserUpdate struct {
 	// The user's Dojo cohort
 	DojoCohort *DojoCohort `dynamodbav:"dojoCohort,omitempty" json:"dojoCohort,omitempty"`
 
+	// Whether the user's cohort was picked from a provisional rating.
+	// Cannot be manually passed by the user and is set when the cohort is autopicked or changed.
+	ProvisionalCohort *bool `dynamodbav:"provisionalCohort,omitempty" json:"-"`
+
+	// The user's pending verifications of their online accounts.
+	// Cannot be manually passed by the user. The user should instead call the user/ratings/verify functions
+	AccountVerifications *map[RatingSystem]*AccountVerification `dynamodbav:"accountVerifications,omitempty" json:"-"`
+
 	// The number of times the user has graduated.
 	// Cannot be manually passed by the user. The user should instead call the user/graduate function
 	NumberOfGraduations *int `dynamodbav:"numberOfGraduations,omitempty" json:"-"`
@@ -589,6 +741,22 @@ type UserUpdate struct {
 	// Cannot be manually passed by the user. The user should instead call the user/graduate function
 	LastGraduatedAt *string `dynamodbav:"lastGraduatedAt,omitempty" json:"-"`
 
+	// When the user's rating first reached the next cohort. Cannot be manually passed by the user
+	// and is updated by the nightly graduation eligibility check.
+	GraduationEligibleSince *string `dynamodbav:"graduationEligibleSince,omitempty" json:"-"`
+
+	// The cohort the user was most recently notified they can graduate to. Cannot be manually
+	// passed by the user and is updated by the nightly graduation eligibility check.
+	GraduationNotifiedCohort *DojoCohort `dynamodbav:"graduationNotifiedCohort,omitempty" json:"-"`
+
+	// When the user's rating first dropped below their cohort. Cannot be manually passed by
+	// the user and is updated by the nightly graduation eligibility check.
+	CohortDropSince *string `dynamodbav:"cohortDropSince,omitempty" json:"-"`
+
+	// The lower cohort suggested to the user. Cannot be manually passed by the user and is
+	// updated by the nightly graduation eligibility check.
+	SuggestedCohort *DojoCohort `dynamodbav:"suggestedCohort,omitempty" json:"-"`
+
 	// When the user was most recently updated (not including nightly rating updates)
 	// Cannot be manually passed by the user and is updated automatically by the server
 	UpdatedAt *string `dynamodbav:"updatedAt,omitempty" json:"-"`
@@ -651,23 +819,37 @@ type UserUpdate struct {
 
 	// The IDs of the user's pinned tasks.
 	PinnedTasks *[]string `dynamodbav:"pinnedTasks,omitempty" json:"pinnedTasks,omitempty"`
+
+	// The roles assigned to the user. This field cannot be manually set by the user.
+	Roles *[]RoleAssignment `dynamodbav:"roles,omitempty" json:"-"`
+
+	// The history of the user's role changes. This field cannot be manually set by the user.
+	RoleHistory *[]RoleChange `dynamodbav:"roleHistory,omitempty" json:"-"`
+
+	// The legacy admin booleans, which are kept in sync with Roles. These fields cannot be
+	// manually set by the user.
+	IsAdmin           *bool `dynamodbav:"isAdmin,omitempty" json:"-"`
+	IsCalendarAdmin   *bool `dynamodbav:"isCalendarAdmin,omitempty" json:"-"`
+	IsTournamentAdmin *bool `dynamodbav:"isTournamentAdmin,omitempty" json:"-"`
+	IsCoach           *bool `dynamodbav:"isCoach,omitempty" json:"-"`
+	IsBetaTester      *bool `dynamodbav:"isBetaTester,omitempty" json:"-"`
 }
 
-// AutopickCohort sets the UserUpdate's dojoCohort field based on the values of the ratingSystem
-// and current rating fields. The chosen cohort is returned.
-func (u *UserUpdate) AutopickCohort() DojoCohort {
-	if u == nil || u.RatingSystem == nil || u.Ratings == nil {
+// AutopickCohort sets the UserUpdate's dojoCohort and provisionalCohort fields based on the
+// values of the ratingSystem and ratings fields, using the provided cohort boundaries. The
+// chosen cohort is returned.
+func (u *UserUpdate) AutopickCohort(boundaries *CohortBoundaries) DojoCohort {
+	if u == nil || u.RatingSystem == nil || u.Ratings == nil || boundaries == nil {
 		return NoCohort
 	}
 
-	rating, ok := (*u.Ratings)[*u.RatingSystem]
-	if !ok {
+	cohort, provisional := boundaries.PlaceCohort(*u.RatingSystem, (*u.Ratings)[*u.RatingSystem])
+	if cohort == NoCohort {
 		return NoCohort
 	}
 
-	currentRating := rating.CurrentRating
-	cohort := getCohort(*u.RatingSystem, currentRating)
 	u.DojoCohort = &cohort
+	u.ProvisionalCohort = &provisional
 	return cohort
 }
 
@@ -757,6 +939,11 @@ type UserUpdater interface {
 	// UpdateUser applies the specified update to the user with the provided username.
 	UpdateUser(username string, update *UserUpdate) (*User, error)
 
+	// ModifyUser reads the user with the provided username, passes it to modify and saves the
+	// returned update only if the user has not changed in the meantime. On a conflict, the process
+	// is retried with a fresh copy of the user.
+	ModifyUser(username string, modify func(user *User) (*UserUpdate, error)) (*User, error)
+
 	// RecordSubscriptionCancelation adds 1 cancelation to the user statistics for
 	// the given cohort.
 	RecordSubscriptionCancelation(cohort DojoCohort) error
@@ -775,6 +962,11 @@ type UserProgressUpdater interface {
 
 	// UpdateUserProgress sets the given progress entry in the user's progress map.
 	UpdateUserProgress(username string, progressEntry *RequirementProgress) (*User, error)
+
+	// ModifyUserProgress reads the user with the provided username, passes it to modify and saves
+	// the returned progress entry only if the user has not changed in the meantime. On a conflict,
+	// the process is retried with a fresh copy of the user.
+	ModifyUserProgress(username string, modify func(user *User) (*RequirementProgress, error)) (*User, error)
 }
 
 type AdminUserLister interface {
@@ -803,16 +995,59 @@ func (repo *dynamoRepository) CreateUser(username, email, name, subscriptionStat
 }
 
 // SetUserConditional saves the provided User object in the database using an optional condition statement.
+// The user's version is incremented.
 func (repo *dynamoRepository) SetUserConditional(user *User, condition *string) error {
+	input, err := userPutInput(user)
+	if err != nil {
+		return err
+	}
+	input.ConditionExpression = condition
+
+	if _, err := repo.svc.PutItem(input); err != nil {
+		return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+	}
+	user.Version++
+	return nil
+}
+
+// putUserIfVersion saves the provided User object in the database only if the saved user
+// still has the provided user's version. The user's version is incremented.
+func (repo *dynamoRepository) putUserIfVersion(user *User) error {
+	input, err := userPutInput(user)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(userVersionCondition(user.Version)).Build()
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "DynamoDB expression building error", err)
+	}
+	input.ConditionExpression = expr.Condition()
+	input.ExpressionAttributeNames = expr.Names()
+	input.ExpressionAttributeValues = expr.Values()
+
+	if _, err := repo.svc.PutItem(input); err != nil {
+		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
+			return userVersionConflict(user.Username, err)
+		}
+		return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+	}
+	user.Version++
+	return nil
+}
+
+// userPutInput returns a PutItemInput which saves the provided User object with its version incremented.
+func userPutInput(user *User) (*dynamodb.PutItemInput, error) {
 	if user.Username == "STATISTICS" {
-		return errors.New(403, "Invalid request: cannot use username `STATISTICS`", "")
+		return nil, errors.New(403, "Invalid request: cannot use username `STATISTICS`", "")
 	}
 
 	user.UpdatedAt = time.Now().Format(time.RFC3339)
 	item, err := dynamodbattribute.MarshalMap(user)
 	if err != nil {
-		return errors.Wrap(500, "Temporary server error", "Unable to marshal user", err)
+		return nil, errors.Wrap(500, "Temporary server error", "Unable to marshal user", err)
 	}
+	item["version"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(user.Version + 1))}
 
 	// Hack to work around https://github.com/aws/aws-sdk-go/issues/682
 	emptyMap := make(map[string]*dynamodb.AttributeValue)
@@ -829,18 +1064,75 @@ func (repo *dynamoRepository) SetUserConditional(user *User, condition *string)
 		item["exams"] = &dynamodb.AttributeValue{M: emptyMap}
 	}
 
-	input := &dynamodb.PutItemInput{
-		ConditionExpression: condition,
-		Item:                item,
-		TableName:           aws.String(userTable),
+	return &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(userTable),
+	}, nil
+}
+
+// maxUserModifyAttempts is the number of times a user is read and written before a
+// version conflict is returned to the caller.
+const maxUserModifyAttempts = 5
+
+// userVersionConflict returns the error returned when a user changed between reading and writing it.
+func userVersionConflict(username string, cause error) error {
+	return errors.Wrap(409, "Invalid request: your profile was changed by another request, please try again",
+		fmt.Sprintf("User %q version conflict", username), cause, errors.WithReason(errors.ReasonUserVersionConflict))
+}
+
+// userVersionCondition returns a condition which passes only if the user exists and has the
+// provided version.
+func userVersionCondition(version int) expression.ConditionBuilder {
+	cond := expression.AttributeExists(expression.Name("username"))
+	if version == 0 {
+		return cond.And(expression.AttributeNotExists(expression.Name("version")))
 	}
+	return cond.And(expression.Name("version").Equal(expression.Value(version)))
+}
+
+// modifyUser reads the user with the provided username and calls write with it. If write fails
+// with a version conflict, the process is retried up to maxUserModifyAttempts times.
+func (repo *dynamoRepository) modifyUser(username string, write func(user *User) (*User, error)) (*User, error) {
+	for attempt := 1; ; attempt++ {
+		user, err := repo.GetUser(username)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err = repo.svc.PutItem(input)
-	return errors.Wrap(500, "Temporary server error", "DynamoDB PutItem failure", err)
+		user, err = write(user)
+		if err == nil || errors.ReasonOf(err) != errors.ReasonUserVersionConflict || attempt >= maxUserModifyAttempts {
+			return user, err
+		}
+		log.Debugf("Retrying write of user %q after version conflict (attempt %d)", username, attempt)
+	}
 }
 
 // UpdateUser applies the specified update to the user with the provided username.
+// The user's version is incremented.
 func (repo *dynamoRepository) UpdateUser(username string, update *UserUpdate) (*User, error) {
+	return repo.updateUser(username, update, nil)
+}
+
+// ModifyUser reads the user with the provided username, passes it to modify and saves the
+// returned update only if the user has not changed in the meantime. On a conflict, the process
+// is retried with a fresh copy of the user. If modify returns a nil update, the user is returned
+// without being saved.
+func (repo *dynamoRepository) ModifyUser(username string, modify func(user *User) (*UserUpdate, error)) (*User, error) {
+	return repo.modifyUser(username, func(user *User) (*User, error) {
+		update, err := modify(user)
+		if err != nil {
+			return nil, err
+		}
+		if update == nil {
+			return user, nil
+		}
+		return repo.updateUser(username, update, &user.Version)
+	})
+}
+
+// updateUser applies the specified update to the user with the provided username. If version
+// is not nil, the update is applied only if the user has that version.
+func (repo *dynamoRepository) updateUser(username string, update *UserUpdate, version *int) (*User, error) {
 	if username == "STATISTICS" {
 		return nil, errors.New(403, "Invalid request: cannot update username `STATISTICS`", "")
 	}
@@ -859,8 +1151,14 @@ func (repo *dynamoRepository) UpdateUser(username string, update *UserUpdate) (*
 	for k, v := range av.M {
 		builder = builder.Set(expression.Name(k), expression.Value(v))
 	}
+	builder = builder.Add(expression.Name("version"), expression.Value(1))
+
+	condition := expression.AttributeExists(expression.Name("username"))
+	if version != nil {
+		condition = userVersionCondition(*version)
+	}
 
-	expr, err := expression.NewBuilder().WithUpdate(builder).Build()
+	expr, err := expression.NewBuilder().WithUpdate(builder).WithCondition(condition).Build()
 	if err != nil {
 		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB expression building error", err)
 	}
@@ -874,12 +1172,15 @@ func (repo *dynamoRepository) UpdateUser(username string, update *UserUpdate) (*
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
-		ConditionExpression:       aws.String("attribute_exists(username)"),
+		ConditionExpression:       expr.Condition(),
 		TableName:                 aws.String(userTable),
 		ReturnValues:              aws.String("ALL_NEW"),
 	}
 	result, err := repo.svc.UpdateItem(input)
 	if err != nil {
+		if _, ok := err.(*dynamodb.ConditionalCheckFailedException); ok && version != nil {
+			return nil, userVersionConflict(username, err)
+		}
 		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB UpdateItem failure", err)
 	}
 
@@ -891,7 +1192,27 @@ func (repo *dynamoRepository) UpdateUser(username string, update *UserUpdate) (*
 }
 
 // UpdateUserProgress sets the given progress entry in the user's progress map.
+// The user's version is incremented.
 func (repo *dynamoRepository) UpdateUserProgress(username string, progressEntry *RequirementProgress) (*User, error) {
+	return repo.updateUserProgress(username, progressEntry, nil)
+}
+
+// ModifyUserProgress reads the user with the provided username, passes it to modify and saves
+// the returned progress entry only if the user has not changed in the meantime. On a conflict,
+// the process is retried with a fresh copy of the user.
+func (repo *dynamoRepository) ModifyUserProgress(username string, modify func(user *User) (*RequirementProgress, error)) (*User, error) {
+	return repo.modifyUser(username, func(user *User) (*User, error) {
+		progressEntry, err := modify(user)
+		if err != nil {
+			return nil, err
+		}
+		return repo.updateUserProgress(username, progressEntry, &user.Version)
+	})
+}
+
+// updateUserProgress sets the given progress entry in the user's progress map. If version
+// is not nil, the entry is set only if the user has that version.
+func (repo *dynamoRepository) updateUserProgress(username string, progressEntry *RequirementProgress, version *int) (*User, error) {
 	if username == "STATISTICS" {
 		return nil, errors.New(403, "Invalid request: cannot update username `STATISTICS`", "")
 	}
@@ -901,31 +1222,47 @@ func (repo *dynamoRepository) UpdateUserProgress(username string, progressEntry
 		return nil, errors.Wrap(500, "Temporary server error", "Unable to marshal progress entry", err)
 	}
 
-	updatedAt := time.Now().Format(time.RFC3339)
+	condition := expression.AttributeExists(expression.Name("username"))
+	if version != nil {
+		condition = userVersionCondition(*version)
+	}
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", "DynamoDB expression building error", err)
+	}
+	names := expr.Names()
+	names["#p"] = aws.String("progress")
+	names["#id"] = aws.String(progressEntry.RequirementId)
+	names["#u"] = aws.String("updatedAt")
+	names["#v"] = aws.String("version")
+	values := expr.Values()
+	if values == nil {
+		values = make(map[string]*dynamodb.AttributeValue)
+	}
+	values[":p"] = pav
+	values[":u"] = &dynamodb.AttributeValue{S: aws.String(time.Now().Format(time.RFC3339))}
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+
 	input := &dynamodb.UpdateItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"username": {
 				S: aws.String(username),
 			},
 		},
-		UpdateExpression: aws.String("SET #p.#id = :p, #u = :u"),
-		ExpressionAttributeNames: map[string]*string{
-			"#p":  aws.String("progress"),
-			"#id": aws.String(progressEntry.RequirementId),
-			"#u":  aws.String("updatedAt"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":p": pav,
-			":u": {S: aws.String(updatedAt)},
-		},
-		ConditionExpression: aws.String("attribute_exists(username)"),
-		ReturnValues:        aws.String("ALL_NEW"),
-		TableName:           aws.String(userTable),
+		UpdateExpression:          aws.String("SET #p.#id = :p, #u = :u ADD #v :one"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              aws.String("ALL_NEW"),
+		TableName:                 aws.String(userTable),
 	}
 	result, err := repo.svc.UpdateItem(input)
 	if err != nil {
 		if aerr, ok := err.(*dynamodb.ConditionalCheckFailedException); ok {
-			return nil, errors.Wrap(404, "Invalid request: user does not exist", "DynamoDB conditional check failed", aerr)
+			if version != nil {
+				return nil, userVersionConflict(username, aerr)
+			}
+			return nil, errors.Wrap(404, "Invalid request: user does not exist", "DynamoDB conditional check failed", aerr, errors.WithReason(errors.ReasonUserNotFound))
 		}
 		return nil, errors.Wrap(500, "Temporary server error", "Failed DynamoDB UpdateItem", err)
 	}
@@ -1016,7 +1353,7 @@ func (repo *dynamoRepository) ScanUsers(startKey string) ([]*User, string, error
 	return users, lastKey, nil
 }
 
-const ratingsProjection = "username, dojoCohort, subscriptionStatus, subscriptionOverride, paymentInfo, wixEmail, updatedAt, progress, minutesSpent, ratingSystem, ratings, ratingHistories, lichessBan"
+const ratingsProjection = "username, dojoCohort, provisionalCohort, subscriptionStatus, subscriptionOverride, paymentInfo, wixEmail, updatedAt, progress, minutesSpent, ratingSystem, ratings, ratingsUpdatedAt, ratingHistories, lichessBan, graduationEligibleSince, graduationNotifiedCohort, cohortDropSince, suggestedCohort, version"
 
 // ListUserRatings returns a list of Users matching the provided cohort, up to 1MB of data.
 // Only the fields necessary for the rating/statistics update are returned.
@@ -1044,35 +1381,52 @@ func (repo *dynamoRepository) ListUserRatings(cohort DojoCohort, startKey string
 	return users, lastKey, nil
 }
 
+// UpdateUserRatings uses DynamoDB PartiQL to save the ratings, rating histories, Lichess ban
+// and provisional cohort flag of the provided users, and their cohort and ratingsUpdatedAt if
+// they are set. A user is saved only if its version has not changed since it was read.
+// Users that changed are reported as failures with reason ConditionalCheckFailed, and their
+// versions are left unchanged. Saved users have their version incremented in the database.
 func (repo *dynamoRepository) UpdateUserRatings(users []*User) error {
 	if len(users) > 25 {
 		return errors.New(500, "Temporary server error", "UpdateUserRatings has max limit of 25 users")
 	}
 
 	statements := make([]*dynamodb.BatchStatementRequest, 0, len(users))
+	keys := make([]string, 0, len(users))
 	for _, user := range users {
-		params, err := dynamodbattribute.MarshalList([]interface{}{user.Ratings, user.RatingHistories, user.LichessBan, user.Username})
+		values := []interface{}{user.Ratings, user.RatingHistories, user.LichessBan, user.ProvisionalCohort}
+		set := "SET ratings=? SET ratingHistories=? SET lichessBan=? SET provisionalCohort=?"
+		if user.DojoCohort != "" {
+			values = append(values, user.DojoCohort)
+			set += " SET dojoCohort=?"
+		}
+		if user.RatingsUpdatedAt != "" {
+			values = append(values, user.RatingsUpdatedAt)
+			set += " SET ratingsUpdatedAt=?"
+		}
+		values = append(values, user.Version+1, user.Username)
+
+		versionCondition := "version IS MISSING"
+		if user.Version != 0 {
+			values = append(values, user.Version)
+			versionCondition = "version=?"
+		}
+		params, err := dynamodbattribute.MarshalList(values)
 		if err != nil {
 			return errors.Wrap(500, "Temporary server error", "Failed to marshal user.Ratings", err)
 		}
 
 		statement := &dynamodb.BatchStatementRequest{
 			Statement: aws.String(fmt.Sprintf(
-				"UPDATE \"%s\" SET ratings=? SET ratingHistories=? SET lichessBan=? WHERE username=?", userTable,
+				"UPDATE \"%s\" %s SET version=? WHERE username=? AND %s", userTable, set, versionCondition,
 			)),
 			Parameters: params,
 		}
 		statements = append(statements, statement)
+		keys = append(keys, "username="+user.Username)
 	}
 
-	input := &dynamodb.BatchExecuteStatementInput{
-		Statements: statements,
-	}
-	log.Debugf("Batch execute statement input: %v", input)
-	output, err := repo.svc.BatchExecuteStatement(input)
-	log.Debugf("Batch execute statement output: %v", output)
-
-	return errors.Wrap(500, "Temporary server error", "Failed BatchExecuteStatement", err)
+	return repo.batchExecute(userTable, statements, keys)
 }
 
 const (
@@ -1094,7 +1448,8 @@ const (
 	AllCohortsNonDojo     = "ALL_COHORTS_NON_DOJO"
 )
 
-// UpdateUserTimes uses DynamoDB PartiQL to update the minutesSpent field on the provided users
+// UpdateUserTimes uses DynamoDB PartiQL to update the minutesSpent field on the provided users.
+// These fields are only written by the nightly statistics job, so the users' versions are not checked.
 func (repo *dynamoRepository) UpdateUserTimes(users []*User) error {
 	if len(users) > 25 {
 		return errors.New(500, "Temporary server error", "UpdateUserTimes has max limit of 25 users")
@@ -1102,6 +1457,7 @@ func (repo *dynamoRepository) UpdateUserTimes(users []*User) error {
 
 	var sb strings.Builder
 	statements := make([]*dynamodb.BatchStatementRequest, 0, len(users))
+	keys := make([]string, 0, len(users))
 	for _, user := range users {
 		params, err := dynamodbattribute.MarshalList([]interface{}{user.TotalDojoScore, user.MinutesSpent})
 		if err != nil {
@@ -1117,18 +1473,12 @@ func (repo *dynamoRepository) UpdateUserTimes(users []*User) error {
 			Parameters: params,
 		}
 		statements = append(statements, statement)
+		keys = append(keys, "username="+user.Username)
 
 		sb.Reset()
 	}
 
-	input := &dynamodb.BatchExecuteStatementInput{
-		Statements: statements,
-	}
-	log.Debugf("Batch execute statement input: %v", input)
-	output, err := repo.svc.BatchExecuteStatement(input)
-	log.Debugf("Batch execute statement output: %v", output)
-
-	return errors.Wrap(500, "Temporary server error", "Failed BatchExecuteStatement", err)
+	return repo.batchExecute(userTable, statements, keys)
 }
 
 func (repo *dynamoRepository) UpdateUserSubscriptionStatuses(users []*User) error {
@@ -1138,6 +1488,7 @@ func (repo *dynamoRepository) UpdateUserSubscriptionStatuses(users []*User) erro
 
 	var sb strings.Builder
 	statements := make([]*dynamodb.BatchStatementRequest, 0, len(users))
+	keys := make([]string, 0, len(users))
 	for _, user := range users {
 		params, err := dynamodbattribute.MarshalList([]interface{}{user.SubscriptionStatus})
 		if err != nil {
@@ -1153,29 +1504,40 @@ func (repo *dynamoRepository) UpdateUserSubscriptionStatuses(users []*User) erro
 			Parameters: params,
 		}
 		statements = append(statements, statement)
+		keys = append(keys, "username="+user.Username)
 
 		sb.Reset()
 	}
 
-	input := &dynamodb.BatchExecuteStatementInput{
-		Statements: statements,
-	}
-	log.Debugf("Batch execute statement input: %v", input)
-	output, err := repo.svc.BatchExecuteStatement(input)
-	log.Debugf("Batch execute statement output: %v", output)
-
-	return errors.Wrap(500, "Temporary server error", "Failed BatchExecuteStatement", err)
+	return repo.batchExecute(userTable, statements, keys)
 }
 
-// RecordGameCreation updates the given user to increase their game creation stats.
+// RecordGameCreation updates the given user to increase their game creation stats. If the
+// user changed since it was read, the stats are recalculated on a fresh copy of the user,
+// which is stored in user.
 func (repo *dynamoRepository) RecordGameCreation(user *User, amount int) error {
 	if user.GamesCreated == nil {
 		user.GamesCreated = make(map[DojoCohort]int)
 	}
 
-	count := user.GamesCreated[user.DojoCohort]
-	user.GamesCreated[user.DojoCohort] = count + amount
-	return repo.SetUserConditional(user, nil)
+	for attempt := 1; ; attempt++ {
+		count := user.GamesCreated[user.DojoCohort]
+		user.GamesCreated[user.DojoCohort] = count + amount
+
+		err := repo.putUserIfVersion(user)
+		if err == nil || errors.ReasonOf(err) != errors.ReasonUserVersionConflict || attempt >= maxUserModifyAttempts {
+			return err
+		}
+
+		fresh, err := repo.GetUser(user.Username)
+		if err != nil {
+			return err
+		}
+		*user = *fresh
+		if user.GamesCreated == nil {
+			user.GamesCreated = make(map[DojoCohort]int)
+		}
+	}
 }
 
 // DeleteUser deletes the user with the given username
@@ -1273,11 +1635,10 @@ func (repo *dynamoRepository) BatchGetUsersProjection(usernames []string, projec
 		input.RequestItems[userTable].Keys = append(input.RequestItems[userTable].Keys, key)
 	}
 
-	result, err := repo.svc.BatchGetItem(input)
+	list, err := repo.batchGet(userTable, input.RequestItems[userTable])
 	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed call to BatchGetItem", err)
+		return nil, err
 	}
-	list := result.Responses[userTable]
 
 	var resultEntries []*User
 	if err := dynamodbattribute.UnmarshalListOfMaps(list, &resultEntries); err != nil {
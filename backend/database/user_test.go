@@ -335,3 +335,49 @@ func TestCalculateScoreUser(t *testing.T) {
 		})
 	}
 }
+
+func TestCarryProgress(t *testing.T) {
+	user := &User{
+		DojoCohort: "1500-1600",
+		Progress: map[string]*RequirementProgress{
+			"per-cohort": {Counts: map[DojoCohort]int{"1500-1600": 12}},
+			"partial":    {Counts: map[DojoCohort]int{"1400-1500": 5, "1500-1600": 3}},
+			"restarts":   {Counts: map[DojoCohort]int{"1500-1600": 4}},
+			"shared":     {Counts: map[DojoCohort]int{AllCohorts: 6}},
+		},
+	}
+	requirements := []*Requirement{
+		{Id: "per-cohort", Counts: map[DojoCohort]int{"1400-1500": 10, "1500-1600": 15}, NumberOfCohorts: 2, UnitScore: 1},
+		{Id: "partial", Counts: map[DojoCohort]int{"1400-1500": 10, "1500-1600": 15}, NumberOfCohorts: 2, UnitScore: 1},
+		{Id: "restarts", Counts: map[DojoCohort]int{"1400-1500": 10, "1500-1600": 15}, NumberOfCohorts: -1, UnitScore: 1},
+		{Id: "shared", Counts: map[DojoCohort]int{"1400-1500": 10, "1500-1600": 15}, NumberOfCohorts: 1, UnitScore: 1},
+	}
+
+	progress := user.CarryProgress(requirements, "1500-1600", "1400-1500")
+
+	// partial already has a higher count in 1400-1500, so nothing is carried.
+	want := map[string]int{"per-cohort": 10, "partial": 0, "restarts": 0, "shared": 0}
+	for id, count := range want {
+		if got := progress[id].CarriedCounts["1400-1500"]; got != count {
+			t.Errorf("CarryProgress %s got carried count %d in 1400-1500; want %d", id, got, count)
+		}
+	}
+	if _, ok := progress["per-cohort"].Counts["1400-1500"]; ok {
+		t.Errorf("CarryProgress added a count in 1400-1500 to Counts")
+	}
+	if user.Progress["per-cohort"].CarriedCounts != nil {
+		t.Errorf("CarryProgress modified the user's progress")
+	}
+
+	demoted := &User{DojoCohort: "1400-1500", Progress: progress}
+	if got := demoted.CalculateScore(requirements); got != 21 {
+		t.Errorf("CalculateScore after CarryProgress got %v; want 21", got)
+	}
+
+	// The carried count does not complete the requirement in other cohorts.
+	regraduated := &User{DojoCohort: "1600-1700", Progress: progress}
+	later := []*Requirement{{Id: "per-cohort", Counts: map[DojoCohort]int{"1600-1700": 20}, NumberOfCohorts: 2, UnitScore: 1}}
+	if got := regraduated.CalculateScore(later); got != 0 {
+		t.Errorf("CalculateScore in 1600-1700 after CarryProgress got %v; want 0", got)
+	}
+}
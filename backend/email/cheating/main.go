@@ -1,19 +1,28 @@
+// Sends the fair play enforcement email to the given address. The email is also sent
+// when an admin enforces a rating anomaly (see user/ratings/anomalies/resolve). Run with
+// -createTemplate to create or update the SES template used by both.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ses"
 )
 
 var email = flag.String("email", "", "The email address to send the cheating notification to")
+var violation = flag.String("violation", "Chess.com or Lichess account has been closed for fair play violations", "The violation included in the email, following `your`")
+var createTemplate = flag.Bool("createTemplate", false, "Create or update the SES template instead of sending an email")
+
+const templateName = "fairPlayEnforcement"
 
 const content = `Hello,
 
-It has come to our attention that your Chess.com or Lichess account has been closed for fairplay violations. In accordance with our Terms of Service, we are exercising our right to terminate your ChessDojo subscription. You can still access the Scoreboard on the free tier, and we wish you the best in your continuing chess improvement journey.
+It has come to our attention that your {{violation}}. In accordance with our Terms of Service, we are exercising our right to terminate your ChessDojo subscription. You can still access the Scoreboard on the free tier, and we wish you the best in your continuing chess improvement journey.
 
 Best,
 ChessDojo
@@ -22,41 +31,60 @@ ChessDojo
 func main() {
 	flag.Parse()
 
+	sess, err := session.NewSession()
+	if err != nil {
+		log.Fatalln("Failed to create AWS session", err)
+	}
+	svc := ses.New(sess)
+
+	if *createTemplate {
+		putTemplate(svc)
+		return
+	}
+
 	if *email == "" {
 		log.Fatalln("Error: email is required")
 	}
 
-	sess, err := session.NewSession()
+	templateData, err := json.Marshal(map[string]string{"violation": *violation})
 	if err != nil {
-		log.Fatalln("Failed to create AWS session", err)
+		log.Fatalln("Failed to marshal template data", err)
 	}
-	svc := ses.New(sess)
 
-	input := &ses.SendEmailInput{
+	input := &ses.SendTemplatedEmailInput{
 		Destination: &ses.Destination{
 			ToAddresses: []*string{
 				email,
 			},
 		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Text: &ses.Content{
-					Charset: aws.String("UTF-8"),
-					Data:    aws.String(content),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String("UTF-8"),
-				Data:    aws.String("ChessDojo Subscription Terminated"),
-			},
-		},
-		Source: aws.String("chessdojotwitch@gmail.com"),
+		Source:       aws.String("ChessDojo <no-reply@mail.chessdojo.club>"),
+		Template:     aws.String(templateName),
+		TemplateData: aws.String(string(templateData)),
 	}
 
-	_, err = svc.SendEmail(input)
+	_, err = svc.SendTemplatedEmail(input)
 	if err != nil {
 		log.Fatalf("Failed to send to %q: %v\n", *email, err)
 	}
 
 	log.Println("Finished.")
 }
+
+// putTemplate creates the enforcement email template, or updates it if it already exists.
+func putTemplate(svc *ses.SES) {
+	template := &ses.Template{
+		SubjectPart:  aws.String("ChessDojo Subscription Terminated"),
+		TextPart:     aws.String(content),
+		TemplateName: aws.String(templateName),
+	}
+
+	_, err := svc.CreateTemplate(&ses.CreateTemplateInput{Template: template})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ses.ErrCodeAlreadyExistsException {
+		_, err = svc.UpdateTemplate(&ses.UpdateTemplateInput{Template: template})
+	}
+	if err != nil {
+		log.Fatalln("Failed to put template: ", err)
+	}
+
+	log.Printf("Saved template %s\n", templateName)
+}
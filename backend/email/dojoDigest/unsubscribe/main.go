@@ -27,13 +27,10 @@ type UnsubscribeRequest struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := UnsubscribeRequest{}
 
 	if _, ok := event.QueryStringParameters["email"]; ok {
@@ -12,7 +12,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 )
 
 type SupportRequest struct {
@@ -32,13 +31,10 @@ const supportEmail = "chessdojo.tickets@gmail.com"
 var sesInstance = ses.New(session.Must(session.NewSession()))
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := SupportRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		err = errors.Wrap(400, "Invalid request: unable to unmarshal request body", "", err)
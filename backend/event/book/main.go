@@ -76,9 +76,6 @@ func checkCohort(event *database.Event, cohort database.DojoCohort) error {
 
 // Handler implements the BookAvailability endpoint.
 func Handler(ctx context.Context, request api.Request) (api.Response, error) {
-	log.SetRequestId(request.RequestContext.RequestID)
-	log.Infof("Request: %#v", request)
-
 	info := api.GetUserInfo(request)
 	if info.Username == "" {
 		err := errors.New(400, "Invalid request: username is required", "")
@@ -103,7 +100,7 @@ func Handler(ctx context.Context, request api.Request) (api.Response, error) {
 	}
 
 	if info.Username == originalEvent.Owner {
-		err := errors.New(400, "Invalid request: you cannot book your own availability", "")
+		err := errors.New(400, "Invalid request: you cannot book your own availability", "", errors.WithReason(errors.ReasonEventOwnBooking))
 		return api.Failure(err), nil
 	}
 
@@ -167,5 +164,5 @@ func Handler(ctx context.Context, request api.Request) (api.Response, error) {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest(), api.Idempotent(database.DynamoDB)))
 }
@@ -25,13 +25,10 @@ const sameOwnerPrefix = "Hello, a member of your upcoming meeting has canceled.
 const withParticipantsSuffix = "There are still %d participants in the meeting. View it [here](%s)."
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, request api.Request) (api.Response, error) {
-	log.SetRequestId(request.RequestContext.RequestID)
-	log.Infof("Request: %#v", request)
-
 	info := api.GetUserInfo(request)
 	if info.Username == "" {
 		err := errors.New(403, "Invalid request: not authenticated", "Username from Cognito token was empty")
@@ -15,9 +15,6 @@ import (
 var repository database.EventDeleter = database.DynamoDB
 
 func Handler(ctx context.Context, request api.Request) (api.Response, error) {
-	log.SetRequestId(request.RequestContext.RequestID)
-	log.Infof("Request: %#v", request)
-
 	info := api.GetUserInfo(request)
 	if info.Username == "" {
 		err := errors.New(403, "Invalid request: not authenticated", "Username from Cognito token was empty")
@@ -40,8 +37,7 @@ func Handler(ctx context.Context, request api.Request) (api.Response, error) {
 		if err != nil {
 			return api.Failure(err), nil
 		}
-		if !user.IsAdmin && !user.IsCalendarAdmin {
-			err := errors.New(403, "You do not have permission to delete dojo events", "")
+		if err := api.Authorize(user, database.PermissionCalendarEventsWrite, ""); err != nil {
 			return api.Failure(err), nil
 		}
 	} else if event.Owner != info.Username {
@@ -75,5 +71,5 @@ func Handler(ctx context.Context, request api.Request) (api.Response, error) {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
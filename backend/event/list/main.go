@@ -23,13 +23,10 @@ func main() {
 	if stage == "prod" {
 		log.SetLevel(log.InfoLevel)
 	}
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, request api.Request) (api.Response, error) {
-	log.SetRequestId(request.RequestContext.RequestID)
-	log.Infof("Request: %#v", request)
-
 	info := api.GetUserInfo(request)
 	startKey, _ := request.QueryStringParameters["startKey"]
 
@@ -19,13 +19,10 @@ import (
 var repository database.EventSetter = database.DynamoDB
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, request api.Request) (api.Response, error) {
-	log.SetRequestId(request.RequestContext.RequestID)
-	log.Infof("Request: %#v", request)
-
 	info := api.GetUserInfo(request)
 	if info.Username == "" {
 		err := errors.New(403, "Invalid request: username is required", "")
@@ -127,8 +124,7 @@ func handleDojoEvent(info *api.UserInfo, event *database.Event) api.Response {
 	if err != nil {
 		return api.Failure(err)
 	}
-	if !user.IsAdmin && !user.IsCalendarAdmin {
-		err := errors.New(403, "You do not have permission to create Dojo events", "")
+	if err := api.Authorize(user, database.PermissionCalendarEventsWrite, ""); err != nil {
 		return api.Failure(err)
 	}
 
@@ -196,8 +192,7 @@ func handleCoachingEvent(info *api.UserInfo, event *database.Event) api.Response
 		return api.Failure(err)
 	}
 
-	if !user.IsCoach {
-		err := errors.New(403, "You must be a coach to create Coaching events", "")
+	if err := api.Authorize(user, database.PermissionCalendarCoachingWrite, ""); err != nil {
 		return api.Failure(err)
 	}
 	if user.CoachInfo == nil || !user.CoachInfo.OnboardingComplete || user.CoachInfo.StripeId == "" {
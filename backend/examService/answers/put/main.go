@@ -10,7 +10,6 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
@@ -30,13 +29,10 @@ type PutExamAnswerResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Debugf("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
@@ -56,6 +52,18 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if request.Attempt.Cohort == "" {
 		return api.Failure(errors.New(400, "Invalid request: attempt.cohort is required", "")), nil
 	}
+
+	user, err := repository.GetUser(info.Username)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	boundaries, err := repository.GetCohortBoundaries(time.Now())
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	if rating, ok := normalizedRating(user, boundaries); ok {
+		request.Attempt.Rating = rating
+	}
 	if request.Attempt.Rating == 0 {
 		return api.Failure(errors.New(400, "Invalid request: attempt.rating is required", "")), nil
 	}
@@ -74,3 +82,19 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 
 	return api.Success(PutExamAnswerResponse{Answer: answer, Exam: exam}), nil
 }
+
+// normalizedRating returns the normalized current rating of the user in their preferred
+// rating system. False is returned if the rating cannot be normalized, in which case the
+// rating sent by the client is used.
+func normalizedRating(user *database.User, boundaries *database.CohortBoundaries) (float32, bool) {
+	current := user.Ratings[user.RatingSystem]
+	if current == nil || current.CurrentRating <= 0 {
+		return 0, false
+	}
+
+	rating, err := boundaries.NormalizedRating(user.RatingSystem, float64(current.CurrentRating))
+	if err != nil {
+		return 0, false
+	}
+	return float32(rating), true
+}
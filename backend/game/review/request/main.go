@@ -10,7 +10,6 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 	payment "github.com/jackstenglein/chess-dojo-scheduler/backend/paymentService"
 )
@@ -28,13 +27,10 @@ type ReviewResponse struct {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Debugf("Event: %#v", event)
-
 	request := ReviewRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)), nil
@@ -63,7 +59,10 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	}
 
 	if game.Review != nil {
-		return api.Failure(errors.New(400, "Invalid request: game has already been requested", "")), nil
+		if game.Review.ReviewedAt != "" {
+			return api.Failure(errors.New(400, "Invalid request: game has already been reviewed", "", errors.WithReason(errors.ReasonGameAlreadyReviewed))), nil
+		}
+		return api.Failure(errors.New(400, "Invalid request: game has already been requested", "", errors.WithReason(errors.ReasonGameReviewAlreadyRequested))), nil
 	}
 
 	checkoutSession, err := payment.GameReviewCheckoutSession(user, request.Cohort, request.Id, request.Type)
@@ -1,5 +1,5 @@
 // Implements a lambda handler which marks a game as reviewed or unreviewed.
-// The caller must be an admin.
+// The caller must have the game.review.complete permission for the game's cohort.
 package main
 
 import (
@@ -20,28 +20,21 @@ type Request struct {
 	Cohort string               `json:"cohort"`
 	Id     string               `json:"id"`
 	Review *database.GameReview `json:"review"`
+
+	// The reason for the review, which is saved in the audit log
+	Reason string `json:"reason"`
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Debugf("Event: %#v", event)
-
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin {
-		return api.Failure(errors.New(403, "Invalid request: you must be an admin to call this function", "")), nil
-	}
+	user := api.UserFromContext(ctx)
 
 	request := Request{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
@@ -53,6 +46,9 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if request.Cohort == "" {
 		return api.Failure(errors.New(400, "Invalid request: cohort is required", "")), nil
 	}
+	if err := api.Authorize(user, database.PermissionGameReviewComplete, database.CohortScope(database.DojoCohort(request.Cohort))); err != nil {
+		return api.Failure(err), nil
+	}
 
 	if request.Review == nil {
 		// This can happen when reviewing a game that was not submitted for review
@@ -65,10 +61,22 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		Cohort:      user.DojoCohort,
 	}
 
-	game, err := repository.SetGameReview(request.Cohort, request.Id, request.Review)
+	game, err := repository.GetGame(request.Cohort, request.Id)
 	if err != nil {
 		return api.Failure(err), nil
 	}
+	before := reviewState(game)
+
+	game, err = repository.SetGameReview(request.Cohort, request.Id, request.Review)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	entry := database.NewAuditEntry(user.Username, database.AuditAction_GameReview,
+		database.GameAuditTarget(request.Cohort, request.Id), request.Reason)
+	if err := repository.CreateAuditEntry(entry, before, reviewState(game)); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 
 	notification := database.GameReviewNotification(game)
 	if err := repository.PutNotification(notification); err != nil {
@@ -77,3 +85,11 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 
 	return api.Success(game), nil
 }
+
+// reviewState returns the review fields of the game, for saving in the audit log.
+func reviewState(game *database.Game) map[string]interface{} {
+	return map[string]interface{}{
+		"reviewStatus": game.ReviewStatus,
+		"review":       game.Review,
+	}
+}
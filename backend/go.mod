@@ -8,6 +8,7 @@ require (
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/davecgh/go-spew v1.1.1
 	github.com/stripe/stripe-go/v81 v81.2.0
+	golang.org/x/image v0.23.0
 )
 
 require (
@@ -0,0 +1,339 @@
+// Implements a Lambda handler which returns time-to-graduate analytics computed from the
+// graduations table. For each cohort, the distributions of the calendar days and training
+// minutes it took to graduate, the dojo score at graduation and the minutes graduates
+// spent in each requirement category are returned. The analytics are computed from full
+// scans, so they are cached for cacheTTL by each Lambda instance.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// The category of progress on requirements which are not in the requirements table, such
+// as custom tasks.
+const nonDojoCategory = "Non-Dojo"
+
+type AnalyticsRepository interface {
+	database.GraduationLister
+	database.RequirementLister
+
+	// ScanGraduations returns a list of all graduations in the table, paginated by the startKey.
+	ScanGraduations(startKey string) ([]database.Graduation, string, error)
+}
+
+var repository AnalyticsRepository = database.DynamoDB
+var stage = os.Getenv("stage")
+
+// How long computed analytics are returned before being computed again.
+const cacheTTL = time.Hour
+
+type cachedResponse struct {
+	response *GraduationAnalyticsResponse
+	expires  time.Time
+}
+
+// cache maps a cohort, or the empty string for all cohorts, to its computed analytics.
+// Lambda instances handle one request at a time, so the cache needs no locking.
+var cache = make(map[database.DojoCohort]cachedResponse)
+
+// Distribution summarizes a list of values. The quartiles are linearly interpolated
+// between the closest values.
+type Distribution struct {
+	// The number of values in the distribution.
+	Count int `json:"count"`
+
+	// The smallest value.
+	Min float64 `json:"min"`
+
+	// The first quartile.
+	Q1 float64 `json:"q1"`
+
+	// The median value.
+	Median float64 `json:"median"`
+
+	// The third quartile.
+	Q3 float64 `json:"q3"`
+
+	// The largest value.
+	Max float64 `json:"max"`
+
+	// The mean value.
+	Mean float64 `json:"mean"`
+}
+
+type CategoryAnalytics struct {
+	// The requirement category.
+	Category string `json:"category"`
+
+	// The number of graduates who spent time on the category in the cohort.
+	NumGraduates int `json:"numGraduates"`
+
+	// The fraction of all training minutes in the cohort spent on the category.
+	Share float64 `json:"share"`
+
+	// The minutes spent on the category by the graduates who spent time on it.
+	Minutes Distribution `json:"minutes"`
+}
+
+type CohortAnalytics struct {
+	// The cohort graduated from.
+	Cohort database.DojoCohort `json:"cohort"`
+
+	// The number of graduations from the cohort.
+	NumGraduations int `json:"numGraduations"`
+
+	// The calendar days between starting the cohort and graduating. Graduations with an
+	// invalid start date are skipped.
+	Days Distribution `json:"days"`
+
+	// The dojo and non-dojo minutes spent in the cohort before graduating. Graduations
+	// without any recorded time are skipped.
+	Minutes Distribution `json:"minutes"`
+
+	// The dojo score at graduation.
+	Score Distribution `json:"score"`
+
+	// The requirement categories graduates spent time on, ordered by the number of
+	// graduates and then by name.
+	Categories []CategoryAnalytics `json:"categories"`
+}
+
+type GraduationAnalyticsResponse struct {
+	// The analytics of each cohort with at least one graduation, in cohort order.
+	Cohorts []CohortAnalytics `json:"cohorts"`
+}
+
+func main() {
+	if stage == "prod" {
+		log.SetLevel(log.InfoLevel)
+	}
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
+}
+
+func Handler(ctx context.Context, event api.Request) (api.Response, error) {
+	cohort, ok := event.PathParameters["cohort"]
+	if ok && !slices.Contains(database.Cohorts, database.DojoCohort(cohort)) {
+		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` does not exist", cohort), "")), nil
+	}
+
+	if cached, ok := cache[database.DojoCohort(cohort)]; ok && time.Now().Before(cached.expires) {
+		return api.Success(cached.response), nil
+	}
+
+	var graduations []database.Graduation
+	var err error
+	if cohort != "" {
+		graduations, err = listGraduations(database.DojoCohort(cohort))
+	} else {
+		graduations, err = scanGraduations()
+	}
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	categories, err := requirementCategories()
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	response := &GraduationAnalyticsResponse{Cohorts: getAnalytics(graduations, categories)}
+	cache[database.DojoCohort(cohort)] = cachedResponse{response: response, expires: time.Now().Add(cacheTTL)}
+	return api.Success(response), nil
+}
+
+// listGraduations returns all graduations from the given cohort.
+func listGraduations(cohort database.DojoCohort) ([]database.Graduation, error) {
+	var result []database.Graduation
+	startKey := ""
+	for loop := true; loop; loop = (startKey != "") {
+		graduations, lastKey, err := repository.ListGraduationsByCohort(cohort, startKey)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, graduations...)
+		startKey = lastKey
+	}
+	return result, nil
+}
+
+// scanGraduations returns all graduations from all cohorts.
+func scanGraduations() ([]database.Graduation, error) {
+	var result []database.Graduation
+	startKey := ""
+	for loop := true; loop; loop = (startKey != "") {
+		graduations, lastKey, err := repository.ScanGraduations(startKey)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, graduations...)
+		startKey = lastKey
+	}
+	return result, nil
+}
+
+// requirementCategories returns a map from the id of every requirement, including
+// archived requirements, to its category.
+func requirementCategories() (map[string]string, error) {
+	categories := make(map[string]string)
+	startKey := ""
+	for loop := true; loop; loop = (startKey != "") {
+		requirements, lastKey, err := repository.ScanRequirements("", startKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range requirements {
+			categories[r.Id] = r.Category
+		}
+		startKey = lastKey
+	}
+	return categories, nil
+}
+
+// getAnalytics returns the analytics of each cohort in the given graduations. categories
+// maps requirement ids to their category. Progress on unknown requirements is counted as
+// non-dojo.
+func getAnalytics(graduations []database.Graduation, categories map[string]string) []CohortAnalytics {
+	byCohort := make(map[database.DojoCohort][]database.Graduation)
+	for _, g := range graduations {
+		byCohort[g.PreviousCohort] = append(byCohort[g.PreviousCohort], g)
+	}
+
+	result := make([]CohortAnalytics, 0, len(byCohort))
+	for cohort, graduations := range byCohort {
+		result = append(result, getCohortAnalytics(cohort, graduations, categories))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := slices.Index(database.Cohorts, result[i].Cohort), slices.Index(database.Cohorts, result[j].Cohort)
+		if a != b {
+			return a < b
+		}
+		return result[i].Cohort < result[j].Cohort
+	})
+	return result
+}
+
+// getCohortAnalytics returns the analytics of the given graduations from the cohort.
+func getCohortAnalytics(cohort database.DojoCohort, graduations []database.Graduation, categories map[string]string) CohortAnalytics {
+	var days, minutes, scores []float64
+	categoryMinutes := make(map[string][]float64)
+	categoryTotals := make(map[string]int)
+	totalMinutes := 0
+
+	for _, g := range graduations {
+		scores = append(scores, float64(g.Score))
+		if d, ok := daysToGraduate(&g); ok {
+			days = append(days, d)
+		}
+		if m := g.DojoMinutes + g.NonDojoMinutes; m > 0 {
+			minutes = append(minutes, float64(m))
+		}
+
+		spent := make(map[string]int)
+		for id, progress := range g.Progress {
+			if progress == nil || progress.MinutesSpent[cohort] <= 0 {
+				continue
+			}
+			category, ok := categories[id]
+			if !ok || category == "" {
+				category = nonDojoCategory
+			}
+			spent[category] += progress.MinutesSpent[cohort]
+		}
+		for category, m := range spent {
+			categoryMinutes[category] = append(categoryMinutes[category], float64(m))
+			categoryTotals[category] += m
+			totalMinutes += m
+		}
+	}
+
+	result := CohortAnalytics{
+		Cohort:         cohort,
+		NumGraduations: len(graduations),
+		Days:           getDistribution(days),
+		Minutes:        getDistribution(minutes),
+		Score:          getDistribution(scores),
+		Categories:     make([]CategoryAnalytics, 0, len(categoryMinutes)),
+	}
+	for category, values := range categoryMinutes {
+		d := getDistribution(values)
+		result.Categories = append(result.Categories, CategoryAnalytics{
+			Category:     category,
+			NumGraduates: d.Count,
+			Share:        float64(categoryTotals[category]) / float64(totalMinutes),
+			Minutes:      d,
+		})
+	}
+	sort.Slice(result.Categories, func(i, j int) bool {
+		a, b := result.Categories[i], result.Categories[j]
+		if a.NumGraduates != b.NumGraduates {
+			return a.NumGraduates > b.NumGraduates
+		}
+		return a.Category < b.Category
+	})
+	return result
+}
+
+// daysToGraduate returns the calendar days between the start of the cohort and the
+// graduation. False is returned if either date is invalid or the graduation is before
+// the start.
+func daysToGraduate(g *database.Graduation) (float64, bool) {
+	startedAt, err := time.Parse(time.RFC3339, g.StartedAt)
+	if err != nil {
+		return 0, false
+	}
+	createdAt, err := time.Parse(time.RFC3339, g.CreatedAt)
+	if err != nil {
+		return 0, false
+	}
+	days := createdAt.Sub(startedAt).Hours() / 24
+	if days < 0 {
+		return 0, false
+	}
+	return days, true
+}
+
+// getDistribution returns the distribution of the given values. The values are sorted
+// in place.
+func getDistribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return Distribution{
+		Count:  len(values),
+		Min:    values[0],
+		Q1:     quantile(values, 0.25),
+		Median: quantile(values, 0.5),
+		Q3:     quantile(values, 0.75),
+		Max:    values[len(values)-1],
+		Mean:   sum / float64(len(values)),
+	}
+}
+
+// quantile returns the q quantile of the sorted values, linearly interpolated between
+// the closest values.
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	fraction := position - float64(lower)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*fraction
+}
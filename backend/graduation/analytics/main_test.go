@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+func TestGetDistribution(t *testing.T) {
+	table := []struct {
+		name   string
+		values []float64
+		want   Distribution
+	}{
+		{
+			name: "Empty",
+			want: Distribution{},
+		},
+		{
+			name:   "Single",
+			values: []float64{5},
+			want:   Distribution{Count: 1, Min: 5, Q1: 5, Median: 5, Q3: 5, Max: 5, Mean: 5},
+		},
+		{
+			name:   "Odd",
+			values: []float64{9, 1, 5, 3, 7},
+			want:   Distribution{Count: 5, Min: 1, Q1: 3, Median: 5, Q3: 7, Max: 9, Mean: 5},
+		},
+		{
+			name:   "Interpolated",
+			values: []float64{40, 10, 30, 20},
+			want:   Distribution{Count: 4, Min: 10, Q1: 17.5, Median: 25, Q3: 32.5, Max: 40, Mean: 25},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			got := getDistribution(tc.values)
+			if got != tc.want {
+				t.Errorf("getDistribution(%v) got %+v; want %+v", tc.values, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetCohortAnalytics(t *testing.T) {
+	cohort := database.DojoCohort("1400-1500")
+	categories := map[string]string{"tactics": "Tactics", "games": "Games + Analysis"}
+	progress := func(minutes map[string]int) map[string]*database.RequirementProgress {
+		result := make(map[string]*database.RequirementProgress)
+		for id, m := range minutes {
+			result[id] = &database.RequirementProgress{
+				RequirementId: id,
+				MinutesSpent:  map[database.DojoCohort]int{cohort: m, "1300-1400": 1000},
+			}
+		}
+		return result
+	}
+
+	graduations := []database.Graduation{
+		{
+			Score:          50,
+			DojoMinutes:    300,
+			NonDojoMinutes: 100,
+			StartedAt:      "2024-01-01T00:00:00Z",
+			CreatedAt:      "2024-03-01T00:00:00Z",
+			Progress:       progress(map[string]int{"tactics": 200, "games": 100, "custom": 100}),
+		},
+		{
+			Score:       70,
+			DojoMinutes: 600,
+			StartedAt:   "2024-01-01T00:00:00Z",
+			CreatedAt:   "2024-01-31T00:00:00Z",
+			Progress:    progress(map[string]int{"tactics": 600}),
+		},
+		{
+			Score:     60,
+			StartedAt: "invalid",
+			CreatedAt: "2024-01-31T00:00:00Z",
+		},
+	}
+
+	got := getCohortAnalytics(cohort, graduations, categories)
+	if got.NumGraduations != 3 {
+		t.Errorf("NumGraduations got %d; want 3", got.NumGraduations)
+	}
+	if want := (Distribution{Count: 2, Min: 30, Q1: 37.5, Median: 45, Q3: 52.5, Max: 60, Mean: 45}); got.Days != want {
+		t.Errorf("Days got %+v; want %+v", got.Days, want)
+	}
+	if want := (Distribution{Count: 2, Min: 400, Q1: 450, Median: 500, Q3: 550, Max: 600, Mean: 500}); got.Minutes != want {
+		t.Errorf("Minutes got %+v; want %+v", got.Minutes, want)
+	}
+	if want := (Distribution{Count: 3, Min: 50, Q1: 55, Median: 60, Q3: 65, Max: 70, Mean: 60}); got.Score != want {
+		t.Errorf("Score got %+v; want %+v", got.Score, want)
+	}
+
+	wantCategories := []struct {
+		category     string
+		numGraduates int
+		share        float64
+		median       float64
+	}{
+		{"Tactics", 2, 0.8, 400},
+		{"Games + Analysis", 1, 0.1, 100},
+		{nonDojoCategory, 1, 0.1, 100},
+	}
+	if len(got.Categories) != len(wantCategories) {
+		t.Fatalf("Categories got %+v; want %d categories", got.Categories, len(wantCategories))
+	}
+	for i, want := range wantCategories {
+		c := got.Categories[i]
+		if c.Category != want.category || c.NumGraduates != want.numGraduates || c.Share != want.share || c.Minutes.Median != want.median {
+			t.Errorf("Categories[%d] got %+v; want %+v", i, c, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	repo := database.NewInMemory()
+	repository = repo
+	cache = make(map[database.DojoCohort]cachedResponse)
+	for _, g := range []database.Graduation{
+		{Username: "a", PreviousCohort: "1500-1600", Score: 10, CreatedAt: "2024-01-01T00:00:00Z"},
+		{Username: "a", PreviousCohort: "1400-1500", Score: 20, CreatedAt: "2023-01-01T00:00:00Z"},
+		{Username: "b", PreviousCohort: "1400-1500", Score: 30, CreatedAt: "2023-02-01T00:00:00Z"},
+	} {
+		if err := repo.PutGraduation(&g); err != nil {
+			t.Fatalf("PutGraduation got err: %v", err)
+		}
+	}
+
+	table := []struct {
+		name           string
+		pathParameters map[string]string
+		wantCohorts    []database.DojoCohort
+		wantCounts     []int
+	}{
+		{
+			name:        "AllCohorts",
+			wantCohorts: []database.DojoCohort{"1400-1500", "1500-1600"},
+			wantCounts:  []int{2, 1},
+		},
+		{
+			name:           "SingleCohort",
+			pathParameters: map[string]string{"cohort": "1400-1500"},
+			wantCohorts:    []database.DojoCohort{"1400-1500"},
+			wantCounts:     []int{2},
+		},
+		{
+			name:           "NoGraduations",
+			pathParameters: map[string]string{"cohort": "0-300"},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := Handler(context.Background(), api.Request{PathParameters: tc.pathParameters})
+			if err != nil {
+				t.Fatalf("Handler got err: %v", err)
+			}
+			if resp.StatusCode != 200 {
+				t.Fatalf("Handler got status %d; want 200: %s", resp.StatusCode, resp.Body)
+			}
+
+			var got GraduationAnalyticsResponse
+			if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+				t.Fatalf("Unmarshal got err: %v", err)
+			}
+			if len(got.Cohorts) != len(tc.wantCohorts) {
+				t.Fatalf("Handler got %d cohorts; want %d", len(got.Cohorts), len(tc.wantCohorts))
+			}
+			for i, c := range got.Cohorts {
+				if c.Cohort != tc.wantCohorts[i] || c.NumGraduations != tc.wantCounts[i] {
+					t.Errorf("Cohorts[%d] got %s with %d graduations; want %s with %d", i, c.Cohort, c.NumGraduations, tc.wantCohorts[i], tc.wantCounts[i])
+				}
+			}
+		})
+	}
+
+	t.Run("Cached", func(t *testing.T) {
+		if err := repo.PutGraduation(&database.Graduation{Username: "c", PreviousCohort: "1400-1500", CreatedAt: "2023-03-01T00:00:00Z"}); err != nil {
+			t.Fatalf("PutGraduation got err: %v", err)
+		}
+		resp, _ := Handler(context.Background(), api.Request{PathParameters: map[string]string{"cohort": "1400-1500"}})
+		var got GraduationAnalyticsResponse
+		if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+			t.Fatalf("Unmarshal got err: %v", err)
+		}
+		if len(got.Cohorts) != 1 || got.Cohorts[0].NumGraduations != 2 {
+			t.Errorf("Handler got %+v; want the cached analytics with 2 graduations", got.Cohorts)
+		}
+	})
+
+	t.Run("UnknownCohort", func(t *testing.T) {
+		resp, err := Handler(context.Background(), api.Request{PathParameters: map[string]string{"cohort": "1400-1450"}})
+		if err != nil || resp.StatusCode != 400 {
+			t.Errorf("Handler got status %d, err %v; want status 400", resp.StatusCode, err)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+// Package certificate renders shareable certificates of graduations. Each certificate
+// shows the user's display name, the cohorts they graduated from and to, their dojo
+// score, the time they spent in the cohort and a sparkline of their rating history in
+// the cohort. Certificates are rendered as PNG images, and as single page PDF documents
+// containing the same image.
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// The size of a certificate in pixels, which is A4 landscape at 150 DPI.
+const (
+	width  = 1754
+	height = 1240
+)
+
+// The maximum width of the display name in the name font. Longer names are drawn in a
+// smaller font.
+const maxNameWidth = width - 300
+
+var (
+	backgroundColor = color.RGBA{0xfb, 0xf8, 0xf1, 0xff}
+	primaryColor    = color.RGBA{0x1a, 0x23, 0x7e, 0xff}
+	secondaryColor  = color.RGBA{0x5f, 0x63, 0x68, 0xff}
+	accentColor     = color.RGBA{0xc9, 0xa2, 0x27, 0xff}
+)
+
+// The names of the rating systems whose name is not their RatingSystem value.
+var ratingSystemNames = map[database.RatingSystem]string{
+	database.Chesscom: "Chess.com",
+	database.Lichess:  "Lichess",
+	database.Custom:   "Custom",
+	database.Custom2:  "Custom",
+	database.Custom3:  "Custom",
+}
+
+// faces contains the font faces used on a certificate.
+type faces struct {
+	title, name, body, label, value, small font.Face
+}
+
+// loadFaces parses the Go fonts and returns the faces used on a certificate. The fonts
+// are only parsed once.
+var loadFaces = sync.OnceValues(func() (*faces, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &faces{}
+	for _, f := range []struct {
+		face *font.Face
+		font *opentype.Font
+		size float64
+	}{
+		{&result.title, bold, 76},
+		{&result.name, bold, 92},
+		{&result.body, regular, 38},
+		{&result.label, regular, 28},
+		{&result.value, bold, 48},
+		{&result.small, regular, 26},
+	} {
+		*f.face, err = opentype.NewFace(f.font, &opentype.FaceOptions{Size: f.size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+})
+
+// Render returns the certificate of the given graduation as a PNG image and as a single
+// page A4 PDF document.
+func Render(graduation *database.Graduation) ([]byte, []byte, error) {
+	img, err := render(graduation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		return nil, nil, errors.Wrap(500, "Temporary server error", "Failed to encode certificate PNG", err)
+	}
+
+	title := fmt.Sprintf("%s graduated from %s", graduation.DisplayName, graduation.PreviousCohort)
+	pdfData, err := encodePDF(img, title)
+	if err != nil {
+		return nil, nil, errors.Wrap(500, "Temporary server error", "Failed to encode certificate PDF", err)
+	}
+	return pngData.Bytes(), pdfData, nil
+}
+
+// render draws the certificate of the given graduation.
+func render(graduation *database.Graduation) (*image.RGBA, error) {
+	f, err := loadFaces()
+	if err != nil {
+		return nil, errors.Wrap(500, "Temporary server error", "Failed to load certificate fonts", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+	strokeRect(img, image.Rect(40, 40, width-40, height-40), 10, primaryColor)
+	strokeRect(img, image.Rect(66, 66, width-66, height-66), 3, accentColor)
+
+	center := width / 2
+	drawCentered(img, f.title, primaryColor, "Certificate of Graduation", center, 220)
+	drawCentered(img, f.body, secondaryColor, "This certifies that", center, 320)
+	nameFace := f.name
+	if font.MeasureString(nameFace, graduation.DisplayName) > fixed.I(maxNameWidth) {
+		nameFace = f.value
+	}
+	drawCentered(img, nameFace, primaryColor, graduation.DisplayName, center, 430)
+	fillRect(img, image.Rect(center-320, 462, center+320, 466), accentColor)
+	drawCentered(img, f.body, secondaryColor,
+		fmt.Sprintf("graduated from the %s cohort to the %s cohort", graduation.PreviousCohort, graduation.NewCohort),
+		center, 540)
+
+	stats := []struct{ label, value string }{
+		{"Dojo Score", formatScore(graduation.Score)},
+		{"Dojo Time", formatMinutes(graduation.DojoMinutes)},
+		{"Non-Dojo Time", formatMinutes(graduation.NonDojoMinutes)},
+	}
+	for i, stat := range stats {
+		x := width * (2*i + 1) / (2 * len(stats))
+		drawCentered(img, f.label, secondaryColor, stat.label, x, 650)
+		drawCentered(img, f.value, primaryColor, stat.value, x, 715)
+	}
+
+	history := graduation.RatingHistories[graduation.RatingSystem]
+	if len(history) >= 2 {
+		drawSparkline(img, history, image.Rect(center-500, 790, center+500, 960))
+	}
+	if graduation.RatingSystem != "" {
+		drawCentered(img, f.label, secondaryColor, ratingLabel(graduation), center, 1010)
+	}
+
+	drawCentered(img, f.small, secondaryColor, fmt.Sprintf("ChessDojo · %s", formatDate(graduation.CreatedAt)), center, height-110)
+	return img, nil
+}
+
+// ratingLabel returns the text shown below the sparkline. The start rating is the first
+// rating of the history, if the graduation has any.
+func ratingLabel(graduation *database.Graduation) string {
+	name, ok := ratingSystemNames[graduation.RatingSystem]
+	if !ok {
+		name = string(graduation.RatingSystem)
+	}
+
+	start, current := graduation.StartRating, graduation.CurrentRating
+	if history := graduation.RatingHistories[graduation.RatingSystem]; len(history) > 0 {
+		start = history[0].Rating
+	}
+	if start <= 0 {
+		return fmt.Sprintf("%s rating: %d", name, current)
+	}
+	return fmt.Sprintf("%s rating: %d to %d", name, start, current)
+}
+
+// point is a point on the certificate, in pixels.
+type point struct {
+	x, y float32
+}
+
+// sparklinePoints returns the points of the sparkline of the given history within the
+// given rectangle. The points are evenly spaced, and the lowest and highest ratings are
+// at the bottom and top of the rectangle. If all ratings are equal, the line is drawn
+// through the middle of the rectangle.
+func sparklinePoints(history []database.RatingHistory, r image.Rectangle) []point {
+	low, high := math.MaxInt, math.MinInt
+	for _, h := range history {
+		low = min(low, h.Rating)
+		high = max(high, h.Rating)
+	}
+
+	points := make([]point, 0, len(history))
+	for i, h := range history {
+		x := float32(r.Min.X)
+		if len(history) > 1 {
+			x += float32(r.Dx()) * float32(i) / float32(len(history)-1)
+		}
+		y := float32(r.Min.Y) + float32(r.Dy())/2
+		if high > low {
+			y = float32(r.Max.Y) - float32(r.Dy())*float32(h.Rating-low)/float32(high-low)
+		}
+		points = append(points, point{x, y})
+	}
+	return points
+}
+
+// drawSparkline draws the sparkline of the given history within the given rectangle,
+// with a dot at its last point.
+func drawSparkline(img *image.RGBA, history []database.RatingHistory, r image.Rectangle) {
+	points := sparklinePoints(history, r)
+	rasterizer := vector.NewRasterizer(width, height)
+	for i := 1; i < len(points); i++ {
+		addSegment(rasterizer, points[i-1], points[i], 6)
+	}
+	rasterizer.Draw(img, img.Bounds(), image.NewUniform(primaryColor), image.Point{})
+
+	// The joins are drawn separately, as overlapping paths with opposite windings would
+	// cancel out.
+	rasterizer = vector.NewRasterizer(width, height)
+	for _, p := range points {
+		addCircle(rasterizer, p, 3)
+	}
+	rasterizer.Draw(img, img.Bounds(), image.NewUniform(primaryColor), image.Point{})
+
+	rasterizer = vector.NewRasterizer(width, height)
+	addCircle(rasterizer, points[len(points)-1], 12)
+	rasterizer.Draw(img, img.Bounds(), image.NewUniform(accentColor), image.Point{})
+}
+
+// addSegment adds a line of the given thickness from a to b to the rasterizer.
+func addSegment(r *vector.Rasterizer, a, b point, thickness float32) {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		return
+	}
+	nx, ny := -dy/length*thickness/2, dx/length*thickness/2
+	r.MoveTo(a.x+nx, a.y+ny)
+	r.LineTo(b.x+nx, b.y+ny)
+	r.LineTo(b.x-nx, b.y-ny)
+	r.LineTo(a.x-nx, a.y-ny)
+	r.ClosePath()
+}
+
+// addCircle adds a circle with the given center and radius to the rasterizer,
+// approximated by a polygon.
+func addCircle(r *vector.Rasterizer, center point, radius float32) {
+	const sides = 24
+	r.MoveTo(center.x+radius, center.y)
+	for i := 1; i < sides; i++ {
+		angle := 2 * math.Pi * float64(i) / sides
+		r.LineTo(center.x+radius*float32(math.Cos(angle)), center.y+radius*float32(math.Sin(angle)))
+	}
+	r.ClosePath()
+}
+
+// drawCentered draws the text horizontally centered on x, with its baseline at y.
+func drawCentered(img draw.Image, face font.Face, c color.Color, text string, x, y int) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	d.Dot = fixed.Point26_6{X: fixed.I(x) - d.MeasureString(text)/2, Y: fixed.I(y)}
+	d.DrawString(text)
+}
+
+// fillRect fills the rectangle with the given color.
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// strokeRect draws the outline of the rectangle, with the given thickness inside it.
+func strokeRect(img draw.Image, r image.Rectangle, thickness int, c color.Color) {
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+// formatScore returns the score with at most one decimal.
+func formatScore(score float32) string {
+	return strconv.FormatFloat(math.Round(float64(score)*10)/10, 'f', -1, 64)
+}
+
+// formatMinutes returns the minutes in the form 12h 5m.
+func formatMinutes(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+// formatDate returns the RFC3339 date in the form January 2, 2006, or the date itself
+// if it cannot be parsed.
+func formatDate(date string) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return t.Format("January 2, 2006")
+}
@@ -0,0 +1,136 @@
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var testGraduation = &database.Graduation{
+	DisplayName:    "Test User",
+	PreviousCohort: "1400-1500",
+	NewCohort:      "1500-1600",
+	Score:          123.45,
+	DojoMinutes:    4321,
+	NonDojoMinutes: 59,
+	RatingSystem:   database.Chesscom,
+	StartRating:    1400,
+	CurrentRating:  1560,
+	CreatedAt:      "2024-10-10T06:00:00Z",
+	RatingHistories: map[database.RatingSystem][]database.RatingHistory{
+		database.Chesscom: {{Rating: 1400}, {Rating: 1390}, {Rating: 1480}, {Rating: 1560}},
+	},
+}
+
+func TestRender(t *testing.T) {
+	pngData, pdfData, err := Render(testGraduation)
+	if err != nil {
+		t.Fatalf("Render got err: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode got err: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, width, height); got != want {
+		t.Errorf("Render PNG got bounds %v; want %v", got, want)
+	}
+
+	if !bytes.HasPrefix(pdfData, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdfData, []byte("%%EOF\n")) {
+		t.Errorf("Render PDF is missing its header or trailer")
+	}
+
+	// Every offset in the cross-reference table must point to its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdfData)
+	if startxref == nil {
+		t.Fatalf("Render PDF has no startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdfData[xref:], []byte("xref\n")) {
+		t.Fatalf("Render PDF startxref %d does not point to the xref table", xref)
+	}
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdfData[xref:], -1)
+	if len(offsets) != 6 {
+		t.Fatalf("Render PDF got %d objects; want 6", len(offsets))
+	}
+	for i, offset := range offsets {
+		n, _ := strconv.Atoi(string(offset[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdfData[n:], []byte(want)) {
+			t.Errorf("Render PDF offset of object %d does not point to it", i+1)
+		}
+	}
+}
+
+func TestRenderWithoutHistory(t *testing.T) {
+	graduation := *testGraduation
+	graduation.RatingHistories = nil
+	graduation.DisplayName = "A Very Long Display Name Which Does Not Fit In The Name Font"
+
+	if _, _, err := Render(&graduation); err != nil {
+		t.Errorf("Render got err: %v", err)
+	}
+}
+
+func TestSparklinePoints(t *testing.T) {
+	r := image.Rect(100, 100, 400, 200)
+
+	table := []struct {
+		name    string
+		ratings []int
+		want    []point
+	}{
+		{
+			name:    "Rising",
+			ratings: []int{1400, 1450, 1500},
+			want:    []point{{100, 200}, {250, 150}, {400, 100}},
+		},
+		{
+			name:    "Flat",
+			ratings: []int{1400, 1400},
+			want:    []point{{100, 150}, {400, 150}},
+		},
+		{
+			name:    "SinglePoint",
+			ratings: []int{1400},
+			want:    []point{{100, 150}},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			history := make([]database.RatingHistory, 0, len(tc.ratings))
+			for _, rating := range tc.ratings {
+				history = append(history, database.RatingHistory{Rating: rating})
+			}
+
+			got := sparklinePoints(history, r)
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("sparklinePoints got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := formatMinutes(59); got != "59m" {
+		t.Errorf("formatMinutes(59) got %q; want 59m", got)
+	}
+	if got := formatMinutes(125); got != "2h 5m" {
+		t.Errorf("formatMinutes(125) got %q; want 2h 5m", got)
+	}
+	if got := formatScore(12.46); got != "12.5" {
+		t.Errorf("formatScore(12.46) got %q; want 12.5", got)
+	}
+	if got := formatScore(12); got != "12" {
+		t.Errorf("formatScore(12) got %q; want 12", got)
+	}
+	if got := formatDate("2024-10-10T06:00:00Z"); got != "October 10, 2024" {
+		t.Errorf("formatDate got %q; want October 10, 2024", got)
+	}
+}
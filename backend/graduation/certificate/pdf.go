@@ -0,0 +1,91 @@
+package certificate
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"strings"
+	"unicode/utf16"
+)
+
+// The size of an A4 landscape page in PDF points.
+const (
+	pageWidth  = 842
+	pageHeight = 595
+)
+
+// pdfWriter writes the objects of a PDF document and records their offsets for the
+// cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object writes the next object, numbered from 1, with the given dictionary and an
+// optional stream.
+func (w *pdfWriter) object(dictionary string, stream []byte) {
+	w.offsets = append(w.offsets, w.buf.Len())
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", len(w.offsets))
+	if stream == nil {
+		fmt.Fprintf(&w.buf, "%s\nendobj\n", dictionary)
+		return
+	}
+	fmt.Fprintf(&w.buf, "<< %s >>\nstream\n", strings.TrimSpace(fmt.Sprintf("%s /Length %d", dictionary, len(stream))))
+	w.buf.Write(stream)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// encodePDF returns a single page A4 landscape PDF document with the given title, whose
+// page is filled by the image. The image is embedded losslessly using the Flate filter.
+func encodePDF(img *image.RGBA, title string) ([]byte, error) {
+	bounds := img.Bounds()
+	var pixels bytes.Buffer
+	zw := zlib.NewWriter(&pixels)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			row = append(row, img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	w.object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	w.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	w.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /XObject << /Im0 4 0 R >> >> /Contents 5 0 R >>",
+		pageWidth, pageHeight), nil)
+	w.object(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+		bounds.Dx(), bounds.Dy()), pixels.Bytes())
+	w.object("", []byte(fmt.Sprintf("q %d 0 0 %d 0 0 cm /Im0 Do Q", pageWidth, pageHeight)))
+	w.object(fmt.Sprintf("<< /Title %s /Producer (ChessDojo) >>", pdfTextString(title)), nil)
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.offsets)+1, len(w.offsets), xref)
+	return w.buf.Bytes(), nil
+}
+
+// pdfTextString returns s as a PDF hex string encoded in UTF-16BE with a byte order
+// mark, so that any characters of display names are preserved.
+func pdfTextString(s string) string {
+	var buf bytes.Buffer
+	buf.WriteString("<FEFF")
+	for _, c := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&buf, "%04X", c)
+	}
+	buf.WriteString(">")
+	return buf.String()
+}
@@ -61,9 +61,6 @@ func byDateHandler(event api.Request) (api.Response, error) {
 }
 
 func Handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	if _, ok := event.PathParameters["cohort"]; ok {
 		return byCohortHandler(event)
 	}
@@ -79,5 +76,5 @@ func main() {
 	if stage == "prod" {
 		log.SetLevel(log.InfoLevel)
 	}
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +10,7 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/cursor"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
@@ -21,23 +21,28 @@ const limit = 25
 var repository = database.DynamoDB
 var stage = os.Getenv("stage")
 
+// cursorScope is the scope of the composite cursors returned by this handler. The
+// cursor contains one part for each newsfeed id.
+const cursorScope = "ListNewsfeed"
+
+// notStarted is the cursor part of a newsfeed that was skipped because the page was
+// already full. It is fetched from the beginning on the next page.
+const notStarted = "-"
+
 type ListNewsfeedResponse struct {
 	Entries   []database.TimelineEntry `json:"entries"`
 	LastFetch string                   `json:"lastFetch"`
-	LastKeys  map[string]string        `json:"lastKeys"`
+	LastKey   string                   `json:"lastKey,omitempty"`
 }
 
 func main() {
 	if stage == "prod" {
 		log.SetLevel(log.InfoLevel)
 	}
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	lastFetch := getLastFetched(info.Username, event)
 
@@ -58,25 +63,33 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		}
 	}
 
-	startKey := event.QueryStringParameters["startKey"]
-	startKeys := make(map[string]string)
-	if startKey != "" {
-		if err := json.Unmarshal([]byte(startKey), &startKeys); err != nil {
-			err = errors.Wrap(400, "Invalid request: startKey is not valid", "startKey could not be unmarshaled", err)
-			return api.Failure(err), nil
-		}
+	startKeys, err := cursor.DecodeComposite(cursorScope, event.QueryStringParameters["startKey"])
+	if err != nil {
+		return api.Failure(err), nil
 	}
 
 	timelineEntries := make(map[string]database.TimelineEntryKey)
 	lastKeys := make(map[string]string)
 
 	for _, newsfeedId := range newsfeedIds {
+		startKey, ok := startKeys[newsfeedId]
+		if startKeys != nil && !ok {
+			// All entries in this newsfeed were returned on previous pages
+			continue
+		}
+		if startKey == notStarted {
+			startKey = ""
+		}
+
 		if len(timelineEntries) >= limit {
-			lastKeys[newsfeedId] = startKeys[newsfeedId]
+			if startKey == "" {
+				startKey = notStarted
+			}
+			lastKeys[newsfeedId] = startKey
 			continue
 		}
 
-		if err := fetchEntries(newsfeedId, startKeys[newsfeedId], lastFetch, timelineEntries, lastKeys); err != nil {
+		if err := fetchEntries(newsfeedId, startKey, lastFetch, timelineEntries, lastKeys); err != nil {
 			return api.Failure(err), nil
 		}
 	}
@@ -87,7 +100,12 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(err), nil
 	}
 
-	if len(lastKeys) == 0 && info.Username != "" && event.QueryStringParameters["skipLastFetched"] == "" {
+	lastKey, err := cursor.EncodeComposite(cursorScope, lastKeys)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	if lastKey == "" && info.Username != "" && event.QueryStringParameters["skipLastFetched"] == "" {
 		update := &database.UserUpdate{
 			LastFetchedNewsfeed: aws.String(time.Now().Format(time.RFC3339)),
 		}
@@ -100,7 +118,7 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	return api.Success(&ListNewsfeedResponse{
 		Entries:   resultEntries,
 		LastFetch: lastFetch,
-		LastKeys:  lastKeys,
+		LastKey:   lastKey,
 	}), nil
 }
 
@@ -33,14 +33,11 @@ func init() {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 // Responds to Stripe webhook events.
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	signatureHeader, ok := event.Headers["stripe-signature"]
 	if !ok {
 		err := errors.New(400, "Invalid request: missing stripe signature", "")
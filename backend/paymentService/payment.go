@@ -17,6 +17,7 @@ import (
 	"github.com/stripe/stripe-go/v81/checkout/session"
 	"github.com/stripe/stripe-go/v81/loginlink"
 	"github.com/stripe/stripe-go/v81/refund"
+	"github.com/stripe/stripe-go/v81/subscription"
 )
 
 var frontendHost = os.Getenv("frontendHost")
@@ -307,6 +308,14 @@ func GetBillingPortalSession(customerId string) (*stripe.BillingPortalSession, e
 	return session, nil
 }
 
+// CancelSubscription immediately cancels the Stripe subscription with the given id.
+func CancelSubscription(subscriptionId string) error {
+	if _, err := subscription.Cancel(subscriptionId, nil); err != nil {
+		return errors.Wrap(500, "Temporary server error", "Failed to cancel Stripe subscription", err)
+	}
+	return nil
+}
+
 func CreateConnectedAccount(username, email string) (*stripe.Account, error) {
 	params := &stripe.AccountParams{
 		Type:         stripe.String(string(stripe.AccountTypeExpress)),
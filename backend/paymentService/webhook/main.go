@@ -38,14 +38,11 @@ func init() {
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler, api.Recover(), api.LogRequest()))
 }
 
 // handler responds to Stripe webhook events.
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	signatureHeader, ok := event.Headers["stripe-signature"]
 	if !ok {
 		err := errors.New(400, "Invalid request: missing stripe signature", "")
@@ -111,21 +108,14 @@ func handleCoursePurchase(username string, courseIds []string) api.Response {
 		return api.Success(nil)
 	}
 
-	user, err := repository.GetUser(username)
-	if err != nil {
-		return api.Failure(err)
-	}
-
-	if user.PurchasedCourses == nil {
-		user.PurchasedCourses = make(map[string]bool)
-	}
-
-	for _, id := range courseIds {
-		user.PurchasedCourses[id] = true
-	}
-
-	_, err = repository.UpdateUser(username, &database.UserUpdate{
-		PurchasedCourses: &user.PurchasedCourses,
+	_, err := repository.ModifyUser(username, func(user *database.User) (*database.UserUpdate, error) {
+		if user.PurchasedCourses == nil {
+			user.PurchasedCourses = make(map[string]bool)
+		}
+		for _, id := range courseIds {
+			user.PurchasedCourses[id] = true
+		}
+		return &database.UserUpdate{PurchasedCourses: &user.PurchasedCourses}, nil
 	})
 	if err != nil {
 		return api.Failure(err)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository = database.DynamoDB
+
+var dryRun = flag.Bool("dryRun", false, "Print the users whose custom ratings would be migrated without saving them")
+
+func main() {
+	flag.Parse()
+
+	var users []*database.User
+	var startKey string
+	var err error
+
+	updated := 0
+	failed := 0
+
+	for ok := true; ok; ok = startKey != "" {
+		fmt.Println("StartKey: ", startKey)
+		users, startKey, err = repository.ScanUsers(startKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, u := range users {
+			if database.MigrateCustomRatings(u) == nil {
+				continue
+			}
+			if *dryRun {
+				fmt.Printf("Would migrate custom ratings of user %s\n", u.Username)
+				updated += 1
+				continue
+			}
+
+			_, err := repository.ModifyUser(u.Username, func(user *database.User) (*database.UserUpdate, error) {
+				return database.MigrateCustomRatings(user), nil
+			})
+			if err != nil {
+				failed += 1
+				fmt.Printf("Failed to update user %s: %v\n", u.Username, err)
+			} else {
+				updated += 1
+			}
+		}
+	}
+
+	fmt.Printf("Success: %d updated, %d failed\n", updated, failed)
+}
@@ -30,16 +30,21 @@ type BanPlayerRequest struct {
 
 	// The section the player is in
 	Section string `json:"section"`
+
+	// The reason for the action, which is saved in the audit log
+	Reason string `json:"reason"`
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentPlayersWrite),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := BanPlayerRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)), nil
@@ -55,21 +60,6 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: section is required", "")), nil
 	}
 
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		err := errors.New(400, "Invalid request: username is required", "")
-		return api.Failure(err), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		err := errors.New(403, "Invalid request: you are not a tournament admin", "")
-		return api.Failure(err), nil
-	}
-
 	openClassical, err := repository.GetOpenClassical(database.CurrentLeaderboard)
 	if err != nil {
 		return api.Failure(err), nil
@@ -96,6 +86,7 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		}
 	}
 
+	before := player
 	player.Status = database.OpenClassicalPlayerStatus_Banned
 	player.LastActiveRound = lastActiveRound
 
@@ -103,5 +94,11 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if err != nil {
 		return api.Failure(err), nil
 	}
+
+	entry := database.NewAuditEntry(api.UserFromContext(ctx).Username, database.AuditAction_OpenClassicalBanPlayer,
+		database.OpenClassicalPlayerAuditTarget(player.LichessUsername), request.Reason)
+	if err := repository.CreateAuditEntry(entry, before, player); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(openClassical), nil
 }
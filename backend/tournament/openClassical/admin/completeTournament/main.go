@@ -16,16 +16,19 @@ var repository = database.DynamoDB
 
 type CompleteTournamentRequest struct {
 	NextStartDate string `json:"nextStartDate"`
+	Reason        string `json:"reason"`
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentComplete),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := CompleteTournamentRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		return api.Failure(errors.New(400, "Invalid request: failed to unmarshal body", "")), nil
@@ -34,19 +37,6 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: nextStartDate is required", "")), nil
 	}
 
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		return api.Failure(errors.New(403, "Invalid request: you are not a tournament admin", "")), nil
-	}
-
 	openClassical, err := repository.GetOpenClassical(database.CurrentLeaderboard)
 	if err != nil {
 		return api.Failure(err), nil
@@ -55,6 +45,7 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: the tournament is still accepting registrations", "")), nil
 	}
 
+	before := openClassical.AuditSummary()
 	openClassical.StartsAt = openClassical.StartMonth
 	openClassical.Name = openClassical.StartsAt
 
@@ -77,5 +68,11 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if err := repository.SetOpenClassical(openClassical); err != nil {
 		return api.Failure(err), nil
 	}
+
+	entry := database.NewAuditEntry(api.UserFromContext(ctx).Username, database.AuditAction_OpenClassicalComplete,
+		database.OpenClassicalAuditTarget(database.CurrentLeaderboard), request.Reason)
+	if err := repository.CreateAuditEntry(entry, before, openClassical.AuditSummary()); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(openClassical), nil
 }
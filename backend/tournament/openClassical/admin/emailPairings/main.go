@@ -35,34 +35,21 @@ var repository = database.DynamoDB
 var Ses = ses.New(session.Must(session.NewSession()))
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentPairingsEmail),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	var request EmailPairingsRequest
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		err = errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)
 		return api.Failure(err), nil
 	}
 
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		err := errors.New(400, "Invalid request: username is required", "")
-		return api.Failure(err), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		err := errors.New(403, "Invalid request: you are not a tournament admin", "")
-		return api.Failure(err), nil
-	}
-
 	openClassical, err := repository.GetOpenClassical(database.CurrentLeaderboard)
 	if err != nil {
 		return api.Failure(err), nil
@@ -28,52 +28,54 @@ type SetPairingsRequest struct {
 	Section            string `json:"section"`
 	Round              int    `json:"round"`
 	CsvData            string `json:"csvData"`
+	Reason             string `json:"reason"`
 }
 
 var repository = database.DynamoDB
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentPairingsWrite),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		return api.Failure(errors.New(403, "Invalid request: you are not a tournament admin", "")), nil
-	}
-
 	request := SetPairingsRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)), nil
 	}
 
+	actor := api.UserFromContext(ctx).Username
 	if request.CloseRegistrations {
-		return handleCloseRegistrations(), nil
+		return handleCloseRegistrations(actor, request.Reason), nil
 	}
 
-	return handlePairings(request), nil
+	return handlePairings(actor, request), nil
 }
 
-func handleCloseRegistrations() api.Response {
+func handleCloseRegistrations(actor, reason string) api.Response {
+	before, err := repository.GetOpenClassical(database.CurrentLeaderboard)
+	if err != nil {
+		return api.Failure(err)
+	}
+
 	openClassical, err := repository.OpenClassicalCloseRegistrations()
 	if err != nil {
 		return api.Failure(err)
 	}
+
+	entry := database.NewAuditEntry(actor, database.AuditAction_OpenClassicalCloseRegistrations,
+		database.OpenClassicalAuditTarget(database.CurrentLeaderboard), reason)
+	if err := repository.CreateAuditEntry(entry, before.AuditSummary(), openClassical.AuditSummary()); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(openClassical)
 }
 
-func handlePairings(request SetPairingsRequest) api.Response {
+func handlePairings(actor string, request SetPairingsRequest) api.Response {
 	if request.Region == "" {
 		return api.Failure(errors.New(400, "Invalid request: region is required", ""))
 	}
@@ -99,15 +101,24 @@ func handlePairings(request SetPairingsRequest) api.Response {
 
 	sectionName := fmt.Sprintf("%s_%s", request.Region, request.Section)
 	section := openClassical.Sections[sectionName]
+	var before []database.OpenClassicalPairing
 	if request.Round-1 >= len(section.Rounds) {
 		openClassical, err = repository.OpenClassicalAddRound(request.Region, request.Section, pairings)
 	} else {
+		before = section.Rounds[request.Round-1].Pairings
 		openClassical, err = repository.OpenClassicalSetRound(request.Region, request.Section, request.Round-1, pairings)
 	}
 
 	if err != nil {
 		return api.Failure(err)
 	}
+
+	path := fmt.Sprintf("sections.%s.rounds.%d.pairings", sectionName, request.Round-1)
+	entry := database.NewAuditEntry(actor, database.AuditAction_OpenClassicalSetPairings,
+		database.OpenClassicalAuditTarget(database.CurrentLeaderboard), request.Reason)
+	if err := repository.CreateAuditEntry(entry, map[string]interface{}{path: before}, map[string]interface{}{path: pairings}); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(openClassical)
 }
 
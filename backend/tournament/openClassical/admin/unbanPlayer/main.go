@@ -6,6 +6,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
@@ -19,16 +20,21 @@ var repository = database.DynamoDB
 type UnbanPlayerRequest struct {
 	// The Lichess username of the player to unban
 	LichessUsername string `json:"lichessUsername"`
+
+	// The reason for the action, which is saved in the audit log
+	Reason string `json:"reason"`
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentPlayersWrite),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := UnbanPlayerRequest{}
 	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)), nil
@@ -38,24 +44,24 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: lichessUsername is required", "")), nil
 	}
 
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		err := errors.New(400, "Invalid request: username is required", "")
-		return api.Failure(err), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
+	openClassical, err := repository.GetOpenClassical(database.CurrentLeaderboard)
 	if err != nil {
 		return api.Failure(err), nil
 	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		err := errors.New(403, "Invalid request: you are not a tournament admin", "")
-		return api.Failure(err), nil
+	var before interface{}
+	if player, ok := openClassical.BannedPlayers[strings.ToLower(request.LichessUsername)]; ok {
+		before = player
 	}
 
-	openClassical, err := repository.UnbanPlayer(request.LichessUsername)
+	openClassical, err = repository.UnbanPlayer(request.LichessUsername)
 	if err != nil {
 		return api.Failure(err), nil
 	}
+
+	entry := database.NewAuditEntry(api.UserFromContext(ctx).Username, database.AuditAction_OpenClassicalUnbanPlayer,
+		database.OpenClassicalPlayerAuditTarget(request.LichessUsername), request.Reason)
+	if err := repository.CreateAuditEntry(entry, before, nil); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
 	return api.Success(openClassical), nil
 }
@@ -34,16 +34,21 @@ type VerifyResultRequest struct {
 
 	// The result to set
 	Result string `json:"result"`
+
+	// The reason for the action, which is saved in the audit log
+	Reason string `json:"reason"`
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionTournamentResultsWrite),
+	))
 }
 
 func handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	request := &VerifyResultRequest{}
 	if err := json.Unmarshal([]byte(event.Body), request); err != nil {
 		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal body", "", err)), nil
@@ -64,21 +69,6 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: result is required", "")), nil
 	}
 
-	info := api.GetUserInfo(event)
-	if info.Username == "" {
-		err := errors.New(400, "Invalid request: username is required", "")
-		return api.Failure(err), nil
-	}
-
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	if !user.IsAdmin && !user.IsTournamentAdmin {
-		err := errors.New(403, "Invalid request: you are not a tournament admin", "")
-		return api.Failure(err), nil
-	}
-
 	openClassical, err := repository.GetOpenClassical(database.CurrentLeaderboard)
 	if err != nil {
 		return api.Failure(err), nil
@@ -88,12 +78,20 @@ func handler(ctx context.Context, event api.Request) (api.Response, error) {
 	if err != nil {
 		return api.Failure(err), nil
 	}
+	path := fmt.Sprintf("sections.%s_%s.rounds.%d.pairings.%d", update.Region, update.Section, update.Round, update.PairingIndex)
+	before := openClassical.Sections[fmt.Sprintf("%s_%s", update.Region, update.Section)].Rounds[update.Round].Pairings[update.PairingIndex]
 
 	openClassical, err = repository.UpdateOpenClassicalResult(update)
 	if err != nil {
 		return api.Failure(err), nil
 	}
 
+	entry := database.NewAuditEntry(api.UserFromContext(ctx).Username, database.AuditAction_OpenClassicalVerifyResult,
+		database.OpenClassicalAuditTarget(database.CurrentLeaderboard), request.Reason)
+	if err := repository.CreateAuditEntry(entry, map[string]interface{}{path: before}, map[string]interface{}{path: update.Pairing}); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
+
 	return api.Success(openClassical), nil
 }
 
@@ -9,7 +9,6 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
 )
@@ -31,7 +30,9 @@ var validTitles = []string{
 }
 
 type RegisterRequest struct {
-	Email           string `json:"email"`
+	Email string `json:"email"`
+
+	// The caller's verified Lichess username, which overrides the username sent by the client.
 	LichessUsername string `json:"lichessUsername"`
 	LichessRating   int    `json:"-"`
 	DiscordUsername string `json:"discordUsername"`
@@ -42,13 +43,10 @@ type RegisterRequest struct {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 
 	request := &RegisterRequest{}
@@ -60,6 +58,20 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 		request.Email = info.Email
 	}
 
+	if info.Username == "" {
+		err := errors.New(401, "You must sign in and verify your Lichess account to register", "")
+		return api.Failure(err), nil
+	}
+	user, err := repository.GetUser(info.Username)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	if !user.IsAccountVerified(database.Lichess) {
+		err := errors.New(400, "You must verify your Lichess account on your profile to register", "", errors.WithReason(errors.ReasonAccountNotVerified))
+		return api.Failure(err), nil
+	}
+	request.LichessUsername = user.Ratings[database.Lichess].Username
+
 	if err := checkRequest(request); err != nil {
 		return api.Failure(err), nil
 	}
@@ -134,11 +146,14 @@ func checkRequest(req *RegisterRequest) error {
 		errors.New(400, "Invalid request: title is not in list of valid titles", "")
 	}
 
-	rating, err := ratings.FetchLichessRating(req.LichessUsername)
+	rating, err := ratings.Lichess.FetchRating(req.LichessUsername)
+	if err != nil {
+		return err
+	}
 	if req.Section == "U1900" && rating.CurrentRating >= 1900 {
 		return errors.New(400, "Your Lichess rating is above 1900. Please register for the open section instead.", "")
 	}
 
 	req.LichessRating = rating.CurrentRating
-	return err
+	return nil
 }
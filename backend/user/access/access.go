@@ -70,7 +70,7 @@ func IsForbidden(email string, timeout time.Duration) (bool, error) {
 	}
 
 	if len(body.Subscriptions) == 0 {
-		return true, errors.New(403, fmt.Sprintf("Not Authorized: no active subscriptions found on https://chessdojo.shop for email `%s`", email), "")
+		return true, errors.New(403, fmt.Sprintf("Not Authorized: no active subscriptions found on https://chessdojo.shop for email `%s`", email), "", errors.WithReason(errors.ReasonSubscriptionRequired))
 	}
 
 	return false, nil
@@ -0,0 +1,55 @@
+// Implements a Lambda handler which moves the caller to the lower cohort suggested by the
+// nightly graduation eligibility check after a sustained drop of their rating. A
+// CohortChange record is saved, and the record and the updated user are returned.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/graduation"
+)
+
+var repository database.CohortChangeCreator = database.DynamoDB
+
+type DemoteRequest struct {
+	// The cohort to move to. Must be the caller's suggested cohort.
+	Cohort database.DojoCohort `json:"cohort"`
+}
+
+type DemoteResponse struct {
+	CohortChange *database.CohortChange `json:"cohortChange"`
+	UserUpdate   *database.User         `json:"userUpdate"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := DemoteRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+	if caller.SuggestedCohort == "" || req.Cohort != caller.SuggestedCohort {
+		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` is not suggested for you", req.Cohort), "")), nil
+	}
+
+	reason := graduation.DemotionReason(caller, caller.CohortDropSince)
+	change, user, err := graduation.Demote(repository, caller, req.Cohort, reason, false)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(&DemoteResponse{CohortChange: change, UserUpdate: user}), nil
+}
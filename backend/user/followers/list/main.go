@@ -5,11 +5,9 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
@@ -21,13 +19,10 @@ type ListFollowersResponse struct {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
-func Handler(ctx context.Context, event events.APIGatewayV2HTTPRequest) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
+func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 	username := event.PathParameters["username"]
 	if username == "" {
 		err := errors.New(400, "Invalid request: username is required", "")
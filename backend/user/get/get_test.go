@@ -16,17 +16,12 @@ const testUsername = "test-getUser"
 const testEmail = "test@chess-dojo-scheduler.com"
 const testName = "Test Name"
 
-func setupSuite(t *testing.T) func(t *testing.T) {
-	_, err := database.DynamoDB.CreateUser(testUsername, testEmail, testName, database.SubscriptionStatus_Unknown)
-	if err != nil {
-		t.Errorf("Failed to create user: %v", err)
-	}
+func setupSuite(t *testing.T) {
+	repo := database.NewInMemory()
+	repository = repo
 
-	return func(t *testing.T) {
-		err := database.DynamoDB.DeleteUser(testUsername)
-		if err != nil {
-			t.Errorf("Failed to delete user: %v", err)
-		}
+	if _, err := repo.CreateUser(testUsername, testEmail, testName, database.SubscriptionStatus_Unknown); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
 	}
 }
 
@@ -57,8 +52,7 @@ func getEvent(testName string, username string, public bool) api.Request {
 }
 
 func TestGetUser(t *testing.T) {
-	teardownSuite := setupSuite(t)
-	defer teardownSuite(t)
+	setupSuite(t)
 
 	ctx := context.Background()
 
@@ -97,9 +91,10 @@ func TestGetUser(t *testing.T) {
 			public:   true,
 			wantCode: 200,
 			wantUser: &database.User{
-				Username:   testUsername,
-				DojoCohort: database.NoCohort,
-				Progress:   map[string]*database.RequirementProgress{},
+				Username:           testUsername,
+				SubscriptionStatus: database.SubscriptionStatus_Unknown,
+				DojoCohort:         database.NoCohort,
+				Progress:           map[string]*database.RequirementProgress{},
 			},
 		},
 		{
@@ -108,9 +103,10 @@ func TestGetUser(t *testing.T) {
 			public:   false,
 			wantCode: 200,
 			wantUser: &database.User{
-				Username:   testUsername,
-				DojoCohort: database.NoCohort,
-				Progress:   map[string]*database.RequirementProgress{},
+				Username:           testUsername,
+				SubscriptionStatus: database.SubscriptionStatus_Unknown,
+				DojoCohort:         database.NoCohort,
+				Progress:           map[string]*database.RequirementProgress{},
 			},
 		},
 	}
@@ -133,7 +129,7 @@ func TestGetUser(t *testing.T) {
 				gotUser := &database.User{}
 				json.Unmarshal([]byte(got.Body), gotUser)
 
-				if diff := cmp.Diff(tc.wantUser, gotUser, cmpopts.IgnoreFields(database.User{}, "CreatedAt", "UpdatedAt")); diff != "" {
+				if diff := cmp.Diff(tc.wantUser, gotUser, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(database.User{}, "CreatedAt", "UpdatedAt")); diff != "" {
 					t.Errorf("GetUser(%v) diff (-want +got):\n%s", event, diff)
 				}
 			}
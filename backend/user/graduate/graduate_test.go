@@ -22,10 +22,9 @@ var testUser = &database.User{
 	DisplayName:     "testDisplayName",
 	DiscordUsername: "testDiscord",
 	RatingSystem:    database.Chesscom,
-	// ChesscomUsername:      "testChesscom",
-	// LichessUsername:       "testLichess",
-	// StartChesscomRating:   500,
-	// CurrentChesscomRating: 2300,
+	Ratings: map[database.RatingSystem]*database.Rating{
+		database.Chesscom: {Username: "testChesscom", StartRating: 500, CurrentRating: 2300},
+	},
 	DojoCohort:          "2300-2400",
 	LastGraduatedAt:     "testLastGraduatedAt",
 	NumberOfGraduations: 2,
@@ -45,14 +44,14 @@ var testUserAfterGraduation = &database.User{
 	DisplayName:     "testDisplayName",
 	DiscordUsername: "testDiscord",
 	RatingSystem:    database.Chesscom,
-	// ChesscomUsername:      "testChesscom",
-	// LichessUsername:       "testLichess",
-	// StartChesscomRating:   500,
-	// CurrentChesscomRating: 2300,
+	Ratings: map[database.RatingSystem]*database.Rating{
+		database.Chesscom: {Username: "testChesscom", StartRating: 500, CurrentRating: 2300},
+	},
 	DojoCohort:          "2400+",
 	LastGraduatedAt:     "Unknown",
 	NumberOfGraduations: 3,
 	PreviousCohort:      "2300-2400",
+	GraduationCohorts:   []database.DojoCohort{"2300-2400"},
 	Progress: map[string]*database.RequirementProgress{
 		"38f46441-7a4e-4506-8632-166bcbe78baf": {
 			RequirementId: "38f46441-7a4e-4506-8632-166bcbe78baf",
@@ -64,15 +63,44 @@ var testUserAfterGraduation = &database.User{
 	UpdatedAt: "Unknown",
 }
 
-func setupSuite(t *testing.T) func(t *testing.T) {
-	err := database.DynamoDB.SetUserConditional(testUser, nil)
-	if err != nil {
-		t.Errorf("Failed to create user: %v", err)
-	}
+// testRepository is the in-memory repository with the requirements of the test user's
+// cohort, as the repository has no method to save requirements.
+type testRepository struct {
+	database.GraduationCreator
+}
+
+func (r testRepository) ListRequirements(cohort database.DojoCohort, scoreboardOnly bool, startKey string) ([]*database.Requirement, string, error) {
+	return []*database.Requirement{
+		{
+			Id:              "38f46441-7a4e-4506-8632-166bcbe78baf",
+			Counts:          map[database.DojoCohort]int{"2300-2400": 25},
+			NumberOfCohorts: -1,
+			UnitScore:       1,
+		},
+	}, "", nil
+}
+
+// memoryMediaStore is a database.MediaStore which saves uploaded files in memory.
+type memoryMediaStore struct {
+	database.MediaStore
+	files map[string][]byte
+}
 
+func (m memoryMediaStore) UploadFile(key, contentType string, data []byte) error {
+	m.files[key] = data
 	return nil
 }
 
+func setupSuite(t *testing.T) {
+	repo := database.NewInMemory()
+	repository = testRepository{repo}
+	mediaStore = memoryMediaStore{files: make(map[string][]byte)}
+
+	if err := repo.SetUserConditional(testUser, nil); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+}
+
 func getEvent(testName string, username string, comments string) api.Request {
 	return api.Request{
 		RequestContext: events.APIGatewayV2HTTPRequestContext{
@@ -121,19 +149,22 @@ func TestGraduate(t *testing.T) {
 			comments: "These are the comments",
 			wantCode: 200,
 			wantGraduation: &database.Graduation{
-				Username:       testUsername,
-				DisplayName:    testUser.DisplayName,
-				PreviousCohort: testUser.DojoCohort,
-				NewCohort:      "2400+",
-				Score:          25,
-				RatingSystem:   database.Chesscom,
-				// StartRating:         testUser.StartChesscomRating,
-				// CurrentRating:       testUser.CurrentChesscomRating,
+				Type:                "GRADUATION",
+				Username:            testUsername,
+				DisplayName:         testUser.DisplayName,
+				PreviousCohort:      testUser.DojoCohort,
+				NewCohort:           "2400+",
+				Score:               25,
+				RatingSystem:        database.Chesscom,
+				StartRating:         500,
+				CurrentRating:       2300,
 				Comments:            "These are the comments",
 				Progress:            testUser.Progress,
 				StartedAt:           testUser.LastGraduatedAt,
 				CreatedAt:           "Unknown",
 				NumberOfGraduations: 3,
+				GraduationCohorts:   []database.DojoCohort{"2300-2400"},
+				RatingHistories:     map[database.RatingSystem][]database.RatingHistory{},
 			},
 			wantUser: testUserAfterGraduation,
 		},
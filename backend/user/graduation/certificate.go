@@ -0,0 +1,27 @@
+package graduation
+
+import (
+	"fmt"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/graduation/certificate"
+)
+
+// uploadCertificates renders the PNG and PDF certificates of the given graduation and
+// saves them in the media store. The keys of the PNG and PDF certificates are returned.
+// Graduating from the same cohort again replaces the certificates.
+func uploadCertificates(media database.MediaStore, graduation *database.Graduation) (string, string, error) {
+	pngData, pdfData, err := certificate.Render(graduation)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := fmt.Sprintf("/certificates/%s/%s", graduation.Username, graduation.PreviousCohort)
+	if err := media.UploadFile(key+".png", "image/png", pngData); err != nil {
+		return "", "", err
+	}
+	if err := media.UploadFile(key+".pdf", "application/pdf", pdfData); err != nil {
+		return "", "", err
+	}
+	return key + ".png", key + ".pdf", nil
+}
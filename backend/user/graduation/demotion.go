@@ -0,0 +1,105 @@
+package graduation
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// emptyCohort is used to clear the cohort fields of a user.
+var emptyCohort database.DojoCohort = ""
+
+// Demote moves the user from their current cohort to the lower newCohort. The user's
+// progress on requirements restricted to several cohorts is carried to newCohort, so
+// that work done in the higher cohort still counts towards the lower cohort's score. The
+// progress is carried from a fresh copy of the user, which is saved only if the user has
+// not changed in the meantime. A CohortChange record with the reason, the user's score in
+// both cohorts and their rating is saved before the user, so that every demotion has a
+// record, and the record and the updated user are returned. The record's key is fixed
+// before reading the user, so a retry after a conflicting write replaces the record
+// instead of adding another. automatic is saved on the record and is true if the user
+// did not accept a suggestion themselves.
+func Demote(repository database.CohortChangeCreator, user *database.User, newCohort database.DojoCohort, reason string, automatic bool) (*database.CohortChange, *database.User, error) {
+	previous := user.DojoCohort
+	index := slices.Index(database.Cohorts, newCohort)
+	if index < 0 || index >= slices.Index(database.Cohorts, previous) {
+		return nil, nil, errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` is not below `%s`", newCohort, previous), "")
+	}
+
+	previousRequirements, err := listRequirements(repository, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+	newRequirements, err := listRequirements(repository, newCohort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var change database.CohortChange
+	createdAt := time.Now().Format(time.RFC3339)
+	user, err = repository.ModifyUser(user.Username, func(user *database.User) (*database.UserUpdate, error) {
+		if user.DojoCohort != previous {
+			return nil, errors.New(409, fmt.Sprintf("Invalid request: cohort changed from `%s` to `%s`", previous, user.DojoCohort), "")
+		}
+
+		progress := user.CarryProgress(newRequirements, previous, newCohort)
+		demoted := *user
+		demoted.DojoCohort = newCohort
+		demoted.Progress = progress
+
+		startedAt := user.LastGraduatedAt
+		if startedAt == "" {
+			startedAt = user.CreatedAt
+		}
+		_, currentRating := user.GetRatings()
+
+		change = database.CohortChange{
+			Username:       user.Username,
+			DisplayName:    user.DisplayName,
+			Type:           database.CohortChangeType_Demotion,
+			PreviousCohort: previous,
+			NewCohort:      newCohort,
+			Reason:         reason,
+			Automatic:      automatic,
+			RatingSystem:   user.RatingSystem,
+			CurrentRating:  currentRating,
+			Score:          user.CalculateScore(previousRequirements),
+			NewScore:       demoted.CalculateScore(newRequirements),
+			StartedAt:      startedAt,
+			CreatedAt:      createdAt,
+		}
+		if err := repository.PutCohortChange(&change); err != nil {
+			return nil, err
+		}
+
+		// The graduation notification is cleared so that the user is notified again when
+		// their rating reaches the next cohort.
+		return &database.UserUpdate{
+			DojoCohort:               &newCohort,
+			Progress:                 &progress,
+			CohortDropSince:          aws.String(""),
+			SuggestedCohort:          &emptyCohort,
+			GraduationEligibleSince:  aws.String(""),
+			GraduationNotifiedCohort: &emptyCohort,
+		}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &change, user, nil
+}
+
+// DemotionReason returns the reason saved on a CohortChange when the user's rating in
+// their preferred rating system has stayed below their cohort since dropSince.
+func DemotionReason(user *database.User, dropSince string) string {
+	_, currentRating := user.GetRatings()
+	since := dropSince
+	if t, err := time.Parse(time.RFC3339, dropSince); err == nil {
+		since = t.Format(time.DateOnly)
+	}
+	return fmt.Sprintf("%s rating of %d has been below the %s cohort since %s", user.RatingSystem, currentRating, user.DojoCohort, since)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/graduation"
+)
+
+// memoryMediaStore is a database.MediaStore which saves files in memory.
+type memoryMediaStore map[string][]byte
+
+func (m memoryMediaStore) UploadImage(key, imageData string) error {
+	m[key] = []byte(imageData)
+	return nil
+}
+
+func (m memoryMediaStore) UploadFile(key, contentType string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func (m memoryMediaStore) CopyImageFromURL(url, key string) error {
+	m[key] = []byte(url)
+	return nil
+}
+
+func (m memoryMediaStore) DeleteImage(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memoryMediaStore) Download(bucket, key string, file *os.File) error {
+	_, err := file.Write(m[key])
+	return err
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC)
+	cohort := database.DojoCohort("1400-1500")
+
+	for _, autoGraduate := range []bool{false, true} {
+		repo := database.NewInMemory()
+		if _, err := repo.CreateUser("test", "test@example.com", "Test", database.SubscriptionStatus_Subscribed); err != nil {
+			t.Fatalf("CreateUser got err: %v", err)
+		}
+		ratingSystem := database.Uscf
+		ratings := map[database.RatingSystem]*database.Rating{database.Uscf: {Username: "1", CurrentRating: 1650}}
+		discordUsername := "test"
+		if _, err := repo.UpdateUser("test", &database.UserUpdate{DojoCohort: &cohort, RatingSystem: &ratingSystem, Ratings: &ratings, DiscordUsername: &discordUsername}); err != nil {
+			t.Fatalf("UpdateUser got err: %v", err)
+		}
+
+		var messages []string
+		media := memoryMediaStore{}
+		e := &evaluator{
+			repository: repo,
+			media:      media,
+			boundaries: &database.DefaultCohortBoundaries,
+			settings:   &database.GraduationSettings{SustainedDays: 7, AutoGraduate: autoGraduate},
+			sendDiscord: func(user *database.User, message string) error {
+				messages = append(messages, message)
+				return nil
+			},
+		}
+
+		// evaluateAt evaluates the user as returned by ListUserRatings at the given time.
+		evaluateAt := func(at time.Time) *database.User {
+			e.now = at
+			users, _, err := repo.ListUserRatings(cohort, "")
+			if err != nil {
+				t.Fatalf("ListUserRatings got err: %v", err)
+			}
+			for _, user := range users {
+				if err := e.evaluate(user); err != nil {
+					t.Fatalf("evaluate got err: %v", err)
+				}
+			}
+			user, err := repo.GetUser("test")
+			if err != nil {
+				t.Fatalf("GetUser got err: %v", err)
+			}
+			return user
+		}
+
+		user := evaluateAt(now)
+		if user.GraduationEligibleSince != "2024-10-10T06:00:00Z" || len(messages) != 0 {
+			t.Errorf("autoGraduate %v: first evaluation got eligible since %q and messages %v; want eligible since now and no messages", autoGraduate, user.GraduationEligibleSince, messages)
+		}
+
+		user = evaluateAt(now.AddDate(0, 0, 7))
+		if len(messages) != 1 || user.GraduationNotifiedCohort != "1500-1600" {
+			t.Errorf("autoGraduate %v: evaluation after 7 days got messages %v and notified cohort %q; want 1 message for 1500-1600", autoGraduate, messages, user.GraduationNotifiedCohort)
+		}
+		if autoGraduate && (user.DojoCohort != "1500-1600" || user.GraduationEligibleSince != "") {
+			t.Errorf("autoGraduate %v: got cohort %q and eligible since %q; want 1500-1600 and empty", autoGraduate, user.DojoCohort, user.GraduationEligibleSince)
+		}
+		if _, ok := media["/certificates/test/1400-1500.pdf"]; ok != autoGraduate {
+			t.Errorf("autoGraduate %v: got certificate saved %v; want %v", autoGraduate, ok, autoGraduate)
+		}
+		if autoGraduate {
+			entries, _, err := repo.ListTimelineEntries("test", "")
+			if err != nil || len(entries) != 1 || entries[0].GraduationInfo == nil || entries[0].GraduationInfo.CertificatePngKey != "/certificates/test/1400-1500.png" {
+				t.Errorf("autoGraduate %v: ListTimelineEntries got %+v, %v; want a graduation entry linking the certificate", autoGraduate, entries, err)
+			}
+		}
+		if !autoGraduate && user.DojoCohort != cohort {
+			t.Errorf("autoGraduate %v: got cohort %q; want %q", autoGraduate, user.DojoCohort, cohort)
+		}
+
+		notifications, _, err := repo.ListNotifications("test", "")
+		if err != nil || len(notifications) != 1 || notifications[0].GraduationMetadata == nil || notifications[0].GraduationMetadata.Graduated != autoGraduate {
+			t.Errorf("autoGraduate %v: ListNotifications got %+v, %v; want a single graduation notification", autoGraduate, notifications, err)
+		}
+
+		if !autoGraduate {
+			evaluateAt(now.AddDate(0, 0, 8))
+			if len(messages) != 1 {
+				t.Errorf("autoGraduate %v: evaluation after 8 days got messages %v; want no new messages", autoGraduate, messages)
+			}
+		}
+	}
+}
+
+func TestEvaluateDemotion(t *testing.T) {
+	now := time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC)
+	cohort := database.DojoCohort("1400-1500")
+
+	for _, mode := range []database.DemotionMode{database.DemotionMode_Suggest, database.DemotionMode_Automatic} {
+		repo := database.NewInMemory()
+		if _, err := repo.CreateUser("test", "test@example.com", "Test", database.SubscriptionStatus_Subscribed); err != nil {
+			t.Fatalf("CreateUser got err: %v", err)
+		}
+		ratingSystem := database.Uscf
+		ratings := map[database.RatingSystem]*database.Rating{database.Uscf: {Username: "1", CurrentRating: 1400}}
+		if _, err := repo.UpdateUser("test", &database.UserUpdate{DojoCohort: &cohort, RatingSystem: &ratingSystem, Ratings: &ratings}); err != nil {
+			t.Fatalf("UpdateUser got err: %v", err)
+		}
+
+		e := &evaluator{
+			repository: repo,
+			boundaries: &database.DefaultCohortBoundaries,
+			settings:   &database.GraduationSettings{SustainedDays: 7, DemotionMode: mode, DemotionMargin: 100, DemotionSustainedDays: 30},
+		}
+
+		// evaluateAt evaluates the user as returned by ListUserRatings at the given time.
+		evaluateAt := func(at time.Time) *database.User {
+			e.now = at
+			users, _, err := repo.ListUserRatings(cohort, "")
+			if err != nil {
+				t.Fatalf("ListUserRatings got err: %v", err)
+			}
+			for _, user := range users {
+				if err := e.evaluate(user); err != nil {
+					t.Fatalf("evaluate got err: %v", err)
+				}
+			}
+			user, err := repo.GetUser("test")
+			if err != nil {
+				t.Fatalf("GetUser got err: %v", err)
+			}
+			return user
+		}
+
+		user := evaluateAt(now)
+		if user.CohortDropSince != "2024-10-10T06:00:00Z" || user.SuggestedCohort != "" || e.suggested != 0 {
+			t.Errorf("%s: first evaluation got drop since %q and suggested cohort %q; want drop since now and no suggestion", mode, user.CohortDropSince, user.SuggestedCohort)
+		}
+
+		user = evaluateAt(now.AddDate(0, 0, 30))
+		if e.suggested != 1 {
+			t.Errorf("%s: evaluation after 30 days suggested %d users; want 1", mode, e.suggested)
+		}
+
+		notifications, _, err := repo.ListNotifications("test", "")
+		if err != nil || len(notifications) != 1 || notifications[0].CohortChangeMetadata == nil ||
+			notifications[0].CohortChangeMetadata.NewCohort != "1200-1300" || notifications[0].CohortChangeMetadata.Changed != (mode == database.DemotionMode_Automatic) {
+			t.Errorf("%s: ListNotifications got %+v, %v; want a single cohort change notification", mode, notifications, err)
+		}
+
+		changes, _, err := repo.ListCohortChangesByOwner("test", "")
+		if err != nil {
+			t.Fatalf("ListCohortChangesByOwner got err: %v", err)
+		}
+		if mode == database.DemotionMode_Suggest {
+			if user.DojoCohort != cohort || user.SuggestedCohort != "1200-1300" || len(changes) != 0 {
+				t.Errorf("%s: got cohort %q, suggested cohort %q and changes %+v; want %q, 1200-1300 and no changes", mode, user.DojoCohort, user.SuggestedCohort, changes, cohort)
+			}
+
+			evaluateAt(now.AddDate(0, 0, 31))
+			if e.suggested != 1 {
+				t.Errorf("%s: evaluation after 31 days suggested %d users; want 1", mode, e.suggested)
+			}
+			continue
+		}
+
+		if user.DojoCohort != "1200-1300" || user.SuggestedCohort != "" || user.CohortDropSince != "" {
+			t.Errorf("%s: got cohort %q, suggested cohort %q and drop since %q; want 1200-1300 and both cleared", mode, user.DojoCohort, user.SuggestedCohort, user.CohortDropSince)
+		}
+		if len(changes) != 1 || changes[0].Type != database.CohortChangeType_Demotion || changes[0].PreviousCohort != cohort ||
+			changes[0].NewCohort != "1200-1300" || !changes[0].Automatic || changes[0].Reason == "" {
+			t.Errorf("%s: ListCohortChangesByOwner got %+v; want an automatic demotion from %s to 1200-1300", mode, changes, cohort)
+		}
+	}
+}
+
+func TestEvaluateGraduatesOncePerRun(t *testing.T) {
+	repo := database.NewInMemory()
+	if _, err := repo.CreateUser("test", "test@example.com", "Test", database.SubscriptionStatus_Subscribed); err != nil {
+		t.Fatalf("CreateUser got err: %v", err)
+	}
+	cohort := database.DojoCohort("1400-1500")
+	ratingSystem := database.Uscf
+	ratings := map[database.RatingSystem]*database.Rating{database.Uscf: {Username: "1", CurrentRating: 2000}}
+	if _, err := repo.UpdateUser("test", &database.UserUpdate{DojoCohort: &cohort, RatingSystem: &ratingSystem, Ratings: &ratings}); err != nil {
+		t.Fatalf("UpdateUser got err: %v", err)
+	}
+
+	e := &evaluator{
+		repository:  repo,
+		media:       memoryMediaStore{},
+		boundaries:  &database.DefaultCohortBoundaries,
+		settings:    &database.GraduationSettings{AutoGraduate: true},
+		sendDiscord: func(user *database.User, message string) error { return nil },
+		now:         time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC),
+	}
+
+	// The user reaches each following cohort immediately, as in a run of the handler.
+	for _, c := range database.Cohorts {
+		users, _, err := repo.ListUserRatings(c, "")
+		if err != nil {
+			t.Fatalf("ListUserRatings got err: %v", err)
+		}
+		for _, user := range users {
+			if err := e.evaluate(user); err != nil {
+				t.Fatalf("evaluate got err: %v", err)
+			}
+		}
+	}
+
+	user, err := repo.GetUser("test")
+	if err != nil {
+		t.Fatalf("GetUser got err: %v", err)
+	}
+	if e.graduated != 1 || user.DojoCohort != "1500-1600" {
+		t.Errorf("evaluate graduated %d times to %q; want once to 1500-1600", e.graduated, user.DojoCohort)
+	}
+}
+
+// failingCohortChangeRepository is a repository which fails to save cohort changes.
+type failingCohortChangeRepository struct {
+	eligibilityRepository
+}
+
+func (r failingCohortChangeRepository) PutCohortChange(change *database.CohortChange) error {
+	return fmt.Errorf("PutCohortChange failed")
+}
+
+func TestDemoteWithoutRecord(t *testing.T) {
+	repo := database.NewInMemory()
+	user, err := repo.CreateUser("test", "test@example.com", "Test", database.SubscriptionStatus_Subscribed)
+	if err != nil {
+		t.Fatalf("CreateUser got err: %v", err)
+	}
+	cohort := database.DojoCohort("1400-1500")
+	ratingSystem := database.Uscf
+	ratings := map[database.RatingSystem]*database.Rating{database.Uscf: {Username: "1", CurrentRating: 1200}}
+	if user, err = repo.UpdateUser("test", &database.UserUpdate{DojoCohort: &cohort, RatingSystem: &ratingSystem, Ratings: &ratings}); err != nil {
+		t.Fatalf("UpdateUser got err: %v", err)
+	}
+
+	if _, _, err := graduation.Demote(failingCohortChangeRepository{repo}, user, "1200-1300", "reason", false); err == nil {
+		t.Errorf("Demote with a failing PutCohortChange got nil err; want an error")
+	}
+	user, err = repo.GetUser("test")
+	if err != nil || user.DojoCohort != cohort {
+		t.Errorf("GetUser after Demote failed got cohort %q, err %v; want %q", user.DojoCohort, err, cohort)
+	}
+}
@@ -0,0 +1,282 @@
+// Implements a Lambda handler which runs nightly after the rating update and checks
+// whether each user's rating in their preferred rating system has reached their next
+// cohort. Once it has stayed there for the number of days in the graduation settings,
+// the user is notified on the site and over Discord, or graduated automatically if
+// auto-graduation is enabled. Users are notified once per cohort.
+//
+// The handler also checks whether each user's rating has dropped further below their
+// cohort than the demotion margin. Once it has stayed there for the demotion's number
+// of days, the user is suggested the cohort of their rating, or moved to it
+// automatically, depending on the demotion mode.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/discord"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/graduation"
+)
+
+type Event events.CloudWatchEvent
+
+type eligibilityRepository interface {
+	database.GraduationCreator
+	database.CohortChangeCreator
+	database.NotificationPutter
+	database.CohortBoundariesGetter
+	database.GraduationSettingsEditor
+
+	ListUserRatings(cohort database.DojoCohort, startKey string) ([]*database.User, string, error)
+}
+
+var repository eligibilityRepository = database.DynamoDB
+
+var frontendHost = os.Getenv("frontendHost")
+
+type sendDiscordFunc func(user *database.User, message string) error
+
+// evaluator checks the graduation eligibility of users and accumulates the results.
+type evaluator struct {
+	repository  eligibilityRepository
+	media       database.MediaStore
+	boundaries  *database.CohortBoundaries
+	settings    *database.GraduationSettings
+	sendDiscord sendDiscordFunc
+	now         time.Time
+
+	// The users whose cohort was changed in this run. They are skipped when the run
+	// reaches their new cohort, so that they are graduated at most once per run.
+	changedCohort map[string]bool
+
+	updated   int
+	notified  int
+	graduated int
+	suggested int
+	demoted   int
+	failed    int
+}
+
+// result is the outcome of evaluating a user.
+type result struct {
+	// The user's new GraduationEligibleSince.
+	eligibleSince string
+
+	// Whether the user should be notified that they can graduate.
+	notify bool
+
+	// The user's new CohortDropSince.
+	dropSince string
+
+	// The user's new SuggestedCohort.
+	suggestedCohort database.DojoCohort
+}
+
+// evaluateUser returns the result of evaluating the given user at e.now.
+func (e *evaluator) evaluateUser(user *database.User) result {
+	since, sustained := database.EvaluateGraduationEligibility(user, e.boundaries, e.settings.SustainedDays, e.now)
+	r := result{
+		eligibleSince: since,
+		notify:        sustained && user.GraduationNotifiedCohort != user.DojoCohort.GetNextCohort(),
+	}
+
+	if e.settings.DemotionEnabled() {
+		dropSince, cohort, sustained := database.EvaluateCohortDrop(user, e.boundaries, e.settings.DemotionMargin, e.settings.DemotionSustainedDays, e.now)
+		r.dropSince = dropSince
+		if sustained {
+			r.suggestedCohort = cohort
+		}
+	}
+	return r
+}
+
+// changed returns true if the result must be saved on the given user.
+func (r result) changed(user *database.User) bool {
+	return r.notify ||
+		r.eligibleSince != user.GraduationEligibleSince ||
+		r.dropSince != user.CohortDropSince ||
+		r.suggestedCohort != user.SuggestedCohort
+}
+
+// evaluate saves the user's GraduationEligibleSince, CohortDropSince and SuggestedCohort
+// if they changed. It notifies the user, or graduates them, if they have been eligible
+// for the configured number of days and were not yet notified for their next cohort,
+// and suggests or moves them to a lower cohort if their rating dropped for the
+// configured number of days and the cohort was not yet suggested.
+func (e *evaluator) evaluate(user *database.User) error {
+	if e.changedCohort[user.Username] {
+		return nil
+	}
+
+	// The user returned by ListUserRatings is checked first, so that only users whose
+	// result changed are read again.
+	if !e.evaluateUser(user).changed(user) {
+		return nil
+	}
+
+	var r result
+	var suggest bool
+	updated, err := e.repository.ModifyUser(user.Username, func(user *database.User) (*database.UserUpdate, error) {
+		r = e.evaluateUser(user)
+		suggest = r.suggestedCohort != "" && r.suggestedCohort != user.SuggestedCohort
+		if !r.changed(user) {
+			return nil, nil
+		}
+
+		update := &database.UserUpdate{
+			GraduationEligibleSince: &r.eligibleSince,
+			CohortDropSince:         &r.dropSince,
+			SuggestedCohort:         &r.suggestedCohort,
+		}
+		if r.notify {
+			next := user.DojoCohort.GetNextCohort()
+			update.GraduationNotifiedCohort = &next
+		}
+		return update, nil
+	})
+	if err != nil {
+		return err
+	}
+	e.updated++
+
+	if r.notify {
+		e.notify(updated)
+	}
+	if suggest {
+		e.suggest(updated)
+	}
+	return nil
+}
+
+// notify graduates the user if auto-graduation is enabled and tells them they can
+// graduate, or have graduated, through the site and Discord. Failures are logged, as
+// the user is marked notified before notify is called.
+func (e *evaluator) notify(user *database.User) {
+	previous := user.DojoCohort
+	next := previous.GetNextCohort()
+	_, currentRating := user.GetRatings()
+
+	graduated := false
+	if e.settings.AutoGraduate {
+		if _, _, err := graduation.Graduate(e.repository, e.media, user, "", true); err != nil {
+			log.Errorf("Failed to graduate %q from %s: %v", user.Username, previous, err)
+		} else {
+			graduated = true
+			e.graduated++
+			e.markChanged(user)
+		}
+	}
+	e.notified++
+
+	if err := e.repository.PutNotification(database.GraduationEligibleNotification(user, previous, next, graduated)); err != nil {
+		log.Errorf("Failed to create graduation notification for %q: %v", user.Username, err)
+	}
+
+	if user.DiscordUsername == "" || user.NotificationSettings.DiscordNotificationSettings.GetDisableGraduationEligible() {
+		return
+	}
+	var message string
+	if graduated {
+		message = fmt.Sprintf("Congratulations! Your %s rating of %d has reached the %s cohort, so you have graduated from %s. Check out your new training plan at %s/profile",
+			user.RatingSystem, currentRating, next, previous, frontendHost)
+	} else {
+		message = fmt.Sprintf("Congratulations! Your %s rating of %d has reached the %s cohort. You can graduate from %s on your profile at %s/profile",
+			user.RatingSystem, currentRating, next, previous, frontendHost)
+	}
+	if err := e.sendDiscord(user, message); err != nil {
+		log.Errorf("Failed to send graduation Discord notification to %q: %v", user.Username, err)
+	}
+}
+
+// suggest moves the user to their SuggestedCohort if the demotion mode is automatic and
+// tells them they can move, or have moved, through the site. Failures are logged, as the
+// suggested cohort is saved before suggest is called.
+func (e *evaluator) suggest(user *database.User) {
+	previous := user.DojoCohort
+	cohort := user.SuggestedCohort
+
+	changed := false
+	if e.settings.DemotionMode == database.DemotionMode_Automatic {
+		reason := graduation.DemotionReason(user, user.CohortDropSince)
+		if _, _, err := graduation.Demote(e.repository, user, cohort, reason, true); err != nil {
+			log.Errorf("Failed to demote %q from %s to %s: %v", user.Username, previous, cohort, err)
+		} else {
+			changed = true
+			e.demoted++
+			e.markChanged(user)
+		}
+	}
+	e.suggested++
+
+	if err := e.repository.PutNotification(database.CohortChangeNotification(user, previous, cohort, changed)); err != nil {
+		log.Errorf("Failed to create cohort change notification for %q: %v", user.Username, err)
+	}
+}
+
+// markChanged records that the user's cohort was changed in this run.
+func (e *evaluator) markChanged(user *database.User) {
+	if e.changedCohort == nil {
+		e.changedCohort = make(map[string]bool)
+	}
+	e.changedCohort[user.Username] = true
+}
+
+func Handler(ctx context.Context, event Event) (Event, error) {
+	log.SetRequestId(event.ID)
+	log.Infof("Event: %#v", event)
+
+	now := time.Now()
+	boundaries, err := repository.GetCohortBoundaries(now)
+	if err != nil {
+		log.Errorf("Failed to get cohort boundaries: %v", err)
+		return event, err
+	}
+	settings, err := repository.GetGraduationSettings()
+	if err != nil {
+		log.Errorf("Failed to get graduation settings: %v", err)
+		return event, err
+	}
+	log.Infof("Graduation settings: %+v", settings)
+
+	e := &evaluator{
+		repository:  repository,
+		media:       database.S3,
+		boundaries:  boundaries,
+		settings:    settings,
+		sendDiscord: discord.SendNotification,
+		now:         now,
+	}
+
+	for _, cohort := range database.Cohorts {
+		var users []*database.User
+		var startKey string
+		for ok := true; ok; ok = startKey != "" {
+			users, startKey, err = repository.ListUserRatings(cohort, startKey)
+			if err != nil {
+				log.Errorf("Failed to list users in cohort %s: %v", cohort, err)
+				return event, err
+			}
+
+			for _, user := range users {
+				if err := e.evaluate(user); err != nil {
+					e.failed++
+					log.Errorf("Failed to evaluate graduation eligibility of %q: %v", user.Username, err)
+				}
+			}
+		}
+	}
+
+	log.Infof("Updated %d users, notified %d, graduated %d, suggested %d, demoted %d, failed %d",
+		e.updated, e.notified, e.graduated, e.suggested, e.demoted, e.failed)
+	return event, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
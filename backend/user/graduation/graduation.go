@@ -0,0 +1,180 @@
+// Package graduation moves users between cohorts. It graduates users to their next
+// cohort and demotes users to a lower cohort after a sustained rating drop, both when
+// the user asks to and when the nightly graduation eligibility check does so
+// automatically.
+package graduation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// Graduate moves the user from their current cohort to the next one. It saves a
+// Graduation record with the user's score, time spent and rating history in the cohort,
+// adds a graduation entry to the user's timeline and returns the record and the updated
+// user. The graduation's certificates are saved in the media store and linked from the
+// timeline entry. automatic is saved on the record and is true if the user did not
+// graduate themselves.
+func Graduate(repository database.GraduationCreator, media database.MediaStore, user *database.User, comments string, automatic bool) (*database.Graduation, *database.User, error) {
+	nextCohort := user.DojoCohort.GetNextCohort()
+	if nextCohort == database.NoCohort {
+		return nil, nil, errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` cannot graduate", user.DojoCohort), "")
+	}
+
+	requirements, err := listRequirements(repository, user.DojoCohort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	startedAt := user.LastGraduatedAt
+	if startedAt == "" {
+		startedAt = user.CreatedAt
+	}
+	now := time.Now()
+	createdAt := now.Format(time.RFC3339)
+
+	startRating, currentRating := user.GetRatings()
+
+	graduationCohorts := append(user.GraduationCohorts, user.DojoCohort)
+	var numberOfGraduations = user.NumberOfGraduations + 1
+
+	score := user.CalculateScore(requirements)
+	totalTime := user.TimeSpent()
+	dojoTime := user.TimeSpentOnReqs(requirements)
+	nonDojoTime := totalTime - dojoTime
+
+	log.Debugf("Total Time: %d, Dojo Time: %d, NonDojo Time: %d", totalTime, dojoTime, nonDojoTime)
+
+	ratingHistories, err := getRatingHistories(repository, user, startedAt, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graduation := database.Graduation{
+		Type:                "GRADUATION",
+		Username:            user.Username,
+		DisplayName:         user.DisplayName,
+		PreviousCohort:      user.DojoCohort,
+		NewCohort:           nextCohort,
+		Score:               score,
+		RatingSystem:        user.RatingSystem,
+		StartRating:         startRating,
+		CurrentRating:       currentRating,
+		Comments:            comments,
+		Automatic:           automatic,
+		Progress:            user.Progress,
+		StartedAt:           startedAt,
+		CreatedAt:           createdAt,
+		NumberOfGraduations: numberOfGraduations,
+		GraduationCohorts:   graduationCohorts,
+		DojoMinutes:         dojoTime,
+		NonDojoMinutes:      nonDojoTime,
+		RatingHistories:     ratingHistories,
+	}
+	if err := repository.PutGraduation(&graduation); err != nil {
+		return nil, nil, err
+	}
+
+	timelineEntry := database.TimelineEntry{
+		TimelineEntryKey: database.TimelineEntryKey{
+			Owner: user.Username,
+			Id:    fmt.Sprintf("%s_%s", now.Format(time.DateOnly), uuid.NewString()),
+		},
+		OwnerDisplayName:    user.DisplayName,
+		RequirementId:       "Graduation",
+		RequirementName:     fmt.Sprintf("Graduated from %s", user.DojoCohort),
+		RequirementCategory: "Graduation",
+		ScoreboardDisplay:   database.Hidden,
+		Cohort:              user.DojoCohort,
+		CreatedAt:           createdAt,
+		GraduationInfo: &database.TimelineGraduationInfo{
+			Comments:       comments,
+			DojoScore:      score,
+			NewCohort:      nextCohort,
+			DojoMinutes:    dojoTime,
+			NonDojoMinutes: nonDojoTime,
+		},
+	}
+	if pngKey, pdfKey, err := uploadCertificates(media, &graduation); err != nil {
+		log.Errorf("Failed to create graduation certificates: %v", err)
+	} else {
+		timelineEntry.GraduationInfo.CertificatePngKey = pngKey
+		timelineEntry.GraduationInfo.CertificatePdfKey = pdfKey
+	}
+	if err := repository.PutTimelineEntry(&timelineEntry); err != nil {
+		log.Debugf("Failed to create timeline entry: %v", err)
+	}
+
+	update := database.UserUpdate{
+		NumberOfGraduations: &numberOfGraduations,
+		LastGraduatedAt:     &createdAt,
+		DojoCohort:          &nextCohort,
+		PreviousCohort:      &user.DojoCohort,
+		GraduationCohorts:   &graduationCohorts,
+	}
+	if user.ProvisionalCohort {
+		update.ProvisionalCohort = aws.Bool(false)
+	}
+	if user.GraduationEligibleSince != "" {
+		update.GraduationEligibleSince = aws.String("")
+	}
+	if user.CohortDropSince != "" {
+		update.CohortDropSince = aws.String("")
+	}
+	if user.SuggestedCohort != "" {
+		update.SuggestedCohort = &emptyCohort
+	}
+	user, err = repository.UpdateUser(user.Username, &update)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &graduation, user, nil
+}
+
+// listRequirements returns all the scoreboard requirements of the given cohort.
+func listRequirements(repository database.RequirementLister, cohort database.DojoCohort) ([]*database.Requirement, error) {
+	var requirements []*database.Requirement
+	var startKey string
+	for ok := true; ok; ok = (startKey != "") {
+		reqs, lastKey, err := repository.ListRequirements(cohort, true, startKey)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, reqs...)
+		startKey = lastKey
+	}
+	return requirements, nil
+}
+
+// getRatingHistories returns the user's rating history in each rating system since
+// startedAt, including the legacy history saved on the user.
+func getRatingHistories(repository database.RatingHistoryLister, user *database.User, startedAt string, now time.Time) (map[database.RatingSystem][]database.RatingHistory, error) {
+	start, err := time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		log.Warnf("Failed to parse startedAt %q: %v", startedAt, err)
+		start = time.Time{}
+	}
+
+	ratingHistories := make(map[database.RatingSystem][]database.RatingHistory)
+	for system, rating := range user.Ratings {
+		if system == database.Custom || system == database.Custom2 || system == database.Custom3 || rating.Username == "" {
+			continue
+		}
+
+		history, err := repository.ListRatingHistory(user.Username, system, start, now)
+		if err != nil {
+			return nil, err
+		}
+		history = database.MergeLegacyRatingHistory(user.RatingHistories[system], history, start, now)
+		if len(history) > 0 {
+			ratingHistories[system] = history
+		}
+	}
+	return ratingHistories, nil
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
-	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
@@ -24,13 +23,10 @@ type UpdateTimelineRequest struct {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
@@ -63,11 +59,6 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 		}
 	}
 
-	user, err := repository.GetUser(info.Username)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-
 	if len(request.Deleted) > 0 {
 		_, err := repository.DeleteTimelineEntries(request.Deleted)
 		if err != nil {
@@ -83,22 +74,20 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 	}
 
 	// Update user's progress
-	found := false
-	for _, t := range user.CustomTasks {
-		if t.Id == request.RequirementId {
-			updateTaskProgress(request, user, t)
-			found = true
-			break
+	user, err := repository.ModifyUser(info.Username, func(user *database.User) (*database.UserUpdate, error) {
+		found := false
+		for _, t := range user.CustomTasks {
+			if t.Id == request.RequirementId {
+				updateTaskProgress(request, user, t)
+				found = true
+				break
+			}
 		}
-	}
-	if !found {
-		updateRequirementProgress(request, user)
-	}
-
-	update := &database.UserUpdate{
-		Progress: &user.Progress,
-	}
-	user, err = repository.UpdateUser(user.Username, update)
+		if !found {
+			updateRequirementProgress(request, user)
+		}
+		return &database.UserUpdate{Progress: &user.Progress}, nil
+	})
 	if err != nil {
 		return api.Failure(err), nil
 	}
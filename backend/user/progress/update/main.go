@@ -26,13 +26,10 @@ type ProgressUpdateRequest struct {
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest(), api.Idempotent(database.DynamoDB)))
 }
 
 func Handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
@@ -49,32 +46,46 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 		return api.Failure(errors.New(400, "Invalid request: cohort is required", "")), nil
 	}
 
-	user, err := repository.GetUser(info.Username)
+	var requirement *database.Requirement
+	var timelineEntry *database.TimelineEntry
+	user, err := repository.ModifyUserProgress(info.Username, func(user *database.User) (*database.RequirementProgress, error) {
+		var task database.Task
+		for _, t := range user.CustomTasks {
+			if t.Id == request.RequirementId {
+				task = t
+				break
+			}
+		}
+		if task == nil {
+			if requirement == nil {
+				var err error
+				if requirement, err = repository.GetRequirement(request.RequirementId); err != nil {
+					return nil, err
+				}
+			}
+			task = requirement
+		}
+
+		progress, entry, err := handleTask(request, user, task)
+		timelineEntry = entry
+		return progress, err
+	})
 	if err != nil {
 		return api.Failure(err), nil
 	}
 
-	for _, t := range user.CustomTasks {
-		if t.Id == request.RequirementId {
-			return handleTask(request, user, t)
-		}
-	}
-
-	return handleDefaultTask(request, user)
-}
-
-func handleDefaultTask(request *ProgressUpdateRequest, user *database.User) (api.Response, error) {
-	requirement, err := repository.GetRequirement(request.RequirementId)
-	if err != nil {
+	if err := repository.PutTimelineEntry(timelineEntry); err != nil {
 		return api.Failure(err), nil
 	}
-	return handleTask(request, user, requirement)
+	return api.Success(user), nil
 }
 
-func handleTask(request *ProgressUpdateRequest, user *database.User, task database.Task) (api.Response, error) {
+// handleTask applies the request to the user's progress on the given task. The updated progress
+// entry and the timeline entry recording the update are returned.
+func handleTask(request *ProgressUpdateRequest, user *database.User, task database.Task) (*database.RequirementProgress, *database.TimelineEntry, error) {
 	totalCount, ok := task.GetCounts()[request.Cohort]
 	if !ok {
-		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` does not apply to this requirement", request.Cohort), "")), nil
+		return nil, nil, errors.New(400, fmt.Sprintf("Invalid request: cohort `%s` does not apply to this requirement", request.Cohort), "")
 	}
 
 	progress, ok := user.Progress[request.RequirementId]
@@ -98,8 +109,11 @@ func handleTask(request *ProgressUpdateRequest, user *database.User, task databa
 		originalCount = progress.Counts[database.AllCohorts]
 		progress.Counts[database.AllCohorts] += request.IncrementalCount
 	} else {
-		originalCount = progress.Counts[request.Cohort]
-		progress.Counts[request.Cohort] += request.IncrementalCount
+		// A count carried from a higher cohort becomes the user's own count once they
+		// update their progress in the cohort.
+		originalCount = max(progress.Counts[request.Cohort], progress.CarriedCounts[request.Cohort])
+		progress.Counts[request.Cohort] = originalCount + request.IncrementalCount
+		delete(progress.CarriedCounts, request.Cohort)
 	}
 	progress.MinutesSpent[request.Cohort] += request.IncrementalMinutesSpent
 
@@ -144,13 +158,5 @@ func handleTask(request *ProgressUpdateRequest, user *database.User, task databa
 		Notes:               request.Notes,
 	}
 
-	if err := repository.PutTimelineEntry(timelineEntry); err != nil {
-		return api.Failure(err), nil
-	}
-
-	user, err := repository.UpdateUserProgress(user.Username, progress)
-	if err != nil {
-		return api.Failure(err), nil
-	}
-	return api.Success(user), nil
+	return progress, timelineEntry, nil
 }
@@ -0,0 +1,48 @@
+// Implements a Lambda handler which returns a paginated list of flagged rating
+// anomalies, oldest first. The anomalies are filtered by the query parameter status,
+// which defaults to OPEN. Pagination is handled by the query parameter startKey.
+//
+// The caller must have the ratingAnomalies.review permission.
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository database.RatingAnomalyReviewer = database.DynamoDB
+
+type ListRatingAnomaliesResponse struct {
+	Anomalies        []database.RatingAnomaly `json:"anomalies"`
+	LastEvaluatedKey string                   `json:"lastEvaluatedKey"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionRatingAnomaliesReview),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	status := database.RatingAnomalyStatus(event.QueryStringParameters["status"])
+	switch status {
+	case "":
+		status = database.RatingAnomalyStatus_Open
+	case database.RatingAnomalyStatus_Open, database.RatingAnomalyStatus_Dismissed, database.RatingAnomalyStatus_Enforced:
+	default:
+		return api.Failure(errors.New(400, "Invalid request: status must be OPEN, DISMISSED or ENFORCED", "")), nil
+	}
+
+	anomalies, lastKey, err := repository.ListRatingAnomalies(status, event.QueryStringParameters["startKey"])
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(ListRatingAnomaliesResponse{Anomalies: anomalies, LastEvaluatedKey: lastKey}), nil
+}
@@ -0,0 +1,169 @@
+// Implements a Lambda handler which resolves a flagged rating anomaly. Dismissing the
+// anomaly only closes it. Enforcing the anomaly also terminates the user's subscription
+// and sends them the fair play enforcement email. Both actions are recorded in the
+// audit log.
+//
+// The caller must have the ratingAnomalies.review permission.
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	payment "github.com/jackstenglein/chess-dojo-scheduler/backend/paymentService"
+)
+
+var repository database.RatingAnomalyReviewer = database.DynamoDB
+var sesInstance = ses.New(session.Must(session.NewSession()))
+
+// The SES template of the enforcement email, created by email/cheating.
+const enforcementTemplate = "fairPlayEnforcement"
+
+type ResolveRatingAnomalyRequest struct {
+	// The username of the flagged user.
+	Username string `json:"username"`
+
+	// The id of the anomaly.
+	Id string `json:"id"`
+
+	// Either `dismiss` or `enforce`.
+	Action string `json:"action"`
+
+	// The reason for the resolution, which is saved on the anomaly and in the audit log.
+	Reason string `json:"reason"`
+}
+
+type ResolveRatingAnomalyResponse struct {
+	// The anomaly after it was resolved.
+	Anomaly *database.RatingAnomaly `json:"anomaly"`
+
+	// Whether the enforcement email was sent. Always false for dismissed anomalies.
+	EmailSent bool `json:"emailSent"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+		api.RequirePermission(database.PermissionRatingAnomaliesReview),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := ResolveRatingAnomalyRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+	if req.Username == "" {
+		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
+	}
+	if req.Id == "" {
+		return api.Failure(errors.New(400, "Invalid request: id is required", "")), nil
+	}
+
+	anomaly, err := repository.GetRatingAnomaly(req.Username, req.Id)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	if anomaly.Status != database.RatingAnomalyStatus_Open {
+		return api.Failure(errors.New(400, "Invalid request: anomaly is already resolved", "")), nil
+	}
+
+	before := map[string]interface{}{"ratingAnomaly": map[string]interface{}{"id": anomaly.Id, "status": anomaly.Status}}
+	after := map[string]interface{}{}
+
+	var action database.AuditAction
+	var user *database.User
+	switch req.Action {
+	case "dismiss":
+		action = database.AuditAction_RatingAnomalyDismiss
+		anomaly, err = repository.ResolveRatingAnomaly(req.Username, req.Id, database.RatingAnomalyStatus_Dismissed, caller.Username, req.Reason)
+	case "enforce":
+		action = database.AuditAction_RatingAnomalyEnforce
+		if user, err = repository.GetUser(req.Username); err != nil {
+			return api.Failure(err), nil
+		}
+		before["subscriptionStatus"] = user.SubscriptionStatus
+		if err = downgradeSubscription(user); err != nil {
+			return api.Failure(err), nil
+		}
+		after["subscriptionStatus"] = database.SubscriptionStatus_FreeTier
+		anomaly, err = repository.ResolveRatingAnomaly(req.Username, req.Id, database.RatingAnomalyStatus_Enforced, caller.Username, req.Reason)
+	default:
+		err = errors.New(400, "Invalid request: action must be `dismiss` or `enforce`", "")
+	}
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	after["ratingAnomaly"] = map[string]interface{}{"id": anomaly.Id, "status": anomaly.Status}
+
+	entry := database.NewAuditEntry(caller.Username, action, database.UserAuditTarget(req.Username), req.Reason)
+	if err := repository.CreateAuditEntry(entry, before, after); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
+
+	emailSent := false
+	if user != nil {
+		if err := sendEnforcementEmail(user, anomaly); err != nil {
+			log.Errorf("Failed to send enforcement email to %q: %v", user.Username, err)
+		} else {
+			emailSent = true
+		}
+	}
+	return api.Success(ResolveRatingAnomalyResponse{Anomaly: anomaly, EmailSent: emailSent}), nil
+}
+
+// downgradeSubscription cancels the user's Stripe subscription, if they have one, and
+// moves them to the free tier.
+func downgradeSubscription(user *database.User) error {
+	if user.PaymentInfo.IsSubscribed() {
+		if err := payment.CancelSubscription(user.PaymentInfo.SubscriptionId); err != nil {
+			return err
+		}
+	}
+
+	_, err := repository.UpdateUser(user.Username, &database.UserUpdate{
+		SubscriptionStatus: aws.String(database.SubscriptionStatus_FreeTier),
+	})
+	return err
+}
+
+// sendEnforcementEmail sends the fair play enforcement email for the given anomaly to the user.
+func sendEnforcementEmail(user *database.User, anomaly *database.RatingAnomaly) error {
+	violation := "rating history shows signs of fair play violations"
+	switch anomaly.Type {
+	case database.RatingAnomalyType_FairPlayClosure:
+		violation = "Chess.com account has been closed for fair play violations"
+	case database.RatingAnomalyType_TosViolation:
+		violation = "Lichess account has been closed for violating its terms of service"
+	}
+
+	templateData, err := json.Marshal(map[string]string{"violation": violation})
+	if err != nil {
+		return errors.Wrap(500, "Temporary server error", "Failed to marshal template data", err)
+	}
+
+	input := &ses.SendTemplatedEmailInput{
+		Destination: &ses.Destination{
+			ToAddresses: []*string{aws.String(user.Email)},
+		},
+		Source:       aws.String("ChessDojo <no-reply@mail.chessdojo.club>"),
+		Template:     aws.String(enforcementTemplate),
+		TemplateData: aws.String(string(templateData)),
+	}
+	if _, err := sesInstance.SendTemplatedEmail(input); err != nil {
+		return errors.Wrap(500, "Temporary server error", "Failed to send templated email", err)
+	}
+	return nil
+}
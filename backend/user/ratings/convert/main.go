@@ -0,0 +1,89 @@
+// Implements a Lambda handler which converts a rating from one rating system into the
+// equivalent rating in another. The query parameters from and to are the rating systems,
+// and rating is the rating to convert. The optional query parameter date, in RFC3339
+// format, selects the cohort boundaries used for the conversion, which default to the
+// current boundaries. The response also contains the Dojo's normalized rating, which
+// can be used to compare ratings from any rating system.
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+var repository database.CohortBoundariesGetter = database.DynamoDB
+
+type ConvertRatingResponse struct {
+	// The rating system converted from.
+	From database.RatingSystem `json:"from"`
+
+	// The rating system converted to.
+	To database.RatingSystem `json:"to"`
+
+	// The rating in the from rating system.
+	Rating int `json:"rating"`
+
+	// The equivalent rating in the to rating system.
+	ConvertedRating int `json:"convertedRating"`
+
+	// The Dojo's normalized rating equivalent to the rating.
+	NormalizedRating float64 `json:"normalizedRating"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	from := database.RatingSystem(event.QueryStringParameters["from"])
+	to := database.RatingSystem(event.QueryStringParameters["to"])
+	if from == "" || to == "" {
+		return api.Failure(errors.New(400, "Invalid request: from and to are required", "")), nil
+	}
+
+	rating, err := strconv.Atoi(event.QueryStringParameters["rating"])
+	if err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: rating must be an integer", "", err)), nil
+	}
+	if rating < 0 {
+		return api.Failure(errors.New(400, "Invalid request: rating must be non-negative", "")), nil
+	}
+
+	date := time.Now()
+	if d := event.QueryStringParameters["date"]; d != "" {
+		if date, err = time.Parse(time.RFC3339, d); err != nil {
+			return api.Failure(errors.Wrap(400, "Invalid request: date must be in RFC3339 format", "", err)), nil
+		}
+	}
+	boundaries, err := repository.GetCohortBoundaries(date)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	normalized, err := boundaries.NormalizedRating(from, float64(rating))
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	converted, err := boundaries.RatingFromNormalized(to, normalized)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	return api.Success(ConvertRatingResponse{
+		From:             from,
+		To:               to,
+		Rating:           rating,
+		ConvertedRating:  int(math.Round(converted)),
+		NormalizedRating: math.Round(normalized*10) / 10,
+	}), nil
+}
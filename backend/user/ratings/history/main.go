@@ -0,0 +1,83 @@
+// Implements a Lambda handler which returns a user's rating history in a single rating
+// system, oldest first. The rating system is given by the query parameter system, and
+// the history can be limited by startDate and endDate in RFC3339 format. Recent history
+// contains a point per day, while older history is compacted into weekly and then
+// monthly points.
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+type RatingHistoryGetter interface {
+	database.UserGetter
+	database.RatingHistoryLister
+}
+
+var repository RatingHistoryGetter = database.DynamoDB
+
+type GetRatingHistoryResponse struct {
+	// The rating system of the history.
+	RatingSystem database.RatingSystem `json:"ratingSystem"`
+
+	// The rating history, oldest first.
+	History []database.RatingHistory `json:"history"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	username := event.PathParameters["username"]
+	if username == "" {
+		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
+	}
+
+	system := database.RatingSystem(event.QueryStringParameters["system"])
+	if system == "" {
+		return api.Failure(errors.New(400, "Invalid request: system is required", "")), nil
+	}
+
+	start, err := parseTime(event.QueryStringParameters["startDate"], time.Time{})
+	if err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: startDate must be in RFC3339 format", "", err)), nil
+	}
+	end, err := parseTime(event.QueryStringParameters["endDate"], time.Now())
+	if err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: endDate must be in RFC3339 format", "", err)), nil
+	}
+
+	user, err := repository.GetUser(username)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	history, err := repository.ListRatingHistory(username, system, start, end)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	history = database.MergeLegacyRatingHistory(user.RatingHistories[system], history, start, end)
+	if history == nil {
+		history = []database.RatingHistory{}
+	}
+
+	return api.Success(GetRatingHistoryResponse{RatingSystem: system, History: history}), nil
+}
+
+// parseTime returns the time represented by s, or def if s is empty.
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
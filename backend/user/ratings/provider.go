@@ -0,0 +1,294 @@
+package ratings
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// RatingProvider fetches ratings from a single rating system.
+type RatingProvider interface {
+	// FetchRating returns the current rating of the player with the given username
+	// or id. If the player does not exist, the returned error has reason
+	// errors.ReasonRatingNotFound. If the rating system could not be reached, the
+	// returned error has reason errors.ReasonRatingProviderUnavailable.
+	FetchRating(username string) (*database.Rating, error)
+}
+
+// RatingFetchFunc adapts an ordinary function to the RatingProvider interface.
+type RatingFetchFunc func(username string) (*database.Rating, error)
+
+// FetchRating calls f(username).
+func (f RatingFetchFunc) FetchRating(username string) (*database.Rating, error) {
+	return f(username)
+}
+
+// RetryPolicy controls how a provider retries requests that fail with a network
+// error, a 429 or a 5xx status.
+type RetryPolicy struct {
+	// The maximum number of requests sent, including the first.
+	MaxAttempts int
+
+	// The delay before the first retry. Each following retry doubles the delay.
+	BaseDelay time.Duration
+
+	// The maximum delay before a single retry.
+	MaxDelay time.Duration
+}
+
+// ProviderConfig configures the requests sent by a RatingProvider.
+type ProviderConfig struct {
+	// The scheme and host of the rating system's website or API, without a trailing slash.
+	BaseURL string
+
+	// The timeout of a single request.
+	Timeout time.Duration
+
+	// The policy used to retry failed requests.
+	Retry RetryPolicy
+
+	// The minimum time between the start of two requests to the provider.
+	MinInterval time.Duration
+
+	// How long fetched ratings are cached. Ratings are not cached if zero.
+	CacheTTL time.Duration
+
+	// The maximum number of requests sent to the provider at once by the nightly
+	// rating update. Requests are still spaced by MinInterval.
+	Concurrency int
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// defaultConfig returns a ProviderConfig with the default timeout, retry policy and
+// cache TTL, and the given base URL, minimum interval and concurrency.
+func defaultConfig(baseURL string, minInterval time.Duration, concurrency int) ProviderConfig {
+	return ProviderConfig{
+		BaseURL:     baseURL,
+		Timeout:     5 * time.Second,
+		Retry:       defaultRetryPolicy,
+		MinInterval: minInterval,
+		CacheTTL:    10 * time.Minute,
+		Concurrency: concurrency,
+	}
+}
+
+// DefaultConfigs contains the configuration of the production rating systems. APIs
+// are rate limited less than websites that are scraped. Lichess ratings are fetched
+// in bulk, so a single request at a time is enough.
+var DefaultConfigs = map[database.RatingSystem]ProviderConfig{
+	database.Chesscom: defaultConfig("https://api.chess.com", 50*time.Millisecond, 8),
+	database.Lichess:  defaultConfig("https://lichess.org", 50*time.Millisecond, 1),
+	database.Fide:     defaultConfig("https://ratings.fide.com", 500*time.Millisecond, 2),
+	database.Uscf:     defaultConfig("https://www.uschess.org", 500*time.Millisecond, 2),
+	database.Ecf:      defaultConfig("https://www.ecfrating.org.uk", 50*time.Millisecond, 4),
+	database.Cfc:      defaultConfig("https://server.chess.ca", 50*time.Millisecond, 4),
+	database.Dwz:      defaultConfig("https://www.schachbund.de", 200*time.Millisecond, 2),
+	database.Acf:      defaultConfig("https://sachess.org.au", 200*time.Millisecond, 2),
+	database.Knsb:     defaultConfig("https://ratingviewer.nl", 50*time.Millisecond, 4),
+	database.Ffe:      defaultConfig("https://www.echecs.asso.fr", 200*time.Millisecond, 2),
+	database.Feda:     defaultConfig("https://www.feda.org", 200*time.Millisecond, 2),
+	database.Fsi:      defaultConfig("https://www.federscacchi.com", 200*time.Millisecond, 2),
+	database.Aicf:     defaultConfig("https://prs.aicf.in", 200*time.Millisecond, 2),
+	database.Cbx:      defaultConfig("https://www.cbx.org.br", 200*time.Millisecond, 2),
+	database.Nzcf:     defaultConfig("https://www.newzealandchess.co.nz", 200*time.Millisecond, 2),
+}
+
+// notFound returns an error indicating that the given player does not exist in the
+// named rating system.
+func notFound(name, username string) error {
+	return errors.New(404, fmt.Sprintf("Invalid request: %s player %q not found", name, username), "",
+		errors.WithReason(errors.ReasonRatingNotFound))
+}
+
+// unavailable returns an error indicating that the named rating system could not
+// be reached. cause can be nil.
+func unavailable(name, privateMsg string, cause error) error {
+	publicMsg := fmt.Sprintf("%s is temporarily unavailable", name)
+	reason := errors.WithReason(errors.ReasonRatingProviderUnavailable)
+	if cause == nil {
+		return errors.New(503, publicMsg, privateMsg, reason)
+	}
+	return errors.Wrap(503, publicMsg, privateMsg, cause, reason)
+}
+
+// IsNotFound returns true if err indicates that the player does not exist.
+func IsNotFound(err error) bool {
+	return errors.ReasonOf(err) == errors.ReasonRatingNotFound
+}
+
+// IsUnavailable returns true if err indicates that the rating system could not be reached.
+func IsUnavailable(err error) bool {
+	return errors.ReasonOf(err) == errors.ReasonRatingProviderUnavailable
+}
+
+// httpProvider implements the retries, rate limiting and caching shared by all
+// providers. Each provider embeds an httpProvider and implements only the parsing
+// of the rating system's responses.
+type httpProvider struct {
+	// The human-readable name of the rating system, used in error messages.
+	name string
+
+	config ProviderConfig
+	client *http.Client
+	sleep  func(time.Duration)
+
+	mu          sync.Mutex
+	nextRequest time.Time
+	cache       map[string]cachedRating
+}
+
+type cachedRating struct {
+	rating  database.Rating
+	expires time.Time
+}
+
+func newHttpProvider(name string, config ProviderConfig) *httpProvider {
+	return &httpProvider{
+		name:   name,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		sleep:  time.Sleep,
+		cache:  make(map[string]cachedRating),
+	}
+}
+
+// cached returns the cached rating for key if it has not expired. Otherwise, it
+// calls fetch and caches the result if fetch succeeds.
+func (p *httpProvider) cached(key string, fetch func() (*database.Rating, error)) (*database.Rating, error) {
+	key = strings.ToLower(key)
+	if p.config.CacheTTL > 0 {
+		p.mu.Lock()
+		entry, ok := p.cache[key]
+		p.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			rating := entry.rating
+			return &rating, nil
+		}
+	}
+
+	rating, err := fetch()
+	if err != nil || p.config.CacheTTL <= 0 {
+		return rating, err
+	}
+
+	p.mu.Lock()
+	p.cache[key] = cachedRating{rating: *rating, expires: time.Now().Add(p.config.CacheTTL)}
+	p.mu.Unlock()
+	return rating, nil
+}
+
+// wait blocks until the provider's rate limit allows another request.
+func (p *httpProvider) wait() {
+	if p.config.MinInterval <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	start := p.nextRequest
+	if start.Before(now) {
+		start = now
+	}
+	p.nextRequest = start.Add(p.config.MinInterval)
+	p.mu.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		p.sleep(delay)
+	}
+}
+
+// backoff waits before the given retry using exponential backoff with full jitter.
+func (p *httpProvider) backoff(retry int) {
+	delay := p.config.Retry.BaseDelay << (retry - 1)
+	if delay > p.config.Retry.MaxDelay || delay <= 0 {
+		delay = p.config.Retry.MaxDelay
+	}
+	if delay > 0 {
+		p.sleep(time.Duration(rand.Int63n(int64(delay) + 1)))
+	}
+}
+
+// get sends a GET request for the given path and returns the response body.
+// username is the player being fetched, and is used in the error returned on a 404.
+func (p *httpProvider) get(path, username string) ([]byte, error) {
+	return p.do(username, func() (*http.Response, error) {
+		return p.client.Get(p.config.BaseURL + path)
+	})
+}
+
+// post sends a POST request for the given path and returns the response body.
+func (p *httpProvider) post(path, contentType, body string) ([]byte, error) {
+	return p.do("", func() (*http.Response, error) {
+		return p.client.Post(p.config.BaseURL+path, contentType, strings.NewReader(body))
+	})
+}
+
+// authorized sends a request with the given method, path and OAuth access token and
+// returns the response body.
+func (p *httpProvider) authorized(method, path, accessToken string) ([]byte, error) {
+	return p.do("", func() (*http.Response, error) {
+		req, err := http.NewRequest(method, p.config.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		return p.client.Do(req)
+	})
+}
+
+// do sends the request created by send, retrying according to the provider's retry
+// policy, and returns the body of a 2xx response.
+func (p *httpProvider) do(username string, send func() (*http.Response, error)) ([]byte, error) {
+	attempts := p.config.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			log.Debugf("Retrying %s request (attempt %d) after: %v", p.name, attempt, err)
+			p.backoff(attempt - 1)
+		}
+		p.wait()
+
+		var resp *http.Response
+		resp, err = send()
+		if err != nil {
+			err = unavailable(p.name, fmt.Sprintf("Failed request to %s", p.name), err)
+			continue
+		}
+
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			err = unavailable(p.name, fmt.Sprintf("%s returned status `%d`", p.name, resp.StatusCode), nil)
+			continue
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, notFound(p.name, username)
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			return nil, errors.New(400, fmt.Sprintf("Invalid request: %s returned status `%d` for given player", p.name, resp.StatusCode), "")
+		case err != nil:
+			err = unavailable(p.name, fmt.Sprintf("Failed to read %s response", p.name), err)
+			continue
+		}
+		return body, nil
+	}
+	return nil, err
+}
@@ -4,8 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,27 +15,59 @@ import (
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
 )
 
-var client = http.Client{Timeout: 5 * time.Second}
-
 var fideRegexp, _ = regexp.Compile("std</span>\n\\s+(\\d+)")
+var fideRapidRegexp, _ = regexp.Compile("rapid</span>\n\\s+(\\d+)")
+var fideBlitzRegexp, _ = regexp.Compile("blitz</span>\n\\s+(\\d+)")
 var uscfCurrRegexp, _ = regexp.Compile(`Regular Rating\s*</td>\s*<td>\s*<b><nobr>\s*(\d+)`)
 var uscfFutureRegexp, _ = regexp.Compile(`(?s)Regular Rating\s*<\/td>\s*<td>\s*<b><nobr>.*<\/nobr>\s*<\/b>\s*<\/td>\s*<td>\s*(\d+)`)
 var uscfGameCountRegexp, _ = regexp.Compile(`<tr><td></td><td><b>(\d+)`)
 var acfRegexp, _ = regexp.Compile(`Current Rating:\s*</div>\s*<div id="stats-box-data-col">\s*[-\d]*\s*</div>\s*<div id="stats-box-data-col">\s*(\d+)`)
+var ffeRegexp, _ = regexp.Compile(`LabelElo">\s*(\d+)`)
+var fedaRegexp, _ = regexp.Compile(`Elo FEDA:?\s*</th>\s*<td[^>]*>\s*(\d+)`)
+var fsiRegexp, _ = regexp.Compile(`Elo Italia\s*</td>\s*<td[^>]*>\s*(\d+)`)
+var aicfRegexp, _ = regexp.Compile(`Standard Rating\s*</span>\s*<span[^>]*>\s*(\d+)`)
+var cbxRegexp, _ = regexp.Compile(`Rating Cl[aá]ssico:?\s*</span>\s*<span[^>]*>\s*(\d+)`)
+var nzcfRegexp, _ = regexp.Compile(`Current Rating:?\s*</td>\s*<td[^>]*>\s*(\d+)`)
+
+type ChesscomStats struct {
+	Last struct {
+		Rating    int `json:"rating"`
+		Deviation int `json:"rd"`
+	} `json:"last"`
+
+	Record struct {
+		Wins   int `json:"win"`
+		Losses int `json:"loss"`
+		Draws  int `json:"draw"`
+	} `json:"record"`
+}
 
 type ChesscomResponse struct {
-	Rapid struct {
-		Last struct {
-			Rating    int `json:"rating"`
-			Deviation int `json:"rd"`
-		} `json:"last"`
+	Bullet *ChesscomStats `json:"chess_bullet"`
+	Blitz  *ChesscomStats `json:"chess_blitz"`
+	Rapid  *ChesscomStats `json:"chess_rapid"`
+	Daily  *ChesscomStats `json:"chess_daily"`
+}
 
-		Record struct {
-			Wins   int `json:"win"`
-			Losses int `json:"loss"`
-			Draws  int `json:"draw"`
-		} `json:"record"`
-	} `json:"chess_rapid"`
+// ChesscomProfile is the public profile of a chess.com account.
+type ChesscomProfile struct {
+	Username string `json:"username"`
+
+	// The status of the account, such as `basic`, `premium` or `closed:fair_play_violations`.
+	Status string `json:"status"`
+
+	// The location set by the user on their profile, which is used to verify ownership
+	// of the account.
+	Location string `json:"location"`
+}
+
+// ChesscomFairPlayClosure is the status of chess.com accounts closed for fair play violations.
+const ChesscomFairPlayClosure = "closed:fair_play_violations"
+
+type LichessPerf struct {
+	Rating    int `json:"rating"`
+	NumGames  int `json:"games"`
+	Deviation int `json:"rd"`
 }
 
 type LichessResponse struct {
@@ -44,11 +75,11 @@ type LichessResponse struct {
 	Username string `json:"username"`
 
 	Performances struct {
-		Classical struct {
-			Rating    int `json:"rating"`
-			NumGames  int `json:"games"`
-			Deviation int `json:"rd"`
-		} `json:"classical"`
+		Bullet         *LichessPerf `json:"bullet"`
+		Blitz          *LichessPerf `json:"blitz"`
+		Rapid          *LichessPerf `json:"rapid"`
+		Classical      *LichessPerf `json:"classical"`
+		Correspondence *LichessPerf `json:"correspondence"`
 	} `json:"perfs"`
 
 	TosViolation bool `json:"tosViolation"`
@@ -78,90 +109,212 @@ type KnsbListResponse struct {
 	Items []KnsbList `json:"items"`
 }
 
-type RatingFetchFunc func(username string) (*database.Rating, error)
+// Lichess is the default Lichess provider, which also supports fetching ratings in bulk.
+var Lichess = NewLichessProvider(DefaultConfigs[database.Lichess])
+
+// Chesscom is the default chess.com provider, which also supports fetching account statuses.
+var Chesscom = NewChesscomProvider(DefaultConfigs[database.Chesscom])
 
-var RatingFetchFuncs map[database.RatingSystem]RatingFetchFunc = map[database.RatingSystem]RatingFetchFunc{
-	database.Chesscom: FetchChesscomRating,
-	database.Lichess:  FetchLichessRating,
-	database.Fide:     FetchFideRating,
-	database.Uscf:     FetchUscfRating,
-	database.Ecf:      FetchEcfRating,
-	database.Cfc:      FetchCfcRating,
-	database.Dwz:      FetchDwzRating,
-	database.Acf:      FetchAcfRating,
-	database.Knsb:     FetchKnsbRating,
+// Providers contains the default provider of each supported rating system.
+var Providers = defaultProviders()
+
+func defaultProviders() map[database.RatingSystem]RatingProvider {
+	providers := NewProviders(DefaultConfigs)
+	providers[database.Lichess] = Lichess
+	providers[database.Chesscom] = Chesscom
+	return providers
 }
 
-func FetchChesscomRating(chesscomUsername string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://api.chess.com/pub/player/%s/stats", chesscomUsername))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to get chess.com stats", err)
-		return nil, err
+// NewProviders returns a provider for each supported rating system in configs.
+func NewProviders(configs map[database.RatingSystem]ProviderConfig) map[database.RatingSystem]RatingProvider {
+	providers := make(map[database.RatingSystem]RatingProvider, len(configs))
+	for system, config := range configs {
+		if provider := NewProvider(system, config); provider != nil {
+			providers[system] = provider
+		}
 	}
+	return providers
+}
 
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: chess.com returned status `%d` for given player", resp.StatusCode), "")
-		return nil, err
+// NewProvider returns a provider for the given rating system, or nil if the rating
+// system is not supported.
+func NewProvider(system database.RatingSystem, config ProviderConfig) RatingProvider {
+	switch system {
+	case database.Chesscom:
+		return NewChesscomProvider(config)
+	case database.Lichess:
+		return NewLichessProvider(config)
+	case database.Fide:
+		return &fideProvider{newHttpProvider("FIDE", config)}
+	case database.Uscf:
+		return &uscfProvider{newHttpProvider("USCF", config)}
+	case database.Ecf:
+		return &ecfProvider{newHttpProvider("ECF", config)}
+	case database.Cfc:
+		return &cfcProvider{newHttpProvider("CFC", config)}
+	case database.Dwz:
+		return &dwzProvider{newHttpProvider("DWZ", config)}
+	case database.Acf:
+		return &acfProvider{newHttpProvider("ACF", config)}
+	case database.Knsb:
+		return &knsbProvider{newHttpProvider("KNSB", config)}
+	case database.Ffe:
+		return &pageProvider{newHttpProvider("FFE", config), "/FicheJoueur.aspx?Id=%s", ffeRegexp}
+	case database.Feda:
+		return &pageProvider{newHttpProvider("FEDA", config), "/elo/jugador.php?id=%s", fedaRegexp}
+	case database.Fsi:
+		return &pageProvider{newHttpProvider("FSI", config), "/fsi/index.php/punteggi/elo-italia?idx=%s", fsiRegexp}
+	case database.Aicf:
+		return &pageProvider{newHttpProvider("AICF", config), "/players/rating?aicfId=%s", aicfRegexp}
+	case database.Cbx:
+		return &pageProvider{newHttpProvider("CBX", config), "/jogador/%s", cbxRegexp}
+	case database.Nzcf:
+		return &pageProvider{newHttpProvider("NZCF", config), "/ratings/player?id=%s", nzcfRegexp}
+	}
+	return nil
+}
+
+// newRating returns a Rating with the given time control ratings. The current values
+// of the Rating are those of the rating system's default time control.
+func newRating(system database.RatingSystem, tcs map[database.TimeControl]*database.TimeControlRating) *database.Rating {
+	rating := &database.Rating{TimeControls: tcs}
+	if r := tcs[database.DefaultTimeControl(system)]; r != nil {
+		rating.CurrentRating = r.CurrentRating
+		rating.Deviation = r.Deviation
+		rating.NumGames = r.NumGames
 	}
+	return rating
+}
 
-	var rating ChesscomResponse
-	err = json.NewDecoder(resp.Body).Decode(&rating)
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read chess.com response", err)
-		return nil, err
+// decodeJson unmarshals the body of a response from the named rating system into v.
+func decodeJson(name string, body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(500, "Temporary server error", fmt.Sprintf("Failed to read %s response", name), err)
 	}
+	return nil
+}
 
-	dojoRating := &database.Rating{
-		CurrentRating: rating.Rapid.Last.Rating,
-		Deviation:     rating.Rapid.Last.Deviation,
-		NumGames:      rating.Rapid.Record.Wins + rating.Rapid.Record.Draws + rating.Rapid.Record.Losses,
+func findRating(body []byte, regex *regexp.Regexp) (int, error) {
+	groups := regex.FindSubmatch(body)
+	if len(groups) < 2 {
+		err := errors.New(400, "Unable to find rating on website", "")
+		return 0, err
+	}
+
+	rating, err := strconv.Atoi(string(groups[1]))
+	if err != nil {
+		err = errors.Wrap(500, "Temporary server error", "Failed to convert rating to int", err)
+		return 0, err
 	}
+	return rating, nil
+}
+
+// ChesscomProvider fetches ratings and account statuses from chess.com.
+type ChesscomProvider struct{ *httpProvider }
+
+// NewChesscomProvider returns a ChesscomProvider with the given config.
+func NewChesscomProvider(config ProviderConfig) *ChesscomProvider {
+	return &ChesscomProvider{newHttpProvider("chess.com", config)}
+}
+
+func (p *ChesscomProvider) FetchRating(chesscomUsername string) (*database.Rating, error) {
+	return p.cached(chesscomUsername, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/pub/player/%s/stats", url.PathEscape(chesscomUsername)), chesscomUsername)
+		if err != nil {
+			return nil, err
+		}
 
-	return dojoRating, nil
+		var rating ChesscomResponse
+		if err := decodeJson(p.name, b, &rating); err != nil {
+			return nil, err
+		}
+		return rating.Rating(), nil
+	})
 }
 
-func FetchLichessRating(lichessUsername string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://lichess.org/api/user/%s", lichessUsername))
+// FetchProfile returns the public profile of the given chess.com account.
+func (p *ChesscomProvider) FetchProfile(chesscomUsername string) (*ChesscomProfile, error) {
+	b, err := p.get(fmt.Sprintf("/pub/player/%s", url.PathEscape(chesscomUsername)), chesscomUsername)
 	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to get lichess stats", err)
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: lichess returned status `%d` for given player", resp.StatusCode), "")
+	var profile ChesscomProfile
+	if err := decodeJson(p.name, b, &profile); err != nil {
 		return nil, err
 	}
+	return &profile, nil
+}
 
-	var rating LichessResponse
-	err = json.NewDecoder(resp.Body).Decode(&rating)
+// FetchAccountStatus returns the status of the given chess.com account, which is
+// ChesscomFairPlayClosure if the account was closed for fair play violations.
+func (p *ChesscomProvider) FetchAccountStatus(chesscomUsername string) (string, error) {
+	profile, err := p.FetchProfile(chesscomUsername)
 	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read lichess response", err)
-		return nil, err
+		return "", err
 	}
+	return profile.Status, nil
+}
 
-	dojoRating := &database.Rating{
-		CurrentRating: rating.Performances.Classical.Rating,
-		Deviation:     rating.Performances.Classical.Deviation,
-		NumGames:      rating.Performances.Classical.NumGames,
+// Rating returns the ratings in each time control of the chess.com stats. The rapid
+// rating is used as the current rating.
+func (r ChesscomResponse) Rating() *database.Rating {
+	tcs := make(map[database.TimeControl]*database.TimeControlRating)
+	for tc, stats := range map[database.TimeControl]*ChesscomStats{
+		database.TimeControl_Bullet: r.Bullet,
+		database.TimeControl_Blitz:  r.Blitz,
+		database.TimeControl_Rapid:  r.Rapid,
+		database.TimeControl_Daily:  r.Daily,
+	} {
+		if stats != nil {
+			tcs[tc] = &database.TimeControlRating{
+				CurrentRating: stats.Last.Rating,
+				Deviation:     stats.Last.Deviation,
+				NumGames:      stats.Record.Wins + stats.Record.Draws + stats.Record.Losses,
+			}
+		}
 	}
-	return dojoRating, nil
+	return newRating(database.Chesscom, tcs)
 }
 
-func FetchBulkLichessRatings(lichessUsernames []string) (map[string]LichessResponse, error) {
+// LichessProvider fetches classical ratings from Lichess.
+type LichessProvider struct{ *httpProvider }
+
+// NewLichessProvider returns a LichessProvider with the given config.
+func NewLichessProvider(config ProviderConfig) *LichessProvider {
+	return &LichessProvider{newHttpProvider("Lichess", config)}
+}
+
+func (p *LichessProvider) FetchRating(lichessUsername string) (*database.Rating, error) {
+	return p.cached(lichessUsername, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/api/user/%s", url.PathEscape(lichessUsername)), lichessUsername)
+		if err != nil {
+			return nil, err
+		}
+
+		var rating LichessResponse
+		if err := decodeJson(p.name, b, &rating); err != nil {
+			return nil, err
+		}
+		return rating.Rating(), nil
+	})
+}
+
+// FetchBulk returns the Lichess profiles of the given usernames, mapped by their
+// lowercase id. Usernames which do not exist are not included in the result.
+func (p *LichessProvider) FetchBulk(lichessUsernames []string) (map[string]LichessResponse, error) {
 	log.Debugf("Fetching bulk lichess usernames: %#v", lichessUsernames)
 	if len(lichessUsernames) == 0 {
 		return make(map[string]LichessResponse, 0), nil
 	}
 
-	resp, err := client.Post("https://lichess.org/api/users", "text/plain", strings.NewReader(strings.Join(lichessUsernames, ",")))
+	b, err := p.post("/api/users", "text/plain", strings.Join(lichessUsernames, ","))
 	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to get lichess bulk stats", err)
 		return nil, err
 	}
 
 	var rs []*LichessResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rs); err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read lichess response", err)
+	if err := decodeJson(p.name, b, &rs); err != nil {
 		return nil, err
 	}
 
@@ -173,244 +326,229 @@ func FetchBulkLichessRatings(lichessUsernames []string) (map[string]LichessRespo
 	return result, nil
 }
 
-func findRating(body []byte, regex *regexp.Regexp) (int, error) {
-	groups := regex.FindSubmatch(body)
-	if len(groups) < 2 {
-		err := errors.New(400, "Unable to find rating on website", "")
-		return 0, err
-	}
-
-	rating, err := strconv.Atoi(string(groups[1]))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to convert rating to int", err)
-		return 0, err
+// Rating returns the ratings in each time control of the Lichess profile. The classical
+// rating is used as the current rating.
+func (r LichessResponse) Rating() *database.Rating {
+	tcs := make(map[database.TimeControl]*database.TimeControlRating)
+	for tc, perf := range map[database.TimeControl]*LichessPerf{
+		database.TimeControl_Bullet:         r.Performances.Bullet,
+		database.TimeControl_Blitz:          r.Performances.Blitz,
+		database.TimeControl_Rapid:          r.Performances.Rapid,
+		database.TimeControl_Classical:      r.Performances.Classical,
+		database.TimeControl_Correspondence: r.Performances.Correspondence,
+	} {
+		if perf != nil {
+			tcs[tc] = &database.TimeControlRating{
+				CurrentRating: perf.Rating,
+				Deviation:     perf.Deviation,
+				NumGames:      perf.NumGames,
+			}
+		}
 	}
-	return rating, nil
+	return newRating(database.Lichess, tcs)
 }
 
-func FetchFideRating(fideId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://ratings.fide.com/profile/%s", fideId))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to get Fide page", err)
-		return nil, err
-	}
-
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: FIDE returned status `%d` for given ID", resp.StatusCode), "")
-		return nil, err
-	}
+type fideProvider struct{ *httpProvider }
 
-	b, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read FIDE response", err)
-		return nil, err
-	}
+func (p *fideProvider) FetchRating(fideId string) (*database.Rating, error) {
+	return p.cached(fideId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/profile/%s", url.PathEscape(fideId)), fideId)
+		if err != nil {
+			return nil, err
+		}
 
-	rating, err := findRating(b, fideRegexp)
-	if err != nil {
-		log.Warnf("FIDE id %q: no rating found on website", fideId)
-		rating = 0
-	}
-	return &database.Rating{CurrentRating: rating}, nil
+		tcs := make(map[database.TimeControl]*database.TimeControlRating)
+		for tc, regex := range map[database.TimeControl]*regexp.Regexp{
+			database.TimeControl_Classical: fideRegexp,
+			database.TimeControl_Rapid:     fideRapidRegexp,
+			database.TimeControl_Blitz:     fideBlitzRegexp,
+		} {
+			if rating, err := findRating(b, regex); err == nil {
+				tcs[tc] = &database.TimeControlRating{CurrentRating: rating}
+			}
+		}
+		if tcs[database.TimeControl_Classical] == nil {
+			log.Warnf("FIDE id %q: no standard rating found on website", fideId)
+		}
+		return newRating(database.Fide, tcs), nil
+	})
 }
 
-func FetchUscfRating(uscfId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://www.uschess.org/msa/MbrDtlMain.php?%s", uscfId))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to get USCF page", err)
-		return nil, err
-	}
-
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: USCF returned status `%d` for given ID", resp.StatusCode), "")
-		return nil, err
-	}
+type uscfProvider struct{ *httpProvider }
 
-	b, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read USCF response", err)
-		return nil, err
-	}
+func (p *uscfProvider) FetchRating(uscfId string) (*database.Rating, error) {
+	return p.cached(uscfId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/msa/MbrDtlMain.php?%s", url.QueryEscape(uscfId)), uscfId)
+		if err != nil {
+			return nil, err
+		}
 
-	rating, err := findRating(b, uscfFutureRegexp)
-	if err != nil {
-		rating, err = findRating(b, uscfCurrRegexp)
+		rating, err := findRating(b, uscfFutureRegexp)
 		if err != nil {
-			if bytes.Contains(b, []byte("Non-Member")) {
-				err := errors.New(404, "Invalid request: the given USCF ID does not exist", "")
-				return nil, err
+			rating, err = findRating(b, uscfCurrRegexp)
+			if err != nil {
+				if bytes.Contains(b, []byte("Non-Member")) {
+					return nil, notFound(p.name, uscfId)
+				}
+				log.Warnf("USCF id %q: no rating found on website", uscfId)
+				rating = 0
 			}
-			log.Warnf("USCF id %q: no rating found on website", uscfId)
-			rating = 0
 		}
-	}
 
-	dojoRating := &database.Rating{CurrentRating: rating}
+		dojoRating := &database.Rating{CurrentRating: rating}
 
-	resp, err = client.Get(fmt.Sprintf("https://www.uschess.org/datapage/gamestats.php?memid=%s", uscfId))
-	if err != nil {
-		log.Errorf("Failed to get USCF game stats for ID %s: %v", uscfId, err)
-		return dojoRating, nil
-	}
-	if resp.StatusCode != 200 {
-		log.Errorf("Invalid request: USCF game stats returned status `%d` for ID %s", resp.StatusCode, uscfId)
-		return dojoRating, nil
-	}
-	b, err = io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		log.Errorf("Failed to read USCF game stats response for ID %s: %v", uscfId, err)
-		return dojoRating, nil
-	}
+		b, err = p.get(fmt.Sprintf("/datapage/gamestats.php?memid=%s", url.QueryEscape(uscfId)), uscfId)
+		if err != nil {
+			log.Errorf("Failed to get USCF game stats for ID %s: %v", uscfId, err)
+			return dojoRating, nil
+		}
 
-	gameCount, err := findRating(b, uscfGameCountRegexp)
-	if err != nil {
-		log.Errorf("Failed to find game count for USCF ID %s: %v", uscfId, err)
-		return dojoRating, nil
-	}
+		gameCount, err := findRating(b, uscfGameCountRegexp)
+		if err != nil {
+			log.Errorf("Failed to find game count for USCF ID %s: %v", uscfId, err)
+			return dojoRating, nil
+		}
 
-	dojoRating.NumGames = gameCount
-	return dojoRating, nil
+		dojoRating.NumGames = gameCount
+		return dojoRating, nil
+	})
 }
 
-func FetchEcfRating(ecfId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://www.ecfrating.org.uk/v2/new/api.php?v2/ratings/S/%s/%s", ecfId, time.Now().Format(time.DateOnly)))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed call to ECF API", err)
-		return nil, err
-	}
-
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: ECF API returned status `%d`", resp.StatusCode), "")
-		return nil, err
-	}
+type ecfProvider struct{ *httpProvider }
 
-	var rating EcfResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rating); err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to parse ECF API response", err)
-		return nil, err
-	}
+func (p *ecfProvider) FetchRating(ecfId string) (*database.Rating, error) {
+	return p.cached(ecfId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/v2/new/api.php?v2/ratings/S/%s/%s", url.PathEscape(ecfId), time.Now().Format(time.DateOnly)), ecfId)
+		if err != nil {
+			return nil, err
+		}
 
-	return &database.Rating{CurrentRating: rating.Rating}, nil
+		var rating EcfResponse
+		if err := decodeJson(p.name, b, &rating); err != nil {
+			return nil, err
+		}
+		return &database.Rating{CurrentRating: rating.Rating}, nil
+	})
 }
 
-func FetchCfcRating(cfcId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://server.chess.ca/api/player/v1/%s", cfcId))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed call to CFC API", err)
-		return nil, err
-	}
+type cfcProvider struct{ *httpProvider }
 
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: CFC API returned status `%d`", resp.StatusCode), "")
-		return nil, err
-	}
+func (p *cfcProvider) FetchRating(cfcId string) (*database.Rating, error) {
+	return p.cached(cfcId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/api/player/v1/%s", url.PathEscape(cfcId)), cfcId)
+		if err != nil {
+			return nil, err
+		}
 
-	var r CfcResponse
-	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to parse CFC API response", err)
-		return nil, err
-	}
-	return &database.Rating{CurrentRating: r.Player.Rating}, nil
+		var r CfcResponse
+		if err := decodeJson(p.name, b, &r); err != nil {
+			return nil, err
+		}
+		return &database.Rating{CurrentRating: r.Player.Rating}, nil
+	})
 }
 
-func FetchDwzRating(dwzId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://www.schachbund.de/php/dewis/spieler.php?pkz=%s", dwzId))
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed call to DWZ API", err)
-		return nil, err
-	}
-
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: DWZ API returned status `%d`", resp.StatusCode), "")
-		return nil, err
-	}
+type dwzProvider struct{ *httpProvider }
 
-	b, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read DWZ response", err)
-		return nil, err
-	}
+func (p *dwzProvider) FetchRating(dwzId string) (*database.Rating, error) {
+	return p.cached(dwzId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/php/dewis/spieler.php?pkz=%s", url.QueryEscape(dwzId)), dwzId)
+		if err != nil {
+			return nil, err
+		}
 
-	const ratingIndex = 13
-	tokens := strings.Split(string(b), "|")
-	if ratingIndex >= len(tokens) {
-		err = errors.New(400, "Invalid request: DWZ API did not return a rating", fmt.Sprintf("ratingIndex out of bounds for tokens %v", tokens))
-		return nil, err
-	}
+		const ratingIndex = 13
+		tokens := strings.Split(string(b), "|")
+		if ratingIndex >= len(tokens) {
+			return nil, errors.New(400, "Invalid request: DWZ API did not return a rating", fmt.Sprintf("ratingIndex out of bounds for tokens %v", tokens))
+		}
 
-	rating, err := strconv.Atoi(tokens[ratingIndex])
-	if err != nil {
-		return nil, errors.Wrap(400, fmt.Sprintf("Invalid request: DWZ API returned rating `%s` which cannot be converted to integer", tokens[14]), "", err)
-	}
-	return &database.Rating{CurrentRating: rating}, nil
+		rating, err := strconv.Atoi(tokens[ratingIndex])
+		if err != nil {
+			return nil, errors.Wrap(400, fmt.Sprintf("Invalid request: DWZ API returned rating `%s` which cannot be converted to integer", tokens[ratingIndex]), "", err)
+		}
+		return &database.Rating{CurrentRating: rating}, nil
+	})
 }
 
-func FetchAcfRating(acfId string) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://sachess.org.au/ratings/player?id=%s", acfId))
-	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed to fetch ACF site", err)
-	}
-
-	if resp.StatusCode != 200 {
-		err = errors.New(400, fmt.Sprintf("Invalid request: ACF site returned status `%d`", resp.StatusCode), "")
-		return nil, err
-	}
+type acfProvider struct{ *httpProvider }
 
-	b, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed to read ACF response", err)
-		return nil, err
-	}
+func (p *acfProvider) FetchRating(acfId string) (*database.Rating, error) {
+	return p.cached(acfId, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf("/ratings/player?id=%s", url.QueryEscape(acfId)), acfId)
+		if err != nil {
+			return nil, err
+		}
 
-	rating, err := findRating(b, acfRegexp)
-	if err != nil {
-		log.Warnf("ACF id %q: no rating found on website", acfId)
-		rating = 0
-	}
-	return &database.Rating{CurrentRating: rating}, nil
+		rating, err := findRating(b, acfRegexp)
+		if err != nil {
+			log.Warnf("ACF id %q: no rating found on website", acfId)
+			rating = 0
+		}
+		return &database.Rating{CurrentRating: rating}, nil
+	})
 }
 
-func FetchKnsbRating(knsbId string) (*database.Rating, error) {
-	resp, err := client.Get("https://ratingviewer.nl/rating-lists/index.json?page=1&pageSize=10")
-	if err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed call to KNSB list API", err)
-	}
-	if resp.StatusCode != 200 {
-		return nil, errors.New(500, "Temporary server error", fmt.Sprintf("Invalid request: KNSB list API returned status `%d`", resp.StatusCode))
-	}
+type knsbProvider struct{ *httpProvider }
 
-	var listResp KnsbListResponse
-	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed to unmarshal KNSB list response", err)
-	}
+func (p *knsbProvider) FetchRating(knsbId string) (*database.Rating, error) {
+	return p.cached(knsbId, func() (*database.Rating, error) {
+		b, err := p.get("/rating-lists/index.json?page=1&pageSize=10", knsbId)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, item := range listResp.Items {
-		if item.Category == "C" {
-			return fetchKnsbListRating(knsbId, item.ListId)
+		var listResp KnsbListResponse
+		if err := decodeJson(p.name, b, &listResp); err != nil {
+			return nil, err
 		}
-	}
-	return nil, errors.New(500, "Temporary server error", "Failed to find category C in KNSB list API response")
+
+		for _, item := range listResp.Items {
+			if item.Category == "C" {
+				return p.fetchListRating(knsbId, item.ListId)
+			}
+		}
+		return nil, errors.New(500, "Temporary server error", "Failed to find category C in KNSB list API response")
+	})
 }
 
-func fetchKnsbListRating(knsbId string, listId int) (*database.Rating, error) {
-	resp, err := client.Get(fmt.Sprintf("https://ratingviewer.nl/metrics/ratingList/%s/%d.json", knsbId, listId))
+func (p *knsbProvider) fetchListRating(knsbId string, listId int) (*database.Rating, error) {
+	b, err := p.get(fmt.Sprintf("/metrics/ratingList/%s/%d.json", url.PathEscape(knsbId), listId), knsbId)
 	if err != nil {
-		err = errors.Wrap(500, "Temporary server error", "Failed call to KNSB API", err)
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New(400, fmt.Sprintf("Invalid request: KNSB API returned status `%d`", resp.StatusCode), "")
-	}
-
 	var r KnsbResponse
-	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
-		return nil, errors.Wrap(500, "Temporary server error", "Failed to unmarshal KNSB response", err)
+	if err := decodeJson(p.name, b, &r); err != nil {
+		return nil, err
 	}
 	return &database.Rating{CurrentRating: r.Rating, NumGames: r.NumGames}, nil
 }
+
+// pageProvider fetches ratings by scraping the player page of a rating system's
+// website. It is used by the rating systems which do not have an API and show a
+// single rating on the player page.
+type pageProvider struct {
+	*httpProvider
+
+	// The path of the player page, formatted with the escaped player id.
+	path string
+
+	// Matches the player's rating in the first group.
+	regex *regexp.Regexp
+}
+
+func (p *pageProvider) FetchRating(id string) (*database.Rating, error) {
+	return p.cached(id, func() (*database.Rating, error) {
+		b, err := p.get(fmt.Sprintf(p.path, url.QueryEscape(id)), id)
+		if err != nil {
+			return nil, err
+		}
+
+		rating, err := findRating(b, p.regex)
+		if err != nil {
+			return nil, err
+		}
+		return &database.Rating{CurrentRating: rating}, nil
+	})
+}
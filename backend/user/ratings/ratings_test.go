@@ -0,0 +1,347 @@
+package ratings
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+// fixtures maps the prefix of a request URI to the file in testdata which is served
+// for it. The files are recorded responses of each rating system.
+var fixtures = []struct {
+	prefix string
+	file   string
+}{
+	{prefix: "/pub/player/dojoplayer/stats", file: "chesscom_stats.json"},
+	{prefix: "/pub/player/dojoplayer", file: "chesscom_player.json"},
+	{prefix: "/pub/player/closedplayer", file: "chesscom_player_closed.json"},
+	{prefix: "/api/user/DojoPlayer", file: "lichess_user.json"},
+	{prefix: "/api/users", file: "lichess_users.json"},
+	{prefix: "/api/token", file: "lichess_token.json"},
+	{prefix: "/api/account", file: "lichess_account.json"},
+	{prefix: "/profile/2093596", file: "fide_profile.html"},
+	{prefix: "/msa/MbrDtlMain.php?12345678", file: "uscf_member.html"},
+	{prefix: "/msa/MbrDtlMain.php?99999999", file: "uscf_nonmember.html"},
+	{prefix: "/datapage/gamestats.php?memid=12345678", file: "uscf_gamestats.html"},
+	{prefix: "/v2/new/api.php?v2/ratings/S/123456A/", file: "ecf_rating.json"},
+	{prefix: "/api/player/v1/123456", file: "cfc_player.json"},
+	{prefix: "/php/dewis/spieler.php?pkz=10034471", file: "dwz_spieler.txt"},
+	{prefix: "/ratings/player?id=3203105", file: "acf_player.html"},
+	{prefix: "/rating-lists/index.json", file: "knsb_lists.json"},
+	{prefix: "/metrics/ratingList/8123456/411.json", file: "knsb_rating.json"},
+	{prefix: "/FicheJoueur.aspx?Id=F12345", file: "ffe_player.html"},
+	{prefix: "/elo/jugador.php?id=2201234", file: "feda_player.html"},
+	{prefix: "/fsi/index.php/punteggi/elo-italia?idx=123456", file: "fsi_player.html"},
+	{prefix: "/players/rating?aicfId=25001234", file: "aicf_player.html"},
+	{prefix: "/jogador/54321", file: "cbx_player.html"},
+	{prefix: "/ratings/player?id=4321", file: "nzcf_player.html"},
+}
+
+// newFixtureServer returns a server which stands in for every rating system by
+// serving the recorded fixtures. Unknown URIs return a 404.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, f := range fixtures {
+			if strings.HasPrefix(r.URL.RequestURI(), f.prefix) {
+				b, err := os.ReadFile(filepath.Join("testdata", f.file))
+				if err != nil {
+					t.Errorf("Failed to read fixture %s: %v", f.file, err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Write(b)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testConfig(baseURL string) ProviderConfig {
+	return ProviderConfig{
+		BaseURL: baseURL,
+		Timeout: time.Second,
+		Retry:   RetryPolicy{MaxAttempts: 3},
+	}
+}
+
+func TestProviders(t *testing.T) {
+	server := newFixtureServer(t)
+
+	table := []struct {
+		system   database.RatingSystem
+		username string
+		want     *database.Rating
+	}{
+		{
+			system:   database.Chesscom,
+			username: "dojoplayer",
+			want: &database.Rating{
+				CurrentRating: 1587,
+				Deviation:     45,
+				NumGames:      850,
+				TimeControls: map[database.TimeControl]*database.TimeControlRating{
+					database.TimeControl_Blitz: {CurrentRating: 1311, Deviation: 52, NumGames: 2028},
+					database.TimeControl_Rapid: {CurrentRating: 1587, Deviation: 45, NumGames: 850},
+					database.TimeControl_Daily: {CurrentRating: 1402, Deviation: 98, NumGames: 56},
+				},
+			},
+		},
+		{
+			system:   database.Lichess,
+			username: "DojoPlayer",
+			want: &database.Rating{
+				CurrentRating: 1912,
+				Deviation:     72,
+				NumGames:      87,
+				TimeControls: map[database.TimeControl]*database.TimeControlRating{
+					database.TimeControl_Blitz:          {CurrentRating: 1788, Deviation: 47, NumGames: 1204},
+					database.TimeControl_Rapid:          {CurrentRating: 1850, Deviation: 61, NumGames: 311},
+					database.TimeControl_Classical:      {CurrentRating: 1912, Deviation: 72, NumGames: 87},
+					database.TimeControl_Correspondence: {CurrentRating: 1500, Deviation: 500},
+				},
+			},
+		},
+		{
+			system:   database.Fide,
+			username: "2093596",
+			want: &database.Rating{
+				CurrentRating: 1795,
+				TimeControls: map[database.TimeControl]*database.TimeControlRating{
+					database.TimeControl_Classical: {CurrentRating: 1795},
+					database.TimeControl_Rapid:     {CurrentRating: 1820},
+				},
+			},
+		},
+		{system: database.Uscf, username: "12345678", want: &database.Rating{CurrentRating: 1658, NumGames: 140}},
+		{system: database.Ecf, username: "123456A", want: &database.Rating{CurrentRating: 1834}},
+		{system: database.Cfc, username: "123456", want: &database.Rating{CurrentRating: 1712}},
+		{system: database.Dwz, username: "10034471", want: &database.Rating{CurrentRating: 1688}},
+		{system: database.Acf, username: "3203105", want: &database.Rating{CurrentRating: 1547}},
+		{system: database.Knsb, username: "8123456", want: &database.Rating{CurrentRating: 1903, NumGames: 211}},
+		{system: database.Ffe, username: "F12345", want: &database.Rating{CurrentRating: 1642}},
+		{system: database.Feda, username: "2201234", want: &database.Rating{CurrentRating: 1731}},
+		{system: database.Fsi, username: "123456", want: &database.Rating{CurrentRating: 1788}},
+		{system: database.Aicf, username: "25001234", want: &database.Rating{CurrentRating: 1493}},
+		{system: database.Cbx, username: "54321", want: &database.Rating{CurrentRating: 1856}},
+		{system: database.Nzcf, username: "4321", want: &database.Rating{CurrentRating: 1324}},
+	}
+
+	for _, tc := range table {
+		t.Run(string(tc.system), func(t *testing.T) {
+			provider := NewProvider(tc.system, testConfig(server.URL))
+			got, err := provider.FetchRating(tc.username)
+			if err != nil {
+				t.Fatalf("FetchRating(%q) got err: %v", tc.username, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FetchRating(%q) mismatch (-want +got):\n%s", tc.username, diff)
+			}
+		})
+	}
+}
+
+func TestProvidersNotFound(t *testing.T) {
+	server := newFixtureServer(t)
+
+	table := []struct {
+		system   database.RatingSystem
+		username string
+	}{
+		{system: database.Chesscom, username: "missing"},
+		{system: database.Lichess, username: "missing"},
+		{system: database.Uscf, username: "99999999"},
+		{system: database.Ffe, username: "missing"},
+	}
+
+	for _, tc := range table {
+		t.Run(string(tc.system), func(t *testing.T) {
+			provider := NewProvider(tc.system, testConfig(server.URL))
+			if _, err := provider.FetchRating(tc.username); !IsNotFound(err) {
+				t.Errorf("FetchRating(%q) got err %v; want not found", tc.username, err)
+			}
+		})
+	}
+}
+
+func TestLichessFetchBulk(t *testing.T) {
+	server := newFixtureServer(t)
+	provider := NewLichessProvider(testConfig(server.URL))
+
+	got, err := provider.FetchBulk([]string{"DojoPlayer", "ClosedAccount"})
+	if err != nil {
+		t.Fatalf("FetchBulk got err: %v", err)
+	}
+	if len(got) != 2 || got["dojoplayer"].Rating().CurrentRating != 1912 || !got["closedaccount"].TosViolation {
+		t.Errorf("FetchBulk got %+v; want dojoplayer rated 1912 and closedaccount with a TOS violation", got)
+	}
+}
+
+func TestLichessOAuth(t *testing.T) {
+	server := newFixtureServer(t)
+	provider := NewLichessProvider(testConfig(server.URL))
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got := CodeChallenge(verifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge(%s) = %s; want the RFC 7636 example challenge", verifier, got)
+	}
+
+	u, err := url.Parse(provider.AuthorizationURL("chessdojo", "https://www.chessdojo.club/verify", "state", CodeChallenge(verifier)))
+	if err != nil {
+		t.Fatalf("AuthorizationURL got unparseable URL: %v", err)
+	}
+	if q := u.Query(); u.Path != "/oauth" || q.Get("state") != "state" || q.Get("code_challenge_method") != "S256" || q.Get("redirect_uri") != "https://www.chessdojo.club/verify" {
+		t.Errorf("AuthorizationURL got %s; want the Lichess OAuth page with the state, challenge and redirect URI", u)
+	}
+
+	token, err := provider.ExchangeCode("chessdojo", "https://www.chessdojo.club/verify", "code", verifier)
+	if err != nil || token == "" {
+		t.Fatalf("ExchangeCode got %q, %v; want a token", token, err)
+	}
+	account, err := provider.FetchAccount(token)
+	if err != nil || account.Id != "dojoplayer" || account.Username != "DojoPlayer" {
+		t.Errorf("FetchAccount got %+v, %v; want DojoPlayer", account, err)
+	}
+	if err := provider.RevokeToken(token); err != nil {
+		t.Errorf("RevokeToken got err: %v", err)
+	}
+}
+
+func TestChesscomFetchProfile(t *testing.T) {
+	server := newFixtureServer(t)
+	provider := NewChesscomProvider(testConfig(server.URL))
+
+	got, err := provider.FetchProfile("dojoplayer")
+	if err != nil {
+		t.Fatalf("FetchProfile got err: %v", err)
+	}
+	if got.Username != "dojoplayer" || !strings.Contains(got.Location, "chessdojo-3Fq9xK2m") {
+		t.Errorf("FetchProfile got %+v; want dojoplayer with the verification token in the location", got)
+	}
+}
+
+func TestChesscomFetchAccountStatus(t *testing.T) {
+	server := newFixtureServer(t)
+	provider := NewChesscomProvider(testConfig(server.URL))
+
+	table := []struct {
+		username string
+		want     string
+	}{
+		{username: "dojoplayer", want: "premium"},
+		{username: "closedplayer", want: ChesscomFairPlayClosure},
+	}
+	for _, tc := range table {
+		got, err := provider.FetchAccountStatus(tc.username)
+		if err != nil {
+			t.Fatalf("FetchAccountStatus(%s) got err: %v", tc.username, err)
+		}
+		if got != tc.want {
+			t.Errorf("FetchAccountStatus(%s) = %q; want %q", tc.username, got, tc.want)
+		}
+	}
+
+	if _, err := provider.FetchAccountStatus("missing"); !IsNotFound(err) {
+		t.Errorf("FetchAccountStatus(missing) got err %v; want not found", err)
+	}
+}
+
+func TestProviderRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if strings.Contains(r.URL.RawQuery, "/down/") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"revised_rating": 2001}`))
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+	provider := NewProvider(database.Ecf, config).(*ecfProvider)
+	var slept time.Duration
+	provider.sleep = func(d time.Duration) { slept += d }
+
+	got, err := provider.FetchRating("1")
+	if err != nil {
+		t.Fatalf("FetchRating got err: %v", err)
+	}
+	if got.CurrentRating != 2001 || requests != 3 {
+		t.Errorf("FetchRating got rating %d after %d requests; want 2001 after 3 requests", got.CurrentRating, requests)
+	}
+	if slept > 2*time.Second {
+		t.Errorf("FetchRating slept %v; want at most 2s", slept)
+	}
+
+	requests = 0
+	if _, err := provider.FetchRating("down"); !IsUnavailable(err) || requests != 3 {
+		t.Errorf("FetchRating got err %v after %d requests; want unavailable after 3 requests", err, requests)
+	}
+}
+
+func TestProviderCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"player": {"regular_rating": 1500}}`))
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.CacheTTL = time.Minute
+	provider := NewProvider(database.Cfc, config)
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.FetchRating("123"); err != nil {
+			t.Fatalf("FetchRating got err: %v", err)
+		}
+	}
+	if _, err := provider.FetchRating("456"); err != nil {
+		t.Fatalf("FetchRating got err: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("FetchRating sent %d requests; want 2", requests)
+	}
+}
+
+func TestProviderRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"player": {"regular_rating": 1500}}`))
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.MinInterval = time.Hour
+	provider := NewProvider(database.Cfc, config).(*cfcProvider)
+	var sleeps []time.Duration
+	provider.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.FetchRating("123"); err != nil {
+			t.Fatalf("FetchRating got err: %v", err)
+		}
+	}
+	if len(sleeps) != 2 || sleeps[0] < 59*time.Minute || sleeps[1] < 119*time.Minute {
+		t.Errorf("FetchRating slept %v; want about 1h and 2h", sleeps)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,156 +15,317 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awslambda "github.com/aws/aws-sdk-go/service/lambda"
 )
 
 type Event events.CloudWatchEvent
 
 var repository = database.DynamoDB
 
+var lambdaSvc = awslambda.New(session.Must(session.NewSession()))
+
 var now = time.Now()
 
 type isBannedFunc func(username string) bool
 
-func updateRating(rating *database.Rating, systemName string, fetcher ratings.RatingFetchFunc) bool {
+type accountStatusFunc func(username string) (string, error)
+
+// updateRating fetches the given rating from the provider and updates it. updated is true if
+// the rating changed. fetched is true if the provider returned a rating.
+func updateRating(rating *database.Rating, system database.RatingSystem, provider ratings.RatingProvider) (updated bool, fetched bool) {
 	rating.Username = strings.TrimSpace(rating.Username)
 	if rating.Username == "" {
-		return false
+		return false, false
 	}
 
-	data, err := fetcher(rating.Username)
+	data, err := provider.FetchRating(rating.Username)
+	if ratings.IsNotFound(err) {
+		log.Warnf("No %s rating found for %q", system, rating.Username)
+		return false, false
+	}
 	if err != nil {
-		log.Errorf("Failed to get %s rating for %q: %v", systemName, rating.Username, err)
-		return false
+		log.Errorf("Failed to get %s rating for %q: %v", system, rating.Username, err)
+		return false, false
 	}
 
-	shouldUpdate := false
+	return rating.Update(system, data), true
+}
+
+// reevaluateCohort moves a user whose cohort was picked from a provisional rating to the
+// cohort of their current rating, once that rating is no longer provisional.
+func reevaluateCohort(user *database.User, boundaries *database.CohortBoundaries) {
+	if !user.ProvisionalCohort {
+		return
+	}
 
-	if data.CurrentRating != rating.CurrentRating || data.Deviation != rating.Deviation || data.NumGames != rating.NumGames {
-		rating.CurrentRating = data.CurrentRating
-		rating.Deviation = data.Deviation
-		rating.NumGames = data.NumGames
-		shouldUpdate = true
+	rating := user.Ratings[user.RatingSystem]
+	if rating != nil && rating.IsProvisional(user.RatingSystem) {
+		return
 	}
 
-	if rating.StartRating == 0 {
-		rating.StartRating = data.CurrentRating
-		shouldUpdate = true
+	if rating != nil && rating.CurrentRating > 0 {
+		if cohort := boundaries.GetCohort(user.RatingSystem, rating.CurrentRating); cohort != database.NoCohort && cohort != user.DojoCohort {
+			log.Infof("Moving %q from provisional cohort %s to %s", user.Username, user.DojoCohort, cohort)
+			user.DojoCohort = cohort
+		}
 	}
+	user.ProvisionalCohort = false
+}
+
+// updater updates the ratings of users and accumulates the writes to save.
+type updater struct {
+	providers       map[database.RatingSystem]ratings.RatingProvider
+	isBannedLichess isBannedFunc
+	chesscomStatus  accountStatusFunc
+	boundaries      *database.CohortBoundaries
 
-	return shouldUpdate
+	queuedUpdates []*database.User
+	histories     []database.RatingHistoryUpdate
+	anomalies     []*database.RatingAnomaly
 }
 
-func updateIfNecessary(user *database.User, queuedUpdates []*database.User, ratingFetchFuncs map[database.RatingSystem]ratings.RatingFetchFunc, isBannedLichess isBannedFunc) (*database.User, []*database.User) {
-	shouldUpdate := false
+// refreshUser updates the user's ratings and queues the user to be saved. If at least one
+// rating was fetched, ratingsUpdatedAt records the refresh and the user is saved even if
+// their ratings did not change. Users whose ratings could not be fetched (such as during
+// a provider outage) keep their ratingsUpdatedAt, so that they are retried first, and are
+// only saved if they changed otherwise.
+func (u *updater) refreshUser(user *database.User) {
+	changed := false
+	fetchedAny := false
+	previousRatings := make(map[database.RatingSystem]int, len(user.Ratings))
 
 	for system, rating := range user.Ratings {
 		if system != database.Custom && system != database.Custom2 && system != database.Custom3 {
-			shouldUpdate = updateRating(rating, string(system), ratingFetchFuncs[system]) || shouldUpdate
+			previousRatings[system] = rating.CurrentRating
+			updated, fetched := updateRating(rating, system, u.providers[system])
+			changed = updated || changed
+			fetchedAny = fetched || fetchedAny
+
+			if fetched && rating.CurrentRating > 0 {
+				u.histories = append(u.histories, database.RatingHistoryUpdate{
+					Username:     user.Username,
+					RatingSystem: system,
+					History:      rating.History(now.Format(time.RFC3339)),
+				})
+			}
 		}
 
-		if system == database.Lichess && isBannedLichess(rating.Username) {
+		if system == database.Lichess && u.isBannedLichess(rating.Username) {
 			if user.LichessBan == "" {
 				user.LichessBan = rating.Username
-				shouldUpdate = true
+				changed = true
+				u.anomalies = append(u.anomalies, database.NewAccountClosureAnomaly(user.Username, system, rating.Username, "tosViolation", now))
 			}
 		}
+	}
 
-		if now.Weekday() == time.Monday {
-			history := user.RatingHistories[system]
-			if rating.CurrentRating > 0 && (history == nil || history[len(history)-1].Rating != rating.CurrentRating) {
-				if user.RatingHistories == nil {
-					user.RatingHistories = make(map[database.RatingSystem][]database.RatingHistory)
-				}
-				user.RatingHistories[system] = append(history, database.RatingHistory{
-					Date:   now.Format(time.RFC3339),
-					Rating: rating.CurrentRating,
-				})
-				shouldUpdate = true
-			}
-		}
+	if changed {
+		u.detectAnomalies(user, previousRatings)
+	}
+	if user.SubscriptionStatus == database.SubscriptionStatus_Subscribed {
+		u.checkChesscomStatus(user)
 	}
 
-	if shouldUpdate {
-		queuedUpdates = append(queuedUpdates, user)
-		if len(queuedUpdates) == 25 {
-			if err := repository.UpdateUserRatings(queuedUpdates); err != nil {
-				log.Error(err)
-			} else {
-				log.Infof("Updated %d users", len(queuedUpdates))
-			}
-			queuedUpdates = nil
-		}
+	cohort, provisional := user.DojoCohort, user.ProvisionalCohort
+	reevaluateCohort(user, u.boundaries)
+	changed = changed || user.DojoCohort != cohort || user.ProvisionalCohort != provisional
+
+	if fetchedAny {
+		user.RatingsUpdatedAt = now.UTC().Format(time.RFC3339)
+	} else if !changed {
+		return
 	}
 
-	return user, queuedUpdates
+	u.queuedUpdates = append(u.queuedUpdates, user)
+	if len(u.queuedUpdates) == 25 {
+		u.flushUpdates()
+	}
 }
 
-func updateUsers(users []*database.User) {
-	if len(users) == 0 {
+// detectAnomalies queues rating jumps since previousRatings and mismatches between the
+// user's preferred and other rating systems for admin review. Only subscribed users are
+// checked, as enforcement terminates the user's subscription.
+func (u *updater) detectAnomalies(user *database.User, previousRatings map[database.RatingSystem]int) {
+	if user.SubscriptionStatus != database.SubscriptionStatus_Subscribed {
 		return
 	}
 
-	lichessUsernames := make([]string, 0, len(users))
-	for _, user := range users {
-		if lichess := user.Ratings[database.Lichess]; lichess != nil {
-			if lichessUsername := strings.TrimSpace(lichess.Username); lichessUsername != "" {
-				lichessUsernames = append(lichessUsernames, lichessUsername)
-			}
+	for system, previous := range previousRatings {
+		if anomaly := database.DetectRatingJump(user.Username, system, previous, user.Ratings[system].CurrentRating, u.boundaries, now); anomaly != nil {
+			u.anomalies = append(u.anomalies, anomaly)
 		}
 	}
-	lichessRatings, err := ratings.FetchBulkLichessRatings(lichessUsernames)
+	u.anomalies = append(u.anomalies, database.DetectSystemMismatches(user, u.boundaries, now)...)
+}
+
+// checkChesscomStatus queues the user's chess.com account for admin review if it was
+// closed for fair play violations.
+func (u *updater) checkChesscomStatus(user *database.User) {
+	rating := user.Ratings[database.Chesscom]
+	if rating == nil || rating.Username == "" {
+		return
+	}
+
+	status, err := u.chesscomStatus(rating.Username)
 	if err != nil {
-		log.Error(err)
+		if !ratings.IsNotFound(err) {
+			log.Errorf("Failed to get chess.com status for %q: %v", rating.Username, err)
+		}
+		return
+	}
+	if status == ratings.ChesscomFairPlayClosure {
+		u.anomalies = append(u.anomalies, database.NewAccountClosureAnomaly(user.Username, database.Chesscom, rating.Username, status, now))
 	}
+}
 
-	fetchLichessRating := func(username string) (*database.Rating, error) {
-		if rating, ok := lichessRatings[strings.ToLower(username)]; !ok {
-			return nil, errors.New("no Lichess rating found in cache")
+// flushUpdates saves the queued user updates.
+func (u *updater) flushUpdates() {
+	if len(u.queuedUpdates) == 0 {
+		return
+	}
+	if err := repository.UpdateUserRatings(u.queuedUpdates); err != nil {
+		log.With(log.Fields{"failures": database.BatchFailures(err)}).Error(err)
+	} else {
+		log.Infof("Updated %d users", len(u.queuedUpdates))
+	}
+	u.queuedUpdates = nil
+}
+
+// flush saves all queued user updates, rating histories and anomalies.
+func (u *updater) flush() {
+	u.flushUpdates()
+
+	if len(u.histories) > 0 {
+		if err := repository.PutRatingHistories(u.histories); err != nil {
+			log.With(log.Fields{"failures": database.BatchFailures(err)}).Error(err)
 		} else {
-			return &database.Rating{
-				CurrentRating: rating.Performances.Classical.Rating,
-				Deviation:     rating.Performances.Classical.Deviation,
-				NumGames:      rating.Performances.Classical.NumGames,
-			}, nil
+			log.Infof("Saved %d rating history points", len(u.histories))
 		}
+		u.histories = nil
 	}
 
-	isBannedLichess := func(username string) bool {
-		if result, ok := lichessRatings[strings.ToLower(username)]; !ok {
-			return false
+	if len(u.anomalies) > 0 {
+		if created, err := repository.CreateRatingAnomalies(u.anomalies); err != nil {
+			log.Errorf("Failed to create rating anomalies after creating %d: %v", created, err)
 		} else {
-			return result.TosViolation
+			log.Infof("Flagged %d new rating anomalies", created)
 		}
+		u.anomalies = nil
 	}
+}
 
-	ratingFetchFuncs := map[database.RatingSystem]ratings.RatingFetchFunc{
-		database.Chesscom: ratings.FetchChesscomRating,
-		database.Lichess:  fetchLichessRating,
-		database.Fide:     ratings.FetchFideRating,
-		database.Uscf:     ratings.FetchUscfRating,
-		database.Ecf:      ratings.FetchEcfRating,
-		database.Cfc:      ratings.FetchCfcRating,
-		database.Dwz:      ratings.FetchDwzRating,
-		database.Acf:      ratings.FetchAcfRating,
-		database.Knsb:     ratings.FetchKnsbRating,
+// updateUsers refreshes the ratings of the given users and saves them. users must not
+// contain more than batchSize users.
+func updateUsers(users []*database.User, boundaries *database.CohortBoundaries, r *refresher) {
+	if len(users) == 0 {
+		return
 	}
 
-	var queuedUpdates []*database.User
-	for _, user := range users {
-		_, queuedUpdates = updateIfNecessary(user, queuedUpdates, ratingFetchFuncs, isBannedLichess)
+	f := r.fetch(users)
+	providers := make(map[database.RatingSystem]ratings.RatingProvider, len(r.providers)+1)
+	for system := range r.providers {
+		providers[system] = f.provider(system)
 	}
+	providers[database.Lichess] = f.provider(database.Lichess)
 
-	if len(queuedUpdates) > 0 {
-		if err := repository.UpdateUserRatings(queuedUpdates); err != nil {
-			log.Error(err)
-		} else {
-			log.Infof("Updated %d users", len(queuedUpdates))
-		}
+	u := &updater{
+		providers:       providers,
+		isBannedLichess: f.isBannedLichess,
+		chesscomStatus:  f.chesscomStatus,
+		boundaries:      boundaries,
+	}
+	for _, user := range users {
+		u.refreshUser(user)
 	}
+	u.flush()
 }
 
+// The maximum number of users refreshed at once, which is the maximum number of
+// usernames in a Lichess bulk request.
+const batchSize = 300
+
+// The time left in an invocation when it stops refreshing users, which leaves time
+// to finish the batch in progress and continue the run.
+const deadlineMargin = 2 * time.Minute
+
+// The maximum number of invocations which continue a single run, in addition to the
+// scheduled invocation.
+const maxContinuations = 5
+
 type RatingUpdateRequest struct {
 	Cohorts []database.DojoCohort `json:"cohorts"`
+
+	// The run continued by this invocation. Nil if the invocation starts a new run.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Checkpoint identifies a run of the rating update which is continued by another
+// invocation when it runs out of time.
+type Checkpoint struct {
+	// The time the run started, in time.RFC3339 format. Users whose ratings were
+	// refreshed at or after this time are skipped.
+	StartedAt string `json:"startedAt"`
+
+	// The number of invocations which have continued the run.
+	Continuations int `json:"continuations"`
+}
+
+// listStaleUsers returns the users in the given cohorts whose ratings were not refreshed
+// since startedAt, ordered by the time their ratings were last refreshed.
+func listStaleUsers(cohorts []database.DojoCohort, startedAt string) ([]*database.User, error) {
+	var stale []*database.User
+	for _, cohort := range cohorts {
+		log.Debugf("Listing cohort %s", cohort)
+
+		var users []*database.User
+		var startKey string
+		var err error
+		for ok := true; ok; ok = startKey != "" {
+			users, startKey, err = repository.ListUserRatings(cohort, startKey)
+			if err != nil {
+				return nil, err
+			}
+			for _, user := range users {
+				if user.RatingsUpdatedAt < startedAt {
+					stale = append(stale, user)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].RatingsUpdatedAt < stale[j].RatingsUpdatedAt
+	})
+	return stale, nil
+}
+
+// continueRun asynchronously invokes this function to continue the run of the given
+// request.
+func continueRun(event Event, req RatingUpdateRequest, checkpoint Checkpoint) error {
+	if checkpoint.Continuations >= maxContinuations {
+		return fmt.Errorf("run started at %s has already been continued %d times", checkpoint.StartedAt, checkpoint.Continuations)
+	}
+
+	checkpoint.Continuations++
+	req.Checkpoint = &checkpoint
+	detail, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	event.Detail = detail
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = lambdaSvc.Invoke(&awslambda.InvokeInput{
+		FunctionName:   aws.String(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")),
+		InvocationType: aws.String(awslambda.InvocationTypeEvent),
+		Payload:        payload,
+	})
+	return err
 }
 
 func Handler(ctx context.Context, event Event) (Event, error) {
@@ -177,20 +340,39 @@ func Handler(ctx context.Context, event Event) (Event, error) {
 	}
 	log.Infof("Request: %+v", req)
 
-	var startKey string
-	for _, cohort := range req.Cohorts {
-		log.Debugf("Processing cohort %s", cohort)
+	now = time.Now()
+	checkpoint := Checkpoint{StartedAt: now.UTC().Format(time.RFC3339)}
+	if req.Checkpoint != nil {
+		checkpoint = *req.Checkpoint
+	}
 
-		var users []*database.User
-		startKey = ""
-		for ok := true; ok; ok = startKey != "" {
-			users, startKey, err = repository.ListUserRatings(cohort, startKey)
-			if err != nil {
-				log.Errorf("Failed to scan users: %v", err)
-				return event, err
-			}
-			log.Infof("Processing %d users", len(users))
-			updateUsers(users)
+	boundaries, err := repository.GetCohortBoundaries(now)
+	if err != nil {
+		log.Errorf("Failed to get cohort boundaries: %v", err)
+		return event, err
+	}
+
+	users, err := listStaleUsers(req.Cohorts, checkpoint.StartedAt)
+	if err != nil {
+		log.Errorf("Failed to list users: %v", err)
+		return event, err
+	}
+	log.Infof("Refreshing %d users not refreshed since %s", len(users), checkpoint.StartedAt)
+
+	deadline, ok := ctx.Deadline()
+	r := newRefresher()
+	for len(users) > 0 && (!ok || time.Until(deadline) > deadlineMargin) {
+		batch := users[:min(len(users), batchSize)]
+		users = users[len(batch):]
+		log.Infof("Processing %d users", len(batch))
+		updateUsers(batch, boundaries, r)
+	}
+	r.logMetrics()
+
+	if len(users) > 0 {
+		log.Warnf("Continuing run with %d users remaining", len(users))
+		if err := continueRun(event, req, checkpoint); err != nil {
+			log.Errorf("Failed to continue run: %v", err)
 		}
 	}
 
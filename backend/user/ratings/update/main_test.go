@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
+)
+
+func TestRefreshUser(t *testing.T) {
+	now = time.Date(2024, 10, 10, 6, 0, 0, 0, time.UTC)
+	previous := "2024-10-09T06:00:00Z"
+
+	fide := ratings.RatingFetchFunc(func(username string) (*database.Rating, error) {
+		switch username {
+		case "down":
+			return nil, errors.New(500, "Temporary server error", "provider outage")
+		case "missing":
+			return nil, errors.New(404, "Invalid request: player not found", "", errors.WithReason(errors.ReasonRatingNotFound))
+		}
+		return &database.Rating{CurrentRating: 1500}, nil
+	})
+
+	table := []struct {
+		name            string
+		user            *database.User
+		wantSaved       bool
+		wantRefreshedAt string
+	}{
+		{
+			name: "Fetched",
+			user: &database.User{
+				DojoCohort: "1400-1500",
+				Ratings:    map[database.RatingSystem]*database.Rating{database.Fide: {Username: "found", CurrentRating: 1500, StartRating: 1500}},
+			},
+			wantSaved:       true,
+			wantRefreshedAt: now.Format(time.RFC3339),
+		},
+		{
+			name: "FetchFailed",
+			user: &database.User{
+				DojoCohort: "1400-1500",
+				Ratings:    map[database.RatingSystem]*database.Rating{database.Fide: {Username: "down", CurrentRating: 1500}},
+			},
+			wantRefreshedAt: previous,
+		},
+		{
+			name: "NotFound",
+			user: &database.User{
+				DojoCohort: "1400-1500",
+				Ratings:    map[database.RatingSystem]*database.Rating{database.Fide: {Username: "missing", CurrentRating: 1500}},
+			},
+			wantRefreshedAt: previous,
+		},
+		{
+			name: "FetchFailedCohortChanged",
+			user: &database.User{
+				DojoCohort:        "1400-1500",
+				ProvisionalCohort: true,
+				RatingSystem:      database.Fide,
+				Ratings:           map[database.RatingSystem]*database.Rating{database.Fide: {Username: "down", CurrentRating: 1500, NumGames: 50}},
+			},
+			wantSaved:       true,
+			wantRefreshedAt: previous,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.user.RatingsUpdatedAt = previous
+			u := &updater{
+				providers:       map[database.RatingSystem]ratings.RatingProvider{database.Fide: fide},
+				isBannedLichess: func(string) bool { return false },
+				boundaries:      &database.DefaultCohortBoundaries,
+			}
+			u.refreshUser(tc.user)
+
+			if saved := len(u.queuedUpdates) == 1; saved != tc.wantSaved {
+				t.Errorf("refreshUser saved the user: %v; want %v", saved, tc.wantSaved)
+			}
+			if tc.user.RatingsUpdatedAt != tc.wantRefreshedAt {
+				t.Errorf("refreshUser got ratingsUpdatedAt %q; want %q", tc.user.RatingsUpdatedAt, tc.wantRefreshedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
+)
+
+// The CloudWatch namespace of the metrics written by the rating update.
+const metricsNamespace = "ChessDojo/RatingUpdate"
+
+type lichessBulkFunc func(lichessUsernames []string) (map[string]ratings.LichessResponse, error)
+
+// task is a single request sent to a rating system.
+type task struct {
+	system database.RatingSystem
+	run    func() error
+}
+
+// providerMetrics counts the results of the requests sent to a rating system.
+type providerMetrics struct {
+	succeeded int
+	notFound  int
+	failed    int
+	latency   time.Duration
+}
+
+// refresher fetches ratings through a pool of workers for each rating system, so that
+// a slow rating system does not hold up the others. The size of each pool limits the
+// concurrent requests sent to the rating system, and each provider spaces its requests
+// by its ProviderConfig.MinInterval.
+type refresher struct {
+	providers      map[database.RatingSystem]ratings.RatingProvider
+	lichessBulk    lichessBulkFunc
+	chesscomStatus accountStatusFunc
+	concurrency    map[database.RatingSystem]int
+
+	mu      sync.Mutex
+	metrics map[database.RatingSystem]*providerMetrics
+}
+
+func newRefresher() *refresher {
+	concurrency := make(map[database.RatingSystem]int, len(ratings.DefaultConfigs))
+	for system, config := range ratings.DefaultConfigs {
+		concurrency[system] = config.Concurrency
+	}
+
+	return &refresher{
+		providers:      ratings.Providers,
+		lichessBulk:    ratings.Lichess.FetchBulk,
+		chesscomStatus: ratings.Chesscom.FetchAccountStatus,
+		concurrency:    concurrency,
+		metrics:        make(map[database.RatingSystem]*providerMetrics),
+	}
+}
+
+// run runs the tasks on the workers of their rating systems and returns once all of
+// them finish.
+func (r *refresher) run(tasks []task) {
+	grouped := make(map[database.RatingSystem][]task)
+	for _, t := range tasks {
+		grouped[t.system] = append(grouped[t.system], t)
+	}
+
+	var wg sync.WaitGroup
+	for system, systemTasks := range grouped {
+		queue := make(chan task, len(systemTasks))
+		for _, t := range systemTasks {
+			queue <- t
+		}
+		close(queue)
+
+		workers := min(max(r.concurrency[system], 1), len(systemTasks))
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range queue {
+					r.record(t)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// record runs the task and adds its result to the metrics of its rating system.
+func (r *refresher) record(t task) {
+	start := time.Now()
+	err := t.run()
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.metrics[t.system]
+	if m == nil {
+		m = &providerMetrics{}
+		r.metrics[t.system] = m
+	}
+	m.latency += latency
+	switch {
+	case err == nil:
+		m.succeeded++
+	case ratings.IsNotFound(err):
+		m.notFound++
+	default:
+		m.failed++
+	}
+}
+
+// fetchKey identifies a player in a rating system.
+type fetchKey struct {
+	system   database.RatingSystem
+	username string
+}
+
+type fetchResult struct {
+	rating *database.Rating
+	err    error
+}
+
+type statusResult struct {
+	status string
+	err    error
+}
+
+// fetched contains the results of a refresher's requests for a batch of users.
+type fetched struct {
+	mu          sync.Mutex
+	ratings     map[fetchKey]fetchResult
+	statuses    map[string]statusResult
+	lichessBans map[string]bool
+}
+
+var errNotFetched = errors.New("rating was not fetched")
+
+var errLichessNotFound = errors.New("no Lichess rating found in bulk response")
+
+// provider returns a RatingProvider which serves the fetched ratings of the given system.
+func (f *fetched) provider(system database.RatingSystem) ratings.RatingProvider {
+	return ratings.RatingFetchFunc(func(username string) (*database.Rating, error) {
+		result, ok := f.ratings[fetchKey{system, strings.ToLower(username)}]
+		if !ok {
+			return nil, errNotFetched
+		}
+		return result.rating, result.err
+	})
+}
+
+func (f *fetched) chesscomStatus(username string) (string, error) {
+	result, ok := f.statuses[strings.ToLower(username)]
+	if !ok {
+		return "", errNotFetched
+	}
+	return result.status, result.err
+}
+
+func (f *fetched) isBannedLichess(username string) bool {
+	return f.lichessBans[strings.ToLower(username)]
+}
+
+// fetch fetches the ratings of the given users, and the chess.com account status of the
+// subscribed users. Each player is fetched once, even if they are linked to multiple users.
+// Lichess ratings are fetched in a single bulk request, so users must not contain more
+// Lichess accounts than the bulk request allows.
+func (r *refresher) fetch(users []*database.User) *fetched {
+	f := &fetched{
+		ratings:     make(map[fetchKey]fetchResult),
+		statuses:    make(map[string]statusResult),
+		lichessBans: make(map[string]bool),
+	}
+
+	var tasks []task
+	var lichessUsernames []string
+	seen := make(map[fetchKey]bool)
+	seenStatuses := make(map[string]bool)
+	for _, user := range users {
+		for system, rating := range user.Ratings {
+			username := strings.TrimSpace(rating.Username)
+			key := fetchKey{system, strings.ToLower(username)}
+			if username == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if system == database.Lichess {
+				lichessUsernames = append(lichessUsernames, username)
+				continue
+			}
+			provider := r.providers[system]
+			if provider == nil {
+				continue
+			}
+			tasks = append(tasks, task{system: system, run: func() error {
+				rating, err := provider.FetchRating(username)
+				f.mu.Lock()
+				f.ratings[key] = fetchResult{rating: rating, err: err}
+				f.mu.Unlock()
+				return err
+			}})
+		}
+
+		if chesscom := user.Ratings[database.Chesscom]; chesscom != nil && user.SubscriptionStatus == database.SubscriptionStatus_Subscribed {
+			username := strings.TrimSpace(chesscom.Username)
+			key := strings.ToLower(username)
+			if username != "" && !seenStatuses[key] {
+				seenStatuses[key] = true
+				tasks = append(tasks, task{system: database.Chesscom, run: func() error {
+					status, err := r.chesscomStatus(username)
+					f.mu.Lock()
+					f.statuses[key] = statusResult{status: status, err: err}
+					f.mu.Unlock()
+					return err
+				}})
+			}
+		}
+	}
+
+	if len(lichessUsernames) > 0 {
+		tasks = append(tasks, task{system: database.Lichess, run: func() error {
+			return r.fetchLichess(f, lichessUsernames)
+		}})
+	}
+
+	r.run(tasks)
+	return f
+}
+
+// fetchLichess saves the results of a Lichess bulk request for the given usernames in f.
+func (r *refresher) fetchLichess(f *fetched, lichessUsernames []string) error {
+	profiles, err := r.lichessBulk(lichessUsernames)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, username := range lichessUsernames {
+		key := fetchKey{database.Lichess, strings.ToLower(username)}
+		if err != nil {
+			f.ratings[key] = fetchResult{err: err}
+		} else if profile, ok := profiles[key.username]; ok {
+			f.ratings[key] = fetchResult{rating: profile.Rating()}
+			f.lichessBans[key.username] = profile.TosViolation
+		} else {
+			f.ratings[key] = fetchResult{err: errLichessNotFound}
+		}
+	}
+	return err
+}
+
+// logMetrics writes the metrics of each rating system in the CloudWatch embedded metric
+// format, so that they are extracted from the logs as the Succeeded, NotFound, Failed and
+// Latency metrics with a RatingSystem dimension.
+func (r *refresher) logMetrics() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for system, m := range r.metrics {
+		requests := m.succeeded + m.notFound + m.failed
+		log.With(log.Fields{
+			"_aws": map[string]interface{}{
+				"Timestamp": time.Now().UnixMilli(),
+				"CloudWatchMetrics": []interface{}{
+					map[string]interface{}{
+						"Namespace":  metricsNamespace,
+						"Dimensions": [][]string{{"RatingSystem"}},
+						"Metrics": []map[string]string{
+							{"Name": "Succeeded", "Unit": "Count"},
+							{"Name": "NotFound", "Unit": "Count"},
+							{"Name": "Failed", "Unit": "Count"},
+							{"Name": "Latency", "Unit": "Milliseconds"},
+						},
+					},
+				},
+			},
+			"RatingSystem": system,
+			"Succeeded":    m.succeeded,
+			"NotFound":     m.notFound,
+			"Failed":       m.failed,
+			"Latency":      m.latency.Milliseconds() / int64(max(requests, 1)),
+		}).Infof("%s: %d succeeded, %d not found, %d failed", system, m.succeeded, m.notFound, m.failed)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
+)
+
+func TestRefresherConcurrency(t *testing.T) {
+	var inFlight, maxInFlight, requests int32
+	fide := ratings.RatingFetchFunc(func(username string) (*database.Rating, error) {
+		atomic.AddInt32(&requests, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			prev := atomic.LoadInt32(&maxInFlight)
+			if n <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if username == "missing" {
+			return nil, errors.New(404, "Invalid request: player not found", "", errors.WithReason(errors.ReasonRatingNotFound))
+		}
+		return &database.Rating{CurrentRating: 1500}, nil
+	})
+
+	r := &refresher{
+		providers:   map[database.RatingSystem]ratings.RatingProvider{database.Fide: fide},
+		concurrency: map[database.RatingSystem]int{database.Fide: 2},
+		metrics:     make(map[database.RatingSystem]*providerMetrics),
+	}
+
+	users := []*database.User{{Ratings: map[database.RatingSystem]*database.Rating{database.Fide: {Username: "missing"}}}}
+	for _, id := range strings.Split("1 2 3 4 5 6 7 8 1 2", " ") {
+		users = append(users, &database.User{Ratings: map[database.RatingSystem]*database.Rating{database.Fide: {Username: id}}})
+	}
+
+	f := r.fetch(users)
+	if requests != 9 {
+		t.Errorf("fetch sent %d requests; want 9, one per distinct player", requests)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("fetch sent %d concurrent requests; want at most 2", maxInFlight)
+	}
+	if m := r.metrics[database.Fide]; m == nil || m.succeeded != 8 || m.notFound != 1 || m.failed != 0 {
+		t.Errorf("fetch got metrics %+v; want 8 succeeded and 1 not found", m)
+	}
+
+	if rating, err := f.provider(database.Fide).FetchRating("3"); err != nil || rating.CurrentRating != 1500 {
+		t.Errorf("provider(FIDE).FetchRating(3) got %+v, %v; want 1500, nil", rating, err)
+	}
+	if _, err := f.provider(database.Fide).FetchRating("missing"); !ratings.IsNotFound(err) {
+		t.Errorf("provider(FIDE).FetchRating(missing) got err %v; want not found", err)
+	}
+}
+
+func TestRefresherFetch(t *testing.T) {
+	var mu sync.Mutex
+	var bulkRequests [][]string
+	var statusRequests []string
+
+	r := &refresher{
+		providers: map[database.RatingSystem]ratings.RatingProvider{},
+		lichessBulk: func(lichessUsernames []string) (map[string]ratings.LichessResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			bulkRequests = append(bulkRequests, lichessUsernames)
+			return map[string]ratings.LichessResponse{"banned": {Id: "banned", TosViolation: true}}, nil
+		},
+		chesscomStatus: func(username string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			statusRequests = append(statusRequests, username)
+			return ratings.ChesscomFairPlayClosure, nil
+		},
+		metrics: make(map[database.RatingSystem]*providerMetrics),
+	}
+
+	users := []*database.User{
+		{
+			SubscriptionStatus: database.SubscriptionStatus_Subscribed,
+			Ratings: map[database.RatingSystem]*database.Rating{
+				database.Lichess:  {Username: "Banned"},
+				database.Chesscom: {Username: "subscriber"},
+			},
+		},
+		{
+			SubscriptionStatus: database.SubscriptionStatus_FreeTier,
+			Ratings: map[database.RatingSystem]*database.Rating{
+				database.Lichess:  {Username: "unknown"},
+				database.Chesscom: {Username: "free"},
+			},
+		},
+	}
+
+	f := r.fetch(users)
+	if len(bulkRequests) != 1 || len(bulkRequests[0]) != 2 {
+		t.Errorf("fetch sent Lichess bulk requests %v; want a single request for both users", bulkRequests)
+	}
+	if len(statusRequests) != 1 || statusRequests[0] != "subscriber" {
+		t.Errorf("fetch sent chess.com status requests %v; want only the subscriber", statusRequests)
+	}
+	if !f.isBannedLichess("banned") || f.isBannedLichess("unknown") {
+		t.Errorf("isBannedLichess got %v; want only banned", f.lichessBans)
+	}
+	if _, err := f.provider(database.Lichess).FetchRating("unknown"); err == nil {
+		t.Error("provider(Lichess).FetchRating(unknown) got nil err; want an error")
+	}
+	if status, err := f.chesscomStatus("Subscriber"); err != nil || status != ratings.ChesscomFairPlayClosure {
+		t.Errorf("chesscomStatus(Subscriber) got %q, %v; want %q, nil", status, err, ratings.ChesscomFairPlayClosure)
+	}
+	if m := r.metrics[database.Lichess]; m == nil || m.succeeded != 1 {
+		t.Errorf("fetch got Lichess metrics %+v; want 1 succeeded", m)
+	}
+}
@@ -0,0 +1,98 @@
+package ratings
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+)
+
+// LichessClientId is the OAuth client id sent to Lichess, which does not require
+// clients to be registered.
+const LichessClientId = "chessdojo.club"
+
+// LichessAccount is the Lichess account of a user authenticated through OAuth.
+type LichessAccount struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// NewSecret returns a random, URL-safe string encoding n bytes. It is used for OAuth
+// states, PKCE code verifiers and account verification tokens.
+func NewSecret(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(500, "Temporary server error", "Failed to generate secret", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of the given code verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizationURL returns the URL of the Lichess page where the user signs in and
+// authorizes the given OAuth client. Lichess then redirects the user to redirectUri with
+// the authorization code and the given state.
+func (p *LichessProvider) AuthorizationURL(clientId, redirectUri, state, codeChallenge string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", clientId)
+	query.Set("redirect_uri", redirectUri)
+	query.Set("code_challenge_method", "S256")
+	query.Set("code_challenge", codeChallenge)
+	query.Set("state", state)
+	return p.config.BaseURL + "/oauth?" + query.Encode()
+}
+
+// ExchangeCode exchanges an OAuth authorization code for an access token, using the code
+// verifier whose challenge was sent to AuthorizationURL.
+func (p *LichessProvider) ExchangeCode(clientId, redirectUri, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("redirect_uri", redirectUri)
+	form.Set("client_id", clientId)
+
+	b, err := p.post("/api/token", "application/x-www-form-urlencoded", form.Encode())
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeJson(p.name, b, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New(500, "Temporary server error", "Lichess returned an empty access token")
+	}
+	return token.AccessToken, nil
+}
+
+// FetchAccount returns the account which authorized the given access token.
+func (p *LichessProvider) FetchAccount(accessToken string) (*LichessAccount, error) {
+	b, err := p.authorized(http.MethodGet, "/api/account", accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var account LichessAccount
+	if err := decodeJson(p.name, b, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// RevokeToken revokes the given access token, so that it cannot be used again.
+func (p *LichessProvider) RevokeToken(accessToken string) error {
+	_, err := p.authorized(http.MethodDelete, "/api/token", accessToken)
+	return err
+}
@@ -0,0 +1,141 @@
+// Implements a Lambda handler which completes the verification of the caller's Lichess or
+// chess.com account, started by user/ratings/verify/start. For Lichess, the request contains
+// the OAuth code and state Lichess sent to the redirect URI, and the account which signed in
+// must be the linked account. For chess.com, the token must be in the location of the linked
+// account's profile. On success, the rating is marked as verified and the updated user is
+// returned.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
+)
+
+var repository database.UserUpdater = database.DynamoDB
+
+type CompleteVerificationRequest struct {
+	// The rating system of the account to verify. Either LICHESS or CHESSCOM.
+	RatingSystem database.RatingSystem `json:"ratingSystem"`
+
+	// The OAuth authorization code sent by Lichess. Required for LICHESS.
+	Code string `json:"code"`
+
+	// The OAuth state sent by Lichess. Required for LICHESS.
+	State string `json:"state"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+	))
+}
+
+// verificationFailed returns a 400 error with reason errors.ReasonAccountVerificationFailed.
+func verificationFailed(publicMsg string) error {
+	return errors.New(400, publicMsg, "", errors.WithReason(errors.ReasonAccountVerificationFailed))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := CompleteVerificationRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+	if !database.IsVerifiable(req.RatingSystem) {
+		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: accounts in rating system `%s` cannot be verified", req.RatingSystem), "")), nil
+	}
+
+	verification := caller.AccountVerifications[req.RatingSystem]
+	if verification == nil || verification.IsExpired(time.Now()) {
+		return api.Failure(verificationFailed("Your verification has expired. Please start again")), nil
+	}
+	if rating := caller.Ratings[req.RatingSystem]; rating == nil || !strings.EqualFold(strings.TrimSpace(rating.Username), verification.Username) {
+		return api.Failure(verificationFailed("Your linked account changed during verification. Please start again")), nil
+	}
+
+	username := verification.Username
+	var err error
+	if req.RatingSystem == database.Lichess {
+		username, err = verifyLichess(req, verification)
+	} else {
+		err = verifyChesscom(verification)
+	}
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	user, err := repository.ModifyUser(caller.Username, func(user *database.User) (*database.UserUpdate, error) {
+		rating := user.Ratings[req.RatingSystem]
+		if rating == nil || !strings.EqualFold(strings.TrimSpace(rating.Username), verification.Username) {
+			return nil, verificationFailed("Your linked account changed during verification. Please start again")
+		}
+		rating.Username = username
+		rating.Verified = true
+
+		verifications := make(map[database.RatingSystem]*database.AccountVerification, len(user.AccountVerifications))
+		for system, v := range user.AccountVerifications {
+			if system != req.RatingSystem {
+				verifications[system] = v
+			}
+		}
+		return &database.UserUpdate{Ratings: &user.Ratings, AccountVerifications: &verifications}, nil
+	})
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	return api.Success(user), nil
+}
+
+// verifyLichess exchanges the OAuth code for an access token and checks that the account
+// which authorized it is the one being verified. Returns the username of the account.
+func verifyLichess(req CompleteVerificationRequest, verification *database.AccountVerification) (string, error) {
+	if req.Code == "" {
+		return "", errors.New(400, "Invalid request: code is required", "")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.State), []byte(verification.Token)) != 1 {
+		return "", verificationFailed("Invalid request: state does not match")
+	}
+
+	token, err := ratings.Lichess.ExchangeCode(ratings.LichessClientId, verification.RedirectUri, req.Code, verification.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+	account, err := ratings.Lichess.FetchAccount(token)
+	if err := ratings.Lichess.RevokeToken(token); err != nil {
+		log.Warnf("Failed to revoke Lichess token: %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.EqualFold(account.Id, verification.Username) {
+		return "", verificationFailed(fmt.Sprintf("You signed in to Lichess as %s, but your linked Lichess account is %s", account.Username, verification.Username))
+	}
+	return account.Username, nil
+}
+
+// verifyChesscom checks that the verification token is in the location of the chess.com profile.
+func verifyChesscom(verification *database.AccountVerification) error {
+	profile, err := ratings.Chesscom.FetchProfile(verification.Username)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(profile.Location, verification.Token) {
+		return verificationFailed(fmt.Sprintf("The token %s was not found in the location of your chess.com profile. Chess.com can take a few minutes to show profile changes", verification.Token))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Implements a Lambda handler which starts the verification of the caller's Lichess or
+// chess.com account. For Lichess, the response contains the URL of the Lichess OAuth page,
+// which redirects the user back to redirectUri. For chess.com, the response contains a
+// token which the user must add to the location of their chess.com profile. In both
+// cases, the verification is completed by user/ratings/verify/complete.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/user/ratings"
+)
+
+var repository database.UserUpdater = database.DynamoDB
+
+var frontendHost = os.Getenv("frontendHost")
+
+type StartVerificationRequest struct {
+	// The rating system of the account to verify. Either LICHESS or CHESSCOM.
+	RatingSystem database.RatingSystem `json:"ratingSystem"`
+
+	// The page Lichess redirects the user to after they authorize the Dojo. Required for
+	// LICHESS and must be on the frontend's host.
+	RedirectUri string `json:"redirectUri"`
+}
+
+type StartVerificationResponse struct {
+	// The username of the account being verified.
+	Username string `json:"username"`
+
+	// The URL of the Lichess OAuth page. Only present for LICHESS.
+	AuthorizationUrl string `json:"authorizationUrl,omitempty"`
+
+	// The token to add to the location of the chess.com profile. Only present for CHESSCOM.
+	Token string `json:"token,omitempty"`
+
+	// When the verification expires, in time.RFC3339 format.
+	ExpiresAt string `json:"expiresAt"`
+}
+
+func main() {
+	lambda.Start(api.Chain(handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+	))
+}
+
+func handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := StartVerificationRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+	if !database.IsVerifiable(req.RatingSystem) {
+		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: accounts in rating system `%s` cannot be verified", req.RatingSystem), "")), nil
+	}
+	if req.RatingSystem == database.Lichess && (frontendHost == "" || !strings.HasPrefix(req.RedirectUri, frontendHost+"/")) {
+		return api.Failure(errors.New(400, "Invalid request: redirectUri must be a page of the ChessDojo site", "")), nil
+	}
+
+	var verification *database.AccountVerification
+	_, err := repository.ModifyUser(caller.Username, func(user *database.User) (*database.UserUpdate, error) {
+		rating := user.Ratings[req.RatingSystem]
+		if rating == nil || strings.TrimSpace(rating.Username) == "" {
+			return nil, errors.New(400, fmt.Sprintf("Invalid request: you must link your %s account before verifying it", req.RatingSystem), "")
+		}
+		if rating.Verified {
+			return nil, errors.New(400, fmt.Sprintf("Invalid request: your %s account is already verified", req.RatingSystem), "")
+		}
+
+		var err error
+		verification, err = newVerification(req, strings.TrimSpace(rating.Username))
+		if err != nil {
+			return nil, err
+		}
+
+		verifications := make(map[database.RatingSystem]*database.AccountVerification, len(user.AccountVerifications)+1)
+		for system, v := range user.AccountVerifications {
+			verifications[system] = v
+		}
+		verifications[req.RatingSystem] = verification
+		return &database.UserUpdate{AccountVerifications: &verifications}, nil
+	})
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	resp := StartVerificationResponse{Username: verification.Username, ExpiresAt: verification.ExpiresAt}
+	if req.RatingSystem == database.Lichess {
+		resp.AuthorizationUrl = ratings.Lichess.AuthorizationURL(ratings.LichessClientId, verification.RedirectUri, verification.Token, ratings.CodeChallenge(verification.CodeVerifier))
+	} else {
+		resp.Token = verification.Token
+	}
+	return api.Success(resp), nil
+}
+
+// newVerification returns a new verification of the given account. For Lichess, the
+// token is the OAuth state and the verification contains the PKCE code verifier.
+func newVerification(req StartVerificationRequest, username string) (*database.AccountVerification, error) {
+	verification := &database.AccountVerification{
+		Username:  username,
+		ExpiresAt: time.Now().Add(database.AccountVerificationTTL).Format(time.RFC3339),
+	}
+
+	if req.RatingSystem == database.Lichess {
+		state, err := ratings.NewSecret(32)
+		if err != nil {
+			return nil, err
+		}
+		codeVerifier, err := ratings.NewSecret(48)
+		if err != nil {
+			return nil, err
+		}
+		verification.Token = state
+		verification.CodeVerifier = codeVerifier
+		verification.RedirectUri = req.RedirectUri
+		return verification, nil
+	}
+
+	token, err := ratings.NewSecret(6)
+	if err != nil {
+		return nil, err
+	}
+	verification.Token = "chessdojo-" + token
+	return verification, nil
+}
@@ -0,0 +1,102 @@
+// Implements a Lambda handler which assigns or revokes a role of a user.
+// The caller must have the roles.write permission for the role's scope.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/errors"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+type RoleEditor interface {
+	database.UserRoleEditor
+	database.AuditEntryCreator
+}
+
+var repository RoleEditor = database.DynamoDB
+
+type EditRoleRequest struct {
+	// The username of the user whose role is edited.
+	Username string `json:"username"`
+
+	// Either `assign` or `revoke`.
+	Action string `json:"action"`
+
+	// The role to assign or revoke.
+	Role database.Role `json:"role"`
+
+	// The scope of the role to assign or revoke, such as club:clubId. Empty for global roles.
+	Scope string `json:"scope"`
+
+	// The reason for the edit, which is saved in the audit log.
+	Reason string `json:"reason"`
+}
+
+type EditRoleResponse struct {
+	// The user's roles after the edit.
+	Roles []database.RoleAssignment `json:"roles"`
+
+	// The history of the user's role changes, including the edit.
+	RoleHistory []database.RoleChange `json:"roleHistory"`
+}
+
+func main() {
+	lambda.Start(api.Chain(Handler,
+		api.Recover(),
+		api.LogRequest(),
+		api.RequireUser(repository),
+	))
+}
+
+func Handler(ctx context.Context, event api.Request) (api.Response, error) {
+	caller := api.UserFromContext(ctx)
+
+	req := EditRoleRequest{}
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
+		return api.Failure(errors.Wrap(400, "Invalid request: failed to unmarshal request body", "", err)), nil
+	}
+	if req.Username == "" {
+		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
+	}
+	if err := api.Authorize(caller, database.PermissionRolesWrite, req.Scope); err != nil {
+		return api.Failure(err), nil
+	}
+
+	user, err := repository.GetUser(req.Username)
+	if err != nil {
+		return api.Failure(err), nil
+	}
+	before := user.GetRoles()
+
+	var action database.AuditAction
+	switch req.Action {
+	case "assign":
+		action = database.AuditAction_RoleAssign
+		user, err = repository.AssignUserRole(req.Username, database.RoleAssignment{
+			Role:       req.Role,
+			Scope:      req.Scope,
+			AssignedBy: caller.Username,
+			AssignedAt: time.Now().Format(time.RFC3339),
+		})
+	case "revoke":
+		action = database.AuditAction_RoleRevoke
+		user, err = repository.RevokeUserRole(req.Username, req.Role, req.Scope, caller.Username)
+	default:
+		err = errors.New(400, "Invalid request: action must be `assign` or `revoke`", "")
+	}
+	if err != nil {
+		return api.Failure(err), nil
+	}
+
+	entry := database.NewAuditEntry(caller.Username, action, database.UserAuditTarget(req.Username), req.Reason)
+	if err := repository.CreateAuditEntry(entry, map[string]interface{}{"roles": before}, map[string]interface{}{"roles": user.GetRoles()}); err != nil {
+		log.Error("Failed CreateAuditEntry: ", err)
+	}
+	return api.Success(EditRoleResponse{Roles: user.GetRoles(), RoleHistory: user.RoleHistory}), nil
+}
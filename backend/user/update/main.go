@@ -30,13 +30,10 @@ const (
 )
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(api.Chain(Handler, api.Recover(), api.LogRequest()))
 }
 
 func Handler(ctx context.Context, event api.Request) (api.Response, error) {
-	log.SetRequestId(event.RequestContext.RequestID)
-	log.Infof("Event: %#v", event)
-
 	info := api.GetUserInfo(event)
 	if info.Username == "" {
 		return api.Failure(errors.New(400, "Invalid request: username is required", "")), nil
@@ -77,6 +74,14 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 		if *update.DojoCohort == "" {
 			return api.Failure(errors.New(400, "Invalid request: dojoCohort cannot be empty", "")), nil
 		}
+		if user.ProvisionalCohort && *update.DojoCohort != user.DojoCohort {
+			update.ProvisionalCohort = aws.Bool(false)
+		}
+		if *update.DojoCohort != user.DojoCohort && (user.CohortDropSince != "" || user.SuggestedCohort != "") {
+			suggestedCohort := database.DojoCohort("")
+			update.CohortDropSince = aws.String("")
+			update.SuggestedCohort = &suggestedCohort
+		}
 	}
 
 	if err := saveReferralSource(ctx, user, update); err != nil {
@@ -128,25 +133,21 @@ func Handler(ctx context.Context, event api.Request) (api.Response, error) {
 	return api.Success(newUser), nil
 }
 
-func fetchCurrentRating(rating *database.Rating, fetcher ratings.RatingFetchFunc) error {
+func fetchCurrentRating(system database.RatingSystem, rating *database.Rating, provider ratings.RatingProvider) error {
 	rating.Username = strings.TrimSpace(rating.Username)
 	if rating.Username == "" {
 		rating.CurrentRating = 0
 		rating.StartRating = 0
+		rating.TimeControls = nil
 		return nil
 	}
 
-	data, err := fetcher(rating.Username)
+	data, err := provider.FetchRating(rating.Username)
 	if err != nil {
 		return err
 	}
 
-	rating.CurrentRating = data.CurrentRating
-	rating.Deviation = data.Deviation
-	rating.NumGames = data.NumGames
-	if rating.StartRating == 0 {
-		rating.StartRating = data.CurrentRating
-	}
+	rating.Update(system, data)
 	return nil
 }
 
@@ -156,11 +157,25 @@ func fetchRatings(user *database.User, update *database.UserUpdate) error {
 	}
 
 	for system, rating := range *update.Ratings {
+		if !database.SupportsTimeControl(system, rating.TimeControl) {
+			return errors.New(400, fmt.Sprintf("Invalid request: %s does not support time control %q", system, rating.TimeControl), "")
+		}
+
 		existingRating := user.Ratings[system]
-		if system != database.Custom && system != database.Custom2 && system != database.Custom3 && (existingRating == nil || rating.Username != existingRating.Username || rating.CurrentRating == 0 || rating.StartRating == 0) {
-			if err := fetchCurrentRating(rating, ratings.RatingFetchFuncs[system]); err != nil {
+		if existingRating != nil && rating.Username != existingRating.Username {
+			rating.TimeControls = nil
+		}
+		// Accounts are only verified by user/ratings/verify, and changing the
+		// username requires verifying the new account.
+		rating.Verified = existingRating != nil && existingRating.Verified && strings.EqualFold(rating.Username, existingRating.Username)
+		if system != database.Custom && system != database.Custom2 && system != database.Custom3 && (existingRating == nil || rating.Username != existingRating.Username || rating.TimeControl != existingRating.TimeControl || rating.CurrentRating == 0 || rating.StartRating == 0) {
+			if err := fetchCurrentRating(system, rating, ratings.Providers[system]); err != nil {
 				return err
 			}
+		} else if existingRating != nil {
+			// The deviation and number of games are not sent by the client.
+			rating.Deviation = existingRating.Deviation
+			rating.NumGames = existingRating.NumGames
 		}
 	}
 	return nil
@@ -177,11 +192,18 @@ func handleAutopickCohort(user *database.User, update *database.UserUpdate) api.
 	if err := fetchRatings(user, update); err != nil {
 		return api.Failure(err)
 	}
-	if cohort := update.AutopickCohort(); cohort == database.NoCohort {
+	boundaries, err := repository.GetCohortBoundaries(time.Now())
+	if err != nil {
+		return api.Failure(err)
+	}
+	if _, ok := boundaries.Boundaries[*update.RatingSystem]; !ok {
+		return api.Failure(errors.New(400, fmt.Sprintf("Invalid request: %s has no cohort boundaries, so the cohort must be chosen manually", *update.RatingSystem), ""))
+	}
+	if cohort := update.AutopickCohort(boundaries); cohort == database.NoCohort {
 		return api.Failure(errors.New(500, "Unable to choose cohort. Please contact support", fmt.Sprintf("Autopick cohort returned NoCohort for update %#v", update)))
 	}
 
-	user, err := repository.UpdateUser(user.Username, update)
+	user, err = repository.UpdateUser(user.Username, update)
 	if err != nil {
 		return api.Failure(err)
 	}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/api/log"
 	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
@@ -20,6 +21,9 @@ const ACTIVE_DEADLINE = PERIOD + "-09-31"
 
 var repository = database.DynamoDB
 
+// The cohort boundaries in effect at the end of the period.
+var boundaries *database.CohortBoundaries
+
 type percentileTrackers struct {
 	ratings    map[string]map[database.DojoCohort][]float32
 	dojoPoints map[database.DojoCohort][]float32
@@ -54,6 +58,21 @@ func main() {
 		}
 	}()
 
+	end, err := time.Parse(time.DateOnly, END_DATE)
+	if err != nil {
+		log.Errorf("Failed to parse END_DATE: %v", err)
+		os.Exit(1)
+	}
+	boundaries, err = repository.GetCohortBoundaries(end.AddDate(0, 0, 1).Add(-time.Second))
+	if err != nil {
+		log.Errorf("Failed to get cohort boundaries: %v", err)
+		os.Exit(1)
+	}
+	if boundaries.Id == "" {
+		// No version was published before the end of the period.
+		boundaries = legacyBoundaries(boundaries)
+	}
+
 	requirements, err := fetchRequirements()
 	if err != nil {
 		log.Errorf("Failed to get requirements: %v", err)
@@ -109,7 +128,7 @@ func main() {
 
 	success, err := repository.PutYearReviews(reviews)
 	if err != nil {
-		log.Errorf("Error while saving. Only %d saved. %v", success, err)
+		log.With(log.Fields{"failures": database.BatchFailures(err)}).Errorf("Error while saving. Only %d saved. %v", success, err)
 	} else {
 		log.Debugf("Saved %d reviews", success)
 	}
@@ -549,355 +568,32 @@ func calculatePercentile(dataset []float32, value float32) float32 {
 	return float32(lower) / float32(len(dataset)) * 100
 }
 
-func getNormalizedRating(rating int, ratingSystem database.RatingSystem) float32 {
-	if ratingSystem == database.Custom {
-		return -1
-	}
-
-	for _, cohort := range database.Cohorts {
-		x2 := getRatingBoundary(cohort, ratingSystem)
-		if x2 <= 0 {
-			continue
-		}
-
-		if x2 >= rating {
-			x1 := getMinRatingBoundary(cohort, ratingSystem)
-
-			y1, y2 := getCohortRangeInt(cohort)
-
-			if y1 == -1 {
-				y1 = 0
-			}
-			if y2 == -1 {
-				y2 = 0
-			}
-
-			result := (float32(y2-y1)/float32(x2-x1))*float32(rating-x1) + float32(y1)
-			return result
-		}
-	}
-
-	// We are in the 2400+ cohort if we make it here, so we just extrapolate from the 2300-2400 line
-	x1 := getMinRatingBoundary("2300-2400", ratingSystem)
-	x2 := getRatingBoundary("2300-2400", ratingSystem)
-	y1 := 2300
-	y2 := 2400
-	result := (float32(y2-y1)/float32(x2-x1))*float32(rating-x1) + float32(y1)
-	return result
-}
-
-func getRatingBoundary(cohort database.DojoCohort, ratingSystem database.RatingSystem) int {
-	cohortBoundaries := ratingBoundaries[cohort]
-	if cohortBoundaries == nil {
-		return -1
-	}
-
-	boundary := cohortBoundaries[ratingSystem]
-	if boundary <= 0 {
-		return -1
-	}
-	return boundary
-}
-
-func getMinRatingBoundary(cohort database.DojoCohort, ratingSystem database.RatingSystem) int {
-	cohortIdx := 0
-	for i, c := range database.Cohorts {
-		if c == cohort {
-			cohortIdx = i
-			break
-		}
+// legacyBoundaries returns the given default boundaries as the year review used them
+// before the boundaries were stored in the database, so that the results of past periods
+// do not change. The year review had no FIDE boundaries below the 900-1000 cohort, so
+// FIDE ratings below 1450 were interpolated from 0 rather than from the FIDE rating floor.
+func legacyBoundaries(defaults *database.CohortBoundaries) *database.CohortBoundaries {
+	legacy := *defaults
+	legacy.Boundaries = make(map[database.RatingSystem][]int, len(defaults.Boundaries))
+	for system, b := range defaults.Boundaries {
+		legacy.Boundaries[system] = b
 	}
 
-	if cohortIdx <= 0 {
-		return 0
+	fide := slices.Clone(defaults.Boundaries[database.Fide])
+	for i := 0; i < slices.Index(database.Cohorts, "900-1000") && i < len(fide); i++ {
+		fide[i] = 0
 	}
-	return getRatingBoundary(database.Cohorts[cohortIdx-1], ratingSystem)
+	legacy.Boundaries[database.Fide] = fide
+	legacy.Description = "Year review boundaries before versioning"
+	return &legacy
 }
 
-func getCohortRangeInt(cohort database.DojoCohort) (int, int) {
-	strCohort := strings.ReplaceAll(string(cohort), "+", "")
-	tokens := strings.Split(strCohort, "-")
-
-	minCohort, err := strconv.Atoi(tokens[0])
-	if err != nil {
-		return -1, -1
-	}
-
-	if len(tokens) < 2 {
-		return minCohort, 2500
-	}
-
-	maxCohort, err := strconv.Atoi((tokens[1]))
+// getNormalizedRating returns the normalized rating of the given rating, using the cohort
+// boundaries in effect at the end of the period.
+func getNormalizedRating(rating int, ratingSystem database.RatingSystem) float32 {
+	normalized, err := boundaries.NormalizedRating(ratingSystem, float64(rating))
 	if err != nil {
-		return minCohort, 2500
+		return -1
 	}
-
-	return minCohort, maxCohort
-}
-
-var ratingBoundaries = map[database.DojoCohort]map[database.RatingSystem]int{
-	"0-300": {
-		database.Chesscom: 550,
-		database.Lichess:  1250,
-		database.Fide:     0,
-		database.Uscf:     350,
-		database.Ecf:      400,
-		database.Cfc:      350,
-		database.Dwz:      450,
-		database.Acf:      300,
-		database.Knsb:     400,
-		database.Custom:   -1,
-	},
-	"300-400": {
-		database.Chesscom: 650,
-		database.Lichess:  1310,
-		database.Fide:     0,
-		database.Uscf:     460,
-		database.Ecf:      625,
-		database.Cfc:      460,
-		database.Dwz:      540,
-		database.Acf:      395,
-		database.Knsb:     600,
-		database.Custom:   -1,
-	},
-	"400-500": {
-		database.Chesscom: 750,
-		database.Lichess:  1370,
-		database.Fide:     0,
-		database.Uscf:     570,
-		database.Ecf:      850,
-		database.Cfc:      570,
-		database.Dwz:      630,
-		database.Acf:      490,
-		database.Knsb:     800,
-		database.Custom:   -1,
-	},
-	"500-600": {
-		database.Chesscom: 850,
-		database.Lichess:  1435,
-		database.Fide:     0,
-		database.Uscf:     680,
-		database.Ecf:      1000,
-		database.Cfc:      680,
-		database.Dwz:      725,
-		database.Acf:      585,
-		database.Knsb:     1000,
-		database.Custom:   -1,
-	},
-	"600-700": {
-		database.Chesscom: 950,
-		database.Lichess:  1500,
-		database.Fide:     0,
-		database.Uscf:     790,
-		database.Ecf:      1130,
-		database.Cfc:      780,
-		database.Dwz:      815,
-		database.Acf:      680,
-		database.Knsb:     1140,
-		database.Custom:   -1,
-	},
-	"700-800": {
-		database.Chesscom: 1050,
-		database.Lichess:  1550,
-		database.Fide:     0,
-		database.Uscf:     900,
-		database.Ecf:      1210,
-		database.Cfc:      880,
-		database.Dwz:      920,
-		database.Acf:      775,
-		database.Knsb:     1280,
-		database.Custom:   -1,
-	},
-	"800-900": {
-		database.Chesscom: 1150,
-		database.Lichess:  1600,
-		database.Fide:     0,
-		database.Uscf:     1010,
-		database.Ecf:      1270,
-		database.Cfc:      980,
-		database.Dwz:      1025,
-		database.Acf:      870,
-		database.Knsb:     1400,
-		database.Custom:   -1,
-	},
-	"900-1000": {
-		database.Fide:     1450,
-		database.Uscf:     1120,
-		database.Chesscom: 1250,
-		database.Lichess:  1665,
-		database.Ecf:      1325,
-		database.Cfc:      1090,
-		database.Dwz:      1110,
-		database.Acf:      990,
-		database.Knsb:     1450,
-		database.Custom:   -1,
-	},
-	"1000-1100": {
-		database.Fide:     1500,
-		database.Uscf:     1230,
-		database.Chesscom: 1350,
-		database.Lichess:  1730,
-		database.Ecf:      1390,
-		database.Cfc:      1200,
-		database.Dwz:      1185,
-		database.Acf:      1100,
-		database.Knsb:     1500,
-		database.Custom:   -1,
-	},
-	"1100-1200": {
-		database.Fide:     1550,
-		database.Uscf:     1330,
-		database.Chesscom: 1450,
-		database.Lichess:  1795,
-		database.Ecf:      1455,
-		database.Cfc:      1300,
-		database.Dwz:      1260,
-		database.Acf:      1210,
-		database.Knsb:     1550,
-		database.Custom:   -1,
-	},
-	"1200-1300": {
-		database.Fide:     1600,
-		database.Uscf:     1420,
-		database.Chesscom: 1550,
-		database.Lichess:  1850,
-		database.Ecf:      1535,
-		database.Cfc:      1390,
-		database.Dwz:      1335,
-		database.Acf:      1320,
-		database.Knsb:     1600,
-		database.Custom:   -1,
-	},
-	"1300-1400": {
-		database.Fide:     1650,
-		database.Uscf:     1510,
-		database.Chesscom: 1650,
-		database.Lichess:  1910,
-		database.Ecf:      1595,
-		database.Cfc:      1480,
-		database.Dwz:      1410,
-		database.Acf:      1415,
-		database.Knsb:     1650,
-		database.Custom:   -1,
-	},
-	"1400-1500": {
-		database.Fide:     1700,
-		database.Uscf:     1600,
-		database.Chesscom: 1750,
-		database.Lichess:  1970,
-		database.Ecf:      1665,
-		database.Cfc:      1570,
-		database.Dwz:      1480,
-		database.Acf:      1510,
-		database.Knsb:     1700,
-		database.Custom:   -1,
-	},
-	"1500-1600": {
-		database.Fide:     1750,
-		database.Uscf:     1675,
-		database.Chesscom: 1850,
-		database.Lichess:  2030,
-		database.Ecf:      1735,
-		database.Cfc:      1645,
-		database.Dwz:      1560,
-		database.Acf:      1605,
-		database.Knsb:     1750,
-		database.Custom:   -1,
-	},
-	"1600-1700": {
-		database.Fide:     1800,
-		database.Uscf:     1750,
-		database.Chesscom: 1950,
-		database.Lichess:  2090,
-		database.Ecf:      1805,
-		database.Cfc:      1730,
-		database.Dwz:      1640,
-		database.Acf:      1700,
-		database.Knsb:     1800,
-		database.Custom:   -1,
-	},
-	"1700-1800": {
-		database.Fide:     1850,
-		database.Uscf:     1825,
-		database.Chesscom: 2050,
-		database.Lichess:  2150,
-		database.Ecf:      1875,
-		database.Cfc:      1825,
-		database.Dwz:      1720,
-		database.Acf:      1790,
-		database.Knsb:     1850,
-		database.Custom:   -1,
-	},
-	"1800-1900": {
-		database.Fide:     1910,
-		database.Uscf:     1930,
-		database.Chesscom: 2165,
-		database.Lichess:  2225,
-		database.Ecf:      1955,
-		database.Cfc:      1925,
-		database.Dwz:      1815,
-		database.Acf:      1900,
-		database.Knsb:     1910,
-		database.Custom:   -1,
-	},
-	"1900-2000": {
-		database.Fide:     2000,
-		database.Uscf:     2055,
-		database.Chesscom: 2275,
-		database.Lichess:  2310,
-		database.Ecf:      2065,
-		database.Cfc:      2060,
-		database.Dwz:      1940,
-		database.Acf:      2000,
-		database.Knsb:     2000,
-		database.Custom:   -1,
-	},
-	"2000-2100": {
-		database.Fide:     2100,
-		database.Uscf:     2185,
-		database.Chesscom: 2360,
-		database.Lichess:  2370,
-		database.Ecf:      2165,
-		database.Cfc:      2185,
-		database.Dwz:      2070,
-		database.Acf:      2105,
-		database.Knsb:     2100,
-		database.Custom:   -1,
-	},
-	"2100-2200": {
-		database.Fide:     2200,
-		database.Uscf:     2290,
-		database.Chesscom: 2425,
-		database.Lichess:  2410,
-		database.Ecf:      2260,
-		database.Cfc:      2290,
-		database.Dwz:      2185,
-		database.Acf:      2215,
-		database.Knsb:     2200,
-		database.Custom:   -1,
-	},
-	"2200-2300": {
-		database.Fide:     2300,
-		database.Uscf:     2395,
-		database.Chesscom: 2485,
-		database.Lichess:  2440,
-		database.Ecf:      2360,
-		database.Cfc:      2395,
-		database.Dwz:      2285,
-		database.Acf:      2330,
-		database.Knsb:     2300,
-		database.Custom:   -1,
-	},
-	"2300-2400": {
-		database.Fide:     2400,
-		database.Uscf:     2500,
-		database.Chesscom: 2550,
-		database.Lichess:  2470,
-		database.Ecf:      2460,
-		database.Cfc:      2500,
-		database.Dwz:      2385,
-		database.Acf:      2450,
-		database.Knsb:     2400,
-		database.Custom:   -1,
-	},
+	return float32(normalized)
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jackstenglein/chess-dojo-scheduler/backend/database"
+)
+
+func TestLegacyBoundaries(t *testing.T) {
+	defaults := database.DefaultCohortBoundaries
+	boundaries = legacyBoundaries(&defaults)
+
+	table := []struct {
+		system database.RatingSystem
+		rating int
+		want   float32
+	}{
+		{database.Fide, 1000, 969},
+		{database.Fide, 1450, 1000},
+		{database.Fide, 1500, 1100},
+		{database.Uscf, 460, 400},
+		{database.Custom, 1000, -1},
+	}
+	for _, tc := range table {
+		if got := getNormalizedRating(tc.rating, tc.system); math.Abs(float64(got-tc.want)) > 0.5 {
+			t.Errorf("getNormalizedRating(%d, %s) got %f; want %f", tc.rating, tc.system, got, tc.want)
+		}
+	}
+
+	if database.DefaultCohortBoundaries.Boundaries[database.Fide][0] != 1400 {
+		t.Errorf("legacyBoundaries modified DefaultCohortBoundaries: %v", database.DefaultCohortBoundaries.Boundaries[database.Fide])
+	}
+}
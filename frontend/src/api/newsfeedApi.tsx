@@ -72,8 +72,8 @@ export interface ListNewsfeedResponse {
     /** The date of the previous request to ListNewsfeed.  */
     lastFetch: string;
 
-    /** The start keys to pass in the next request. */
-    lastKeys: Record<string, string>;
+    /** The opaque start key to pass in the next request, if there are more entries. */
+    lastKey?: string;
 }
 
 /**
@@ -9,7 +9,7 @@ interface LoadMoreButtonProps<T> {
     request: Request<T>;
     hasMore?: boolean;
     since?: string;
-    startKey?: Record<string, string>;
+    startKey?: string;
     onLoadMore: () => void;
 }
 
@@ -22,7 +22,7 @@ function LoadMoreButton<T>({
 }: LoadMoreButtonProps<T>) {
     const { user } = useAuth();
 
-    if (hasMore || Object.values(startKey || {}).length > 0) {
+    if (hasMore || startKey) {
         return (
             <Stack alignItems='center' spacing={1}>
                 <LoadingButton
@@ -116,11 +116,11 @@ const NewsfeedList: React.FC<NewsfeedListProps> = ({
     const [newsfeedIds, setNewsfeedIds] = useNewsfeedIds(initialNewsfeedIds);
     const [filters, setFilters] = useState<string[]>([AllCategoriesFilterName]);
     const [data, setData] = useState<ListNewsfeedResponse>();
-    const [lastStartKey, setLastStartKey] = useState<Record<string, string>>({});
+    const [lastStartKey, setLastStartKey] = useState<string>();
 
     const handleResponse = useCallback(
         (resp: ListNewsfeedResponse) => {
-            setLastStartKey(data?.lastKeys || {});
+            setLastStartKey(data?.lastKey);
 
             const seen: Record<string, boolean> = {};
             const newEntries = (data?.entries || [])
@@ -138,7 +138,7 @@ const NewsfeedList: React.FC<NewsfeedListProps> = ({
             setData({
                 entries: newEntries,
                 lastFetch: resp.lastFetch,
-                lastKeys: resp.lastKeys,
+                lastKey: resp.lastKey,
             });
         },
         [setLastStartKey, data],
@@ -163,7 +163,7 @@ const NewsfeedList: React.FC<NewsfeedListProps> = ({
     useEffect(() => {
         reset();
         setData(undefined);
-        setLastStartKey({});
+        setLastStartKey(undefined);
     }, [newsfeedIds, reset]);
 
     if ((newsfeedIds.length > 0 && !request.isSent()) || (request.isLoading() && !data)) {
@@ -177,13 +177,13 @@ const NewsfeedList: React.FC<NewsfeedListProps> = ({
         setData({
             entries: [...newData.slice(0, i), entry, ...newData.slice(i + 1)],
             lastFetch: data?.lastFetch || '',
-            lastKeys: data?.lastKeys || {},
+            lastKey: data?.lastKey,
         });
     };
 
     let skipLastFetch = true;
-    let startKey = data?.lastKeys || {};
-    if (data?.lastFetch && Object.values(data.lastKeys).length > 0) {
+    let startKey = data?.lastKey;
+    if (data?.lastFetch && data.lastKey) {
         skipLastFetch = false;
     } else if (data?.lastFetch) {
         startKey = lastStartKey;
@@ -191,7 +191,7 @@ const NewsfeedList: React.FC<NewsfeedListProps> = ({
 
     const onLoadMore = () => {
         request.onStart();
-        api.listNewsfeed(newsfeedIds, skipLastFetch, JSON.stringify(startKey))
+        api.listNewsfeed(newsfeedIds, skipLastFetch, startKey)
             .then((resp) => {
                 handleResponse(resp.data);
                 request.onSuccess();